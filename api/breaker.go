@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Breaker is a circuit breaker that pauses requests to a workspace after
+// consecutive failures. After a cooldown it is half-open: one request probes
+// the workspace while the others keep waiting, and the breaker closes when
+// the probe succeeds or opens again when it fails.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	cond        *sync.Cond // signaled when a probe's outcome is recorded
+	failures    int
+	openUntil   time.Time
+	halfOpen    bool
+	probing     bool
+	timesOpened int
+}
+
+// NewBreaker creates a circuit breaker. A threshold of 0 disables it.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	b := &Breaker{threshold: threshold, cooldown: cooldown}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Wait blocks while the breaker is open, and while another caller probes a
+// half-open breaker. Every Wait must be followed by a Record.
+func (b *Breaker) Wait() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if remaining := time.Until(b.openUntil); remaining > 0 {
+			b.mu.Unlock()
+			time.Sleep(remaining)
+			b.mu.Lock()
+			continue
+		}
+		if !b.openUntil.IsZero() {
+			// Cooldown elapsed: the next request probes the workspace
+			b.openUntil = time.Time{}
+			b.halfOpen = true
+		}
+		if !b.halfOpen {
+			return
+		}
+		if !b.probing {
+			b.probing = true
+			return
+		}
+		b.cond.Wait()
+	}
+}
+
+// Record reports the outcome of a request to the breaker
+func (b *Breaker) Record(success bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Callers waiting on a probe re-check the breaker either way
+	defer b.cond.Broadcast()
+
+	if success {
+		b.failures = 0
+		b.halfOpen = false
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.halfOpen || b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.halfOpen = false
+		b.probing = false
+		b.failures = 0
+		b.timesOpened++
+		fmt.Printf("Circuit breaker open: pausing requests for %v after repeated failures\n", b.cooldown)
+	}
+}
+
+// TimesOpened returns how many times the breaker has tripped
+func (b *Breaker) TimesOpened() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.timesOpened
+}
+
+// OpenFor returns how long the breaker stays open, or 0 when it is closed
+func (b *Breaker) OpenFor() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := time.Until(b.openUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RetryBudget limits the total number of retries across all requests of a client
+type RetryBudget struct {
+	remaining atomic.Int64
+	used      atomic.Int64
+}
+
+// NewRetryBudget creates a retry budget. A max of 0 means unlimited.
+func NewRetryBudget(max int) *RetryBudget {
+	if max <= 0 {
+		return nil
+	}
+	b := &RetryBudget{}
+	b.remaining.Store(int64(max))
+	return b
+}
+
+// Take consumes one retry, reporting false when the budget is exhausted
+func (b *RetryBudget) Take() bool {
+	if b == nil {
+		return true
+	}
+	if b.remaining.Add(-1) < 0 {
+		b.remaining.Add(1)
+		return false
+	}
+	b.used.Add(1)
+	return true
+}
+
+// Used returns the number of retries consumed
+func (b *RetryBudget) Used() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.used.Load())
+}
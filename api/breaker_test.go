@@ -0,0 +1,54 @@
+package api
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b := NewBreaker(1, 20*time.Millisecond)
+	b.Wait()
+	b.Record(false)
+	if b.TimesOpened() != 1 {
+		t.Fatalf("breaker did not open")
+	}
+
+	// After the cooldown one caller probes; the others wait for its outcome
+	var passed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Wait()
+			passed.Add(1)
+		}()
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := passed.Load(); got != 1 {
+		t.Fatalf("%d callers passed the half-open breaker, want 1", got)
+	}
+
+	// A failed probe opens the breaker again and nobody else passes
+	b.Record(false)
+	time.Sleep(10 * time.Millisecond)
+	if got := passed.Load(); got != 1 {
+		t.Fatalf("%d callers passed after a failed probe, want 1", got)
+	}
+	if b.TimesOpened() != 2 {
+		t.Fatalf("got %d openings, want 2", b.TimesOpened())
+	}
+
+	// After the next cooldown another caller probes, and its success releases the rest
+	time.Sleep(40 * time.Millisecond)
+	if got := passed.Load(); got != 2 {
+		t.Fatalf("%d callers passed after the second cooldown, want 2", got)
+	}
+	b.Record(true)
+	wg.Wait()
+	if got := passed.Load(); got != 5 {
+		t.Fatalf("%d callers passed after a successful probe, want 5", got)
+	}
+}
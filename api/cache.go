@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// cachedPaths are the endpoint families whose GET responses are cached. Result
+// lists are never cached because idempotency checks need the current state.
+var cachedPaths = []string{"/case/", "/suite/", "/run/", "/custom_field", "/system_field"}
+
+// cacheEntry is a cached GET response stored on disk
+type cacheEntry struct {
+	URL         string    `json:"url"`
+	StoredAt    time.Time `json:"stored_at"`
+	ETag        string    `json:"etag,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body"`
+}
+
+// cachingTransport serves repeated GETs of slowly changing data (cases,
+// suites, runs, custom fields) from a disk cache shared by every client and
+// subcommand using the same directory. Expired entries with an ETag are
+// revalidated with If-None-Match. Any successful write to an endpoint family
+// drops its cached entries.
+type cachingTransport struct {
+	base http.RoundTripper
+	dir  string
+	ttl  time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// SetCache enables the read-through response cache in dir with the given TTL.
+// It does nothing when dir is empty.
+func (c *Client) SetCache(dir string, ttl time.Duration) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	c.cache = &cachingTransport{base: c.HTTP.Transport, dir: dir, ttl: ttl}
+	c.HTTP.Transport = c.cache
+	return nil
+}
+
+// Uncached returns a view of the client that bypasses the response cache,
+// for lookups that must see other processes' writes. It shares the client's
+// tokens, including refreshed ones, and its read retries.
+func (c *Client) Uncached() *Client {
+	transport := c.HTTP.Transport
+	if c.cache != nil {
+		transport = c.cache.base
+	}
+	return &Client{
+		BaseURL:         c.BaseURL,
+		Token:           c.Token,
+		HTTP:            &http.Client{Timeout: c.HTTP.Timeout, Transport: transport},
+		Breaker:         c.Breaker,
+		RetryBudget:     c.RetryBudget,
+		Gate:            c.Gate,
+		MaxPayloadBytes: c.MaxPayloadBytes,
+		Capabilities:    c.Capabilities,
+		tokens:          c.tokens,
+		reads:           c.reads,
+		counts:          c.counts,
+		fair:            c.fair,
+		parent:          c,
+		worker:          c.worker,
+	}
+}
+
+// CacheStats returns the number of cache hits and misses (zero when disabled)
+func (c *Client) CacheStats() (hits, misses int) {
+	if c.cache == nil {
+		return 0, 0
+	}
+	return int(c.cache.hits.Load()), int(c.cache.misses.Load())
+}
+
+// RoundTrip serves cacheable GETs from the cache and invalidates on writes
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	family, ok := cacheFamily(req.URL.Path)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && resp.StatusCode < 400 {
+			os.RemoveAll(t.familyDir(req.URL.Host, family))
+		}
+		return resp, err
+	}
+
+	path := t.entryPath(req, family)
+	entry, fresh := t.load(path)
+	if entry != nil && fresh {
+		t.hits.Add(1)
+		return entry.response(req), nil
+	}
+
+	if entry != nil && entry.ETag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		t.hits.Add(1)
+		entry.StoredAt = time.Now()
+		t.store(path, entry)
+		return entry.response(req), nil
+	}
+
+	t.misses.Add(1)
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.store(path, &cacheEntry{
+		URL:         req.URL.String(),
+		StoredAt:    time.Now(),
+		ETag:        resp.Header.Get("ETag"),
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	return resp, nil
+}
+
+// cacheFamily returns the endpoint family of a cacheable path, e.g.
+// "/v1/case/DEMO" for "/v1/case/DEMO/12"
+func cacheFamily(path string) (string, bool) {
+	for _, prefix := range cachedPaths {
+		i := strings.Index(path, prefix)
+		if i < 0 {
+			continue
+		}
+		if !strings.HasSuffix(prefix, "/") {
+			// Workspace-wide endpoints form a single family
+			return path[:i] + prefix, true
+		}
+		project, _, _ := strings.Cut(path[i+len(prefix):], "/")
+		return path[:i] + prefix + project, true
+	}
+	return "", false
+}
+
+// familyDir is the directory holding every cached entry of an endpoint family
+func (t *cachingTransport) familyDir(host, family string) string {
+	return filepath.Join(t.dir, hashKey(host+family))
+}
+
+// entryPath keys entries by URL and token, so workspaces sharing a base URL
+// and project code never see each other's data
+func (t *cachingTransport) entryPath(req *http.Request, family string) string {
+	return filepath.Join(t.familyDir(req.URL.Host, family), hashKey(req.URL.String()+"\x00"+req.Header.Get("Token"))+".json")
+}
+
+// load reads a cache entry, reporting whether it is still within the TTL
+func (t *cachingTransport) load(path string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	// An entry encrypted with another key, or none, is a miss
+	if data, err = artifact.Decrypt(path, data); err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, time.Since(entry.StoredAt) < t.ttl
+}
+
+// store writes a cache entry atomically, encrypted like the artifacts when
+// an artifact key is configured; failures only cost a cache miss
+func (t *cachingTransport) store(path string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if data, err = artifact.Encrypt(path, data); err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// response builds an HTTP response from a cache entry
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	header := make(http.Header)
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if e.ETag != "" {
+		header.Set("ETag", e.ETag)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func hashKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
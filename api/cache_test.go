@@ -0,0 +1,61 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUncachedSharesRefreshedToken(t *testing.T) {
+	var served atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Token") != "fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":true,"result":{"entities":[]}}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "")
+	tokens := []string{"stale", "fresh"}
+	if err := c.SetTokenProvider(func() (string, error) {
+		token := tokens[0]
+		if len(tokens) > 1 {
+			tokens = tokens[1:]
+		}
+		return token, nil
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCache(t.TempDir(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	lookup := c.Uncached()
+
+	for i := 0; i < 2; i++ {
+		req, err := lookup.NewRequest("GET", "/run/PRJ", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := lookup.HTTP.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200 after the token refresh", i+1, resp.StatusCode)
+		}
+	}
+	if n := served.Load(); n != 2 {
+		t.Errorf("server answered %d lookups, want 2 (the cache must be bypassed)", n)
+	}
+	if c.Token != "fresh" {
+		t.Errorf("client token %q, want the refreshed one", c.Token)
+	}
+}
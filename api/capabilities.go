@@ -0,0 +1,42 @@
+package api
+
+import "strings"
+
+// Capabilities records the optional API features a workspace supports, as
+// probed at startup. A client without capabilities (nil) has not been probed
+// and tries the v2 API with a fallback to v1.
+type Capabilities struct {
+	ResultsV2      bool // v2 result list and bulk create endpoints
+	CustomStatuses bool // result statuses listed through system fields
+	ExternalIssues bool // external issue links on runs
+}
+
+// String lists the supported features, e.g. "v2 results, custom statuses"
+func (c *Capabilities) String() string {
+	if c == nil {
+		return "not probed"
+	}
+	var features []string
+	if c.ResultsV2 {
+		features = append(features, "v2 results")
+	}
+	if c.CustomStatuses {
+		features = append(features, "custom statuses")
+	}
+	if c.ExternalIssues {
+		features = append(features, "external issues")
+	}
+	if len(features) == 0 {
+		return "v1 only"
+	}
+	return strings.Join(features, ", ")
+}
+
+// UseV2Results reports whether results should be read and written with the
+// v2 API, and whether that choice is known from probing (no fallback needed)
+func (c *Client) UseV2Results() (v2, probed bool) {
+	if c.Capabilities == nil {
+		return true, false
+	}
+	return c.Capabilities.ResultsV2, true
+}
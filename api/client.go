@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -12,6 +13,48 @@ type Client struct {
 	BaseURL string
 	Token   string
 	HTTP    *http.Client
+
+	// Optional resilience controls for the post path (nil disables them)
+	Breaker     *Breaker
+	RetryBudget *RetryBudget
+
+	// Optional operator pause gate checked before each post chunk
+	Gate Gate
+
+	// Maximum serialized size of a bulk post request (0 uses the default)
+	MaxPayloadBytes int
+
+	// Optional workspace features detected at startup (nil if not probed)
+	Capabilities *Capabilities
+
+	// Optional pool of tokens rotated across requests (see SetTokens)
+	tokens *TokenPool
+
+	// Optional read-through response cache (see SetCache)
+	cache *cachingTransport
+
+	// Retries of read requests (see SetReadRetry)
+	reads *retryTransport
+
+	// Listings reconciled against reported totals (see RecordCount)
+	counts *countChecks
+
+	// Optional token refresh for short-lived tokens (see SetTokenProvider)
+	refresher *tokenRefresher
+	tokenMu   sync.RWMutex
+
+	// Optional pacing by rate limit response headers (see SetRateLimitPacing)
+	pace *pacer
+
+	// Optional rate partition among workers (see SetFairShare and ForWorker)
+	fair   *FairShare
+	parent *Client
+	worker string
+}
+
+// Gate blocks callers while an operator has paused the migration
+type Gate interface {
+	Wait()
 }
 
 // NewClient creates a new Qase API client
@@ -20,13 +63,24 @@ func NewClient(baseURL, token string) *Client {
 		baseURL = "https://api.qase.io"
 	}
 
-	return &Client{
+	c := &Client{
 		BaseURL: baseURL,
 		Token:   token,
-		HTTP: &http.Client{
-			Timeout: 5 * time.Minute, // Increased timeout for bulk operations
-		},
+		counts:  &countChecks{},
+	}
+	c.reads = &retryTransport{
+		base:     &trackingTransport{base: http.DefaultTransport, client: c},
+		client:   c,
+		attempts: DefaultReadRetries,
+		baseWait: DefaultReadBaseWait,
+		maxWait:  DefaultReadMaxWait,
 	}
+	c.HTTP = &http.Client{
+		Timeout:   5 * time.Minute, // Increased timeout for bulk operations
+		Transport: c.reads,
+	}
+
+	return c
 }
 
 // NewRequest creates a new HTTP request with Qase API headers
@@ -46,7 +100,8 @@ func (c *Client) NewRequest(method, path string, body []byte) (*http.Request, er
 		return nil, err
 	}
 
-	req.Header.Set("Token", c.Token)
+	c.fair.Wait(c.worker)
+	req.Header.Set("Token", c.token())
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
@@ -70,7 +125,8 @@ func (c *Client) NewV2Request(method, path string, body []byte) (*http.Request,
 		return nil, err
 	}
 
-	req.Header.Set("Token", c.Token)
+	c.fair.Wait(c.worker)
+	req.Header.Set("Token", c.token())
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
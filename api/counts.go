@@ -0,0 +1,30 @@
+package api
+
+import "sync"
+
+// countChecks records listings reconciled against the totals the API
+// reported for them
+type countChecks struct {
+	mu         sync.Mutex
+	checked    int
+	mismatches []string
+}
+
+// RecordCount records a reconciled listing, with a description of the
+// disagreement when its fetched count differed from the reported total
+func (c *Client) RecordCount(mismatch string) {
+	c.counts.mu.Lock()
+	defer c.counts.mu.Unlock()
+	c.counts.checked++
+	if mismatch != "" {
+		c.counts.mismatches = append(c.counts.mismatches, mismatch)
+	}
+}
+
+// CountChecks returns the number of reconciled listings and the mismatches
+// among them
+func (c *Client) CountChecks() (int, []string) {
+	c.counts.mu.Lock()
+	defer c.counts.mu.Unlock()
+	return c.counts.checked, append([]string(nil), c.counts.mismatches...)
+}
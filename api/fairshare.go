@@ -0,0 +1,144 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// fairShareActiveWindow is how long after its last request a worker still
+// counts as sharing the rate
+const fairShareActiveWindow = 10 * time.Second
+
+// FairShare partitions a client's request rate among the workers using it.
+// Requests are spaced to stay within the rate overall, and each active worker
+// is held to an equal share of it, so a worker posting a huge run cannot
+// starve the others. Requests made without a worker count against the overall
+// rate only. With no rate, requests are only counted per worker.
+type FairShare struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	workers  map[string]*workerShare
+}
+
+type workerShare struct {
+	next     time.Time
+	lastSeen time.Time
+	requests int
+	waited   time.Duration
+}
+
+// WorkerStats reports the requests a worker made and how long it waited for
+// its share of the rate
+type WorkerStats struct {
+	Worker   string
+	Requests int
+	Waited   time.Duration
+}
+
+// NewFairShare creates a partition of rpm requests per minute (0 means unlimited)
+func NewFairShare(rpm int) *FairShare {
+	f := &FairShare{workers: make(map[string]*workerShare)}
+	if rpm > 0 {
+		f.interval = time.Minute / time.Duration(rpm)
+	}
+	return f
+}
+
+// Wait blocks until worker may send its next request
+func (f *FairShare) Wait(worker string) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	now := time.Now()
+	start := now
+	if f.next.After(start) {
+		start = f.next
+	}
+
+	if worker != "" {
+		w, exists := f.workers[worker]
+		if !exists {
+			w = &workerShare{}
+			f.workers[worker] = w
+		}
+		w.lastSeen = now
+
+		active := 0
+		for _, other := range f.workers {
+			if now.Sub(other.lastSeen) < fairShareActiveWindow {
+				active++
+			}
+		}
+		if w.next.After(start) {
+			start = w.next
+		}
+		w.next = start.Add(f.interval * time.Duration(active))
+		w.requests++
+		w.waited += start.Sub(now)
+	}
+
+	f.next = start.Add(f.interval)
+	f.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Stats returns the per-worker counters, ordered by worker name
+func (f *FairShare) Stats() []WorkerStats {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]WorkerStats, 0, len(f.workers))
+	for name, w := range f.workers {
+		stats = append(stats, WorkerStats{Worker: name, Requests: w.requests, Waited: w.waited})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Worker < stats[j].Worker })
+	return stats
+}
+
+// SetFairShare partitions rpm requests per minute among the client's workers
+// (see ForWorker). rpm 0 keeps requests unlimited but still counts them.
+func (c *Client) SetFairShare(rpm int) {
+	c.fair = NewFairShare(rpm)
+}
+
+// FairShare returns the client's rate partition (nil if not enabled)
+func (c *Client) FairShare() *FairShare {
+	return c.fair
+}
+
+// ForWorker returns a view of the client whose requests are counted against
+// worker's share of the rate. It shares the parent's transport, tokens, cache
+// and resilience controls.
+func (c *Client) ForWorker(worker string) *Client {
+	if c.fair == nil {
+		return c
+	}
+	return &Client{
+		BaseURL:         c.BaseURL,
+		Token:           c.Token,
+		HTTP:            c.HTTP,
+		Breaker:         c.Breaker,
+		RetryBudget:     c.RetryBudget,
+		Gate:            c.Gate,
+		MaxPayloadBytes: c.MaxPayloadBytes,
+		Capabilities:    c.Capabilities,
+		tokens:          c.tokens,
+		cache:           c.cache,
+		reads:           c.reads,
+		counts:          c.counts,
+		fair:            c.fair,
+		parent:          c,
+		worker:          worker,
+	}
+}
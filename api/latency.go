@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyReservoirSize bounds the samples kept per endpoint family, so a
+// migration of millions of requests keeps a few KB of them
+const latencyReservoirSize = 1024
+
+// latencyReservoir is a uniform random sample of a family's response times
+// (reservoir sampling), with the exact count and maximum
+type latencyReservoir struct {
+	samples []time.Duration
+	count   int
+	max     time.Duration
+}
+
+// add records one response time
+func (r *latencyReservoir) add(d time.Duration) {
+	r.count++
+	r.max = max(r.max, d)
+	if len(r.samples) < latencyReservoirSize {
+		r.samples = append(r.samples, d)
+	} else if i := rand.Intn(r.count); i < latencyReservoirSize {
+		r.samples[i] = d
+	}
+}
+
+// latencies records the response times of API requests per endpoint family
+var latencies = struct {
+	mu       sync.Mutex
+	families map[string]*latencyReservoir
+}{families: make(map[string]*latencyReservoir)}
+
+// LatencyStat summarizes the response times of one endpoint family
+type LatencyStat struct {
+	Family string
+	Count  int
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// recordLatency adds a request's time to response headers to its family
+func recordLatency(req *http.Request, d time.Duration) {
+	family := endpointFamily(req.Method, req.URL.Path)
+	latencies.mu.Lock()
+	reservoir, ok := latencies.families[family]
+	if !ok {
+		reservoir = &latencyReservoir{}
+		latencies.families[family] = reservoir
+	}
+	reservoir.add(d)
+	latencies.mu.Unlock()
+}
+
+// ResetLatencyStats forgets the response times recorded so far, so each
+// watch cycle reports its own latencies
+func ResetLatencyStats() {
+	latencies.mu.Lock()
+	latencies.families = make(map[string]*latencyReservoir)
+	latencies.mu.Unlock()
+}
+
+// endpointFamily names the kind of call, e.g. "case list" or "bulk post"
+func endpointFamily(method, path string) string {
+	// Drop the version prefix: /v1/run/DEMO/5 -> [run DEMO 5]
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 0 && len(parts[0]) == 2 && parts[0][0] == 'v' {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return method + " /"
+	}
+
+	resource := parts[0]
+	switch {
+	case method == http.MethodPost && resource == "result" && len(parts) >= 4:
+		return "bulk post"
+	case method == http.MethodGet && len(parts) <= 2:
+		return resource + " list"
+	case method == http.MethodGet:
+		return resource + " get"
+	case method == http.MethodPost && len(parts) <= 2:
+		return resource + " create"
+	case method == http.MethodPatch:
+		return resource + " update"
+	case method == http.MethodDelete:
+		return resource + " delete"
+	default:
+		return strings.ToLower(method) + " " + resource
+	}
+}
+
+// LatencyStats returns the latency percentiles of every endpoint family
+// called so far, sorted by family. Percentiles are estimated from a sample
+// once a family has more than latencyReservoirSize calls; counts and maxima
+// are exact.
+func LatencyStats() []LatencyStat {
+	latencies.mu.Lock()
+	defer latencies.mu.Unlock()
+
+	stats := make([]LatencyStat, 0, len(latencies.families))
+	for family, reservoir := range latencies.families {
+		sorted := append([]time.Duration(nil), reservoir.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		stats = append(stats, LatencyStat{
+			Family: family,
+			Count:  reservoir.count,
+			P50:    percentile(sorted, 50),
+			P90:    percentile(sorted, 90),
+			P99:    percentile(sorted, 99),
+			Max:    reservoir.max,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Family < stats[j].Family })
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// PrintLatencyStats prints the per-endpoint latency table
+func PrintLatencyStats() {
+	stats := LatencyStats()
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Printf("\n=== API Latency ===\n")
+	fmt.Printf("%-16s %7s %9s %9s %9s %9s\n", "Endpoint", "Calls", "p50", "p90", "p99", "max")
+	for _, s := range stats {
+		fmt.Printf("%-16s %7d %9s %9s %9s %9s\n", s.Family, s.Count,
+			roundLatency(s.P50), roundLatency(s.P90), roundLatency(s.P99), roundLatency(s.Max))
+	}
+}
+
+func roundLatency(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyStatsReservoir(t *testing.T) {
+	ResetLatencyStats()
+	req := httptest.NewRequest("GET", "/v1/case/PRJ", nil)
+	for i := 1; i <= 3*latencyReservoirSize; i++ {
+		recordLatency(req, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := LatencyStats()
+	if len(stats) != 1 || stats[0].Family != "case list" {
+		t.Fatalf("got stats %+v, want one case list family", stats)
+	}
+	if got, want := stats[0].Count, 3*latencyReservoirSize; got != want {
+		t.Errorf("got count %d, want %d", got, want)
+	}
+	if got, want := stats[0].Max, time.Duration(3*latencyReservoirSize)*time.Millisecond; got != want {
+		t.Errorf("got max %v, want %v", got, want)
+	}
+	if n := len(latencies.families["case list"].samples); n != latencyReservoirSize {
+		t.Errorf("kept %d samples, want %d", n, latencyReservoirSize)
+	}
+}
+
+func TestResetLatencyStats(t *testing.T) {
+	recordLatency(httptest.NewRequest("GET", "/v1/run/PRJ", nil), time.Second)
+	ResetLatencyStats()
+	if stats := LatencyStats(); len(stats) != 0 {
+		t.Errorf("got stats %+v after a reset, want none", stats)
+	}
+
+	recordLatency(httptest.NewRequest("POST", "/v1/result/PRJ/5/bulk", nil), time.Millisecond)
+	stats := LatencyStats()
+	if len(stats) != 1 || stats[0].Family != "bulk post" || stats[0].Count != 1 {
+		t.Errorf("got stats %+v, want one bulk post call", stats)
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limit headers reported by the API, when present
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+)
+
+// pacer spaces requests so the remaining budget reported by the rate limit
+// headers lasts until the window resets, instead of running into 429s and
+// sleeping fixed durations. Limits are tracked per token.
+type pacer struct {
+	headroom int  // requests kept in reserve
+	debug    bool // log observed limits
+
+	mu     sync.Mutex
+	tokens map[string]*tokenLimit
+}
+
+type tokenLimit struct {
+	limit     int
+	remaining int       // estimated: the last reported value minus requests sent since
+	reset     time.Time // zero when unknown
+	next      time.Time // earliest start of the next request
+}
+
+// SetRateLimitPacing paces requests by the X-RateLimit-Limit, -Remaining and
+// -Reset response headers, keeping headroom requests of each window in
+// reserve. Responses without the headers, or without the limit, leave pacing
+// off. With debug set,
+// every newly observed window is logged.
+func (c *Client) SetRateLimitPacing(headroom int, debug bool) {
+	if headroom < 0 {
+		headroom = 0
+	}
+	c.pace = &pacer{headroom: headroom, debug: debug, tokens: make(map[string]*tokenLimit)}
+}
+
+// wait blocks until a request with token may be sent
+func (p *pacer) wait(token string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	l := p.tokens[token]
+	if l == nil || l.limit == 0 {
+		// Without the window size the budget cannot be refilled at the reset
+		p.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	start := now
+	if l.next.After(start) {
+		start = l.next
+	}
+	if l.reset.After(start) {
+		if l.remaining <= p.headroom {
+			// Budget used up: wait for the window to reset, and let later
+			// requests queue behind this one
+			start = l.reset
+			l.next = start
+			l.remaining = l.limit
+			l.reset = time.Time{}
+		} else {
+			// Spread what is left evenly over the rest of the window
+			l.next = start.Add(l.reset.Sub(start) / time.Duration(l.remaining-p.headroom))
+		}
+	}
+	l.remaining--
+	p.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		if wait >= time.Second {
+			fmt.Printf("Rate limit nearly exhausted for token %s, pacing for %v\n", maskedToken(token), wait.Round(time.Second))
+		}
+		time.Sleep(wait)
+	}
+}
+
+// observe records the limits reported by a response
+func (p *pacer) observe(token string, resp *http.Response) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateRemaining))
+	hasRemaining := err == nil
+	reset := parseRateReset(resp.Header.Get(headerRateReset), now)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// Rejected: nothing left until Retry-After or the reported reset
+		remaining, hasRemaining = 0, true
+		if after := retryAfter(resp, 0); after > 0 {
+			reset = now.Add(after)
+		}
+	}
+	if !hasRemaining || reset.IsZero() {
+		return
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get(headerRateLimit))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l := p.tokens[token]
+	if l == nil {
+		l = &tokenLimit{}
+		p.tokens[token] = l
+	}
+
+	// Responses of one window can arrive out of order; keep the lowest count
+	sameWindow := !l.reset.IsZero() && l.reset.Sub(reset).Abs() < 2*time.Second
+	if sameWindow && remaining > l.remaining {
+		return
+	}
+	if !sameWindow && p.debug {
+		fmt.Printf("Rate limit for token %s: %d/%d remaining, resets in %v\n",
+			maskedToken(token), remaining, limit, time.Until(reset).Round(time.Second))
+	}
+	l.remaining = remaining
+	l.reset = reset
+	if limit > 0 {
+		l.limit = limit
+	}
+}
+
+// parseRateReset parses a reset header given as a Unix timestamp or as
+// seconds until the reset, returning zero when absent
+func parseRateReset(value string, now time.Time) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}
+	}
+	if seconds > 1e9 {
+		return time.Unix(seconds, 0)
+	}
+	return now.Add(time.Duration(seconds) * time.Second)
+}
+
+// maskedToken identifies a token in logs by its last characters
+func maskedToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return "..." + token[len(token)-4:]
+}
+
+// RateLimit is the rate limit window last observed for a token
+type RateLimit struct {
+	Token     string // masked
+	Limit     int    // 0 when not reported
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimits returns the windows observed per token, ordered by token, or
+// nil when pacing is off
+func (c *Client) RateLimits() []RateLimit {
+	p := c.pace
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	limits := make([]RateLimit, 0, len(p.tokens))
+	for token, l := range p.tokens {
+		limits = append(limits, RateLimit{Token: maskedToken(token), Limit: l.limit, Remaining: l.remaining, Reset: l.reset})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Token < limits[j].Token })
+	return limits
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ReadOnlyError is returned for a write request sent through a read-only client
+type ReadOnlyError struct {
+	Method string
+	Path   string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("refused %s %s: client is read-only (dry run)", e.Method, e.Path)
+}
+
+// readOnlyTransport rejects every request that could modify the workspace, so
+// a dry run cannot write even if a code path forgets to check the flag
+type readOnlyTransport struct {
+	base http.RoundTripper
+}
+
+// SetReadOnly makes the client refuse every non-GET request
+func (c *Client) SetReadOnly() {
+	c.HTTP.Transport = &readOnlyTransport{base: c.HTTP.Transport}
+}
+
+// RoundTrip forwards reads and refuses writes
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &ReadOnlyError{Method: req.Method, Path: req.URL.Path}
+	}
+	return t.base.RoundTrip(req)
+}
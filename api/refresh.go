@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenProvider returns a fresh API token, e.g. from an SSO helper
+type TokenProvider func() (string, error)
+
+// tokenRefresher keeps the client token current using a TokenProvider
+type tokenRefresher struct {
+	provider    TokenProvider
+	interval    time.Duration
+	mu          sync.Mutex
+	lastRefresh time.Time
+}
+
+// CommandTokenProvider returns a TokenProvider that runs a shell command and
+// uses its trimmed stdout as the token
+func CommandTokenProvider(command string) TokenProvider {
+	return func() (string, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		token := strings.TrimSpace(stdout.String())
+		if token == "" {
+			return "", fmt.Errorf("token command returned an empty token")
+		}
+		return token, nil
+	}
+}
+
+// SetTokenProvider fetches the initial token from provider and refreshes it
+// every interval (0 refreshes only when the API responds with 401)
+func (c *Client) SetTokenProvider(provider TokenProvider, interval time.Duration) error {
+	c.refresher = &tokenRefresher{provider: provider, interval: interval}
+	return c.refreshToken("")
+}
+
+// refreshToken fetches a new token unless another caller already replaced
+// the stale one in the meantime
+func (c *Client) refreshToken(stale string) error {
+	r := c.refresher
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.tokenMu.RLock()
+	current := c.Token
+	c.tokenMu.RUnlock()
+	if stale != "" && current != stale {
+		return nil
+	}
+
+	token, err := r.provider()
+	if err != nil {
+		return err
+	}
+
+	c.tokenMu.Lock()
+	c.Token = token
+	c.tokenMu.Unlock()
+	r.lastRefresh = time.Now()
+
+	fmt.Printf("Refreshed API token for %s\n", c.BaseURL)
+	return nil
+}
+
+// refreshIfDue refreshes the token when the refresh interval has elapsed
+func (c *Client) refreshIfDue() {
+	r := c.refresher
+	if r == nil || r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	due := time.Since(r.lastRefresh) >= r.interval
+	r.mu.Unlock()
+
+	if due {
+		c.tokenMu.RLock()
+		current := c.Token
+		c.tokenMu.RUnlock()
+		if err := c.refreshToken(current); err != nil {
+			fmt.Printf("Warning: Failed to refresh API token: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Default read retry policy, used unless SetReadRetry overrides it
+const (
+	DefaultReadRetries  = 5
+	DefaultReadBaseWait = time.Second
+	DefaultReadMaxWait  = 30 * time.Second
+)
+
+// retryTransport retries GET requests that fail with 429, 5xx, a network
+// error or a corrupt body (see CorruptResponseError), using exponential
+// backoff with full jitter and honoring Retry-After.
+// Writes are not retried here; the post path has its own retry policy.
+type retryTransport struct {
+	base   http.RoundTripper
+	client *Client
+
+	attempts int
+	baseWait time.Duration
+	maxWait  time.Duration
+	budget   *RetryBudget
+
+	used atomic.Int64
+}
+
+// SetReadRetry configures retries of read requests independently of the post
+// path: attempts is the total number of tries per request (1 disables
+// retries), waits grow from baseWait up to maxWait, and maxRetries caps the
+// retries across all reads of this client (0 means unlimited)
+func (c *Client) SetReadRetry(attempts int, baseWait, maxWait time.Duration, maxRetries int) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	c.reads.attempts = attempts
+	c.reads.baseWait = baseWait
+	c.reads.maxWait = maxWait
+	c.reads.budget = NewRetryBudget(maxRetries)
+}
+
+// ReadRetries returns the number of read requests retried so far
+func (c *Client) ReadRetries() int {
+	return int(c.reads.used.Load())
+}
+
+// RoundTrip executes a request, retrying transient failures of GETs
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		var corrupt *CorruptResponseError
+		if err == nil {
+			corrupt = checkJSONResponse(req, resp)
+		}
+		if corrupt != nil && (attempt >= t.attempts || !t.budget.Take()) {
+			return nil, corrupt
+		}
+		if corrupt == nil && (attempt >= t.attempts || !retryableRead(resp, err)) {
+			return resp, err
+		}
+		if corrupt == nil && !t.budget.Take() {
+			fmt.Printf("Read retry budget exhausted, giving up on %s\n", req.URL.Path)
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		reason := "network error"
+		switch {
+		case corrupt != nil:
+			reason = corrupt.Reason
+			resp.Body.Close()
+		case resp != nil:
+			reason = resp.Status
+			wait = retryAfter(resp, wait)
+			resp.Body.Close()
+		}
+		t.used.Add(1)
+		fmt.Printf("GET %s failed (%s), retrying in %v (attempt %d/%d)\n", req.URL.Path, reason, wait.Round(time.Millisecond), attempt+1, t.attempts)
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		// Rotated tokens may have been penalized; pick the next one
+		if t.client != nil && t.client.tokens != nil {
+			if retry, ok := withToken(req, t.client.token()); ok {
+				req = retry
+			}
+		}
+	}
+}
+
+// backoff returns a full-jitter delay for the given attempt
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.baseWait << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.maxWait {
+		ceiling = t.maxWait
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// retryableRead reports whether a read failed transiently
+func retryableRead(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPool rotates requests across multiple API tokens of the same workspace,
+// spacing requests per token to respect per-token rate limits
+type TokenPool struct {
+	mu          sync.Mutex
+	tokens      []*pooledToken
+	next        int
+	minInterval time.Duration
+}
+
+type pooledToken struct {
+	value       string
+	nextAllowed time.Time
+}
+
+// NewTokenPool creates a pool from tokens. perTokenRPM limits the requests per
+// minute sent with each token (0 means unlimited).
+func NewTokenPool(tokens []string, perTokenRPM int) *TokenPool {
+	pool := &TokenPool{}
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		pool.tokens = append(pool.tokens, &pooledToken{value: token})
+	}
+	if perTokenRPM > 0 {
+		pool.minInterval = time.Minute / time.Duration(perTokenRPM)
+	}
+	return pool
+}
+
+// Size returns the number of tokens in the pool
+func (p *TokenPool) Size() int {
+	return len(p.tokens)
+}
+
+// Acquire returns the next token to use, waiting if every token is at its limit
+func (p *TokenPool) Acquire() string {
+	p.mu.Lock()
+
+	// Round-robin, preferring the first token that is available soonest
+	now := time.Now()
+	best := -1
+	for i := 0; i < len(p.tokens); i++ {
+		idx := (p.next + i) % len(p.tokens)
+		if best == -1 || p.tokens[idx].nextAllowed.Before(p.tokens[best].nextAllowed) {
+			best = idx
+		}
+		if !p.tokens[idx].nextAllowed.After(now) {
+			best = idx
+			break
+		}
+	}
+
+	token := p.tokens[best]
+	p.next = (best + 1) % len(p.tokens)
+
+	start := now
+	if token.nextAllowed.After(now) {
+		start = token.nextAllowed
+	}
+	token.nextAllowed = start.Add(p.minInterval)
+	p.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return token.value
+}
+
+// Penalize keeps a token out of rotation for the given duration (e.g., after a 429)
+func (p *TokenPool) Penalize(value string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, token := range p.tokens {
+		if token.value == value {
+			until := time.Now().Add(d)
+			if until.After(token.nextAllowed) {
+				token.nextAllowed = until
+			}
+			return
+		}
+	}
+}
+
+// SetTokens enables token rotation across the client's primary token and the
+// additional tokens given
+func (c *Client) SetTokens(extra []string, perTokenRPM int) {
+	tokens := append([]string{c.Token}, extra...)
+	pool := NewTokenPool(tokens, perTokenRPM)
+	if pool.Size() <= 1 && perTokenRPM <= 0 {
+		return
+	}
+	c.tokens = pool
+	fmt.Printf("Token pool enabled for %s: %d tokens\n", c.BaseURL, pool.Size())
+}
+
+// token returns the token for the next request
+func (c *Client) token() string {
+	if c.parent != nil {
+		return c.parent.token()
+	}
+	if c.tokens != nil {
+		return c.tokens.Acquire()
+	}
+
+	c.refreshIfDue()
+
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.Token
+}
+
+// ParseTokenList splits a comma-separated list of tokens
+func ParseTokenList(value string) []string {
+	var tokens []string
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// lastSuccessfulCall holds the Unix nanosecond time of the last successful API response
+var lastSuccessfulCall atomic.Int64
+
+// trackingTransport records the time of successful responses for status reporting
+// and per-endpoint latencies, paces requests by the reported rate limits, and
+// takes rate-limited tokens out of the client's token rotation
+type trackingTransport struct {
+	base   http.RoundTripper
+	client *Client
+}
+
+// RoundTrip executes the request and records successful responses
+func (t *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var pace *pacer
+	if t.client != nil {
+		pace = t.client.pace
+	}
+	pace.wait(req.Header.Get("Token"))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	recordLatency(req, time.Since(start))
+	if err != nil {
+		return resp, err
+	}
+
+	// Refresh an expired short-lived token and replay the request once
+	if resp.StatusCode == http.StatusUnauthorized && t.client != nil && t.client.refresher != nil && t.client.tokens == nil {
+		if refreshErr := t.client.refreshToken(req.Header.Get("Token")); refreshErr != nil {
+			fmt.Printf("Warning: Failed to refresh API token after 401: %v\n", refreshErr)
+		} else if retry, ok := withToken(req, t.client.token()); ok {
+			resp.Body.Close()
+			req = retry
+			resp, err = t.base.RoundTrip(retry)
+			if err != nil {
+				return resp, err
+			}
+		}
+	}
+
+	if resp.StatusCode < 400 {
+		lastSuccessfulCall.Store(time.Now().UnixNano())
+	}
+	pace.observe(req.Header.Get("Token"), resp)
+
+	if resp.StatusCode == http.StatusTooManyRequests && t.client != nil && t.client.tokens != nil {
+		t.client.tokens.Penalize(req.Header.Get("Token"), retryAfter(resp, 10*time.Second))
+	}
+
+	return resp, err
+}
+
+// withToken clones a request with a different token, reporting false when the
+// request body cannot be replayed
+func withToken(req *http.Request, token string) (*http.Request, bool) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		clone.Body = body
+	}
+	clone.Header.Set("Token", token)
+	return clone, true
+}
+
+// retryAfter parses the Retry-After header in seconds, returning def when absent
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
+
+// LastSuccessfulCall returns the time of the last successful API response across all clients
+func LastSuccessfulCall() time.Time {
+	nanos := lastSuccessfulCall.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// CorruptResponseError is returned for a successful read whose body is not
+// the JSON the API sends, such as an HTML error page a gateway served with
+// status 200, or a body cut off mid-transfer. Trusting such a page would end
+// a listing early without any error.
+type CorruptResponseError struct {
+	Path    string
+	Reason  string
+	Snippet string // start of the body
+}
+
+func (e *CorruptResponseError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("corrupt response for GET %s: %s", e.Path, e.Reason)
+	}
+	return fmt.Sprintf("corrupt response for GET %s: %s: %s", e.Path, e.Reason, e.Snippet)
+}
+
+// HTTPStatus classifies the error as a gateway failure
+func (e *CorruptResponseError) HTTPStatus() int {
+	return http.StatusBadGateway
+}
+
+// snippetLength bounds the body excerpt in a CorruptResponseError
+const snippetLength = 120
+
+// checkJSONResponse reads the body of a successful JSON read and returns an
+// error when it is not complete JSON. The body is restored for the caller.
+func checkJSONResponse(req *http.Request, resp *http.Response) *CorruptResponseError {
+	if resp == nil || resp.StatusCode != http.StatusOK || req.Header.Get("Accept") != "application/json" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	corrupt := &CorruptResponseError{Path: req.URL.Path, Snippet: snippet(body)}
+	if err != nil {
+		corrupt.Reason = fmt.Sprintf("body cut off after %d bytes (%v)", len(body), err)
+		return corrupt
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			corrupt.Reason = fmt.Sprintf("content type %s instead of JSON", contentType)
+			return corrupt
+		}
+	}
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0:
+		corrupt.Reason = "empty body"
+	case trimmed[0] != '{' && trimmed[0] != '[':
+		corrupt.Reason = "body is not JSON"
+	case !json.Valid(trimmed):
+		corrupt.Reason = fmt.Sprintf("invalid or truncated JSON (%d bytes)", len(body))
+	default:
+		return nil
+	}
+	return corrupt
+}
+
+// snippet returns the start of a body on one line
+func snippet(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if len(text) > snippetLength {
+		text = text[:snippetLength] + "..."
+	}
+	return text
+}
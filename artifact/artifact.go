@@ -0,0 +1,146 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned by Read when the artifact does not exist
+var ErrNotExist = errors.New("artifact does not exist")
+
+// Sink stores artifacts (reports, manifests, mapping outputs, checkpoints)
+type Sink interface {
+	Write(location string, data []byte) error
+	Read(location string) ([]byte, error)
+	Delete(location string) error
+}
+
+// httpClient is shared by the object storage sinks
+var httpClient = &http.Client{Timeout: 2 * time.Minute}
+
+// For returns the sink for a location: s3://bucket/key, gs://bucket/object,
+// or a local path
+func For(location string) (Sink, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		return s3Sink{}, nil
+	case strings.HasPrefix(location, "gs://"):
+		return gcsSink{}, nil
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported artifact location %s (use a local path, s3:// or gs://)", location)
+	default:
+		return localSink{}, nil
+	}
+}
+
+// Write stores data at location, encrypted when an artifact key is
+// configured
+func Write(location string, data []byte) error {
+	sink, err := For(location)
+	if err != nil {
+		return err
+	}
+	data, err = Encrypt(location, data)
+	if err != nil {
+		return err
+	}
+	return sink.Write(location, data)
+}
+
+// Read loads the artifact at location, decrypting it when it was written
+// encrypted
+func Read(location string) ([]byte, error) {
+	sink, err := For(location)
+	if err != nil {
+		return nil, err
+	}
+	data, err := sink.Read(location)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(location, data)
+}
+
+// Delete removes the artifact at location. Deleting a missing artifact is
+// not an error.
+func Delete(location string) error {
+	sink, err := For(location)
+	if err != nil {
+		return err
+	}
+	return sink.Delete(location)
+}
+
+// Join appends a file name to a directory path or object storage prefix
+func Join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.Contains(dir, "://") {
+		return strings.TrimRight(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
+
+// splitBucket splits "scheme://bucket/key" into bucket and key
+func splitBucket(location, scheme string) (string, string, error) {
+	rest := strings.TrimPrefix(location, scheme)
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid artifact location %s (expected %sbucket/key)", location, scheme)
+	}
+	return bucket, key, nil
+}
+
+// localSink writes files atomically via a temp file and rename
+type localSink struct{}
+
+func (localSink) Write(location string, data []byte) error {
+	dir := filepath.Dir(location)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(location)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", location, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", location, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", location, err)
+	}
+	if err := os.Rename(tmp.Name(), location); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", location, err)
+	}
+	return nil
+}
+
+func (localSink) Read(location string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(location))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return data, err
+}
+
+func (localSink) Delete(location string) error {
+	if err := os.Remove(filepath.Clean(location)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", location, err)
+	}
+	return nil
+}
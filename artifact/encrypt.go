@@ -0,0 +1,104 @@
+package artifact
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/seal"
+)
+
+// encryptedMagic marks artifacts encrypted at rest. It wraps the sealed data
+// so that data sealed with another key, such as a fetch-only bundle, is
+// never mistaken for an encrypted artifact.
+var encryptedMagic = []byte("QASEARTIFACT1\n")
+
+var (
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+)
+
+// encryptionKey returns the key artifacts are encrypted with, from
+// QASE_ARTIFACT_KEY or the output of QASE_ARTIFACT_KEY_COMMAND (e.g. a KMS
+// decrypt of a data key), or nil when artifacts are written in plaintext
+func encryptionKey() ([]byte, error) {
+	keyOnce.Do(func() {
+		value := os.Getenv("QASE_ARTIFACT_KEY")
+		if command := os.Getenv("QASE_ARTIFACT_KEY_COMMAND"); value == "" && command != "" {
+			var stderr bytes.Buffer
+			cmd := exec.Command("sh", "-c", command)
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				keyErr = fmt.Errorf("QASE_ARTIFACT_KEY_COMMAND failed: %w", err)
+				if detail := strings.TrimSpace(stderr.String()); detail != "" {
+					keyErr = fmt.Errorf("%w: %s", keyErr, detail)
+				}
+				return
+			}
+			value = string(out)
+			if strings.TrimSpace(value) == "" {
+				keyErr = fmt.Errorf("QASE_ARTIFACT_KEY_COMMAND returned an empty key")
+				return
+			}
+		}
+		if value == "" {
+			return
+		}
+		if key, keyErr = seal.ParseKey(value); keyErr != nil {
+			keyErr = fmt.Errorf("invalid artifact key: %w", keyErr)
+		}
+	})
+	return key, keyErr
+}
+
+// Encrypted reports whether artifacts are encrypted at rest, failing when
+// the configured key cannot be loaded
+func Encrypted() (bool, error) {
+	key, err := encryptionKey()
+	return key != nil, err
+}
+
+// IsEncrypted reports whether data is an encrypted artifact
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// Encrypt encrypts data written to location when an artifact key is
+// configured, for files kept outside the artifact sinks
+func Encrypt(location string, data []byte) ([]byte, error) {
+	key, err := encryptionKey()
+	if err != nil || key == nil {
+		return data, err
+	}
+	sealed, err := seal.Seal(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", location, err)
+	}
+	return append(append([]byte{}, encryptedMagic...), sealed...), nil
+}
+
+// Decrypt decrypts an encrypted artifact read from location; other data is
+// returned as is, so plaintext artifacts and inputs stay readable with a key
+// configured
+func Decrypt(location string, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s is encrypted (set QASE_ARTIFACT_KEY or QASE_ARTIFACT_KEY_COMMAND)", location)
+	}
+	plain, err := seal.Open(key, data[len(encryptedMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", location, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,153 @@
+package artifact
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gcsSink stores artifacts in Google Cloud Storage using an OAuth access
+// token from GOOGLE_OAUTH_ACCESS_TOKEN or QASE_GCS_TOKEN_COMMAND
+type gcsSink struct{}
+
+func (gcsSink) Write(location string, data []byte) error {
+	bucket, object, err := splitBucket(location, "gs://")
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		gcsEndpoint(), url.PathEscape(bucket), url.QueryEscape(object))
+	resp, err := gcsDo("POST", u, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to upload %s: status %d: %s", location, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (gcsSink) Read(location string) ([]byte, error) {
+	bucket, object, err := splitBucket(location, "gs://")
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		gcsEndpoint(), url.PathEscape(bucket), escapeObject(object))
+	resp, err := gcsDo("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d: %s", location, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+func (gcsSink) Delete(location string) error {
+	bucket, object, err := splitBucket(location, "gs://")
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gcsEndpoint(), url.PathEscape(bucket), escapeObject(object))
+	resp, err := gcsDo("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete %s: status %d: %s", location, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// gcsDo sends an authorized request to the GCS JSON API
+func gcsDo(method, u string, body []byte) (*http.Response, error) {
+	token, err := gcsToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	return resp, nil
+}
+
+// escapeObject escapes an object name as one path segment of the JSON API:
+// every character but the unreserved ones is percent-encoded, "/" as %2F,
+// so that nested names such as dir/checkpoint.json address the object
+// instead of a longer API path
+func escapeObject(object string) string {
+	return strings.ReplaceAll(url.QueryEscape(object), "+", "%20")
+}
+
+// GoogleToken returns the Google OAuth access token used for GCS, for other
+// Google APIs such as BigQuery
+func GoogleToken() (string, error) {
+	return gcsToken()
+}
+
+// gcsToken returns the access token from the environment or the token command
+func gcsToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	command := os.Getenv("QASE_GCS_TOKEN_COMMAND")
+	if command == "" {
+		command = "gcloud auth print-access-token"
+	}
+	out, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get GCS access token (set GOOGLE_OAUTH_ACCESS_TOKEN or QASE_GCS_TOKEN_COMMAND): %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("GCS token command returned an empty token")
+	}
+	return token, nil
+}
+
+// gcsEndpoint returns the GCS API base URL, honoring STORAGE_EMULATOR_HOST
+func gcsEndpoint() string {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimRight(host, "/")
+	}
+	return "https://storage.googleapis.com"
+}
@@ -0,0 +1,60 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WriteProtected stores data at location without replacing an existing
+// artifact: if location is taken, the data is written to a timestamped name
+// next to it instead. With force, existing artifacts are overwritten. It
+// returns the location actually written.
+func WriteProtected(location string, data []byte, force bool) (string, error) {
+	if force {
+		return location, Write(location, data)
+	}
+
+	ext := filepath.Ext(location)
+	base := strings.TrimSuffix(location, ext)
+	stamp := time.Now().UTC().Format("20060102-150405")
+
+	candidate := location
+	for attempt := 0; ; attempt++ {
+		exists, err := Exists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			break
+		}
+		if attempt == 0 {
+			candidate = fmt.Sprintf("%s-%s%s", base, stamp, ext)
+		} else {
+			candidate = fmt.Sprintf("%s-%s-%d%s", base, stamp, attempt, ext)
+		}
+	}
+
+	if candidate != location {
+		fmt.Printf("%s already exists, writing %s instead (set QASE_FORCE=true to overwrite)\n", location, candidate)
+	}
+	return candidate, Write(candidate, data)
+}
+
+// Exists reports whether an artifact exists at location
+func Exists(location string) (bool, error) {
+	sink, err := For(location)
+	if err != nil {
+		return false, err
+	}
+	_, err = sink.Read(location)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", location, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+// BatchPair describes one source -> target project pair in a batch file.
+// Empty fields inherit the values from the environment configuration.
+type BatchPair struct {
+	SourceProject string `json:"source_project"`
+	TargetProject string `json:"target_project"`
+
+	// Per-pair workspace overrides for projects in different regions/instances
+	SourceAPIBase      string `json:"source_api_base,omitempty"`
+	TargetAPIBase      string `json:"target_api_base,omitempty"`
+	SourceTokenEnv     string `json:"source_token_env,omitempty"`
+	TargetTokenEnv     string `json:"target_token_env,omitempty"`
+	SourceTokenCommand string `json:"source_token_command,omitempty"`
+	TargetTokenCommand string `json:"target_token_command,omitempty"`
+
+	// Per-pair mapping overrides
+	MatchMode       string `json:"match_mode,omitempty"`
+	CustomFieldID   int    `json:"cf_id,omitempty"`
+	CustomFieldName string `json:"cf_name,omitempty"`
+	CFValuePrefix   string `json:"cf_value_prefix,omitempty"`
+	CFValuePattern  string `json:"cf_value_pattern,omitempty"`
+	ExternalIDCF    string `json:"external_id_cf,omitempty"`
+	SourceExtIDCF   string `json:"source_external_id_cf,omitempty"`
+	MappingCSV      string `json:"mapping_csv,omitempty"`
+
+	// Per-pair result handling overrides
+	ParamsMode string `json:"params_mode,omitempty"`
+}
+
+// loadBatchConfigs reads a JSON array of project pairs and builds one Config per pair
+func loadBatchConfigs(base *Config, path string) ([]*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	var pairs []BatchPair
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file: %w", err)
+	}
+
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("batch file %s contains no project pairs", path)
+	}
+
+	configs := make([]*Config, 0, len(pairs))
+	for i, pair := range pairs {
+		pairConfig, err := pairConfig(base, pair)
+		if err != nil {
+			return nil, fmt.Errorf("pair %d (%s -> %s): %w", i+1, pair.SourceProject, pair.TargetProject, err)
+		}
+		configs = append(configs, pairConfig)
+	}
+
+	fmt.Printf("Loaded %d project pairs from %s\n", len(configs), path)
+	return configs, nil
+}
+
+// pairConfig applies a batch pair's overrides on top of the base configuration
+func pairConfig(base *Config, pair BatchPair) (*Config, error) {
+	config := *base
+	config.BatchFile = ""
+	config.InBatch = true
+
+	if pair.SourceProject == "" || pair.TargetProject == "" {
+		return nil, fmt.Errorf("source_project and target_project are required")
+	}
+	config.SourceProject = pair.SourceProject
+	config.TargetProject = pair.TargetProject
+
+	if pair.SourceAPIBase != "" {
+		config.SourceBaseURL = pair.SourceAPIBase
+	}
+	if pair.TargetAPIBase != "" {
+		config.TargetBaseURL = pair.TargetAPIBase
+	}
+
+	// Tokens are referenced by environment variable name to keep secrets out
+	// of the file. A pair's token replaces the inherited pool and command,
+	// which belong to another instance.
+	if pair.SourceTokenEnv != "" {
+		config.SourceToken = os.Getenv(pair.SourceTokenEnv)
+		if config.SourceToken == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", pair.SourceTokenEnv)
+		}
+		config.SourceExtraTokens = nil
+		config.SourceTokenCommand = ""
+	}
+	if pair.TargetTokenEnv != "" {
+		config.TargetToken = os.Getenv(pair.TargetTokenEnv)
+		if config.TargetToken == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", pair.TargetTokenEnv)
+		}
+		config.TargetExtraTokens = nil
+		config.TargetTokenCommand = ""
+	}
+	if pair.SourceTokenCommand != "" {
+		config.SourceTokenCommand = pair.SourceTokenCommand
+		config.SourceExtraTokens = nil
+	}
+	if pair.TargetTokenCommand != "" {
+		config.TargetTokenCommand = pair.TargetTokenCommand
+		config.TargetExtraTokens = nil
+	}
+	if conflicts := tokenPoolConflicts(&config); len(conflicts) > 0 {
+		return nil, conflicts[0]
+	}
+	if !config.TargetRPMSet {
+		config.TargetRPM = defaultTargetRPM(&config)
+	}
+
+	if config.SourceToken == "" && config.SourceTokenCommand == "" {
+		return nil, fmt.Errorf("no source token (set source_token_env or QASE_SOURCE_API_TOKEN)")
+	}
+	if config.TargetToken == "" && config.TargetTokenCommand == "" {
+		return nil, fmt.Errorf("no target token (set target_token_env or QASE_TARGET_API_TOKEN)")
+	}
+
+	if pair.MatchMode != "" {
+		mode, err := mapping.ParseMode(pair.MatchMode)
+		if err != nil {
+			return nil, err
+		}
+		config.MatchMode = mode
+	}
+	// A pair's field ID or name replaces both defaults
+	if pair.CustomFieldID != 0 || pair.CustomFieldName != "" {
+		config.CustomFieldID = pair.CustomFieldID
+		config.CustomFieldName = pair.CustomFieldName
+	}
+	if pair.CFValuePrefix != "" || pair.CFValuePattern != "" {
+		rules, err := mapping.ParseValueRules(pair.CFValuePrefix, pair.CFValuePattern)
+		if err != nil {
+			return nil, err
+		}
+		config.CFValueRules = rules
+	}
+	if pair.MappingCSV != "" {
+		config.MappingCSV = pair.MappingCSV
+	}
+	if pair.ExternalIDCF != "" {
+		config.TargetExternalIDField = pair.ExternalIDCF
+		config.SourceExternalIDField = pair.ExternalIDCF
+	}
+	if pair.SourceExtIDCF != "" {
+		config.SourceExternalIDField = pair.SourceExtIDCF
+	}
+	if pair.ParamsMode != "" {
+		if err := transform.ValidateParamsMode(pair.ParamsMode); err != nil {
+			return nil, err
+		}
+		config.Transform.Params = pair.ParamsMode
+	}
+
+	switch config.MatchMode {
+	case mapping.ModeCF:
+		if config.CustomFieldID == 0 && config.CustomFieldName == "" {
+			return nil, fmt.Errorf("cf_id or cf_name is required for custom_field mode")
+		}
+	case mapping.ModeCSV:
+		if config.MappingCSV == "" {
+			return nil, fmt.Errorf("mapping_csv is required for csv mode")
+		}
+	case mapping.ModeExternalID:
+		if config.TargetExternalIDField == "" {
+			return nil, fmt.Errorf("external_id_cf is required for external_id mode")
+		}
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/runtitle"
+)
+
+// Oversized run policies for QASE_OVERSIZED_RUNS
+const (
+	OversizedFail  = "fail"
+	OversizedSplit = "split"
+	OversizedAllow = "allow"
+)
+
+// Run bucketing modes for QASE_RUN_BUCKET
+const (
+	BucketNone   = "none"
+	BucketDaily  = "daily"
+	BucketWeekly = "weekly"
+)
+
+// runGroup is a set of source results migrated into one target run
+type runGroup struct {
+	id          int    // source run ID, or bucket start date as YYYYMMDD (0 for undated)
+	key         string // stable identity recorded in the target run's marker
+	title       string
+	description string
+	results     []qase.Result
+}
+
+// groupResults groups results into target runs: one per source run, or one
+// per day/week of the results' end time. Groups are ordered by ID.
+func groupResults(results []qase.Result, bucket string) []runGroup {
+	resultsByKey := make(map[int][]qase.Result)
+	for _, result := range results {
+		key := result.RunID
+		if bucket != BucketNone {
+			key = 0
+			if !result.EndedAt.IsZero() {
+				start := bucketStart(result.EndedAt, bucket)
+				key = start.Year()*10000 + int(start.Month())*100 + start.Day()
+			}
+		}
+		resultsByKey[key] = append(resultsByKey[key], result)
+	}
+
+	groups := make([]runGroup, 0, len(resultsByKey))
+	for key, groupResults := range resultsByKey {
+		if bucket == BucketNone {
+			groups = append(groups, sourceRunGroup(key, groupResults))
+		} else {
+			groups = append(groups, bucketRunGroup(key, groupResults, bucket))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].id < groups[j].id })
+	return groups
+}
+
+// guardRunSizes applies the oversized run policy to groups with more than
+// max results (0 disables the guard)
+func guardRunSizes(groups []runGroup, max int, policy string) ([]runGroup, error) {
+	if max <= 0 {
+		return groups, nil
+	}
+
+	var oversized []string
+	guarded := make([]runGroup, 0, len(groups))
+	for _, group := range groups {
+		if len(group.results) <= max {
+			guarded = append(guarded, group)
+			continue
+		}
+
+		fmt.Printf("Warning: '%s' has %d results (limit %d)\n", group.title, len(group.results), max)
+		oversized = append(oversized, fmt.Sprintf("%s (%d)", group.title, len(group.results)))
+		switch policy {
+		case OversizedSplit:
+			parts := splitGroup(group, max)
+			fmt.Printf("Splitting '%s' into %d runs\n", group.title, len(parts))
+			guarded = append(guarded, parts...)
+		default:
+			guarded = append(guarded, group)
+		}
+	}
+
+	if len(oversized) > 0 && policy == OversizedFail {
+		return nil, fmt.Errorf("%d runs exceed QASE_MAX_RESULTS_PER_RUN=%d: %s; set QASE_OVERSIZED_RUNS=split to split them or QASE_OVERSIZED_RUNS=allow to post them anyway",
+			len(oversized), max, strings.Join(oversized, ", "))
+	}
+	return guarded, nil
+}
+
+// splitGroup splits a group into target runs of at most max results each
+func splitGroup(group runGroup, max int) []runGroup {
+	parts := (len(group.results) + max - 1) / max
+	split := make([]runGroup, 0, parts)
+	for i := 0; i < parts; i++ {
+		end := (i + 1) * max
+		if end > len(group.results) {
+			end = len(group.results)
+		}
+		split = append(split, runGroup{
+			id:          group.id,
+			key:         runtitle.PartKey(group.key, i+1, parts),
+			title:       fmt.Sprintf("%s (part %d/%d)", group.title, i+1, parts),
+			description: fmt.Sprintf("%s; part %d of %d", group.description, i+1, parts),
+			results:     group.results[i*max : end],
+		})
+	}
+	return split
+}
+
+// sourceRunGroup names a target run after its source run
+func sourceRunGroup(runID int, results []qase.Result) runGroup {
+	title, description := runtitle.ForSourceRun(runID, results)
+	return runGroup{
+		id:          runID,
+		key:         runtitle.RunKey(runID),
+		title:       title,
+		description: description,
+		results:     results,
+	}
+}
+
+// bucketRunGroup names a target run after its date bucket
+func bucketRunGroup(key int, results []qase.Result, bucket string) runGroup {
+	var title string
+	switch {
+	case key == 0:
+		title = "Migrated results (undated)"
+	case bucket == BucketWeekly:
+		title = fmt.Sprintf("Migrated results week of %04d-%02d-%02d", key/10000, key/100%100, key%100)
+	default:
+		title = fmt.Sprintf("Migrated results %04d-%02d-%02d", key/10000, key/100%100, key%100)
+	}
+
+	seen := make(map[int]bool)
+	var runIDs []int
+	for _, result := range results {
+		if !seen[result.RunID] {
+			seen[result.RunID] = true
+			runIDs = append(runIDs, result.RunID)
+		}
+	}
+	sort.Ints(runIDs)
+
+	ids := make([]string, len(runIDs))
+	for i, id := range runIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+
+	return runGroup{
+		id:    key,
+		key:   runtitle.BucketKey(bucket, key),
+		title: title,
+		description: fmt.Sprintf("Migrated %d results from %d source runs (%s)",
+			len(results), len(runIDs), strings.Join(ids, ", ")),
+		results: results,
+	}
+}
+
+// marker identifies the target run of a group across migrations of srcProject
+func (g runGroup) marker(srcProject string) string {
+	return runtitle.Marker(srcProject, g.key)
+}
+
+// bucketStart returns the UTC start of the day or ISO week (Monday) containing t
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket == BucketWeekly {
+		offset := (int(day.Weekday()) + 6) % 7
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
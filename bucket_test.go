@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+)
+
+func BenchmarkGroupResults(b *testing.B) {
+	// Run sizes are log-normal around the median, averaging ~1.38x of it
+	fixture := mockserver.Generate(mockserver.FixtureOptions{Cases: 10000, Runs: 100, ResultsPerRun: 724, Seed: 1})
+
+	for _, bucket := range []string{BucketNone, BucketDaily, BucketWeekly} {
+		b.Run(bucket, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				groupResults(fixture.Results, bucket)
+			}
+			b.ReportMetric(float64(len(fixture.Results)*b.N)/b.Elapsed().Seconds(), "results/s")
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// File is the checkpoint document. Completed runs are tracked per project
+// pair so one checkpoint can serve a whole batch.
+type File struct {
+	UpdatedAt time.Time        `json:"updated_at"`
+	Pairs     map[string]*Pair `json:"pairs"`
+}
+
+// Pair holds the progress of one source -> target project pair
+type Pair struct {
+	// CompletedRuns maps run group keys (run-<id>, a bucket or a part) to the
+	// target run IDs they were migrated to, 0 when none was created
+	CompletedRuns map[string]int `json:"completed_runs"`
+}
+
+// Checkpoint records completed runs so an interrupted migration can resume
+// without reprocessing them. Progress is flushed every interval and on Close.
+type Checkpoint struct {
+	location string
+	interval time.Duration
+	key      string
+
+	mu    sync.Mutex
+	file  File
+	dirty bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open loads the checkpoint at location for a project pair, starting empty if
+// it does not exist yet. It returns nil when location is empty; all
+// Checkpoint methods are safe to call on a nil Checkpoint.
+func Open(location string, interval time.Duration, sourceProject, targetProject string) (*Checkpoint, error) {
+	if location == "" {
+		return nil, nil
+	}
+	if _, err := artifact.For(location); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	cp := &Checkpoint{
+		location: location,
+		interval: interval,
+		key:      sourceProject + "->" + targetProject,
+		file:     File{Pairs: make(map[string]*Pair)},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	data, err := artifact.Read(location)
+	switch {
+	case errors.Is(err, artifact.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	default:
+		if err := json.Unmarshal(data, &cp.file); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint %s: %w", location, err)
+		}
+		if cp.file.Pairs == nil {
+			cp.file.Pairs = make(map[string]*Pair)
+		}
+	}
+
+	if cp.file.Pairs[cp.key] == nil {
+		cp.file.Pairs[cp.key] = &Pair{}
+	}
+	if cp.file.Pairs[cp.key].CompletedRuns == nil {
+		cp.file.Pairs[cp.key].CompletedRuns = make(map[string]int)
+	}
+
+	go cp.loop()
+
+	fmt.Printf("Checkpoint %s: %d runs already completed for %s, saving every %v\n",
+		location, len(cp.file.Pairs[cp.key].CompletedRuns), cp.key, interval)
+	return cp, nil
+}
+
+// Done reports whether the run group with this key was already completed
+func (cp *Checkpoint) Done(key string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	_, exists := cp.file.Pairs[cp.key].CompletedRuns[key]
+	return exists
+}
+
+// Complete records a run group as migrated to the given target run, 0 when
+// it completed without one (e.g. nothing was left to post)
+func (cp *Checkpoint) Complete(key string, targetRunID int) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.file.Pairs[cp.key].CompletedRuns[key] = targetRunID
+	cp.dirty = true
+}
+
+// Close flushes pending progress and stops the periodic writer
+func (cp *Checkpoint) Close() {
+	if cp == nil {
+		return
+	}
+	close(cp.stop)
+	<-cp.done
+	cp.flush()
+}
+
+func (cp *Checkpoint) loop() {
+	defer close(cp.done)
+
+	ticker := time.NewTicker(cp.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cp.flush()
+		case <-cp.stop:
+			return
+		}
+	}
+}
+
+// flush saves the checkpoint if there is unsaved progress
+func (cp *Checkpoint) flush() {
+	cp.mu.Lock()
+	if !cp.dirty {
+		cp.mu.Unlock()
+		return
+	}
+	cp.file.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(cp.file, "", "  ")
+	cp.dirty = false
+	cp.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("Warning: Failed to marshal checkpoint: %v\n", err)
+		return
+	}
+
+	if err := artifact.Write(cp.location, data); err != nil {
+		fmt.Printf("Warning: Failed to write checkpoint: %v\n", err)
+		cp.mu.Lock()
+		cp.dirty = true
+		cp.mu.Unlock()
+	}
+}
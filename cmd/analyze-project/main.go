@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
 	"github.com/adrianeortiz/clone-run-multi-ws/utils"
 )
@@ -20,9 +23,12 @@ type ProjectAnalysis struct {
 
 	// Source project stats
 	SourceStats struct {
-		TotalCases   int `json:"total_cases"`
-		TotalRuns    int `json:"total_runs"`
-		TotalResults int `json:"total_results"`
+		TotalCases      int `json:"total_cases"`
+		CasesWithSteps  int `json:"cases_with_steps"`
+		CasesWithParams int `json:"cases_with_params"`
+		CasesWithTags   int `json:"cases_with_tags"`
+		TotalRuns       int `json:"total_runs"`
+		TotalResults    int `json:"total_results"`
 	} `json:"source_stats"`
 
 	// Filtered data counts
@@ -34,6 +40,11 @@ type ProjectAnalysis struct {
 }
 
 func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
 	// Load configuration
 	config := loadConfig()
 
@@ -45,6 +56,11 @@ func main() {
 	// Create API clients
 	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
 
+	// Read-through cache shared with other subcommands using the same directory
+	if err := srcClient.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+		log.Fatalf("Failed to enable API cache: %v", err)
+	}
+
 	analysis := ProjectAnalysis{
 		SourceProject: config.SourceProject,
 		TargetProject: config.TargetProject,
@@ -61,12 +77,24 @@ func main() {
 
 	// Get total cases count (with pagination limit)
 	fmt.Printf("Counting test cases...\n")
-	cases, err := qase.GetCases(srcClient, config.SourceProject)
+	cases, err := qase.GetCases(srcClient, config.SourceProject, qase.CaseListOptions{IncludeSteps: true, IncludeParams: true, IncludeTags: true})
 	if err != nil {
 		log.Fatalf("Failed to fetch cases: %v", err)
 	}
 	analysis.SourceStats.TotalCases = len(cases)
-	fmt.Printf("Total cases: %d\n", analysis.SourceStats.TotalCases)
+	for _, c := range cases {
+		if len(c.Steps) > 0 {
+			analysis.SourceStats.CasesWithSteps++
+		}
+		if len(c.Params) > 0 {
+			analysis.SourceStats.CasesWithParams++
+		}
+		if len(c.Tags) > 0 {
+			analysis.SourceStats.CasesWithTags++
+		}
+	}
+	fmt.Printf("Total cases: %d (with steps: %d, parameterized: %d, tagged: %d)\n", analysis.SourceStats.TotalCases,
+		analysis.SourceStats.CasesWithSteps, analysis.SourceStats.CasesWithParams, analysis.SourceStats.CasesWithTags)
 
 	// Get total results count (we'll estimate runs from results)
 	fmt.Printf("Counting test results...\n")
@@ -111,12 +139,13 @@ func main() {
 		log.Fatalf("Failed to marshal analysis: %v", err)
 	}
 
-	if err := os.WriteFile("analysis-results.json", analysisData, 0644); err != nil {
+	outputPath, err := artifact.WriteProtected(artifact.Join(config.ArtifactDir, "analysis-results.json"), analysisData, config.Force)
+	if err != nil {
 		log.Fatalf("Failed to write analysis results: %v", err)
 	}
 
 	fmt.Printf("\n=== Analysis Complete ===\n")
-	fmt.Printf("Analysis saved to: analysis-results.json\n")
+	fmt.Printf("Analysis saved to: %s\n", outputPath)
 
 	// Print summary
 	fmt.Printf("\n--- Summary ---\n")
@@ -147,6 +176,10 @@ func generateRecommendations(analysis ProjectAnalysis) []string {
 		recommendations = append(recommendations, "Very large case database - case mapping may be slow")
 	}
 
+	if analysis.SourceStats.CasesWithParams > 0 {
+		recommendations = append(recommendations, fmt.Sprintf("%d parameterized cases - choose where their results' parameters go with QASE_PARAMS_MODE", analysis.SourceStats.CasesWithParams))
+	}
+
 	if analysis.FilteredResults == 0 {
 		recommendations = append(recommendations, "No results found for the specified date - check date format and project data")
 	}
@@ -167,6 +200,10 @@ type Config struct {
 	SourceProject string
 	TargetProject string
 	AfterDate     time.Time
+	ArtifactDir   string
+	Force         bool
+	CacheDir      string
+	CacheTTL      time.Duration
 }
 
 func loadConfig() Config {
@@ -175,6 +212,8 @@ func loadConfig() Config {
 		SourceBaseURL: getEnv("QASE_SOURCE_API_BASE", "https://api.qase.io"),
 		SourceProject: getEnv("QASE_SOURCE_PROJECT", ""),
 		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
+		ArtifactDir:   getEnv("QASE_ARTIFACT_DIR", ""),
+		Force:         getEnv("QASE_FORCE", "false") == "true",
 	}
 
 	if config.SourceToken == "" {
@@ -187,14 +226,20 @@ func loadConfig() Config {
 		log.Fatal("QASE_TARGET_PROJECT is required")
 	}
 
-	// Parse after date (Unix timestamp)
-	afterDateStr := getEnv("QASE_AFTER_DATE", "1755500400")
-	afterDate, err := utils.ParseUnixTimestamp(afterDateStr)
+	// Parse after date (required; "all" disables the cutoff)
+	afterDate, err := utils.ParseAfterDate(getEnv("QASE_AFTER_DATE", ""), getEnv("QASE_TIMEZONE", ""))
 	if err != nil {
-		log.Fatalf("Invalid QASE_AFTER_DATE format (must be Unix timestamp): %v", err)
+		log.Fatal(err)
 	}
 	config.AfterDate = afterDate
 
+	config.CacheDir = getEnv("QASE_CACHE_DIR", "")
+	cacheTTL, err := strconv.Atoi(getEnv("QASE_CACHE_TTL", "300"))
+	if err != nil || cacheTTL < 0 {
+		log.Fatalf("Invalid QASE_CACHE_TTL: %s", getEnv("QASE_CACHE_TTL", ""))
+	}
+	config.CacheTTL = time.Duration(cacheTTL) * time.Second
+
 	return config
 }
 
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/seal"
+)
+
+// Reads and writes artifacts encrypted at rest with the key from
+// QASE_ARTIFACT_KEY or QASE_ARTIFACT_KEY_COMMAND
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s keygen | decrypt <location> [--out <file>] | encrypt <file> --out <location>\n", os.Args[0])
+		os.Exit(2)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+	if os.Args[1] == "keygen" {
+		key, err := seal.GenerateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(key)
+		return
+	}
+	if len(os.Args) < 3 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	out := flags.String("out", "", "output: a local file for decrypt (default: stdout), a local path, s3:// or gs:// for encrypt")
+	flags.Parse(os.Args[3:])
+	in := os.Args[2]
+
+	switch os.Args[1] {
+	case "decrypt":
+		// Read decrypts; the plaintext is written locally only
+		data, err := artifact.Read(in)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", in, err)
+		}
+		if *out == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(*out, data, 0600); err != nil {
+			log.Fatal(err)
+		}
+	case "encrypt":
+		if *out == "" {
+			usage()
+		}
+		encrypted, err := artifact.Encrypted()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !encrypted {
+			log.Fatal("QASE_ARTIFACT_KEY or QASE_ARTIFACT_KEY_COMMAND is required to encrypt")
+		}
+		data, err := os.ReadFile(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if artifact.IsEncrypted(data) {
+			log.Fatalf("%s is already encrypted", in)
+		}
+		if err := artifact.Write(*out, data); err != nil {
+			log.Fatalf("Failed to write %s: %v", *out, err)
+		}
+	default:
+		usage()
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/runtitle"
+)
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	// Refuse protected target projects before doing any work
+	if !config.DryRun {
+		if err := qase.CheckWritable(config.TargetProject, qase.ParseProjectList(getEnv("QASE_PROTECTED_PROJECTS", "")), getEnv(qase.OverrideEnv, "false") == "true"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("=== Clean Up Empty Migrated Runs ===\n")
+	fmt.Printf("Target Project: %s\n", config.TargetProject)
+	fmt.Printf("Title Prefix: %q\n", config.TitlePrefix)
+	fmt.Printf("Dry Run: %t\n", config.DryRun)
+
+	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+
+	// A dry run must never write to the target workspace
+	if config.DryRun {
+		tgtClient.SetReadOnly()
+	}
+
+	startTime := time.Now()
+
+	runs, err := qase.GetRuns(tgtClient, config.TargetProject, qase.RunListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to fetch target runs: %v", err)
+	}
+
+	// Only runs created by the migration are candidates
+	checked, deleted, failed := 0, 0, 0
+	for _, run := range runs {
+		if !strings.HasPrefix(run.Title, config.TitlePrefix) {
+			continue
+		}
+		checked++
+
+		hasResults, err := qase.CheckRunHasResults(tgtClient, config.TargetProject, run.ID)
+		if err != nil {
+			fmt.Printf("Failed to check results of run %d: %v\n", run.ID, err)
+			failed++
+			continue
+		}
+		if hasResults {
+			continue
+		}
+
+		link := qase.RunURL(config.TargetBaseURL, config.TargetProject, run.ID)
+		if config.DryRun {
+			fmt.Printf("DRY RUN MODE - Would delete empty run %d %q (%s)\n", run.ID, run.Title, link)
+			deleted++
+			continue
+		}
+		if err := qase.DeleteRun(tgtClient, config.TargetProject, run.ID); err != nil {
+			fmt.Printf("Failed to delete run %d: %v\n", run.ID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Deleted empty run %d %q\n", run.ID, run.Title)
+		deleted++
+	}
+
+	fmt.Printf("\n=== Cleanup Summary ===\n")
+	fmt.Printf("Runs in project: %d\n", len(runs))
+	fmt.Printf("Migrated runs checked: %d\n", checked)
+	if config.DryRun {
+		fmt.Printf("Empty runs that would be deleted: %d\n", deleted)
+	} else {
+		fmt.Printf("Empty runs deleted: %d\n", deleted)
+	}
+	fmt.Printf("Failures: %d\n", failed)
+	fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+
+	if config.DryRun {
+		fmt.Println("\nDRY RUN MODE - No actual changes were made")
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type Config struct {
+	TargetToken   string
+	TargetBaseURL string
+	TargetProject string
+	TitlePrefix   string
+	DryRun        bool
+}
+
+func loadConfig() Config {
+	config := Config{
+		TargetToken:   getEnv("QASE_TARGET_API_TOKEN", ""),
+		TargetBaseURL: getEnv("QASE_TARGET_API_BASE", "https://api.qase.io"),
+		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
+		TitlePrefix:   getEnv("QASE_CLEANUP_TITLE_PREFIX", runtitle.Prefix),
+		DryRun:        getEnv("QASE_DRY_RUN", "true") == "true",
+	}
+
+	if config.TargetToken == "" {
+		log.Fatal("QASE_TARGET_API_TOKEN is required")
+	}
+	if config.TargetProject == "" {
+		log.Fatal("QASE_TARGET_PROJECT is required")
+	}
+
+	return config
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
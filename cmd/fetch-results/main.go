@@ -2,12 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/columnar"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
 	"github.com/adrianeortiz/clone-run-multi-ws/utils"
 )
@@ -24,6 +28,11 @@ type ResultsData struct {
 }
 
 func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
 	// Load configuration
 	config := loadConfig()
 
@@ -33,12 +42,13 @@ func main() {
 
 	// Create API client
 	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	fmt.Printf("API capabilities: %s\n", qase.DetectCapabilities(srcClient, config.SourceProject))
 
 	// Fetch results after the specified date
 	fmt.Printf("\nFetching results after %s...\n", config.AfterDate.Format("2006-01-02"))
 	startTime := time.Now()
 
-	results, err := qase.GetResultsAfterDate(srcClient, config.SourceProject, config.AfterDate)
+	results, err := qase.FetchResults(srcClient, config.SourceProject, config.Fetch)
 	if err != nil {
 		log.Fatalf("Failed to fetch results: %v", err)
 	}
@@ -54,28 +64,32 @@ func main() {
 
 	fmt.Printf("Grouped into %d runs\n", len(resultsByRun))
 
-	// Create results data structure
-	resultsData := ResultsData{
-		SourceProject: config.SourceProject,
-		AfterDate:     config.AfterDate,
-		FetchTime:     time.Now(),
-		TotalResults:  len(results),
-		Results:       results,
-		ResultsByRun:  resultsByRun,
+	// Save results data: the JSON document, or one flattened row per result
+	var data []byte
+	if config.Format == columnar.FormatJSON {
+		resultsData := ResultsData{
+			SourceProject: config.SourceProject,
+			AfterDate:     config.AfterDate,
+			FetchTime:     time.Now(),
+			TotalResults:  len(results),
+			Results:       results,
+			ResultsByRun:  resultsByRun,
+		}
+		data, err = json.MarshalIndent(resultsData, "", "  ")
+	} else {
+		data, err = columnar.Encode(config.Format, columnar.Rows(results))
 	}
-
-	// Save results data
-	resultsDataJSON, err := json.MarshalIndent(resultsData, "", "  ")
 	if err != nil {
-		log.Fatalf("Failed to marshal results data: %v", err)
+		log.Fatalf("Failed to encode results data: %v", err)
 	}
 
-	if err := os.WriteFile("results-data.json", resultsDataJSON, 0644); err != nil {
+	outputPath, err := artifact.WriteProtected(artifact.Join(config.ArtifactDir, "results-data."+config.Format), data, config.Force)
+	if err != nil {
 		log.Fatalf("Failed to write results data: %v", err)
 	}
 
 	fmt.Printf("\n=== Fetch Complete ===\n")
-	fmt.Printf("Results data saved to: results-data.json\n")
+	fmt.Printf("Results data saved to: %s\n", outputPath)
 
 	// Print summary
 	fmt.Printf("\n--- Summary ---\n")
@@ -103,6 +117,10 @@ type Config struct {
 	SourceBaseURL string
 	SourceProject string
 	AfterDate     time.Time
+	Fetch         qase.FetchOptions
+	ArtifactDir   string
+	Force         bool
+	Format        string
 }
 
 func loadConfig() Config {
@@ -110,6 +128,18 @@ func loadConfig() Config {
 		SourceToken:   getEnv("QASE_SOURCE_API_TOKEN", ""),
 		SourceBaseURL: getEnv("QASE_SOURCE_API_BASE", "https://api.qase.io"),
 		SourceProject: getEnv("QASE_SOURCE_PROJECT", ""),
+		ArtifactDir:   getEnv("QASE_ARTIFACT_DIR", ""),
+		Force:         getEnv("QASE_FORCE", "false") == "true",
+	}
+
+	// Output format: the full JSON document, or flattened rows for
+	// spreadsheets and dataframes
+	flag.StringVar(&config.Format, "format", getEnv("QASE_FETCH_FORMAT", columnar.FormatJSON), "output format: json, csv or parquet (QASE_FETCH_FORMAT)")
+	flag.Parse()
+	switch config.Format {
+	case columnar.FormatJSON, columnar.FormatCSV, columnar.FormatParquet:
+	default:
+		log.Fatalf("Invalid --format: %s (use json, csv or parquet)", config.Format)
 	}
 
 	if config.SourceToken == "" {
@@ -119,14 +149,26 @@ func loadConfig() Config {
 		log.Fatal("QASE_SOURCE_PROJECT is required")
 	}
 
-	// Parse after date (Unix timestamp)
-	afterDateStr := getEnv("QASE_AFTER_DATE", "1755500400")
-	afterDate, err := utils.ParseUnixTimestamp(afterDateStr)
+	// Parse after date (required; "all" disables the cutoff)
+	afterDate, err := utils.ParseAfterDate(getEnv("QASE_AFTER_DATE", ""), getEnv("QASE_TIMEZONE", ""))
 	if err != nil {
-		log.Fatalf("Invalid QASE_AFTER_DATE format (must be Unix timestamp): %v", err)
+		log.Fatal(err)
 	}
 	config.AfterDate = afterDate
 
+	// Global results query, per-run fetching, or automatic choice
+	config.Fetch = qase.FetchOptions{Mode: getEnv("QASE_FETCH_MODE", qase.FetchGlobal), AfterDate: afterDate}
+	switch config.Fetch.Mode {
+	case qase.FetchGlobal, qase.FetchByRun, qase.FetchAuto:
+	default:
+		log.Fatalf("Invalid QASE_FETCH_MODE: %s (use global, by_run or auto)", config.Fetch.Mode)
+	}
+	runIDs, err := qase.ParseRunIDs(getEnv("QASE_FETCH_RUN_IDS", ""))
+	if err != nil {
+		log.Fatalf("Invalid QASE_FETCH_RUN_IDS: %v", err)
+	}
+	config.Fetch.RunIDs = runIDs
+
 	return config
 }
 
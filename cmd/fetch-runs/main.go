@@ -5,43 +5,62 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/utils"
 )
 
 type RunsData struct {
-	SourceProject string      `json:"source_project"`
-	AfterDate     time.Time   `json:"after_date"`
-	FetchTime     time.Time   `json:"fetch_time"`
-	TotalRuns     int         `json:"total_runs"`
-	Runs          []qase.Run  `json:"runs"`
+	SourceProject string     `json:"source_project"`
+	AfterDate     time.Time  `json:"after_date"`
+	FetchTime     time.Time  `json:"fetch_time"`
+	TotalRuns     int        `json:"total_runs"`
+	Runs          []qase.Run `json:"runs"`
 }
 
 func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
 	// Load configuration
 	config := loadConfig()
-	
+
 	fmt.Printf("=== Fetch Test Runs ===\n")
 	fmt.Printf("Source Project: %s\n", config.SourceProject)
 	fmt.Printf("After Date: %s\n", config.AfterDate.Format("2006-01-02"))
-	
+
 	// Create API client
 	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
-	
+
+	// Read-through cache shared with other subcommands using the same directory
+	if err := srcClient.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+		log.Fatalf("Failed to enable API cache: %v", err)
+	}
+
 	// Fetch runs after the specified date
 	fmt.Printf("\nFetching runs after %s...\n", config.AfterDate.Format("2006-01-02"))
 	startTime := time.Now()
-	
-	runs, err := qase.GetRuns(srcClient, config.SourceProject, config.AfterDate)
+
+	runs, err := qase.GetRuns(srcClient, config.SourceProject, qase.RunListOptions{
+		FromStartTime: config.AfterDate,
+		Status:        config.Status,
+		IncludeCases:  config.IncludeCases,
+	})
 	if err != nil {
 		log.Fatalf("Failed to fetch runs: %v", err)
 	}
-	
+
 	fetchDuration := time.Since(startTime)
 	fmt.Printf("Fetched %d runs in %v\n", len(runs), fetchDuration)
-	
+
 	// Create runs data structure
 	runsData := RunsData{
 		SourceProject: config.SourceProject,
@@ -50,25 +69,26 @@ func main() {
 		TotalRuns:     len(runs),
 		Runs:          runs,
 	}
-	
+
 	// Save runs data
 	runsDataJSON, err := json.MarshalIndent(runsData, "", "  ")
 	if err != nil {
 		log.Fatalf("Failed to marshal runs data: %v", err)
 	}
-	
-	if err := os.WriteFile("runs-data.json", runsDataJSON, 0644); err != nil {
+
+	outputPath, err := artifact.WriteProtected(artifact.Join(config.ArtifactDir, "runs-data.json"), runsDataJSON, config.Force)
+	if err != nil {
 		log.Fatalf("Failed to write runs data: %v", err)
 	}
-	
+
 	fmt.Printf("\n=== Fetch Complete ===\n")
-	fmt.Printf("Runs data saved to: runs-data.json\n")
-	
+	fmt.Printf("Runs data saved to: %s\n", outputPath)
+
 	// Print summary
 	fmt.Printf("\n--- Summary ---\n")
 	fmt.Printf("Total runs found: %d\n", len(runs))
 	fmt.Printf("Fetch time: %v\n", fetchDuration)
-	
+
 	if len(runs) > 0 {
 		fmt.Printf("\n--- Sample Runs ---\n")
 		for i, run := range runs {
@@ -76,8 +96,8 @@ func main() {
 				fmt.Printf("... and %d more runs\n", len(runs)-5)
 				break
 			}
-			fmt.Printf("Run %d: %s (ID: %d, Created: %s)\n", 
-				i+1, run.Title, run.ID, run.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Run %d: %s (ID: %d, Started: %s, Status: %s, Cases: %d)\n",
+				i+1, run.Title, run.ID, run.StartTime.Format("2006-01-02 15:04:05"), run.StatusText, len(run.Cases))
 		}
 	}
 }
@@ -87,6 +107,12 @@ type Config struct {
 	SourceBaseURL string
 	SourceProject string
 	AfterDate     time.Time
+	Status        []string
+	IncludeCases  bool
+	ArtifactDir   string
+	Force         bool
+	CacheDir      string
+	CacheTTL      time.Duration
 }
 
 func loadConfig() Config {
@@ -94,23 +120,45 @@ func loadConfig() Config {
 		SourceToken:   getEnv("QASE_SOURCE_API_TOKEN", ""),
 		SourceBaseURL: getEnv("QASE_SOURCE_API_BASE", "https://api.qase.io"),
 		SourceProject: getEnv("QASE_SOURCE_PROJECT", ""),
+		IncludeCases:  getEnv("QASE_RUN_INCLUDE_CASES", "false") == "true",
+		ArtifactDir:   getEnv("QASE_ARTIFACT_DIR", ""),
+		Force:         getEnv("QASE_FORCE", "false") == "true",
 	}
-	
+
 	if config.SourceToken == "" {
 		log.Fatal("QASE_SOURCE_API_TOKEN is required")
 	}
 	if config.SourceProject == "" {
 		log.Fatal("QASE_SOURCE_PROJECT is required")
 	}
-	
-	// Parse after date
-	afterDateStr := getEnv("QASE_AFTER_DATE", "2025-08-18T00:00:00Z")
-	afterDate, err := time.Parse(time.RFC3339, afterDateStr)
+
+	// Parse after date (required; "all" disables the cutoff)
+	afterDate, err := utils.ParseAfterDate(getEnv("QASE_AFTER_DATE", ""), getEnv("QASE_TIMEZONE", ""))
 	if err != nil {
-		log.Fatalf("Invalid QASE_AFTER_DATE format: %v", err)
+		log.Fatal(err)
 	}
 	config.AfterDate = afterDate
-	
+
+	// Optional run status filter (comma-separated: active, complete, abort)
+	if statusStr := getEnv("QASE_RUN_STATUS", ""); statusStr != "" {
+		for _, status := range strings.Split(statusStr, ",") {
+			status = strings.TrimSpace(status)
+			switch status {
+			case qase.RunStatusActive, qase.RunStatusComplete, qase.RunStatusAbort:
+				config.Status = append(config.Status, status)
+			default:
+				log.Fatalf("Invalid QASE_RUN_STATUS value %q (allowed: active, complete, abort)", status)
+			}
+		}
+	}
+
+	config.CacheDir = getEnv("QASE_CACHE_DIR", "")
+	cacheTTL, err := strconv.Atoi(getEnv("QASE_CACHE_TTL", "300"))
+	if err != nil || cacheTTL < 0 {
+		log.Fatalf("Invalid QASE_CACHE_TTL: %s", getEnv("QASE_CACHE_TTL", ""))
+	}
+	config.CacheTTL = time.Duration(cacheTTL) * time.Second
+
 	return config
 }
 
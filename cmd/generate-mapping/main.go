@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	fmt.Printf("=== Generate Mapping ===\n")
+	fmt.Printf("Source Project: %s\n", config.SourceProject)
+	fmt.Printf("Target Project: %s\n", config.TargetProject)
+	fmt.Printf("Output: %s\n", config.OutputFile)
+
+	// Create API clients
+	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+
+	// Read-through cache shared with other subcommands using the same directory
+	for _, client := range []*api.Client{srcClient, tgtClient} {
+		if err := client.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+			log.Fatalf("Failed to enable API cache: %v", err)
+		}
+	}
+
+	startTime := time.Now()
+
+	// Fetch cases and suites from both projects
+	fmt.Printf("\n--- Fetching Cases and Suites ---\n")
+	srcCases, err := qase.GetCases(srcClient, config.SourceProject, qase.CaseListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to fetch source cases: %v", err)
+	}
+	srcSuites, err := qase.GetSuites(srcClient, config.SourceProject)
+	if err != nil {
+		log.Fatalf("Failed to fetch source suites: %v", err)
+	}
+
+	tgtCases, err := qase.GetCases(tgtClient, config.TargetProject, qase.CaseListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to fetch target cases: %v", err)
+	}
+	tgtSuites, err := qase.GetSuites(tgtClient, config.TargetProject)
+	if err != nil {
+		log.Fatalf("Failed to fetch target suites: %v", err)
+	}
+
+	// Auto-match cases by title and suite
+	fmt.Printf("\n--- Matching Cases ---\n")
+	suggestions := mapping.Suggest(
+		mapping.NewCaseInfos(srcCases, srcSuites),
+		mapping.NewCaseInfos(tgtCases, tgtSuites),
+		config.MinFuzzy,
+	)
+
+	if err := writeProposedMapping(config.OutputFile, suggestions); err != nil {
+		log.Fatalf("Failed to write proposed mapping: %v", err)
+	}
+
+	// Print summary
+	counts := make(map[string]int)
+	for _, s := range suggestions {
+		counts[s.MatchType]++
+	}
+
+	fmt.Printf("\n=== Mapping Proposal Complete ===\n")
+	fmt.Printf("Proposed mapping saved to: %s\n", config.OutputFile)
+	fmt.Printf("Source cases: %d\n", len(srcCases))
+	fmt.Printf("Matched by title and suite: %d\n", counts[mapping.MatchTitleAndSuite])
+	fmt.Printf("Matched by unique title: %d\n", counts[mapping.MatchTitleUnique])
+	fmt.Printf("Matched by ambiguous title (review): %d\n", counts[mapping.MatchTitleAmbig])
+	fmt.Printf("Fuzzy matches (review): %d\n", counts[mapping.MatchFuzzy])
+	fmt.Printf("Unmatched (complete manually): %d\n", counts[mapping.MatchNone])
+	fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+	fmt.Printf("\nReview the file, fill in target_case_id for unmatched rows, then use it with QASE_MATCH_MODE=csv\n")
+}
+
+// writeProposedMapping writes matched rows (by confidence) followed by the
+// unmatched section with empty target IDs for humans to complete
+func writeProposedMapping(path string, suggestions []mapping.Suggestion) error {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		mi, mj := suggestions[i].TargetID != 0, suggestions[j].TargetID != 0
+		if mi != mj {
+			return mi
+		}
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].SourceID < suggestions[j].SourceID
+	})
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"source_case_id", "target_case_id", "confidence", "match_type",
+		"source_title", "target_title", "source_suite", "target_suite"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, s := range suggestions {
+		targetID := ""
+		if s.TargetID != 0 {
+			targetID = strconv.Itoa(s.TargetID)
+		}
+		row := []string{
+			strconv.Itoa(s.SourceID),
+			targetID,
+			strconv.FormatFloat(s.Confidence, 'f', 2, 64),
+			s.MatchType,
+			s.SourceTitle,
+			s.TargetTitle,
+			s.SourceSuite,
+			s.TargetSuite,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return artifact.Write(path, buf.Bytes())
+}
+
+type Config struct {
+	SourceToken   string
+	SourceBaseURL string
+	TargetToken   string
+	TargetBaseURL string
+	SourceProject string
+	TargetProject string
+	OutputFile    string
+	MinFuzzy      float64
+	CacheDir      string
+	CacheTTL      time.Duration
+}
+
+func loadConfig() Config {
+	config := Config{
+		SourceToken:   getEnv("QASE_SOURCE_API_TOKEN", ""),
+		SourceBaseURL: getEnv("QASE_SOURCE_API_BASE", "https://api.qase.io"),
+		TargetToken:   getEnv("QASE_TARGET_API_TOKEN", ""),
+		TargetBaseURL: getEnv("QASE_TARGET_API_BASE", "https://api.qase.io"),
+		SourceProject: getEnv("QASE_SOURCE_PROJECT", ""),
+		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
+		OutputFile:    getEnv("QASE_MAPPING_OUT", "mapping.proposed.csv"),
+	}
+
+	if config.SourceToken == "" {
+		log.Fatal("QASE_SOURCE_API_TOKEN is required")
+	}
+	if config.TargetToken == "" {
+		log.Fatal("QASE_TARGET_API_TOKEN is required")
+	}
+	if config.SourceProject == "" {
+		log.Fatal("QASE_SOURCE_PROJECT is required")
+	}
+	if config.TargetProject == "" {
+		log.Fatal("QASE_TARGET_PROJECT is required")
+	}
+
+	minFuzzy, err := strconv.ParseFloat(getEnv("QASE_MATCH_MIN_SIMILARITY", "0.6"), 64)
+	if err != nil || minFuzzy < 0 || minFuzzy > 1 {
+		log.Fatalf("Invalid QASE_MATCH_MIN_SIMILARITY (must be between 0 and 1): %s", getEnv("QASE_MATCH_MIN_SIMILARITY", ""))
+	}
+	config.MinFuzzy = minFuzzy
+
+	config.CacheDir = getEnv("QASE_CACHE_DIR", "")
+	cacheTTL, err := strconv.Atoi(getEnv("QASE_CACHE_TTL", "300"))
+	if err != nil || cacheTTL < 0 {
+		log.Fatalf("Invalid QASE_CACHE_TTL: %s", getEnv("QASE_CACHE_TTL", ""))
+	}
+	config.CacheTTL = time.Duration(cacheTTL) * time.Second
+
+	return config
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -4,12 +4,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/errclass"
 	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/notify"
+	"github.com/adrianeortiz/clone-run-multi-ws/profiling"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/runtitle"
+	"github.com/adrianeortiz/clone-run-multi-ws/settings"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+	"github.com/adrianeortiz/clone-run-multi-ws/triage"
 	"github.com/adrianeortiz/clone-run-multi-ws/utils"
 )
 
@@ -32,12 +42,43 @@ type MigrationResults struct {
 	RunsDuration      time.Duration `json:"runs_duration"`
 	ResultsDuration   time.Duration `json:"results_duration"`
 	MigrationDuration time.Duration `json:"migration_duration"`
+
+	// Failures by class (auth, rate_limit, validation, mapping, network, server, other)
+	ErrorCounts map[errclass.Class]int `json:"error_counts,omitempty"`
+
+	// Source cases with durations over the maximum, which were capped or dropped
+	LongDurationCases []int `json:"long_duration_cases,omitempty"`
+
+	// Migrated runs with links to the source and target runs in the Qase app
+	Runs []MigratedRun `json:"runs,omitempty"`
+}
+
+// MigratedRun links a source run to the target run it was migrated into
+type MigratedRun struct {
+	SourceRunID int    `json:"source_run_id"`
+	SourceURL   string `json:"source_url"`
+	TargetRunID int    `json:"target_run_id,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Results     int    `json:"results"`
+	Error       string `json:"error,omitempty"`
 }
 
 func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
 	// Load configuration
 	config := loadConfig()
 
+	// Refuse protected target projects before doing any work
+	if !config.DryRun {
+		if err := qase.CheckWritable(config.TargetProject, qase.ParseProjectList(settings.Get("QASE_PROTECTED_PROJECTS", "")), settings.Get(qase.OverrideEnv, "false") == "true"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	fmt.Printf("=== Migrate Data ===\n")
 	fmt.Printf("Source Project: %s\n", config.SourceProject)
 	fmt.Printf("Target Project: %s\n", config.TargetProject)
@@ -48,7 +89,29 @@ func main() {
 
 	// Create API clients
 	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	srcClient.SetTokens(api.ParseTokenList(settings.Get("QASE_SOURCE_API_TOKENS", "")), config.TokenRPM)
 	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+	tgtClient.MaxPayloadBytes = config.MaxPayloadBytes
+	tgtClient.SetTokens(api.ParseTokenList(settings.Get("QASE_TARGET_API_TOKENS", "")), config.TokenRPM)
+
+	// Read-through cache shared with other subcommands using the same directory
+	for _, client := range []*api.Client{srcClient, tgtClient} {
+		if err := client.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+			log.Fatalf("Failed to enable API cache: %v", err)
+		}
+	}
+
+	// A dry run must never write to the target workspace
+	if config.DryRun {
+		tgtClient.SetReadOnly()
+	}
+
+	// Probe optional API features once instead of falling back per request
+	fmt.Printf("Source API capabilities: %s\n", qase.DetectCapabilities(srcClient, config.SourceProject))
+	fmt.Printf("Target API capabilities: %s\n", qase.DetectCapabilities(tgtClient, config.TargetProject))
+
+	// Live heap, goroutine and CPU profiles for diagnosing long migrations
+	profiling.Start(settings.Get("QASE_PPROF_ADDR", ""))
 
 	startTime := time.Now()
 
@@ -98,27 +161,31 @@ func main() {
 		// Build mapping based on match mode
 		// First, we need to fetch cases from both projects
 		fmt.Printf("Fetching source cases...\n")
-		srcCases, err := qase.GetCases(srcClient, config.SourceProject)
+		srcCases, err := qase.GetCases(srcClient, config.SourceProject, qase.CaseListOptions{})
 		if err != nil {
 			log.Fatalf("Failed to fetch source cases: %v", err)
 		}
 
 		fmt.Printf("Fetching target cases...\n")
-		tgtCases, err := qase.GetCases(tgtClient, config.TargetProject)
+		tgtCases, err := qase.GetCases(tgtClient, config.TargetProject, qase.CaseListOptions{})
 		if err != nil {
 			log.Fatalf("Failed to fetch target cases: %v", err)
 		}
 
 		// Build mapping
 		switch config.MatchMode {
-		case "custom_field":
-			fmt.Printf("Building case mapping using custom field %d\n", config.CFID)
-			caseMapping, err = mapping.Build(mapping.ModeCF, srcCases, tgtCases, config.CFID, "")
-		case "csv":
+		case mapping.ModeCF:
+			if config.CustomFieldName != "" {
+				config.CustomFieldID, err = qase.ResolveCustomFieldID(tgtClient, config.TargetProject, config.CustomFieldName)
+				if err != nil {
+					log.Fatalf("Failed to resolve QASE_CF_NAME: %v", err)
+				}
+			}
+			fmt.Printf("Building case mapping using custom field %d\n", config.CustomFieldID)
+			caseMapping, err = mapping.Build(mapping.ModeCF, srcCases, tgtCases, config.CustomFieldID, config.CFValueRules, "")
+		case mapping.ModeCSV:
 			fmt.Printf("Building case mapping from CSV file\n")
-			caseMapping, err = mapping.Build(mapping.ModeCSV, srcCases, tgtCases, 0, config.CSVFile)
-		default:
-			log.Fatalf("Unknown match mode: %s", config.MatchMode)
+			caseMapping, err = mapping.Build(mapping.ModeCSV, srcCases, tgtCases, 0, nil, config.MappingCSV)
 		}
 
 		if err != nil {
@@ -137,24 +204,67 @@ func main() {
 	totalSkipped := 0
 	successfulRuns := 0
 	failedRuns := 0
+	errorSummary := errclass.NewSummary()
+	var migratedRuns []MigratedRun
 
-	for runID, runResults := range resultsByRun {
-		// Create run details from results data
-		runTitle := fmt.Sprintf("Migrated Run %d", runID)
-		runDescription := fmt.Sprintf("Migrated run with %d results from source workspace", len(runResults))
+	// Long and implausible durations are summarized once instead of warned per result
+	longCount, longest, implausibleDurations := 0, 0, 0
+	longCases := make(map[int]bool)
 
-		// Use the first result's end time to create a meaningful run title
-		if len(runResults) > 0 {
-			if endTime, err := time.Parse("2006-01-02T15:04:05-07:00", runResults[0].EndTime); err == nil {
-				runTitle = fmt.Sprintf("Migrated Run %d (%s)", runID, endTime.Format("2006-01-02 15:04"))
+	// Existing target runs are found in one listing instead of one per run
+	var targetRuns *qase.RunIndex
+	if config.Idempotent && !config.DryRun && len(resultsByRun) > 0 {
+		targetRuns, err = qase.BuildRunIndex(tgtClient, config.TargetProject)
+		if err != nil {
+			log.Fatalf("Failed to index target runs: %v", err)
+		}
+	}
+
+	// Custom field values and the milestone set on every created target run
+	var runOptions qase.RunOptions
+	if !config.DryRun {
+		placeholders := strings.NewReplacer("{source_project}", config.SourceProject, "{target_project}", config.TargetProject)
+		values := make(map[string]string, len(config.RunFields))
+		for field, value := range config.RunFields {
+			values[field] = placeholders.Replace(value)
+		}
+		runOptions.Fields, err = qase.ResolveRunCustomFields(tgtClient, config.TargetProject, values)
+		if err != nil {
+			log.Fatalf("Failed to resolve QASE_RUN_CUSTOM_FIELDS: %v", err)
+		}
+		if config.Milestone != "" {
+			description := fmt.Sprintf("Runs migrated from project %s", config.SourceProject)
+			runOptions.MilestoneID, err = qase.GetOrCreateMilestone(tgtClient, config.TargetProject, placeholders.Replace(config.Milestone), description)
+			if err != nil {
+				log.Fatal(err)
 			}
 		}
+	}
+
+	for runID, runResults := range resultsByRun {
+		// Create run details from results data
+		runMarker := runtitle.Marker(config.SourceProject, runtitle.RunKey(runID))
+		runTitle, runDescription := runtitle.ForSourceRun(runID, runResults)
 
 		fmt.Printf("\nProcessing run %d: %s (%d results)\n", runID, runTitle, len(runResults))
+		sourceURL := qase.RunURL(config.SourceBaseURL, config.SourceProject, runID)
+		fmt.Printf("Source run: %s\n", sourceURL)
 
 		// Transform results to target case IDs
-		bulkItems, skipped := transformResults(runResults, caseMapping, config.StatusMap)
+		bulkItems, skipped, durations := transform.Results(runResults, caseMapping, config.Transform)
 		totalSkipped += skipped
+		for _, long := range durations.Long {
+			longCount++
+			if long.Seconds > longest {
+				longest = long.Seconds
+			}
+			longCases[long.CaseID] = true
+		}
+		implausibleDurations += durations.Implausible
+		if skipped > 0 {
+			errorSummary.RecordClass(errclass.ClassMapping, skipped,
+				fmt.Sprintf("run %d: %d results had no mapped target case", runID, skipped))
+		}
 
 		fmt.Printf("Prepared %d results for posting, skipped %d unmapped results\n", len(bulkItems), skipped)
 
@@ -177,9 +287,10 @@ func main() {
 		if config.Idempotent {
 			// Create or get existing target run (idempotent)
 			fmt.Printf("Creating or finding target run: %s\n", runTitle)
-			tgtRun, err = qase.CreateOrGetRun(tgtClient, config.TargetProject, runTitle, runDescription)
+			tgtRun, err = qase.CreateOrGetIndexedRun(tgtClient, targetRuns, config.TargetProject, runMarker, runTitle, runDescription, runOptions)
 			if err != nil {
 				fmt.Printf("Failed to create/get target run for %s: %v\n", runTitle, err)
+				errorSummary.Record(err)
 				failedRuns++
 				continue
 			}
@@ -191,6 +302,7 @@ func main() {
 				hasResults, err := qase.CheckRunHasResults(tgtClient, config.TargetProject, tgtRun.ID)
 				if err != nil {
 					fmt.Printf("Failed to check existing results for run %d: %v\n", tgtRun.ID, err)
+					errorSummary.Record(err)
 					failedRuns++
 					continue
 				}
@@ -201,6 +313,7 @@ func main() {
 					bulkItems, err = qase.FilterNewResults(tgtClient, config.TargetProject, tgtRun.ID, bulkItems)
 					if err != nil {
 						fmt.Printf("Failed to filter existing results for run %d: %v\n", tgtRun.ID, err)
+						errorSummary.Record(err)
 						failedRuns++
 						continue
 					}
@@ -221,9 +334,10 @@ func main() {
 		} else {
 			// Non-idempotent mode: always create new runs
 			fmt.Printf("Creating target run: %s\n", runTitle)
-			tgtRun, err = qase.CreateRun(tgtClient, config.TargetProject, runTitle, runDescription)
+			tgtRun, err = qase.CreateRunWithOptions(tgtClient, config.TargetProject, runTitle, qase.WithRunMarker(runDescription, runMarker), runOptions)
 			if err != nil {
 				fmt.Printf("Failed to create target run for %s: %v\n", runTitle, err)
+				errorSummary.Record(err)
 				failedRuns++
 				continue
 			}
@@ -231,13 +345,22 @@ func main() {
 			// Post all results to target run
 			fmt.Printf("Posting %d results to target run %d...\n", len(bulkItems), tgtRun.ID)
 		}
+		targetURL := qase.RunURL(config.TargetBaseURL, config.TargetProject, tgtRun.ID)
 		if err := qase.PostBulkResults(tgtClient, config.TargetProject, tgtRun.ID, bulkItems, config.BulkSize); err != nil {
-			fmt.Printf("Failed to post results to run %d: %v\n", tgtRun.ID, err)
+			fmt.Printf("Failed to post results to run %d (%s): %v\n", tgtRun.ID, targetURL, err)
+			errorSummary.Record(err)
 			failedRuns++
+			migratedRuns = append(migratedRuns, MigratedRun{
+				SourceRunID: runID, SourceURL: sourceURL, TargetRunID: tgtRun.ID, TargetURL: targetURL, Error: err.Error(),
+			})
 			continue
 		}
 
 		fmt.Printf("Successfully migrated run %d -> %d\n", runID, tgtRun.ID)
+		fmt.Printf("  source: %s\n  target: %s\n", sourceURL, targetURL)
+		migratedRuns = append(migratedRuns, MigratedRun{
+			SourceRunID: runID, SourceURL: sourceURL, TargetRunID: tgtRun.ID, TargetURL: targetURL, Results: len(bulkItems),
+		})
 		successfulRuns++
 		totalResults += len(bulkItems)
 	}
@@ -261,6 +384,9 @@ func main() {
 		RunsDuration:      resultsDuration,
 		ResultsDuration:   resultsDuration,
 		MigrationDuration: migrationDuration,
+		ErrorCounts:       errorSummary.Counts(),
+		LongDurationCases: sortedCases(longCases),
+		Runs:              migratedRuns,
 	}
 
 	// Save migration results
@@ -269,9 +395,11 @@ func main() {
 		log.Fatalf("Failed to marshal migration results: %v", err)
 	}
 
-	if err := os.WriteFile("migration-results.json", resultsJSON, 0644); err != nil {
+	resultsPath, err := artifact.WriteProtected(artifact.Join(config.ArtifactDir, "migration-results.json"), resultsJSON, config.Force)
+	if err != nil {
 		log.Fatalf("Failed to write migration results: %v", err)
 	}
+	fmt.Printf("Migration results saved to: %s\n", resultsPath)
 
 	// Print summary
 	fmt.Printf("\n=== Migration Complete ===\n")
@@ -280,131 +408,111 @@ func main() {
 	fmt.Printf("Failed migrations: %d\n", failedRuns)
 	fmt.Printf("Total results migrated: %d\n", totalResults)
 	fmt.Printf("Total results skipped: %d\n", totalSkipped)
+	verb := "capped"
+	if config.Transform.Durations.Drop {
+		verb = "dropped"
+	}
+	if summary := triage.DurationSummary(verb, longCount, longest); summary != "" {
+		fmt.Printf("Warning: %s (maximum %d seconds), case IDs in the migration results\n", summary, config.Transform.Durations.Max())
+	}
+	if implausibleDurations > 0 {
+		fmt.Printf("Warning: %d results had a zero or negative duration and were posted without time\n", implausibleDurations)
+	}
 	fmt.Printf("Total execution time: %v\n", totalDuration)
 
+	errorSummary.Print()
+	api.PrintLatencyStats()
+
 	if config.DryRun {
 		fmt.Println("\nDRY RUN MODE - No actual changes were made")
 	} else {
 		fmt.Println("\nMigration completed successfully!")
 	}
-}
-
-func transformResults(results []qase.Result, caseMapping map[int]int, statusMap map[string]string) ([]qase.BulkItem, int) {
-	var bulkItems []qase.BulkItem
-	skipped := 0
-
-	// Maximum time allowed by Qase API (1 year in seconds)
-	const maxTimeSeconds = 31536000
-
-	for _, result := range results {
-		// Map case ID
-		targetCaseID, exists := caseMapping[result.CaseID]
-		if !exists {
-			skipped++
-			continue
-		}
 
-		// Map status if needed
-		status := result.Status
-		if mappedStatus, exists := statusMap[result.Status]; exists {
-			status = mappedStatus
+	// Post summary to the migration ticket if configured
+	if config.Jira.Enabled() {
+		summary := notify.Summary{
+			SourceProject:  config.SourceProject,
+			TargetProject:  config.TargetProject,
+			TotalRuns:      len(resultsByRun),
+			SuccessfulRuns: successfulRuns,
+			FailedRuns:     failedRuns,
+			TotalResults:   totalResults,
+			TotalSkipped:   totalSkipped,
+			Duration:       totalDuration,
+			DryRun:         config.DryRun,
+			ReportURL:      config.ReportURL,
 		}
-
-		// Convert time from milliseconds to seconds and cap at maximum allowed
-		var timeSeconds *int
-		if result.TimeSpentMs > 0 {
-			timeInSeconds := result.TimeSpentMs / 1000
-			if timeInSeconds > maxTimeSeconds {
-				fmt.Printf("Warning: Capping time for case %d from %d seconds to %d seconds (max allowed)\n", 
-					result.CaseID, timeInSeconds, maxTimeSeconds)
-				timeInSeconds = maxTimeSeconds
-			}
-			timeSeconds = &timeInSeconds
+		if err := notify.PostJiraComment(config.Jira, summary); err != nil {
+			fmt.Printf("Warning: Failed to post Jira comment: %v\n", err)
 		}
-
-		bulkItem := qase.BulkItem{
-			CaseID:  targetCaseID,
-			Status:  status,
-			Comment: result.Comment,
-			Time:    timeSeconds,
-		}
-
-		bulkItems = append(bulkItems, bulkItem)
 	}
+}
 
-	return bulkItems, skipped
+// sortedCases returns the case IDs of a set in ascending order
+func sortedCases(cases map[int]bool) []int {
+	ids := make([]int, 0, len(cases))
+	for id := range cases {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
 }
 
 type Config struct {
-	SourceToken   string
-	SourceBaseURL string
-	TargetToken   string
-	TargetBaseURL string
-	SourceProject string
-	TargetProject string
-	AfterDate     time.Time
-	MatchMode     string
-	CFID          int
-	CSVFile       string
-	DryRun        bool
-	BulkSize      int
-	StatusMap     map[string]string
-	Idempotent    bool
+	settings.Workspaces
+	settings.Mapping
+	settings.Client
+	settings.Posting
+	AfterDate   time.Time
+	RunFields   map[string]string
+	Milestone   string
+	Transform   transform.Options
+	Idempotent  bool
+	TokenRPM    int
+	Jira        notify.JiraConfig
+	ReportURL   string
+	ArtifactDir string
+	Force       bool
 }
 
+// loadConfig loads the workspaces, case mapping and client settings shared
+// with the main migration, and the options of this command
 func loadConfig() Config {
+	var problems settings.Problems
 	config := Config{
-		SourceToken:   getEnv("QASE_SOURCE_API_TOKEN", ""),
-		SourceBaseURL: getEnv("QASE_SOURCE_API_BASE", "https://api.qase.io"),
-		TargetToken:   getEnv("QASE_TARGET_API_TOKEN", ""),
-		TargetBaseURL: getEnv("QASE_TARGET_API_BASE", "https://api.qase.io"),
-		SourceProject: getEnv("QASE_SOURCE_PROJECT", ""),
-		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
-		MatchMode:     getEnv("QASE_MATCH_MODE", "custom_field"),
-		CSVFile:       getEnv("QASE_CSV_FILE", "mapping.csv"),
-		DryRun:        getEnv("QASE_DRY_RUN", "false") == "true",
-		BulkSize:      100,
-		StatusMap:     make(map[string]string),
-		Idempotent:    getEnv("QASE_IDEMPOTENT", "true") == "true",
-	}
-
-	if config.SourceToken == "" {
-		log.Fatal("QASE_SOURCE_API_TOKEN is required")
-	}
-	if config.TargetToken == "" {
-		log.Fatal("QASE_TARGET_API_TOKEN is required")
+		Workspaces:  settings.LoadWorkspaces(&problems),
+		Mapping:     settings.LoadMapping(&problems, true),
+		Client:      settings.LoadClient(&problems),
+		Posting:     settings.LoadPosting(&problems),
+		Idempotent:  settings.Get("QASE_IDEMPOTENT", "true") == "true",
+		TokenRPM:    problems.Int("QASE_TOKEN_RPM", 0),
+		Jira:        notify.LoadJiraConfig(),
+		ReportURL:   settings.Get("QASE_REPORT_URL", ""),
+		ArtifactDir: settings.Get("QASE_ARTIFACT_DIR", ""),
+		Force:       settings.Get("QASE_FORCE", "false") == "true",
+		Milestone:   settings.Get("QASE_MILESTONE", ""),
 	}
-	if config.SourceProject == "" {
-		log.Fatal("QASE_SOURCE_PROJECT is required")
-	}
-	if config.TargetProject == "" {
-		log.Fatal("QASE_TARGET_PROJECT is required")
+	if config.MatchMode != mapping.ModeCF && config.MatchMode != mapping.ModeCSV {
+		problems.Add(fmt.Errorf("unsupported QASE_MATCH_MODE for migrate-data: %s (use %s or %s)", config.MatchMode, mapping.ModeCF, mapping.ModeCSV))
 	}
 
-	// Parse after date (Unix timestamp)
-	afterDateStr := getEnv("QASE_AFTER_DATE", "1755500400")
-	afterDate, err := utils.ParseUnixTimestamp(afterDateStr)
+	// Parse after date (required; "all" disables the cutoff)
+	var err error
+	config.AfterDate, err = utils.ParseAfterDate(settings.Get("QASE_AFTER_DATE", ""), settings.Get("QASE_TIMEZONE", ""))
+	problems.Add(err)
+
+	config.RunFields, err = qase.ParseFieldValues(settings.Get("QASE_RUN_CUSTOM_FIELDS", ""))
 	if err != nil {
-		log.Fatalf("Invalid QASE_AFTER_DATE format (must be Unix timestamp): %v", err)
-	}
-	config.AfterDate = afterDate
-
-	// Parse CF ID
-	if config.MatchMode == "custom_field" {
-		cfIDStr := getEnv("QASE_CF_ID", "2")
-		if cfIDStr != "" {
-			if _, err := fmt.Sscanf(cfIDStr, "%d", &config.CFID); err != nil {
-				log.Fatalf("Invalid QASE_CF_ID: %s", cfIDStr)
-			}
-		}
+		problems.Add(fmt.Errorf("invalid QASE_RUN_CUSTOM_FIELDS: %w", err))
 	}
 
-	return config
-}
+	// Status mapping, comment normalization and durations, shared with the main migration
+	config.Transform, err = transform.LoadOptions()
+	problems.Add(err)
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if err := problems.Err(); err != nil {
+		log.Fatal(err)
 	}
-	return defaultValue
+	return config
 }
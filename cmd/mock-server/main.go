@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// ResultsData matches the results-data.json written by fetch-results, so a
+// generated fixture can be replayed with simulate
+type ResultsData struct {
+	SourceProject string        `json:"source_project"`
+	AfterDate     time.Time     `json:"after_date"`
+	FetchTime     time.Time     `json:"fetch_time"`
+	TotalResults  int           `json:"total_results"`
+	Results       []qase.Result `json:"results"`
+}
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	fmt.Printf("=== Mock Qase Server ===\n")
+	fmt.Printf("Generating fixture (seed %d)...\n", config.Fixture.Seed)
+	startTime := time.Now()
+	fixture := mockserver.Generate(config.Fixture)
+	fmt.Printf("Generated in %v\n", time.Since(startTime))
+	fmt.Println(fixture.Summary())
+
+	if config.Output != "" {
+		data, err := json.MarshalIndent(ResultsData{
+			SourceProject: fixture.SourceProject,
+			AfterDate:     config.Fixture.Since,
+			FetchTime:     time.Now(),
+			TotalResults:  len(fixture.Results),
+			Results:       fixture.Results,
+		}, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal fixture: %v", err)
+		}
+		path, err := artifact.WriteProtected(config.Output, data, config.Force)
+		if err != nil {
+			log.Fatalf("Failed to write fixture: %v", err)
+		}
+		fmt.Printf("Fixture results saved to: %s\n", path)
+	}
+
+	if config.Addr == "" {
+		return
+	}
+
+	server := mockserver.New()
+	server.Load(fixture)
+	if config.RateLimit > 0 {
+		server.SetRateLimit(config.RateLimit, config.RateWindow)
+		fmt.Printf("Rate limit: %d requests per %v\n", config.RateLimit, config.RateWindow)
+	}
+	if config.PageFault != "" {
+		server.SetPageFault(config.PageFault)
+		fmt.Printf("List pagination fault: %s\n", config.PageFault)
+	}
+	if len(config.ReadOnlyTokens) > 0 {
+		server.SetReadOnlyTokens(config.ReadOnlyTokens)
+		fmt.Printf("Read-only tokens: %d\n", len(config.ReadOnlyTokens))
+	}
+	if err := server.Start(config.Addr); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("\nServing %s (source) and %s (target) on %s\n", fixture.SourceProject, fixture.TargetProject, server.URL())
+	fmt.Printf("Point the migration at it with:\n")
+	fmt.Printf("  QASE_SOURCE_API_BASE=%s QASE_TARGET_API_BASE=%s\n", server.URL(), server.URL())
+	fmt.Printf("  QASE_SOURCE_PROJECT=%s QASE_TARGET_PROJECT=%s QASE_CF_ID=%d\n", fixture.SourceProject, fixture.TargetProject, fixture.CustomFieldID)
+	fmt.Printf("  QASE_AFTER_DATE=%s (any token is accepted)\n", config.Fixture.Since.Format("2006-01-02"))
+	fmt.Printf("Press Ctrl+C to stop\n")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	server.Close()
+
+	posted := 0
+	posts := server.Posts()
+	for _, post := range posts {
+		posted += len(post.Results)
+	}
+	fmt.Printf("\n=== Mock Server Stopped ===\n")
+	fmt.Printf("Runs created in %s: %d\n", fixture.TargetProject, len(server.Runs(fixture.TargetProject)))
+	fmt.Printf("Bulk requests received: %d (%d results)\n", len(posts), posted)
+	if config.RateLimit > 0 {
+		fmt.Printf("Requests rejected by the rate limit: %d\n", server.Throttled())
+	}
+}
+
+type Config struct {
+	Addr       string
+	Output     string
+	Force      bool
+	Fixture    mockserver.FixtureOptions
+	RateLimit  int
+	RateWindow time.Duration
+	PageFault  string
+
+	ReadOnlyTokens []string
+}
+
+func loadConfig() Config {
+	config := Config{
+		Output: getEnv("QASE_FIXTURE_OUT", ""),
+		Force:  getEnv("QASE_FORCE", "false") == "true",
+		Fixture: mockserver.FixtureOptions{
+			SourceProject: getEnv("QASE_SOURCE_PROJECT", "SRC"),
+			TargetProject: getEnv("QASE_TARGET_PROJECT", "TGT"),
+			Cases:         getInt("QASE_FIXTURE_CASES", 1000),
+			Runs:          getInt("QASE_FIXTURE_RUNS", 50),
+			ResultsPerRun: getInt("QASE_FIXTURE_RESULTS_PER_RUN", 200),
+			CustomFieldID: getInt("QASE_CF_ID", 1),
+			Seed:          uint64(getInt("QASE_FIXTURE_SEED", 1)),
+		},
+	}
+
+	// Serve by default; with only an output file, generate and exit
+	defaultAddr := "127.0.0.1:8088"
+	if config.Output != "" {
+		defaultAddr = ""
+	}
+	config.Addr = getEnv("QASE_MOCK_ADDR", defaultAddr)
+
+	// Optional rate limit to exercise pacing and 429 handling
+	if getEnv("QASE_MOCK_RATE_LIMIT", "") != "" {
+		config.RateLimit = getInt("QASE_MOCK_RATE_LIMIT", 0)
+	}
+	config.RateWindow = time.Duration(getInt("QASE_MOCK_RATE_WINDOW", 60)) * time.Second
+
+	// Optional pagination fault to exercise client paging
+	config.PageFault = getEnv("QASE_MOCK_PAGE_FAULT", "")
+	switch config.PageFault {
+	case "", mockserver.PageShort, mockserver.PageIgnoreOffset, mockserver.PageHTML, mockserver.PageTruncated, mockserver.PageNoTotal:
+	default:
+		log.Fatalf("Unsupported QASE_MOCK_PAGE_FAULT: %s (use short, ignore_offset, html, truncated or no_total)", config.PageFault)
+	}
+
+	// Tokens refused on writes, to exercise permission checks
+	config.ReadOnlyTokens = api.ParseTokenList(getEnv("QASE_MOCK_READONLY_TOKENS", ""))
+
+	days := getInt("QASE_FIXTURE_DAYS", 90)
+	config.Fixture.Until = time.Now().UTC().Truncate(time.Second)
+	config.Fixture.Since = config.Fixture.Until.AddDate(0, 0, -days)
+
+	return config
+}
+
+func getInt(key string, defaultValue int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("Invalid %s: %s", key, value)
+	}
+	return n
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
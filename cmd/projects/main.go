@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// token is one configured credential and the project it is meant for
+type token struct {
+	name    string // e.g. "source token 2"
+	baseURL string
+	value   string
+	command string
+	project string // configured project code, empty when none
+}
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	if len(os.Args) < 2 || os.Args[1] != "list" {
+		fmt.Fprintf(os.Stderr, "Usage: %s list\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	tokens := configuredTokens()
+	if len(tokens) == 0 {
+		log.Fatal("No tokens configured (set QASE_SOURCE_API_TOKEN, QASE_TARGET_API_TOKEN or a token command)")
+	}
+
+	failed := 0
+	for _, t := range tokens {
+		fmt.Printf("\n=== %s (%s) at %s ===\n", t.name, t.identity(), t.baseURL)
+		client := api.NewClient(t.baseURL, t.value)
+		if t.command != "" {
+			if err := client.SetTokenProvider(api.CommandTokenProvider(t.command), 0); err != nil {
+				fmt.Printf("Failed to obtain token: %v\n", err)
+				failed++
+				continue
+			}
+		}
+
+		projects, err := qase.GetProjects(client)
+		if err != nil {
+			fmt.Printf("Failed to list projects: %v\n", err)
+			failed++
+			continue
+		}
+		if !printProjects(projects, t.project) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// printProjects prints a token's projects, marking the configured one, and
+// reports whether the configured project (if any) is among them
+func printProjects(projects []qase.Project, configured string) bool {
+	found := configured == ""
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tCODE\tTITLE\tCASES\tSUITES\tRUNS\tACTIVE RUNS")
+	for _, project := range projects {
+		mark := ""
+		if strings.EqualFold(project.Code, configured) {
+			mark = "*"
+			found = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", mark, project.Code, project.Title,
+			project.Counts.Cases, project.Counts.Suites, project.Counts.Runs.Total, project.Counts.Runs.Active)
+	}
+	w.Flush()
+
+	fmt.Printf("%d projects\n", len(projects))
+	if !found {
+		fmt.Printf("Warning: configured project %s is not visible to this token; check that it belongs to this workspace\n", configured)
+	}
+	return found
+}
+
+// configuredTokens returns the source and target tokens, pooled tokens and
+// token commands from the environment
+func configuredTokens() []token {
+	var tokens []token
+	for _, side := range []string{"source", "target"} {
+		upper := strings.ToUpper(side)
+		baseURL := getEnv("QASE_"+upper+"_API_BASE", "https://api.qase.io")
+		project := os.Getenv("QASE_" + upper + "_PROJECT")
+
+		values := api.ParseTokenList(os.Getenv("QASE_" + upper + "_API_TOKENS"))
+		if value := os.Getenv("QASE_" + upper + "_API_TOKEN"); value != "" {
+			values = append([]string{value}, values...)
+		}
+		for i, value := range values {
+			name := side + " token"
+			if len(values) > 1 {
+				name = fmt.Sprintf("%s token %d", side, i+1)
+			}
+			tokens = append(tokens, token{name: name, baseURL: baseURL, value: value, project: project})
+		}
+
+		if command := os.Getenv("QASE_" + upper + "_TOKEN_COMMAND"); command != "" {
+			tokens = append(tokens, token{name: side + " token command", baseURL: baseURL, command: command, project: project})
+		}
+	}
+	return tokens
+}
+
+// identity names a token in output by its last characters
+func (t token) identity() string {
+	if t.command != "" {
+		return "from command"
+	}
+	if len(t.value) <= 4 {
+		return "****"
+	}
+	return "..." + t.value[len(t.value)-4:]
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
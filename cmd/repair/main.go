@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/runtitle"
+	"github.com/adrianeortiz/clone-run-multi-ws/settings"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	// Refuse protected target projects before doing any work
+	if !config.DryRun {
+		if err := qase.CheckWritable(config.TargetProject, qase.ParseProjectList(settings.Get("QASE_PROTECTED_PROJECTS", "")), settings.Get(qase.OverrideEnv, "false") == "true"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("=== Repair Target Run ===\n")
+	fmt.Printf("Source Project: %s\n", config.SourceProject)
+	fmt.Printf("Target Project: %s\n", config.TargetProject)
+	fmt.Printf("Target Run: %d\n", config.TargetRunID)
+	fmt.Printf("Dry Run: %t\n", config.DryRun)
+
+	// Create API clients
+	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+	tgtClient.MaxPayloadBytes = config.MaxPayloadBytes
+
+	// Read-through cache shared with other subcommands using the same directory
+	for _, client := range []*api.Client{srcClient, tgtClient} {
+		if err := client.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+			log.Fatalf("Failed to enable API cache: %v", err)
+		}
+	}
+
+	// A dry run must never write to the target workspace
+	if config.DryRun {
+		tgtClient.SetReadOnly()
+	}
+
+	startTime := time.Now()
+
+	// Resolve the source run or date bucket the target run was migrated from
+	// by the marker in its description
+	tgtRun, err := qase.GetRunByID(tgtClient, config.TargetProject, config.TargetRunID)
+	if err != nil {
+		log.Fatalf("Failed to fetch target run %d: %v", config.TargetRunID, err)
+	}
+
+	src := runtitle.Source{Project: config.SourceProject, Key: runtitle.RunKey(config.SourceRunID), RunID: config.SourceRunID}
+	if config.SourceRunID == 0 {
+		var marker string
+		if tgtRun.Description != nil {
+			marker = qase.RunMarker(*tgtRun.Description)
+		}
+		if marker == "" {
+			log.Fatalf("Target run %d has no source marker in its description; set QASE_SOURCE_RUN", config.TargetRunID)
+		}
+		if src, err = runtitle.ParseMarker(marker); err != nil {
+			log.Fatalf("Cannot resolve the source of target run %d: %v; set QASE_SOURCE_RUN", config.TargetRunID, err)
+		}
+		if src.Project != config.SourceProject {
+			log.Fatalf("Target run %d was migrated from project %s, not %s", config.TargetRunID, src.Project, config.SourceProject)
+		}
+	}
+	fmt.Printf("Target run: %s\n", tgtRun.Title)
+	fmt.Printf("Source: %s\n", describeSource(src))
+
+	// Build the case mapping
+	fmt.Printf("\n--- Building Case Mapping ---\n")
+	srcCases, err := qase.GetCases(srcClient, config.SourceProject, qase.CaseListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to fetch source cases: %v", err)
+	}
+	tgtCases, err := qase.GetCases(tgtClient, config.TargetProject, qase.CaseListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to fetch target cases: %v", err)
+	}
+	if config.MatchMode == mapping.ModeCF && config.CustomFieldID == 0 {
+		config.CustomFieldID, err = qase.ResolveCustomFieldID(tgtClient, config.TargetProject, config.CustomFieldName)
+		if err != nil {
+			log.Fatalf("Failed to resolve QASE_CF_NAME: %v", err)
+		}
+	}
+	var caseMapping map[int]int
+	if config.MatchMode == mapping.ModeExternalID {
+		srcCFID, err := qase.ResolveCustomField(srcClient, config.SourceProject, config.SourceExternalIDField)
+		if err != nil {
+			log.Fatalf("Failed to resolve source external ID field: %v", err)
+		}
+		tgtCFID, err := qase.ResolveCustomField(tgtClient, config.TargetProject, config.TargetExternalIDField)
+		if err != nil {
+			log.Fatalf("Failed to resolve target external ID field: %v", err)
+		}
+		caseMapping, err = mapping.BuildExternalID(srcCases, tgtCases, srcCFID, tgtCFID)
+	} else {
+		caseMapping, err = mapping.Build(config.MatchMode, srcCases, tgtCases, config.CustomFieldID, config.CFValueRules, config.MappingCSV)
+	}
+	if err != nil {
+		log.Fatalf("Failed to build case mapping: %v", err)
+	}
+
+	// Fetch the source results and the results currently in the target run
+	fmt.Printf("\n--- Fetching Results ---\n")
+	srcResults, err := sourceResults(srcClient, config.SourceProject, src)
+	if err != nil {
+		log.Fatalf("Failed to fetch source results: %v", err)
+	}
+	if src.Parts > 0 {
+		if srcResults, err = withoutOtherParts(tgtClient, config.TargetProject, src, srcResults); err != nil {
+			log.Fatalf("Failed to check the other parts of the split run: %v", err)
+		}
+	}
+	bulkItems, skipped, _ := transform.Results(srcResults, caseMapping, config.Transform)
+	fmt.Printf("Prepared %d results for posting, skipped %d unmapped results\n", len(bulkItems), skipped)
+
+	tgtResults, err := qase.GetRunResults(tgtClient, config.TargetProject, config.TargetRunID)
+	if err != nil {
+		log.Fatalf("Failed to fetch target run results: %v", err)
+	}
+	fmt.Printf("Target run currently has %d results\n", len(tgtResults))
+
+	if config.DryRun {
+		fmt.Printf("\nDRY RUN MODE - Would delete %d results and re-post %d results to run %d\n",
+			len(tgtResults), len(bulkItems), config.TargetRunID)
+		return
+	}
+
+	// Wipe the damaged results
+	fmt.Printf("\n--- Deleting Target Results ---\n")
+	deleted := 0
+	for _, result := range tgtResults {
+		if err := qase.DeleteResult(tgtClient, config.TargetProject, config.TargetRunID, result.Hash); err != nil {
+			log.Fatalf("Failed to delete result %s (deleted %d/%d so far): %v", result.Hash, deleted, len(tgtResults), err)
+		}
+		deleted++
+	}
+	fmt.Printf("Deleted %d results\n", deleted)
+
+	// Re-post from source
+	fmt.Printf("\n--- Re-posting Results ---\n")
+	if err := qase.PostBulkResults(tgtClient, config.TargetProject, config.TargetRunID, bulkItems, config.BulkSize); err != nil {
+		log.Fatalf("Failed to re-post results to run %d: %v", config.TargetRunID, err)
+	}
+
+	fmt.Printf("\n=== Repair Complete ===\n")
+	fmt.Printf("Results deleted: %d\n", deleted)
+	fmt.Printf("Results re-posted: %d\n", len(bulkItems))
+	fmt.Printf("Results skipped (unmapped): %d\n", skipped)
+	fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+}
+
+// describeSource names the source of a target run for the log
+func describeSource(src runtitle.Source) string {
+	var name string
+	switch {
+	case src.Bucket == "":
+		name = fmt.Sprintf("run %d", src.RunID)
+	case src.Date == 0:
+		name = fmt.Sprintf("%s bucket of undated results", src.Bucket)
+	default:
+		name = fmt.Sprintf("%s bucket of %04d-%02d-%02d", src.Bucket, src.Date/10000, src.Date/100%100, src.Date%100)
+	}
+	if src.Parts > 0 {
+		name += fmt.Sprintf(", part %d of %d", src.Part, src.Parts)
+	}
+	return name
+}
+
+// sourceResults fetches the results of a source run, or of every source run
+// ended within a date bucket
+func sourceResults(c *api.Client, project string, src runtitle.Source) ([]qase.Result, error) {
+	if src.Bucket == "" {
+		return qase.GetResultsForRuns(c, project, []int{src.RunID})
+	}
+	if src.Date == 0 {
+		return nil, fmt.Errorf("results without an end time cannot be listed by date; repair the runs they came from with QASE_SOURCE_RUN")
+	}
+
+	start := time.Date(src.Date/10000, time.Month(src.Date/100%100), src.Date%100, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+	if src.Bucket == "weekly" {
+		end = start.AddDate(0, 0, 7)
+	}
+	results, err := qase.GetResultsAfterDate(c, project, start)
+	if err != nil {
+		return nil, err
+	}
+	var inBucket []qase.Result
+	for _, result := range results {
+		if ended := result.EndedAt.UTC(); !ended.Before(start) && ended.Before(end) {
+			inBucket = append(inBucket, result)
+		}
+	}
+	return inBucket, nil
+}
+
+// withoutOtherParts leaves out the source results recorded in the other parts
+// of a split run, so a part is re-posted with its own results only
+func withoutOtherParts(c *api.Client, project string, src runtitle.Source, results []qase.Result) ([]qase.Result, error) {
+	runs, err := qase.BuildRunIndex(c, project)
+	if err != nil {
+		return nil, err
+	}
+
+	elsewhere := make(map[string]bool)
+	for part := 1; part <= src.Parts; part++ {
+		if part == src.Part {
+			continue
+		}
+		marker := runtitle.Marker(src.Project, runtitle.PartKey(src.Key, part, src.Parts))
+		run := runs.Find(marker, "")
+		if run == nil {
+			return nil, fmt.Errorf("part %d of %d (%s) is not in the target project", part, src.Parts, marker)
+		}
+		partResults, err := qase.GetRunResults(c, project, run.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range partResults {
+			if hash := qase.SourceHash(result.Comment); hash != "" {
+				elsewhere[hash] = true
+			}
+		}
+	}
+
+	var own []qase.Result
+	for _, result := range results {
+		if !elsewhere[result.Hash] {
+			own = append(own, result)
+		}
+	}
+	fmt.Printf("Left out %d source results posted to the other %d parts\n", len(results)-len(own), src.Parts-1)
+	return own, nil
+}
+
+type Config struct {
+	settings.Workspaces
+	settings.Mapping
+	settings.Client
+	TargetRunID int
+	SourceRunID int // overrides the source recorded in the target run
+	settings.Posting
+	Transform transform.Options
+}
+
+// loadConfig loads the workspaces, case mapping and client settings shared
+// with the main migration, and the run to repair
+func loadConfig() Config {
+	var problems settings.Problems
+	config := Config{
+		Workspaces:  settings.LoadWorkspaces(&problems),
+		Mapping:     settings.LoadMapping(&problems, true),
+		Client:      settings.LoadClient(&problems),
+		TargetRunID: problems.Int("QASE_TARGET_RUN", 0),
+		SourceRunID: problems.Int("QASE_SOURCE_RUN", 0),
+		Posting:     settings.LoadPosting(&problems),
+	}
+	if config.TargetRunID <= 0 {
+		problems.Add(fmt.Errorf("QASE_TARGET_RUN is required (ID of the target run to repair)"))
+	}
+	if config.SourceRunID < 0 {
+		problems.Add(fmt.Errorf("QASE_SOURCE_RUN must be positive"))
+	}
+
+	// Status mapping, comment normalization and durations, shared with the migration
+	var err error
+	config.Transform, err = transform.LoadOptions()
+	problems.Add(err)
+
+	if err := problems.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return config
+}
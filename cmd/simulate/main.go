@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/runtitle"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+// ResultsData is the recorded fetch written by fetch-results
+type ResultsData struct {
+	SourceProject string        `json:"source_project"`
+	AfterDate     time.Time     `json:"after_date"`
+	FetchTime     time.Time     `json:"fetch_time"`
+	TotalResults  int           `json:"total_results"`
+	Results       []qase.Result `json:"results"`
+}
+
+// SimulatedRun is what the migration would post for one source run
+type SimulatedRun struct {
+	SourceRunID int             `json:"source_run_id"`
+	Title       string          `json:"title"`
+	Skipped     int             `json:"skipped"`
+	Chunks      int             `json:"chunks"`
+	Statuses    map[string]int  `json:"statuses"`
+	Results     []qase.BulkItem `json:"results"`
+	Error       string          `json:"error,omitempty"`
+}
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	fmt.Printf("=== Simulate Migration ===\n")
+	fmt.Printf("Input: %s\n", config.Input)
+
+	// Load the recorded fetch
+	data, err := artifact.Read(config.Input)
+	if err != nil {
+		log.Fatalf("Failed to read recorded results: %v", err)
+	}
+	var recorded ResultsData
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		log.Fatalf("Failed to parse recorded results: %v", err)
+	}
+	if config.TargetProject == "" {
+		config.TargetProject = recorded.SourceProject
+	}
+	fmt.Printf("Source Project: %s (fetched %s)\n", recorded.SourceProject, recorded.FetchTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Target Project: %s (mock)\n", config.TargetProject)
+	fmt.Printf("Recorded results: %d\n", len(recorded.Results))
+
+	// Build the case mapping without contacting either workspace
+	var caseMapping map[int]int
+	if config.MappingCSV != "" {
+		caseMapping, err = mapping.Build(mapping.ModeCSV, nil, nil, 0, nil, config.MappingCSV)
+		if err != nil {
+			log.Fatalf("Failed to build case mapping: %v", err)
+		}
+		fmt.Printf("Loaded mapping for %d cases from %s\n", len(caseMapping), config.MappingCSV)
+	} else {
+		caseMapping = make(map[int]int)
+		for _, result := range recorded.Results {
+			caseMapping[result.CaseID] = result.CaseID
+		}
+		fmt.Printf("No QASE_MAPPING_CSV set, using direct case ID mapping\n")
+	}
+
+	// Replay through the real transform and post path against the mock server
+	server := mockserver.New()
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+
+	client := api.NewClient(server.URL(), "simulate")
+	client.MaxPayloadBytes = config.MaxPayload
+
+	resultsByRun := make(map[int][]qase.Result)
+	for _, result := range recorded.Results {
+		resultsByRun[result.RunID] = append(resultsByRun[result.RunID], result)
+	}
+	runIDs := make([]int, 0, len(resultsByRun))
+	for runID := range resultsByRun {
+		runIDs = append(runIDs, runID)
+	}
+	sort.Ints(runIDs)
+
+	fmt.Printf("\n--- Simulating %d runs ---\n", len(runIDs))
+	var simulated []SimulatedRun
+	failed := 0
+	for _, runID := range runIDs {
+		runResults := resultsByRun[runID]
+		runTitle, runDescription := runtitle.ForSourceRun(runID, runResults)
+
+		bulkItems, skipped, _ := transform.Results(runResults, caseMapping, config.Transform)
+		sim := SimulatedRun{
+			SourceRunID: runID,
+			Title:       runTitle,
+			Skipped:     skipped,
+			Chunks:      (len(bulkItems) + config.BulkSize - 1) / config.BulkSize,
+			Statuses:    make(map[string]int),
+			Results:     bulkItems,
+		}
+		for _, item := range bulkItems {
+			sim.Statuses[item.Status]++
+		}
+
+		if len(bulkItems) > 0 {
+			run, err := qase.CreateRun(client, config.TargetProject, runTitle, runDescription)
+			if err == nil {
+				err = qase.PostBulkResults(client, config.TargetProject, run.ID, bulkItems, config.BulkSize)
+			}
+			if err != nil {
+				sim.Error = err.Error()
+				failed++
+			}
+		}
+		simulated = append(simulated, sim)
+	}
+
+	// Report what would be posted
+	fmt.Printf("\n=== Simulation Complete ===\n")
+	totalPosted, totalSkipped := 0, 0
+	for _, sim := range simulated {
+		totalPosted += len(sim.Results)
+		totalSkipped += sim.Skipped
+		line := fmt.Sprintf("Run %d -> %q: %d results in %d chunks (%s)", sim.SourceRunID, sim.Title, len(sim.Results), sim.Chunks, formatStatuses(sim.Statuses))
+		if sim.Skipped > 0 {
+			line += fmt.Sprintf(", %d unmapped", sim.Skipped)
+		}
+		if sim.Error != "" {
+			line += fmt.Sprintf(" FAILED: %s", sim.Error)
+		}
+		fmt.Println(line)
+	}
+
+	posts := server.Posts()
+	fmt.Printf("\nRuns that would be created: %d\n", len(server.Runs(config.TargetProject)))
+	fmt.Printf("Bulk requests that would be sent: %d\n", len(posts))
+	fmt.Printf("Results that would be posted: %d\n", totalPosted)
+	fmt.Printf("Results skipped (unmapped): %d\n", totalSkipped)
+	fmt.Printf("Runs that would fail: %d\n", failed)
+
+	if config.Output != "" {
+		out, err := json.MarshalIndent(simulated, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal simulation: %v", err)
+		}
+		path, err := artifact.WriteProtected(config.Output, out, config.Force)
+		if err != nil {
+			log.Fatalf("Failed to write simulation: %v", err)
+		}
+		fmt.Printf("Simulated payloads saved to: %s\n", path)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// formatStatuses renders status counts as "failed 3, passed 10"
+func formatStatuses(statuses map[string]int) string {
+	if len(statuses) == 0 {
+		return "nothing to post"
+	}
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, statuses[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type Config struct {
+	Input         string
+	Output        string
+	Force         bool
+	TargetProject string
+	MappingCSV    string
+	Transform     transform.Options
+	BulkSize      int
+	MaxPayload    int
+}
+
+func loadConfig() Config {
+	config := Config{
+		Input:         getEnv("QASE_SIMULATE_INPUT", ""),
+		Output:        getEnv("QASE_SIMULATE_OUT", ""),
+		Force:         getEnv("QASE_FORCE", "false") == "true",
+		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
+		MappingCSV:    getEnv("QASE_MAPPING_CSV", ""),
+	}
+
+	if config.Input == "" {
+		log.Fatal("QASE_SIMULATE_INPUT is required (results-data.json written by fetch-results)")
+	}
+
+	bulkSize, err := strconv.Atoi(getEnv("QASE_BULK_SIZE", "200"))
+	if err != nil || bulkSize <= 0 {
+		log.Fatalf("Invalid QASE_BULK_SIZE: %s", getEnv("QASE_BULK_SIZE", ""))
+	}
+	config.BulkSize = bulkSize
+
+	// Status mapping, comment normalization and durations, shared with the migration
+	config.Transform, err = transform.LoadOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxPayload, err := strconv.Atoi(getEnv("QASE_MAX_PAYLOAD_BYTES", strconv.Itoa(qase.DefaultMaxPayloadBytes)))
+	if err != nil || maxPayload <= 0 {
+		log.Fatalf("Invalid QASE_MAX_PAYLOAD_BYTES: %s", getEnv("QASE_MAX_PAYLOAD_BYTES", ""))
+	}
+	config.MaxPayload = maxPayload
+
+	return config
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+// smokeStatuses are the source statuses of the synthetic results
+var smokeStatuses = []qase.Status{qase.StatusPassed, qase.StatusFailed}
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	// The smoke test writes a temporary run, so protected projects are refused
+	if err := qase.CheckWritable(config.TargetProject, qase.ParseProjectList(getEnv("QASE_PROTECTED_PROJECTS", "")), getEnv(qase.OverrideEnv, "false") == "true"); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("=== Target Smoke Test ===\n")
+	fmt.Printf("Target Project: %s\n", config.TargetProject)
+
+	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+	startTime := time.Now()
+	failed := 0
+	check := func(name string, err error) bool {
+		if err != nil {
+			fmt.Printf("[FAIL] %s: %v\n", name, err)
+			failed++
+			return false
+		}
+		fmt.Printf("[ok]   %s\n", name)
+		return true
+	}
+
+	caseID, err := smokeCase(tgtClient, config)
+	if !check("read cases", err) {
+		os.Exit(1)
+	}
+	fmt.Printf("Posting to case %d\n", caseID)
+
+	// Post the synthetic results through the migration's transformation
+	startMarker := strconv.FormatInt(startTime.Unix(), 10)
+	var results []qase.Result
+	for i, status := range smokeStatuses {
+		seconds := i + 1
+		results = append(results, qase.Result{
+			Hash:    fmt.Sprintf("smoke%s%d", startMarker, i+1),
+			CaseID:  caseID,
+			Status:  string(status),
+			Comment: "Synthetic result posted by smoke-test",
+			Time:    &seconds,
+		})
+	}
+	items, _, _ := transform.Results(results, map[int]int{caseID: caseID}, config.Transform)
+	check("status map", checkStatuses(tgtClient, items))
+
+	run, err := qase.CreateRun(tgtClient, config.TargetProject, "[smoke-test] "+startTime.Format("2006-01-02 15:04:05"), "Temporary run created by smoke-test; deleted when the test ends")
+	if !check("create run", err) {
+		os.Exit(1)
+	}
+
+	if check("post results", qase.PostBulkResults(tgtClient, config.TargetProject, run.ID, items, len(items))) {
+		check("verify results", verifyResults(tgtClient, config.TargetProject, run.ID, items))
+	}
+
+	if config.KeepRun {
+		fmt.Printf("Keeping run %d: %s\n", run.ID, qase.RunURL(config.TargetBaseURL, config.TargetProject, run.ID))
+	} else {
+		check("delete run", qase.DeleteRun(tgtClient, config.TargetProject, run.ID))
+	}
+
+	fmt.Printf("\n=== Smoke Test Summary ===\n")
+	fmt.Printf("Failures: %d\n", failed)
+	fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// smokeCase returns the configured case, or the lowest case ID in the project
+func smokeCase(c *api.Client, config Config) (int, error) {
+	if config.CaseID != 0 {
+		return config.CaseID, nil
+	}
+	cases, err := qase.GetCases(c, config.TargetProject, qase.CaseListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if len(cases) == 0 {
+		return 0, fmt.Errorf("project %s has no cases (set QASE_SMOKE_CASE_ID)", config.TargetProject)
+	}
+	caseIDs := make([]int, 0, len(cases))
+	for id := range cases {
+		caseIDs = append(caseIDs, id)
+	}
+	sort.Ints(caseIDs)
+	return caseIDs[0], nil
+}
+
+// checkStatuses verifies that the mapped statuses exist in the target
+// workspace. Workspaces that do not list statuses pass.
+func checkStatuses(c *api.Client, items []qase.BulkItem) error {
+	statuses, err := qase.GetResultStatuses(c)
+	if err != nil {
+		fmt.Printf("Warning: Could not fetch target result statuses: %v\n", err)
+		return nil
+	}
+	for _, item := range items {
+		if _, exists := statuses[item.Status]; !exists {
+			return fmt.Errorf("status %q does not exist in the target workspace", item.Status)
+		}
+	}
+	return nil
+}
+
+// verifyResults reads the run's results back and compares them with the
+// posted items by source hash
+func verifyResults(c *api.Client, project string, runID int, items []qase.BulkItem) error {
+	results, err := qase.GetRunResults(c, project, runID)
+	if err != nil {
+		return err
+	}
+	posted := make(map[string]qase.Result, len(results))
+	for _, result := range results {
+		posted[qase.SourceHash(result.Comment)] = result
+	}
+	for _, item := range items {
+		hash := qase.SourceHash(item.Comment)
+		result, exists := posted[hash]
+		if !exists {
+			return fmt.Errorf("result %s was not returned (%d of %d results read back)", hash, len(results), len(items))
+		}
+		if result.CaseID != item.CaseID || result.Status != item.Status {
+			return fmt.Errorf("result %s read back as case %d, status %q (posted case %d, status %q)", hash, result.CaseID, result.Status, item.CaseID, item.Status)
+		}
+	}
+	return nil
+}
+
+type Config struct {
+	TargetToken   string
+	TargetBaseURL string
+	TargetProject string
+	CaseID        int
+	KeepRun       bool
+
+	// Status mapping, comment normalization and durations, shared with the migration
+	Transform transform.Options
+}
+
+func loadConfig() Config {
+	config := Config{
+		TargetToken:   getEnv("QASE_TARGET_API_TOKEN", ""),
+		TargetBaseURL: getEnv("QASE_TARGET_API_BASE", "https://api.qase.io"),
+		TargetProject: getEnv("QASE_TARGET_PROJECT", ""),
+		KeepRun:       getEnv("QASE_SMOKE_KEEP_RUN", "false") == "true",
+	}
+
+	if config.TargetToken == "" {
+		log.Fatal("QASE_TARGET_API_TOKEN is required")
+	}
+	if config.TargetProject == "" {
+		log.Fatal("QASE_TARGET_PROJECT is required")
+	}
+
+	if value := getEnv("QASE_SMOKE_CASE_ID", ""); value != "" {
+		caseID, err := strconv.Atoi(value)
+		if err != nil || caseID <= 0 {
+			log.Fatalf("Invalid QASE_SMOKE_CASE_ID: %s", value)
+		}
+		config.CaseID = caseID
+	}
+
+	var err error
+	config.Transform, err = transform.LoadOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return config
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+// ResultsData is the recorded fetch written by fetch-results
+type ResultsData struct {
+	SourceProject string        `json:"source_project"`
+	AfterDate     time.Time     `json:"after_date"`
+	FetchTime     time.Time     `json:"fetch_time"`
+	TotalResults  int           `json:"total_results"`
+	Results       []qase.Result `json:"results"`
+}
+
+// TransformedRun is what the migration would post for one source run
+type TransformedRun struct {
+	SourceRunID int             `json:"source_run_id"`
+	Skipped     int             `json:"skipped"`
+	Results     []qase.BulkItem `json:"results"`
+}
+
+func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Load configuration
+	config := loadConfig()
+
+	// Progress, including the packages' own, goes to stderr so the output
+	// can be piped
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	data, err := artifact.Read(config.Input)
+	if err != nil {
+		log.Fatalf("Failed to read recorded results: %v", err)
+	}
+	var recorded ResultsData
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		log.Fatalf("Failed to parse recorded results: %v", err)
+	}
+	fmt.Printf("Recorded results: %d from %s\n", len(recorded.Results), recorded.SourceProject)
+
+	// Build the case mapping without contacting either workspace
+	var caseMapping map[int]int
+	if config.MappingCSV != "" {
+		caseMapping, err = mapping.Build(mapping.ModeCSV, nil, nil, 0, nil, config.MappingCSV)
+		if err != nil {
+			log.Fatalf("Failed to build case mapping: %v", err)
+		}
+	} else {
+		caseMapping = make(map[int]int)
+		for _, result := range recorded.Results {
+			caseMapping[result.CaseID] = result.CaseID
+		}
+		fmt.Printf("No mapping given, using direct case ID mapping\n")
+	}
+
+	resultsByRun := make(map[int][]qase.Result)
+	for _, result := range recorded.Results {
+		resultsByRun[result.RunID] = append(resultsByRun[result.RunID], result)
+	}
+	runIDs := make([]int, 0, len(resultsByRun))
+	for runID := range resultsByRun {
+		runIDs = append(runIDs, runID)
+	}
+	sort.Ints(runIDs)
+
+	// Runs in ID order and results in input order, so outputs diff cleanly
+	transformed := make([]TransformedRun, 0, len(runIDs))
+	totalPosted, totalSkipped := 0, 0
+	for _, runID := range runIDs {
+		bulkItems, skipped, _ := transform.Results(resultsByRun[runID], caseMapping, config.Transform)
+		if bulkItems == nil {
+			bulkItems = []qase.BulkItem{}
+		}
+		transformed = append(transformed, TransformedRun{SourceRunID: runID, Skipped: skipped, Results: bulkItems})
+		totalPosted += len(bulkItems)
+		totalSkipped += skipped
+	}
+	fmt.Printf("Transformed %d runs: %d results to post, %d unmapped\n", len(transformed), totalPosted, totalSkipped)
+
+	out, err := json.MarshalIndent(transformed, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal transformed results: %v", err)
+	}
+	out = append(out, '\n')
+
+	if config.Output == "" || config.Output == "-" {
+		stdout.Write(out)
+		return
+	}
+	if err := artifact.Write(config.Output, out); err != nil {
+		log.Fatalf("Failed to write transformed results: %v", err)
+	}
+	fmt.Printf("Transformed results saved to: %s\n", config.Output)
+}
+
+type Config struct {
+	Input      string
+	Output     string
+	MappingCSV string
+	Transform  transform.Options
+}
+
+// loadConfig reads the flags, which default to the QASE_ variables
+func loadConfig() Config {
+	var config Config
+	flag.StringVar(&config.Input, "input", getEnv("QASE_TRANSFORM_INPUT", ""), "results-data.json written by fetch-results (QASE_TRANSFORM_INPUT)")
+	flag.StringVar(&config.MappingCSV, "mapping", getEnv("QASE_MAPPING_CSV", ""), "case mapping CSV; direct case IDs if empty (QASE_MAPPING_CSV)")
+	flag.StringVar(&config.Output, "output", getEnv("QASE_TRANSFORM_OUT", ""), "output file, or - for stdout (QASE_TRANSFORM_OUT)")
+	statusMapStr := flag.String("status-map", getEnv("QASE_STATUS_MAP", ""), "status translation, e.g. passed:passed,failed:failed (QASE_STATUS_MAP)")
+	flag.Parse()
+
+	if config.Input == "" {
+		log.Fatal("--input (or QASE_TRANSFORM_INPUT) is required (results-data.json written by fetch-results)")
+	}
+
+	// Comment normalization and durations as in the migration; the flag sets the status map
+	var err error
+	config.Transform, err = transform.LoadOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Transform.StatusMap, err = transform.ParseStatusMap(*statusMapStr)
+	if err != nil {
+		log.Fatalf("Invalid --status-map: %v", err)
+	}
+
+	return config
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,92 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// Export formats for fetched results
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Row is one result flattened for spreadsheets and dataframes
+type Row struct {
+	RunID      int
+	CaseID     int
+	Status     string
+	DurationMs *int64     // nil when the result has no duration
+	EndTime    *time.Time // nil when the result has no end time
+	Hash       string
+}
+
+// Columns are the column names, in order, of both formats
+var Columns = []string{"run_id", "case_id", "status", "duration_ms", "end_time", "hash"}
+
+// Rows flattens results in their given order
+func Rows(results []qase.Result) []Row {
+	rows := make([]Row, len(results))
+	for i, result := range results {
+		row := Row{RunID: result.RunID, CaseID: result.CaseID, Status: result.Status, Hash: result.Hash}
+
+		// Milliseconds from the v2 API, else seconds from v1
+		switch {
+		case result.TimeSpentMs > 0:
+			ms := int64(result.TimeSpentMs)
+			row.DurationMs = &ms
+		case result.Time != nil && *result.Time > 0:
+			ms := int64(*result.Time) * 1000
+			row.DurationMs = &ms
+		}
+		if !result.EndedAt.IsZero() {
+			endTime := result.EndedAt.UTC()
+			row.EndTime = &endTime
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// Encode encodes rows in format (csv or parquet)
+func Encode(format string, rows []Row) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return EncodeCSV(rows)
+	case FormatParquet:
+		return EncodeParquet(rows)
+	default:
+		return nil, fmt.Errorf("unsupported format %q (use csv or parquet)", format)
+	}
+}
+
+// EncodeCSV encodes rows as CSV with a header. Missing durations and end
+// times are empty; end times are RFC 3339 in UTC.
+func EncodeCSV(rows []Row) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(Columns); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		duration, endTime := "", ""
+		if row.DurationMs != nil {
+			duration = strconv.FormatInt(*row.DurationMs, 10)
+		}
+		if row.EndTime != nil {
+			endTime = row.EndTime.Format(time.RFC3339)
+		}
+		record := []string{strconv.Itoa(row.RunID), strconv.Itoa(row.CaseID), row.Status, duration, endTime, row.Hash}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
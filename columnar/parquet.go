@@ -0,0 +1,325 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Parquet is written without dependencies: uncompressed, PLAIN encoded, one
+// data page per column chunk, with metadata in the Thrift compact protocol.
+// Readers such as pandas, pyarrow, DuckDB and Spark read this subset.
+
+// parquetRowGroupSize bounds the rows per row group so pages stay small
+const parquetRowGroupSize = 100000
+
+// Parquet physical types, repetitions, converted types and encodings
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetNoConversion    = -1
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+type parquetColumn struct {
+	name       string
+	typ        int32
+	repetition int32
+	converted  int32
+	// value returns the column's value for a row, or nil for null
+	value func(row Row) interface{}
+}
+
+var parquetColumns = []parquetColumn{
+	{"run_id", parquetInt64, parquetRequired, parquetNoConversion, func(r Row) interface{} { return int64(r.RunID) }},
+	{"case_id", parquetInt64, parquetRequired, parquetNoConversion, func(r Row) interface{} { return int64(r.CaseID) }},
+	{"status", parquetByteArray, parquetRequired, parquetUTF8, func(r Row) interface{} { return r.Status }},
+	{"duration_ms", parquetInt64, parquetOptional, parquetNoConversion, func(r Row) interface{} {
+		if r.DurationMs == nil {
+			return nil
+		}
+		return *r.DurationMs
+	}},
+	{"end_time", parquetInt64, parquetOptional, parquetTimestampMillis, func(r Row) interface{} {
+		if r.EndTime == nil {
+			return nil
+		}
+		return r.EndTime.UnixMilli()
+	}},
+	{"hash", parquetByteArray, parquetRequired, parquetUTF8, func(r Row) interface{} { return r.Hash }},
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// EncodeParquet encodes rows as a Parquet file
+func EncodeParquet(rows []Row) ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	var rowGroups [][]parquetChunk
+	var groupSizes []int64
+	for _, r := range groupRanges(len(rows), parquetRowGroupSize) {
+		group := rows[r[0]:r[1]]
+		chunks := make([]parquetChunk, len(parquetColumns))
+		var groupSize int64
+		for i, column := range parquetColumns {
+			page := encodePage(column, group)
+			header := encodePageHeader(len(group), len(page))
+			chunks[i] = parquetChunk{
+				offset: int64(file.Len()),
+				size:   int64(len(header) + len(page)),
+				values: int64(len(group)),
+			}
+			file.Write(header)
+			file.Write(page)
+			groupSize += chunks[i].size
+		}
+		rowGroups = append(rowGroups, chunks)
+		groupSizes = append(groupSizes, groupSize)
+	}
+
+	footer := encodeFileMetaData(int64(len(rows)), rowGroups, groupSizes)
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString("PAR1")
+	return file.Bytes(), nil
+}
+
+// encodePage encodes a data page: definition levels for optional columns,
+// then the non-null values
+func encodePage(column parquetColumn, rows []Row) []byte {
+	var values bytes.Buffer
+	var defined []bool
+	for _, row := range rows {
+		value := column.value(row)
+		if column.repetition == parquetOptional {
+			defined = append(defined, value != nil)
+		}
+		switch v := value.(type) {
+		case int64:
+			binary.Write(&values, binary.LittleEndian, v)
+		case string:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		}
+	}
+
+	if column.repetition != parquetOptional {
+		return values.Bytes()
+	}
+	levels := encodeLevels(defined)
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+	page.Write(levels)
+	page.Write(values.Bytes())
+	return page.Bytes()
+}
+
+// encodeLevels encodes 1-bit definition levels as RLE runs of the
+// RLE/bit-packing hybrid encoding
+func encodeLevels(defined []bool) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		writeUvarint(&buf, uint64(j-i)<<1)
+		if defined[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+	return buf.Bytes()
+}
+
+func encodePageHeader(numValues, pageSize int) []byte {
+	w := newCompactWriter()
+	w.i32(1, 0) // DATA_PAGE
+	w.i32(2, int32(pageSize))
+	w.i32(3, int32(pageSize))
+	w.beginStruct(5) // DataPageHeader
+	w.i32(1, int32(numValues))
+	w.i32(2, parquetPlain)
+	w.i32(3, parquetRLE)
+	w.i32(4, parquetRLE)
+	w.endStruct()
+	w.end()
+	return w.buf.Bytes()
+}
+
+func encodeFileMetaData(numRows int64, rowGroups [][]parquetChunk, groupSizes []int64) []byte {
+	w := newCompactWriter()
+	w.i32(1, 1) // version
+
+	// Schema: a root element followed by one element per column
+	w.beginList(2, compactStruct, len(parquetColumns)+1)
+	w.beginElement()
+	w.binary(4, "schema")
+	w.i32(5, int32(len(parquetColumns)))
+	w.endStruct()
+	for _, column := range parquetColumns {
+		w.beginElement()
+		w.i32(1, column.typ)
+		w.i32(3, column.repetition)
+		w.binary(4, column.name)
+		if column.converted != parquetNoConversion {
+			w.i32(6, column.converted)
+		}
+		w.endStruct()
+	}
+
+	w.i64(3, numRows)
+
+	w.beginList(4, compactStruct, len(rowGroups))
+	for g, chunks := range rowGroups {
+		w.beginElement() // RowGroup
+		w.beginList(1, compactStruct, len(chunks))
+		for i, chunk := range chunks {
+			column := parquetColumns[i]
+			w.beginElement() // ColumnChunk
+			w.i64(2, chunk.offset)
+			w.beginStruct(3) // ColumnMetaData
+			w.i32(1, column.typ)
+			w.beginList(2, compactI32, 2)
+			w.listI32(parquetPlain)
+			w.listI32(parquetRLE)
+			w.beginList(3, compactBinary, 1)
+			w.listBinary(column.name)
+			w.i32(4, 0) // UNCOMPRESSED
+			w.i64(5, chunk.values)
+			w.i64(6, chunk.size)
+			w.i64(7, chunk.size)
+			w.i64(9, chunk.offset)
+			w.endStruct()
+			w.endStruct()
+		}
+		w.i64(2, groupSizes[g])
+		w.i64(3, chunks[0].values)
+		w.endStruct()
+	}
+
+	w.binary(6, "clone-run-multi-ws")
+	w.end()
+	return w.buf.Bytes()
+}
+
+func groupRanges(n, size int) [][2]int {
+	var ranges [][2]int
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// Thrift compact protocol types
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter writes a Thrift struct in the compact protocol
+type compactWriter struct {
+	buf  bytes.Buffer
+	last []int16 // last field ID of each open struct
+}
+
+func newCompactWriter() *compactWriter {
+	return &compactWriter{last: []int16{0}}
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	top := len(w.last) - 1
+	if delta := id - w.last[top]; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		writeUvarint(&w.buf, zigzag(int64(id)))
+	}
+	w.last[top] = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	writeUvarint(&w.buf, zigzag(int64(v)))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	writeUvarint(&w.buf, zigzag(v))
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.listBinary(s)
+}
+
+func (w *compactWriter) beginStruct(id int16) {
+	w.field(id, compactStruct)
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) beginList(id int16, elemType byte, size int) {
+	w.field(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		writeUvarint(&w.buf, uint64(size))
+	}
+}
+
+// beginElement starts a struct element of a list
+func (w *compactWriter) beginElement() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) listI32(v int32) {
+	writeUvarint(&w.buf, zigzag(int64(v)))
+}
+
+func (w *compactWriter) listBinary(s string) {
+	writeUvarint(&w.buf, uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// endStruct closes a nested struct or list element
+func (w *compactWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+// end closes the top-level struct
+func (w *compactWriter) end() {
+	w.buf.WriteByte(0)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
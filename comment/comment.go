@@ -0,0 +1,127 @@
+package comment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Built-in normalizers selectable by name
+const (
+	StripANSI         = "strip_ansi"
+	NormalizeNewlines = "normalize_newlines"
+	TrimSpace         = "trim"
+)
+
+// ansiEscape matches CSI sequences (colors, cursor movement) and OSC sequences
+// (titles, hyperlinks) as emitted by CI logs
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+var builtins = map[string]func(string) string{
+	StripANSI: func(s string) string {
+		return ansiEscape.ReplaceAllString(s, "")
+	},
+	NormalizeNewlines: func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+	},
+	TrimSpace: strings.TrimSpace,
+}
+
+// Pipeline normalizes result comments before they are posted: built-in
+// normalizers run in order, then an optional external hook command
+type Pipeline struct {
+	steps   []func(string) string
+	command string
+}
+
+// NewPipeline builds a pipeline from a comma-separated list of built-in
+// normalizer names and a shell command that receives the comments of a run
+// on stdin as a JSON array of strings and prints the array of replacements,
+// in order (e.g. a translation script). It returns nil when both are empty;
+// Apply is safe to call on a nil Pipeline.
+func NewPipeline(names, command string) (*Pipeline, error) {
+	p := &Pipeline{command: strings.TrimSpace(command)}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		step, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown comment normalizer %q (use %s, %s or %s)", name, StripANSI, NormalizeNewlines, TrimSpace)
+		}
+		p.steps = append(p.steps, step)
+	}
+
+	if len(p.steps) == 0 && p.command == "" {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Apply returns the normalized comments, in order. The hook command runs
+// once for all non-empty comments; if it fails, they are returned with only
+// the built-in normalizers applied.
+func (p *Pipeline) Apply(comments []string) []string {
+	if p == nil {
+		return comments
+	}
+
+	normalized := make([]string, len(comments))
+	var nonEmpty []int
+	for i, comment := range comments {
+		if comment == "" {
+			continue
+		}
+		for _, step := range p.steps {
+			comment = step(comment)
+		}
+		normalized[i] = comment
+		nonEmpty = append(nonEmpty, i)
+	}
+
+	if p.command == "" || len(nonEmpty) == 0 {
+		return normalized
+	}
+	in := make([]string, len(nonEmpty))
+	for j, i := range nonEmpty {
+		in[j] = normalized[i]
+	}
+	out, err := p.runHook(in)
+	if err != nil {
+		fmt.Printf("Warning: Comment hook failed, posting %d comments without it: %v\n", len(in), err)
+		return normalized
+	}
+	for j, i := range nonEmpty {
+		normalized[i] = out[j]
+	}
+	return normalized
+}
+
+// runHook pipes the comments through the hook command as a JSON array
+func (p *Pipeline) runHook(comments []string) ([]string, error) {
+	input, err := json.Marshal(comments)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", p.command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var out []string
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of strings: %w", err)
+	}
+	if len(out) != len(comments) {
+		return nil, fmt.Errorf("returned %d comments for %d", len(out), len(comments))
+	}
+	return out, nil
+}
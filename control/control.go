@@ -0,0 +1,226 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Controller lets an operator pause, resume, or skip runs of a migration in
+// progress, through signals or a local HTTP control server
+type Controller struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	active map[int]bool
+
+	// Time spent paused, for limits that should not count it
+	pausedAt    time.Time
+	pausedTotal time.Duration
+
+	skipped map[int]bool
+
+	server *http.Server
+}
+
+// State is the controller snapshot returned by the control server
+type State struct {
+	Paused      bool  `json:"paused"`
+	ActiveRuns  []int `json:"active_runs"`
+	SkippedRuns []int `json:"skipped_runs"`
+}
+
+// Start creates a controller, installs the signal handlers, and serves the
+// control endpoints on addr when it is not empty
+func Start(addr string) *Controller {
+	ctl := &Controller{
+		active:  make(map[int]bool),
+		skipped: make(map[int]bool),
+	}
+	ctl.cond = sync.NewCond(&ctl.mu)
+
+	ctl.handleSignals()
+
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/pause", ctl.handle(func(r *http.Request) error { ctl.Pause(); return nil }))
+		mux.HandleFunc("/resume", ctl.handle(func(r *http.Request) error { ctl.Resume(); return nil }))
+		mux.HandleFunc("/skip", ctl.handle(func(r *http.Request) error {
+			runID := 0
+			if v := r.URL.Query().Get("run"); v != "" {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					return fmt.Errorf("invalid run: %s", v)
+				}
+				runID = id
+			}
+			ctl.Skip(runID)
+			return nil
+		}))
+		mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ctl.State())
+		})
+
+		ctl.server = &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			if err := ctl.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Warning: Control server stopped: %v\n", err)
+			}
+		}()
+		fmt.Printf("Control server listening on %s (POST /pause, /resume, /skip?run=ID; GET /status)\n", addr)
+	}
+
+	return ctl
+}
+
+// handle wraps a POST-only control action and responds with the new state
+func (ctl *Controller) handle(action func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := action(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ctl.State())
+	}
+}
+
+// Pause holds new runs and result chunks until Resume is called
+func (ctl *Controller) Pause() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if !ctl.paused {
+		ctl.paused = true
+		ctl.pausedAt = time.Now()
+		fmt.Println("Migration paused: in-flight requests will finish, new work waits for resume")
+	}
+}
+
+// Resume releases work held by Pause
+func (ctl *Controller) Resume() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if ctl.paused {
+		ctl.paused = false
+		ctl.pausedTotal += time.Since(ctl.pausedAt)
+		ctl.cond.Broadcast()
+		fmt.Println("Migration resumed")
+	}
+}
+
+// Skip marks a run to be skipped at its next checkpoint. A runID of 0 skips
+// all runs currently in progress.
+func (ctl *Controller) Skip(runID int) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if runID != 0 {
+		ctl.skipped[runID] = true
+		fmt.Printf("Run %d will be skipped\n", runID)
+		return
+	}
+	for id := range ctl.active {
+		ctl.skipped[id] = true
+		fmt.Printf("Run %d will be skipped\n", id)
+	}
+}
+
+// PausedFor returns the total time the migration has been paused, including
+// a pause in progress
+func (ctl *Controller) PausedFor() time.Duration {
+	if ctl == nil {
+		return 0
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if ctl.paused {
+		return ctl.pausedTotal + time.Since(ctl.pausedAt)
+	}
+	return ctl.pausedTotal
+}
+
+// Wait blocks while the migration is paused
+func (ctl *Controller) Wait() {
+	if ctl == nil {
+		return
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	for ctl.paused {
+		ctl.cond.Wait()
+	}
+}
+
+// Begin records that a run is in progress
+func (ctl *Controller) Begin(runID int) {
+	if ctl == nil {
+		return
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.active[runID] = true
+}
+
+// End records that a run is no longer in progress
+func (ctl *Controller) End(runID int) {
+	if ctl == nil {
+		return
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	delete(ctl.active, runID)
+}
+
+// ClearSkips forgets the runs marked to be skipped. Source run IDs are only
+// unique within a project, so skips are cleared when a project pair starts.
+func (ctl *Controller) ClearSkips() {
+	if ctl == nil {
+		return
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	clear(ctl.skipped)
+}
+
+// Skipped reports whether a run was marked to be skipped
+func (ctl *Controller) Skipped(runID int) bool {
+	if ctl == nil {
+		return false
+	}
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.skipped[runID]
+}
+
+// State returns a snapshot of the controller
+func (ctl *Controller) State() State {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	state := State{Paused: ctl.paused, ActiveRuns: []int{}, SkippedRuns: []int{}}
+	for id := range ctl.active {
+		state.ActiveRuns = append(state.ActiveRuns, id)
+	}
+	for id := range ctl.skipped {
+		state.SkippedRuns = append(state.SkippedRuns, id)
+	}
+	sort.Ints(state.ActiveRuns)
+	sort.Ints(state.SkippedRuns)
+	return state
+}
+
+// Stop shuts down the control server
+func (ctl *Controller) Stop() {
+	if ctl == nil || ctl.server == nil {
+		return
+	}
+	ctl.server.Close()
+}
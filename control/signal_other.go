@@ -0,0 +1,7 @@
+//go:build windows
+
+package control
+
+// handleSignals is a no-op where SIGUSR1/SIGUSR2 are unavailable; use the
+// control server instead
+func (ctl *Controller) handleSignals() {}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package control
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals toggles pause on SIGUSR1 and skips the runs in progress on SIGUSR2
+func (ctl *Controller) handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range signals {
+			switch sig {
+			case syscall.SIGUSR1:
+				ctl.mu.Lock()
+				paused := ctl.paused
+				ctl.mu.Unlock()
+				if paused {
+					ctl.Resume()
+				} else {
+					ctl.Pause()
+				}
+			case syscall.SIGUSR2:
+				ctl.Skip(0)
+			}
+		}
+	}()
+}
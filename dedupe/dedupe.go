@@ -0,0 +1,248 @@
+package dedupe
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Index records the source results posted to each target project so that
+// a result is never posted twice, even by separate invocations running in
+// parallel (batch pairs, shards, retries). It is an append-only log on the
+// local filesystem with one line per event:
+//
+//	claim <key> <owner> <unix-nanos>
+//	done <key> <owner> <unix-nanos>
+//	release <key> <owner> <unix-nanos>
+//
+// Appends of whole lines are atomic with O_APPEND, so every process sees the
+// same order of events and agrees on which claim came first. A claim not
+// marked done or released within the TTL is considered abandoned.
+type Index struct {
+	path  string
+	owner string
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	offset  int64
+	entries map[string]*entry
+}
+
+type entry struct {
+	done    bool
+	owner   string
+	claimed time.Time
+}
+
+// Open opens or creates the index at path. It returns nil when path is
+// empty; all Index methods are safe to call on a nil Index.
+func Open(path string, ttl time.Duration) (*Index, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if strings.Contains(path, "://") {
+		return nil, fmt.Errorf("dedupe index %s must be a local file", path)
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedupe index: %w", err)
+	}
+
+	x := &Index{
+		path:    path,
+		owner:   newOwnerID(),
+		ttl:     ttl,
+		file:    file,
+		entries: make(map[string]*entry),
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// A process killed mid-write may have left a partial line; terminate it
+	// so the next append starts a line of its own
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			file.Write([]byte("\n"))
+		}
+	}
+	if err := x.sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	posted := 0
+	for _, e := range x.entries {
+		if e.done {
+			posted++
+		}
+	}
+	fmt.Printf("Dedupe index %s: %d results already posted\n", path, posted)
+	return x, nil
+}
+
+// Key identifies a source result posted to a target project
+func Key(targetProject, hash string) string {
+	return targetProject + "/" + hash
+}
+
+// Claim reserves keys for posting and returns the ones this process may
+// post. Keys already posted, or claimed by another live process, are left
+// out. Claimed keys must be passed to Commit or Release.
+func (x *Index) Claim(keys []string) ([]string, error) {
+	if x == nil {
+		return keys, nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := x.sync(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var candidates []string
+	for _, key := range keys {
+		e := x.entries[key]
+		if e != nil && (e.done || (e.owner != "" && now.Sub(e.claimed) < x.ttl)) {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+	if err := x.append("claim", candidates); err != nil {
+		return nil, err
+	}
+
+	// Another process may have claimed the same keys just before us
+	if err := x.sync(); err != nil {
+		return nil, err
+	}
+	var claimed []string
+	for _, key := range candidates {
+		if e := x.entries[key]; e != nil && !e.done && e.owner == x.owner {
+			claimed = append(claimed, key)
+		}
+	}
+	return claimed, nil
+}
+
+// Commit marks claimed keys as posted
+func (x *Index) Commit(keys []string) error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.append("done", keys)
+}
+
+// Release gives up claimed keys that were not posted, so a later attempt
+// can claim them
+func (x *Index) Release(keys []string) error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.append("release", keys)
+}
+
+// Close closes the index file
+func (x *Index) Close() error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.file.Close()
+}
+
+// append writes one event line per key in a single write
+func (x *Index) append(op string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s %s %s\n", op, key, x.owner, now)
+	}
+	if _, err := x.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write dedupe index: %w", err)
+	}
+	return nil
+}
+
+// sync applies the events appended since the last sync, by any process
+func (x *Index) sync() error {
+	if _, err := x.file.Seek(x.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read dedupe index: %w", err)
+	}
+	reader := bufio.NewReader(x.file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// A line still being written is applied by a later sync
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dedupe index: %w", err)
+		}
+		x.offset += int64(len(line))
+		x.apply(strings.Fields(line))
+	}
+}
+
+// apply replays one event; malformed lines are ignored
+func (x *Index) apply(fields []string) {
+	if len(fields) != 4 {
+		return
+	}
+	op, key, owner := fields[0], fields[1], fields[2]
+	nanos, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return
+	}
+	at := time.Unix(0, nanos)
+
+	e := x.entries[key]
+	if e == nil {
+		e = &entry{}
+		x.entries[key] = e
+	}
+	switch op {
+	case "claim":
+		// The first live claim wins; abandoned claims can be taken over
+		if !e.done && (e.owner == "" || e.owner == owner || at.Sub(e.claimed) >= x.ttl) {
+			e.owner = owner
+			e.claimed = at
+		}
+	case "done":
+		e.done = true
+		e.owner = ""
+	case "release":
+		if e.owner == owner {
+			e.owner = ""
+		}
+	}
+}
+
+func newOwnerID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", strings.ReplaceAll(host, " ", "_"), os.Getpid(), hex.EncodeToString(b))
+}
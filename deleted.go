@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/target"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+	"github.com/adrianeortiz/clone-run-multi-ws/triage"
+)
+
+// Policies for target cases (QASE_DELETED_CASES) and runs (QASE_DELETED_RUNS)
+// deleted after the mapping was built
+const (
+	DeletedFail     = "fail"     // the run fails, as for any other rejected post
+	DeletedSkip     = "skip"     // the results are left out and reported
+	DeletedRemap    = "remap"    // cases: map again from the current target cases
+	DeletedRecreate = "recreate" // runs: create the target run again
+)
+
+// deletedRecoveries bounds the recoveries of one run's post, so a target
+// that keeps rejecting results cannot hold a worker
+const deletedRecoveries = 10
+
+// deletedTargets recovers result posts the target rejected because cases or
+// runs were deleted there after the mapping was built
+type deletedTargets struct {
+	cases     string
+	runs      string
+	attention *triage.Report
+
+	// rebuild maps the source cases to freshly fetched target cases. It runs
+	// once, for the first deleted case under the remap policy.
+	original map[int]int
+	rebuild  func() (map[int]int, error)
+	once     sync.Once
+	current  map[int]int
+}
+
+// post posts items to run, recovering from deleted cases and runs per
+// policy. Items left out are moved after the ones to post, together with
+// their dedupe claims, so unposted claims are released. It returns the run
+// posted to, the number of leading items posted and the number of leading
+// items kept for posting.
+func (d *deletedTargets) post(t target.Target, run *qase.Run, items []qase.BulkItem, claims []string, sourceRunID int, span *tracing.Span, recreate func() (*qase.Run, error)) (*qase.Run, int, int, error) {
+	posted, end := 0, len(items)
+	recreated := false
+	for attempt := 0; ; attempt++ {
+		err := t.PostResults(run.ID, items[posted:end], span)
+		if err == nil {
+			return run, end, end, nil
+		}
+		var chunkErr *qase.ChunkError
+		if errors.As(err, &chunkErr) {
+			posted += chunkErr.Posted
+		}
+		if attempt == deletedRecoveries {
+			return run, posted, end, err
+		}
+
+		var casesErr *qase.DeletedCasesError
+		switch {
+		case errors.As(err, &casesErr) && d.cases != DeletedFail:
+			kept, recovered := d.recoverCases(casesErr.CaseIDs, items, claims, posted, end, sourceRunID, run.ID)
+			if !recovered {
+				return run, posted, end, err
+			}
+			end = kept
+
+		case qase.IsNotFound(err) && d.runs == DeletedSkip:
+			fmt.Printf("Target run %d was deleted, skipping %d results of source run %d\n", run.ID, end-posted, sourceRunID)
+			d.attention.Add(triage.Item{
+				Kind:        triage.KindDeletedRun,
+				SourceRuns:  []int{sourceRunID},
+				TargetRunID: run.ID,
+				Results:     end - posted,
+				Detail:      fmt.Sprintf("target run %d was deleted during the migration", run.ID),
+				Remediation: "re-run the source run with QASE_DELETED_RUNS=recreate to migrate it into a new run",
+			})
+			return run, posted, posted, nil
+
+		case qase.IsNotFound(err) && d.runs == DeletedRecreate && !recreated:
+			// Results posted before the deletion went with the run
+			fmt.Printf("Target run %d was deleted, creating it again\n", run.ID)
+			fresh, createErr := recreate()
+			if createErr != nil {
+				return run, posted, end, fmt.Errorf("failed to recreate deleted run %d: %w", run.ID, createErr)
+			}
+			run, posted, recreated = fresh, 0, true
+
+		default:
+			return run, posted, end, err
+		}
+	}
+}
+
+// recoverCases re-maps or leaves out the results in items[from:end] for the
+// deleted target cases. It returns the new end of the items to post, and
+// false when nothing changed.
+func (d *deletedTargets) recoverCases(deleted []int, items []qase.BulkItem, claims []string, from, end, sourceRunID, targetRunID int) (int, bool) {
+	replacements := make(map[int]int)
+	gone := make(map[int]bool, len(deleted))
+	for _, id := range deleted {
+		gone[id] = true
+		if d.cases == DeletedRemap {
+			if to := d.replacement(id); to != 0 {
+				replacements[id] = to
+			}
+		}
+	}
+
+	// Stable partition: kept items first, left-out ones after them
+	kept := from
+	var droppedItems []qase.BulkItem
+	var droppedClaims []string
+	remapped := make(map[int]int)
+	dropped := make(map[int]int)
+	for i := from; i < end; i++ {
+		item := items[i]
+		if gone[item.CaseID] {
+			to, ok := replacements[item.CaseID]
+			if !ok {
+				dropped[item.CaseID]++
+				droppedItems = append(droppedItems, item)
+				if claims != nil {
+					droppedClaims = append(droppedClaims, claims[i])
+				}
+				continue
+			}
+			remapped[item.CaseID]++
+			item.CaseID = to
+		}
+		items[kept] = item
+		if claims != nil {
+			claims[kept] = claims[i]
+		}
+		kept++
+	}
+	copy(items[kept:end], droppedItems)
+	if claims != nil {
+		copy(claims[kept:end], droppedClaims)
+	}
+
+	for _, id := range deleted {
+		if n := remapped[id]; n > 0 {
+			fmt.Printf("Target case %d was deleted, re-mapped %d results of source run %d to case %d\n", id, n, sourceRunID, replacements[id])
+		}
+		n := dropped[id]
+		if n == 0 {
+			continue
+		}
+		fmt.Printf("Target case %d was deleted, skipping %d results of source run %d\n", id, n, sourceRunID)
+		detail := fmt.Sprintf("target case %d was deleted after the mapping was built", id)
+		if d.cases == DeletedRemap {
+			detail += "; no current target case is mapped from its source cases"
+		}
+		d.attention.Add(triage.Item{
+			Kind:        triage.KindDeletedCase,
+			SourceRuns:  []int{sourceRunID},
+			TargetRunID: targetRunID,
+			CaseID:      id,
+			Results:     n,
+			Detail:      detail,
+			Remediation: "restore the case or map its source case to another target case, then re-run the source run",
+		})
+	}
+	return kept, len(remapped) > 0 || len(dropped) > 0
+}
+
+// replacement returns the target case the source cases of a deleted target
+// case map to now, or 0 when there is none or they disagree
+func (d *deletedTargets) replacement(deleted int) int {
+	d.once.Do(func() {
+		fmt.Println("Target cases were deleted, rebuilding the mapping from the current target cases...")
+		current, err := d.rebuild()
+		if err != nil {
+			log.Printf("Warning: Failed to rebuild the mapping: %v", err)
+			return
+		}
+		d.current = current
+	})
+
+	to := 0
+	for source, target := range d.original {
+		if target != deleted {
+			continue
+		}
+		candidate, ok := d.current[source]
+		if !ok || candidate == deleted {
+			continue
+		}
+		if to != 0 && candidate != to {
+			return 0
+		}
+		to = candidate
+	}
+	return to
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// driftListLimit bounds the rerouted pairs listed in the log
+const driftListLimit = 10
+
+// mappingBaselineName is the file holding a pair's mapping of its previous
+// invocation, compared with and replaced by each invocation
+func mappingBaselineName(srcProject, tgtProject string) string {
+	return fmt.Sprintf("case_map.%s-%s.prev.csv", srcProject, tgtProject)
+}
+
+// saveMappingBaseline replaces the pair's baseline with the mapping
+func saveMappingBaseline(caseMapping map[int]int, location string) error {
+	data, err := encodeMapping(caseMapping, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	return artifact.Write(location, data)
+}
+
+// reportMappingDrift compares the mapping with the pair's previous mapping at
+// previousLocation, prints the added, removed and rerouted pairs and writes
+// them to mapping_changes.out.csv in dir. Nothing is reported for a first
+// invocation.
+func reportMappingDrift(caseMapping map[int]int, srcCases, tgtCases map[int]qase.Case, previousLocation, dir string, force bool) error {
+	exists, err := artifact.Exists(previousLocation)
+	if err != nil || !exists {
+		return err
+	}
+	previous, err := mapping.LoadCSV(previousLocation)
+	if err != nil {
+		return fmt.Errorf("failed to read previous mapping: %w", err)
+	}
+
+	changes := mapping.Diff(previous, caseMapping)
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	fmt.Printf("Mapping changes since %s: %d added, %d removed, %d rerouted\n",
+		previousLocation, counts[mapping.ChangeAdded], counts[mapping.ChangeRemoved], counts[mapping.ChangeRouted])
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// Rerouted pairs are the silent ones: results now land on other cases
+	if n := counts[mapping.ChangeRouted]; n > 0 {
+		fmt.Printf("Warning: %d source cases now map to different target cases; check for edits to the mapping source (e.g. the target custom field)\n", n)
+		listed := 0
+		for _, change := range changes {
+			if change.Kind != mapping.ChangeRouted {
+				continue
+			}
+			if listed == driftListLimit {
+				fmt.Printf("  ... and %d more, see mapping_changes.out.csv\n", n-listed)
+				break
+			}
+			fmt.Printf("  source %d %q: %d %q -> %d %q\n", change.SourceID, srcCases[change.SourceID].Title,
+				change.PreviousTarget, tgtCases[change.PreviousTarget].Title, change.Target, tgtCases[change.Target].Title)
+			listed++
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"change", "source_case_id", "previous_target_case_id", "target_case_id", "source_title", "previous_target_title", "target_title"})
+	for _, change := range changes {
+		writer.Write([]string{
+			change.Kind,
+			strconv.Itoa(change.SourceID),
+			caseIDField(change.PreviousTarget),
+			caseIDField(change.Target),
+			srcCases[change.SourceID].Title,
+			tgtCases[change.PreviousTarget].Title,
+			tgtCases[change.Target].Title,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	location, err := artifact.WriteProtected(artifact.Join(dir, "mapping_changes.out.csv"), buf.Bytes(), force)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mapping changes written to %s\n", location)
+	return nil
+}
+
+// caseIDField formats a case ID for CSV, empty for none
+func caseIDField(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
@@ -0,0 +1,303 @@
+package envcfg
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// Prefix is the canonical prefix of all configuration variables
+	Prefix = "QASE_"
+
+	// PrefixVar selects an alternative prefix (e.g. CLONE_RUN_) whose variables
+	// are used in place of the QASE_ ones
+	PrefixVar = "QASE_ENV_PREFIX"
+
+	// StrictVar makes unknown configuration variables an error
+	StrictVar = "STRICT_ENV"
+)
+
+// knownNames lists every configuration variable read by the tools, without
+// prefix, one per line in sorted order
+var knownNames = []string{
+	"AFTER_DATE",
+	"ALERT_ERROR_RATE",
+	"ARTIFACT_DIR",
+	"ARTIFACT_KEY",
+	"ARTIFACT_KEY_COMMAND",
+	"BATCH_FILE",
+	"BREAKER_COOLDOWN",
+	"BREAKER_THRESHOLD",
+	"BULK_SIZE",
+	"BUNDLE",
+	"BUNDLE_KEY",
+	"CACHE_DIR",
+	"CACHE_TTL",
+	"CF_ID",
+	"CF_NAME",
+	"CF_VALUE_PATTERN",
+	"CF_VALUE_PREFIX",
+	"CHECKPOINT",
+	"CHECKPOINT_INTERVAL",
+	"CLEANUP_TITLE_PREFIX",
+	"COMMENT_HOOK",
+	"COMMENT_NORMALIZE",
+	"CONCURRENCY",
+	"CONTROL_ADDR",
+	"CREATE_STATUSES",
+	"DEBUG",
+	"DEDUPE_CLAIM_TTL",
+	"DEDUPE_INDEX",
+	"DELETED_CASES",
+	"DELETED_RUNS",
+	"DRY_RUN",
+	"DURATION_OVER_MAX",
+	"DURATION_ROUNDING",
+	"ENV_PREFIX",
+	"EXTERNAL_ID_CF",
+	"FETCH_FORMAT",
+	"FETCH_MODE",
+	"FETCH_RUN_IDS",
+	"FIXTURE_CASES",
+	"FIXTURE_DAYS",
+	"FIXTURE_OUT",
+	"FIXTURE_RESULTS_PER_RUN",
+	"FIXTURE_RUNS",
+	"FIXTURE_SEED",
+	"FORCE",
+	"FORCE_CASES",
+	"GCS_TOKEN_COMMAND",
+	"HEALTH_ADDR",
+	"HEALTH_STALL_TIMEOUT",
+	"IDEMPOTENT",
+	"I_KNOW_WHAT_IM_DOING",
+	"JIRA_API_TOKEN",
+	"JIRA_BASE_URL",
+	"JIRA_ISSUE",
+	"JIRA_USER",
+	"LOCK",
+	"LOCK_TTL",
+	"MAPPING_CSV",
+	"MAPPING_OUT",
+	"MAPPING_TITLES",
+	"MATCH_MIN_SIMILARITY",
+	"MATCH_MODE",
+	"MAX_DURATION",
+	"MAX_FAILED_RUNS",
+	"MAX_PAYLOAD_BYTES",
+	"MAX_RESULTS_PER_RUN",
+	"MAX_SKIPPED_PCT",
+	"MILESTONE",
+	"MOCK_ADDR",
+	"MOCK_PAGE_FAULT",
+	"MOCK_RATE_LIMIT",
+	"MOCK_RATE_WINDOW",
+	"MOCK_READONLY_TOKENS",
+	"NEEDS_ATTENTION_FILE",
+	"OPSGENIE_API_KEY",
+	"OPSGENIE_URL",
+	"OVERSIZED_RUNS",
+	"PAGERDUTY_ROUTING_KEY",
+	"PAGERDUTY_URL",
+	"PARAMS_MODE",
+	"PERSIST_CF_ID",
+	"PPROF_ADDR",
+	"PRIORITY_RUNS",
+	"PRIORITY_TAGS",
+	"PROGRESS",
+	"PROJECT_MAP",
+	"PROTECTED_PROJECTS",
+	"RATE_LIMIT_HEADROOM",
+	"RATE_LIMIT_PACING",
+	"RAW_ATTACHMENTS",
+	"READ_RETRIES",
+	"READ_RETRY_BUDGET",
+	"READ_RETRY_MAX_WAIT",
+	"READ_RETRY_WAIT_MS",
+	"REPORT_URL",
+	"RESYNC",
+	"RETRY_BUDGET",
+	"REVIEW_DIR",
+	"RUN_BUCKET",
+	"RUN_CREATE_BATCH",
+	"RUN_CREATE_CONCURRENCY",
+	"RUN_CUSTOM_FIELDS",
+	"RUN_DESCRIPTION_STATS",
+	"RUN_ERROR_FILES",
+	"RUN_INCLUDE_CASES",
+	"RUN_ORDER",
+	"RUN_ORDER_DIRECTION",
+	"RUN_STATUS",
+	"SAMPLE",
+	"SERVE_ADDR",
+	"SERVE_AUTH_TOKEN",
+	"SERVE_DB",
+	"SERVE_DIR",
+	"SERVE_MAX_JOBS",
+	"SERVE_MAX_JOBS_PER_PROJECT",
+	"SERVE_RETENTION_DAYS",
+	"SERVE_SQLITE",
+	"SHARD",
+	"SIMULATE_INPUT",
+	"SIMULATE_OUT",
+	"SKIP_CASES",
+	"SMOKE_CASE_ID",
+	"SMOKE_KEEP_RUN",
+	"SMTP_ATTACH",
+	"SMTP_FROM",
+	"SMTP_HOST",
+	"SMTP_PASSWORD",
+	"SMTP_PORT",
+	"SMTP_TO",
+	"SMTP_USER",
+	"SOURCE_API_BASE",
+	"SOURCE_API_TOKEN",
+	"SOURCE_API_TOKENS",
+	"SOURCE_EXTERNAL_ID_CF",
+	"SOURCE_PROJECT",
+	"SOURCE_RUN",
+	"SOURCE_TOKEN_COMMAND",
+	"STATUS_FILE",
+	"STATUS_INTERVAL",
+	"STATUS_MAP",
+	"STRICT_DECODE",
+	"STRICT_ENV",
+	"TARGET_API_BASE",
+	"TARGET_API_TOKEN",
+	"TARGET_API_TOKENS",
+	"TARGET_PROJECT",
+	"TARGET_RPM",
+	"TARGET_RUN",
+	"TARGET_TOKEN_COMMAND",
+	"TIMEOUT",
+	"TIMEZONE",
+	"TOKEN_REFRESH_INTERVAL",
+	"TOKEN_RPM",
+	"TRANSFORM_INPUT",
+	"TRANSFORM_OUT",
+	"TUI",
+	"TUI_LOG",
+	"WAREHOUSE",
+	"WAREHOUSE_MODE",
+	"WAREHOUSE_PSQL",
+	"WAREHOUSE_TABLE_PREFIX",
+	"WATCH_INTERVAL",
+	"WATCH_OVERLAP",
+
+	// Used by the helper scripts and workflows
+	"API_TOKEN",
+	"PROJECT_CODE",
+}
+
+// known is knownNames as a set
+var known = func() map[string]bool {
+	set := make(map[string]bool, len(knownNames))
+	for _, name := range knownNames {
+		set[name] = true
+	}
+	return set
+}()
+
+// Apply maps variables with the configured prefix onto their QASE_ names and
+// reports unknown configuration variables: as warnings, or as an error when
+// strict mode is enabled
+func Apply() error {
+	prefix := os.Getenv(PrefixVar)
+	if prefix == "" {
+		prefix = Prefix
+	}
+
+	// Remember the name each variable was set under for error messages
+	origin := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if prefix == Prefix || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		canonical := Prefix + strings.TrimPrefix(name, prefix)
+		if err := os.Setenv(canonical, value); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", name, err)
+		}
+		origin[canonical] = name
+	}
+
+	var unknown []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, Prefix) || known[strings.TrimPrefix(name, Prefix)] {
+			continue
+		}
+		if original, exists := origin[name]; exists {
+			name = original
+		}
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(unknown))
+	for i, name := range unknown {
+		messages[i] = name
+		if suggestion := suggest(name, prefix); suggestion != "" {
+			messages[i] += fmt.Sprintf(" (did you mean %s?)", suggestion)
+		}
+	}
+
+	if os.Getenv(Prefix+StrictVar) == "true" {
+		return fmt.Errorf("unknown configuration variables: %s", strings.Join(messages, ", "))
+	}
+	for _, message := range messages {
+		fmt.Printf("Warning: Unknown configuration variable %s\n", message)
+	}
+	return nil
+}
+
+// Known reports whether name (with the QASE_ prefix) is a configuration
+// variable
+func Known(name string) bool {
+	return strings.HasPrefix(name, Prefix) && known[strings.TrimPrefix(name, Prefix)]
+}
+
+// suggest returns the known variable closest to name, if any is close enough
+func suggest(name, prefix string) string {
+	bare := strings.TrimPrefix(strings.TrimPrefix(name, prefix), Prefix)
+	best, bestDistance := "", 4
+	for candidate := range known {
+		if d := distance(bare, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	if strings.HasPrefix(name, prefix) {
+		return prefix + best
+	}
+	return Prefix + best
+}
+
+// distance returns the Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
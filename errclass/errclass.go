@@ -0,0 +1,173 @@
+package errclass
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Class represents a category of migration failure
+type Class string
+
+const (
+	ClassAuth       Class = "auth"
+	ClassRateLimit  Class = "rate_limit"
+	ClassValidation Class = "validation"
+	ClassMapping    Class = "mapping"
+	ClassNetwork    Class = "network"
+	ClassServer     Class = "server"
+	ClassOther      Class = "other"
+)
+
+// hints gives a short remediation suggestion per class
+var hints = map[Class]string{
+	ClassAuth:       "check that the API tokens are valid and have access to the projects",
+	ClassRateLimit:  "lower QASE_CONCURRENCY or QASE_BULK_SIZE and re-run",
+	ClassValidation: "inspect the rejected payloads (statuses, case IDs, comment sizes)",
+	ClassMapping:    "extend the case mapping (custom field values or CSV rows) for unmapped cases",
+	ClassNetwork:    "check connectivity to the API base URLs and re-run",
+	ClassServer:     "the Qase API returned 5xx errors; re-run later (idempotent mode skips posted results)",
+	ClassOther:      "see the log output for details",
+}
+
+// Hint returns the remediation suggestion for a class
+func Hint(class Class) string {
+	return hints[class]
+}
+
+// statusCoder is implemented by API errors that carry an HTTP status code
+type statusCoder interface {
+	HTTPStatus() int
+}
+
+// Classify determines the failure class of an error
+func Classify(err error) Class {
+	if err == nil {
+		return ""
+	}
+
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return classifyStatus(sc.HTTPStatus())
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return ClassNetwork
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassNetwork
+	}
+
+	return ClassOther
+}
+
+func classifyStatus(status int) Class {
+	switch {
+	case status == 401 || status == 403:
+		return ClassAuth
+	case status == 429:
+		return ClassRateLimit
+	case status == 400 || status == 404 || status == 413 || status == 422:
+		return ClassValidation
+	case status >= 500:
+		return ClassServer
+	default:
+		return ClassOther
+	}
+}
+
+// Summary accumulates failure counts per class during a migration
+type Summary struct {
+	mu       sync.Mutex
+	counts   map[Class]int
+	examples map[Class]string
+}
+
+// NewSummary creates an empty error summary
+func NewSummary() *Summary {
+	return &Summary{
+		counts:   make(map[Class]int),
+		examples: make(map[Class]string),
+	}
+}
+
+// Record classifies and counts an error, returning its class
+func (s *Summary) Record(err error) Class {
+	if err == nil {
+		return ""
+	}
+	class := Classify(err)
+	s.RecordClass(class, 1, err.Error())
+	return class
+}
+
+// RecordClass adds n failures of a known class with an example message
+func (s *Summary) RecordClass(class Class, n int, example string) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[class] += n
+	if _, exists := s.examples[class]; !exists && example != "" {
+		s.examples[class] = truncate(example, 200)
+	}
+}
+
+// Counts returns a copy of the per-class counts
+func (s *Summary) Counts() map[Class]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[Class]int, len(s.counts))
+	for class, n := range s.counts {
+		counts[class] = n
+	}
+	return counts
+}
+
+// Print writes the summary-of-errors section to stdout
+func (s *Summary) Print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Printf("\n=== Error Summary ===\n")
+	if len(s.counts) == 0 {
+		fmt.Println("No errors recorded")
+		return
+	}
+
+	classes := make([]Class, 0, len(s.counts))
+	for class := range s.counts {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if s.counts[classes[i]] != s.counts[classes[j]] {
+			return s.counts[classes[i]] > s.counts[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+
+	for _, class := range classes {
+		fmt.Printf("%-11s %d\n", class+":", s.counts[class])
+		if example := s.examples[class]; example != "" {
+			fmt.Printf("            e.g. %s\n", example)
+		}
+		fmt.Printf("            hint: %s\n", hints[class])
+	}
+}
+
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gates are tolerances a finished migration is checked against, so a
+// scheduled or CI job fails when mapping drift or failures exceed them
+type gates struct {
+	maxSkippedPct float64 // unmapped results as a share of source results (-1 disables)
+	maxFailedRuns int     // -1 disables
+}
+
+// parseGates parses QASE_MAX_SKIPPED_PCT (e.g. "2" or "2%") and
+// QASE_MAX_FAILED_RUNS. Empty values disable the gate.
+func parseGates(skippedPct, failedRuns string) (gates, error) {
+	g := gates{maxSkippedPct: -1, maxFailedRuns: -1}
+	if value := strings.TrimSpace(strings.TrimSuffix(skippedPct, "%")); value != "" {
+		pct, err := strconv.ParseFloat(value, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return g, fmt.Errorf("invalid QASE_MAX_SKIPPED_PCT: %s (use a percentage from 0 to 100)", skippedPct)
+		}
+		g.maxSkippedPct = pct
+	}
+	if value := strings.TrimSpace(failedRuns); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return g, fmt.Errorf("invalid QASE_MAX_FAILED_RUNS: %s (use 0 or more runs)", failedRuns)
+		}
+		g.maxFailedRuns = n
+	}
+	return g, nil
+}
+
+func (g gates) enabled() bool {
+	return g.maxSkippedPct >= 0 || g.maxFailedRuns >= 0
+}
+
+// check returns an error listing every exceeded gate
+func (g gates) check(sourceResults, skipped, failedRuns int) error {
+	var exceeded []string
+	if g.maxSkippedPct >= 0 && sourceResults > 0 {
+		pct := float64(skipped) * 100 / float64(sourceResults)
+		if pct > g.maxSkippedPct {
+			exceeded = append(exceeded, fmt.Sprintf("%.2f%% of results skipped as unmapped (max %g%%)", pct, g.maxSkippedPct))
+		}
+	}
+	if g.maxFailedRuns >= 0 && failedRuns > g.maxFailedRuns {
+		exceeded = append(exceeded, fmt.Sprintf("%d failed runs (max %d)", failedRuns, g.maxFailedRuns))
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("gates exceeded: %s", strings.Join(exceeded, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/handoff"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/seal"
+)
+
+// A migration split between two machines for organizations that will not
+// keep both tokens on one: fetch-only reads the source project with the
+// source token into an encrypted bundle, post-only migrates the bundle with
+// the target token
+const (
+	fetchOnlyCommand = "fetch-only"
+	postOnlyCommand  = "post-only"
+	bundleCommand    = "bundle"
+)
+
+// source reads the source project, through the API or, in a post-only
+// migration, from the fetch-only bundle
+type source struct {
+	client *api.Client     // nil in a post-only migration
+	bundle *handoff.Bundle // nil unless post-only
+}
+
+func (s source) cases(project string) (map[int]qase.Case, error) {
+	if s.bundle != nil {
+		return s.bundle.Cases, nil
+	}
+	return qase.GetCases(s.client, project, qase.CaseListOptions{})
+}
+
+func (s source) results(project string, opts qase.FetchOptions) ([]qase.Result, error) {
+	if s.bundle != nil {
+		if len(opts.RunIDs) == 0 {
+			return s.bundle.Results, nil
+		}
+		// Re-runs of failed runs post only those runs of the bundle
+		var results []qase.Result
+		for _, result := range s.bundle.Results {
+			if slices.Contains(opts.RunIDs, result.RunID) {
+				results = append(results, result)
+			}
+		}
+		return results, nil
+	}
+	return qase.FetchResults(s.client, project, opts)
+}
+
+func (s source) resultStatuses() (map[string]qase.ResultStatus, error) {
+	if s.bundle != nil {
+		if s.bundle.Statuses == nil {
+			return nil, fmt.Errorf("the bundle holds no source statuses")
+		}
+		return s.bundle.Statuses, nil
+	}
+	return qase.GetResultStatuses(s.client)
+}
+
+func (s source) runs(project string, after time.Time) ([]qase.Run, error) {
+	if s.bundle != nil {
+		return s.bundle.Runs, nil
+	}
+	return qase.GetRuns(s.client, project, qase.RunListOptions{FromStartTime: after})
+}
+
+func (s source) customField(project, ref string) (int, error) {
+	if s.bundle != nil {
+		if s.bundle.ExternalIDField == 0 {
+			return 0, fmt.Errorf("the bundle was fetched without QASE_MATCH_MODE=external_id")
+		}
+		return s.bundle.ExternalIDField, nil
+	}
+	return qase.ResolveCustomField(s.client, project, ref)
+}
+
+// bundleKey reads the bundle key from QASE_BUNDLE_KEY
+func bundleKey() ([]byte, error) {
+	value := os.Getenv("QASE_BUNDLE_KEY")
+	if value == "" {
+		return nil, fmt.Errorf("QASE_BUNDLE_KEY is required to encrypt and decrypt bundles (create one with %s %s keygen)", executable(), bundleCommand)
+	}
+	key, err := seal.ParseKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QASE_BUNDLE_KEY: %w", err)
+	}
+	return key, nil
+}
+
+// fetchBundle is the fetch-only stage: it reads what the migration needs
+// from the source project and writes it as an encrypted bundle. Nothing is
+// read from or written to the target workspace.
+func fetchBundle(config *Config) error {
+	key, err := bundleKey()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	client.SetTokens(config.SourceExtraTokens, config.TokenRPM)
+	if config.SourceTokenCommand != "" {
+		if err := client.SetTokenProvider(api.CommandTokenProvider(config.SourceTokenCommand), config.TokenRefreshInterval); err != nil {
+			return fmt.Errorf("failed to obtain source token: %w", err)
+		}
+	}
+	client.SetReadRetry(config.ReadRetries, config.ReadRetryWait, config.ReadRetryMaxWait, config.ReadRetryBudget)
+	if config.RateLimitPacing {
+		client.SetRateLimitPacing(config.RateLimitHeadroom, config.Debug)
+	}
+	if err := client.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+		return err
+	}
+	client.SetReadOnly()
+
+	b := &handoff.Bundle{
+		SourceProject: config.SourceProject,
+		SourceBaseURL: config.SourceBaseURL,
+		AfterDate:     config.AfterDate,
+	}
+	fmt.Printf("Fetching %s for a post-only migration (results after %s)\n", config.SourceProject, config.AfterDate.Format("2006-01-02 15:04:05"))
+
+	fmt.Println("Fetching source cases...")
+	if b.Cases, err = qase.GetCases(client, config.SourceProject, qase.CaseListOptions{}); err != nil {
+		return fmt.Errorf("failed to fetch source cases: %w", err)
+	}
+	fmt.Println("Fetching source results...")
+	b.Results, err = qase.FetchResults(client, config.SourceProject, qase.FetchOptions{
+		Mode:      config.FetchMode,
+		AfterDate: config.AfterDate,
+		RunIDs:    config.FetchRunIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch results: %w", err)
+	}
+	fmt.Println("Fetching source runs...")
+	if b.Runs, err = qase.GetRuns(client, config.SourceProject, qase.RunListOptions{FromStartTime: config.AfterDate}); err != nil {
+		return fmt.Errorf("failed to list source runs: %w", err)
+	}
+	if b.Statuses, err = qase.GetResultStatuses(client); err != nil {
+		fmt.Printf("Warning: Could not fetch source result statuses, the post stage matches statuses by slug only: %v\n", err)
+	}
+	if config.MatchMode == mapping.ModeExternalID {
+		if b.ExternalIDField, err = qase.ResolveCustomField(client, config.SourceProject, config.SourceExternalIDField); err != nil {
+			return fmt.Errorf("failed to resolve source external ID field: %w", err)
+		}
+	}
+
+	b.FetchedAt = time.Now().UTC()
+	location, err := handoff.Write(config.BundleLocation, b, key, config.Force)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	fmt.Printf("Wrote encrypted bundle to %s: %d cases, %d results in %d runs\n", location, len(b.Cases), len(b.Results), len(b.Runs))
+	fmt.Printf("Migrate it where the target token is with: QASE_BUNDLE=%s %s %s\n", shellQuote(location), executable(), postOnlyCommand)
+	return nil
+}
+
+// loadBundle reads the bundle of a post-only migration into config, which
+// takes the source project and date cutoff from it
+func loadBundle(config *Config) error {
+	key, err := bundleKey()
+	if err != nil {
+		return err
+	}
+	b, err := handoff.Read(config.BundleLocation, key)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle %s: %w", config.BundleLocation, err)
+	}
+	if config.SourceProject != "" && config.SourceProject != b.SourceProject {
+		return fmt.Errorf("the bundle holds project %s, not QASE_SOURCE_PROJECT %s", b.SourceProject, config.SourceProject)
+	}
+	config.SourceProject = b.SourceProject
+	config.SourceBaseURL = b.SourceBaseURL
+	if config.AfterDate.IsZero() {
+		config.AfterDate = b.AfterDate
+	}
+	config.Source = b
+	fmt.Printf("Loaded bundle of %s fetched %s: %d cases, %d results\n", b.SourceProject, b.FetchedAt.Format("2006-01-02 15:04:05 UTC"), len(b.Cases), len(b.Results))
+	return nil
+}
+
+// runBundle is the bundle helper (`clone-run-multi-ws bundle keygen|encrypt|decrypt|inspect`)
+func runBundle(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s keygen | encrypt --in <file> --out <file> | decrypt --in <file> --out <file> | inspect --in <file>\n", os.Args[0], bundleCommand)
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+	if args[0] == "keygen" {
+		key, err := seal.GenerateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	flags := flag.NewFlagSet(bundleCommand+" "+args[0], flag.ExitOnError)
+	in := flags.String("in", "", "input file (local path, s3:// or gs://)")
+	out := flags.String("out", "", "output file (local path, s3:// or gs://)")
+	flags.Parse(args[1:])
+	if *in == "" || (*out == "" && args[0] != "inspect") {
+		usage()
+	}
+	key, err := bundleKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := artifact.Read(*in)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+
+	switch args[0] {
+	case "encrypt":
+		if seal.IsSealed(data) {
+			log.Fatalf("%s is already encrypted", *in)
+		}
+		if data, err = seal.Seal(key, data); err != nil {
+			log.Fatal(err)
+		}
+	case "decrypt":
+		if data, err = seal.Open(key, data); err != nil {
+			log.Fatalf("Failed to decrypt %s: %v", *in, err)
+		}
+	case "inspect":
+		b, err := handoff.Decode(data, key)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *in, err)
+		}
+		printBundle(b)
+		return
+	default:
+		usage()
+	}
+	if err := artifact.Write(*out, data); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("Wrote %s\n", *out)
+}
+
+// printBundle summarizes a bundle without printing its data
+func printBundle(b *handoff.Bundle) {
+	fmt.Printf("Source project: %s (%s)\n", b.SourceProject, b.SourceBaseURL)
+	fmt.Printf("Fetched: %s\n", b.FetchedAt.Format("2006-01-02 15:04:05 UTC"))
+	fmt.Printf("Results after: %s\n", b.AfterDate.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Cases: %d\n", len(b.Cases))
+	fmt.Printf("Runs: %d\n", len(b.Runs))
+	counts := make(map[string]int)
+	runs := make(map[int]bool)
+	for _, result := range b.Results {
+		counts[result.Status]++
+		runs[result.RunID] = true
+	}
+	fmt.Printf("Results: %d in %d runs (%s)\n", len(b.Results), len(runs), formatCounts(counts))
+	if b.ExternalIDField != 0 {
+		fmt.Printf("External ID field: %d\n", b.ExternalIDField)
+	}
+	statuses := make([]string, 0, len(b.Statuses))
+	for slug := range b.Statuses {
+		statuses = append(statuses, slug)
+	}
+	sort.Strings(statuses)
+	if len(statuses) > 0 {
+		fmt.Printf("Source statuses: %s\n", strings.Join(statuses, ", "))
+	}
+}
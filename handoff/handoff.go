@@ -0,0 +1,93 @@
+package handoff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/seal"
+)
+
+// Version is the bundle format written by this build
+const Version = 1
+
+// Bundle is what a fetch-only stage hands to a post-only stage: everything a
+// migration reads from the source workspace, so that each stage needs the
+// token of one workspace only
+type Bundle struct {
+	Version       int                          `json:"version"`
+	SourceProject string                       `json:"source_project"`
+	SourceBaseURL string                       `json:"source_base_url"`
+	AfterDate     time.Time                    `json:"after_date"`
+	FetchedAt     time.Time                    `json:"fetched_at"`
+	Cases         map[int]qase.Case            `json:"cases"`
+	Results       []qase.Result                `json:"results"`
+	Runs          []qase.Run                   `json:"runs"`               // source runs started after AfterDate
+	Statuses      map[string]qase.ResultStatus `json:"statuses,omitempty"` // nil when the source does not list them
+
+	// ExternalIDField is the source field joined on in external_id mode
+	ExternalIDField int `json:"external_id_field,omitempty"`
+}
+
+// Encode compresses and seals a bundle with key
+func Encode(b *Bundle, key []byte) ([]byte, error) {
+	b.Version = Version
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(b); err != nil {
+		return nil, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress bundle: %w", err)
+	}
+	return seal.Seal(key, buf.Bytes())
+}
+
+// Decode opens a bundle sealed by Encode
+func Decode(data, key []byte) (*Bundle, error) {
+	plain, err := seal.Open(key, data)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	var b Bundle
+	if err := json.Unmarshal(decoded, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("bundle format %d is not supported by this build (expected %d)", b.Version, Version)
+	}
+	return &b, nil
+}
+
+// Write seals a bundle to location (a local path, s3:// or gs://) and
+// returns where it was written; an existing bundle is only replaced with
+// force
+func Write(location string, b *Bundle, key []byte, force bool) (string, error) {
+	data, err := Encode(b, key)
+	if err != nil {
+		return "", err
+	}
+	return artifact.WriteProtected(location, data, force)
+}
+
+// Read reads and opens the bundle at location
+func Read(location string, key []byte) (*Bundle, error) {
+	data, err := artifact.Read(location)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data, key)
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Status is the body returned by the health endpoints
+type Status struct {
+	Ready        bool       `json:"ready"`
+	Stopping     bool       `json:"stopping"`
+	Running      bool       `json:"running"`
+	Cycles       int        `json:"cycles"`
+	LastCycleEnd *time.Time `json:"last_cycle_end,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// Server exposes /healthz and /readyz for orchestrators such as Kubernetes
+type Server struct {
+	stallTimeout time.Duration
+	srv          *http.Server
+
+	mu           sync.Mutex
+	stopping     bool
+	running      bool
+	cycleStart   time.Time
+	cycles       int
+	lastCycleEnd time.Time
+	lastErr      error
+}
+
+// Start serves the health endpoints on addr. It returns nil when addr is
+// empty; all Server methods are safe to call on a nil Server.
+//
+// /healthz fails when a cycle has made no successful API call for
+// stallTimeout; /readyz fails while shutting down or after a failed cycle.
+func Start(addr string, stallTimeout time.Duration) *Server {
+	if addr == "" {
+		return nil
+	}
+	if stallTimeout <= 0 {
+		stallTimeout = 15 * time.Minute
+	}
+
+	s := &Server{stallTimeout: stallTimeout}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, s.healthy())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, s.ready())
+	})
+	s.srv = &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Warning: Health server stopped: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Health endpoints listening on %s (/healthz, /readyz)\n", addr)
+	return s
+}
+
+// CycleStarted records the start of a replication cycle
+func (s *Server) CycleStarted() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = true
+	s.cycleStart = time.Now()
+}
+
+// CycleFinished records the end of a replication cycle and its outcome
+func (s *Server) CycleFinished(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.cycles++
+	s.lastCycleEnd = time.Now()
+	s.lastErr = err
+}
+
+// SetStopping marks the process as shutting down so /readyz fails
+func (s *Server) SetStopping() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopping = true
+}
+
+// Stop shuts down the health server
+func (s *Server) Stop() {
+	if s == nil {
+		return
+	}
+	s.srv.Close()
+}
+
+// healthy reports liveness: a running cycle must keep making progress
+func (s *Server) healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return true
+	}
+	progress := s.cycleStart
+	if last := api.LastSuccessfulCall(); last.After(progress) {
+		progress = last
+	}
+	return time.Since(progress) < s.stallTimeout
+}
+
+// ready reports readiness: not shutting down and the last cycle succeeded
+func (s *Server) ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readyLocked()
+}
+
+func (s *Server) readyLocked() bool {
+	return !s.stopping && s.lastErr == nil
+}
+
+func (s *Server) respond(w http.ResponseWriter, ok bool) {
+	s.mu.Lock()
+	status := Status{
+		Ready:    s.readyLocked(),
+		Stopping: s.stopping,
+		Running:  s.running,
+		Cycles:   s.cycles,
+	}
+	if !s.lastCycleEnd.IsZero() {
+		end := s.lastCycleEnd
+		status.LastCycleEnd = &end
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,234 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Status is the snapshot written to the status file for external watchdogs
+type Status struct {
+	Phase              string     `json:"phase"`
+	Project            string     `json:"project,omitempty"` // pair being migrated, SOURCE -> TARGET
+	StartedAt          time.Time  `json:"started_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	RunsTotal          int        `json:"runs_total"`
+	RunsCompleted      int        `json:"runs_completed"`
+	RunsFailed         int        `json:"runs_failed"`
+	ErrorCount         int        `json:"error_count"` // failed runs and pairs, over all pairs migrated so far
+	LastSuccessfulCall *time.Time `json:"last_successful_api_call,omitempty"`
+	PID                int        `json:"pid"`
+}
+
+// Event is a machine-readable progress event, emitted as one JSON line
+type Event struct {
+	Time          time.Time `json:"time"`
+	Event         string    `json:"event"`
+	Phase         string    `json:"phase"`
+	Project       string    `json:"project,omitempty"`
+	RunsTotal     int       `json:"runs_total"`
+	RunsCompleted int       `json:"runs_completed"`
+	RunsFailed    int       `json:"runs_failed"`
+	Percent       float64   `json:"percent"`
+}
+
+// Writer periodically writes the current status to a JSON file and emits
+// progress events
+type Writer struct {
+	path     string
+	interval time.Duration
+	events   *json.Encoder
+
+	mu     sync.Mutex
+	status Status
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start begins writing the status file every interval and, when events is
+// set, emits newline-delimited JSON progress events to it. It returns nil when
+// both are disabled; all Writer methods are safe to call on a nil Writer.
+func Start(path string, interval time.Duration, events io.Writer) *Writer {
+	if path == "" && events == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	now := time.Now()
+	w := &Writer{
+		path:     path,
+		interval: interval,
+		status: Status{
+			Phase:     "starting",
+			StartedAt: now,
+			PID:       os.Getpid(),
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if events != nil {
+		w.events = json.NewEncoder(events)
+	}
+
+	w.emit("start")
+	if path == "" {
+		close(w.done)
+		return w
+	}
+
+	w.write()
+	go w.loop()
+
+	fmt.Printf("Writing status heartbeat to %s every %v\n", path, interval)
+	return w
+}
+
+// SetPhase records the current migration phase
+func (w *Writer) SetPhase(phase string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.status.Phase = phase
+	w.mu.Unlock()
+	w.emit("phase")
+	w.write()
+}
+
+// StartPair records the project pair being migrated and the number of its
+// runs to be processed. Run counts restart for each pair, so a multi-project
+// migration never reports more runs completed than it has.
+func (w *Writer) StartPair(source, target string, total int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.status.Project = source + " -> " + target
+	w.status.RunsTotal = total
+	w.status.RunsCompleted = 0
+	w.status.RunsFailed = 0
+	w.mu.Unlock()
+}
+
+// RunCompleted records a finished run
+func (w *Writer) RunCompleted(success bool) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.status.RunsCompleted++
+	if !success {
+		w.status.RunsFailed++
+	}
+	w.mu.Unlock()
+	w.emit("run_completed")
+}
+
+// AddError counts a failed run or project pair
+func (w *Writer) AddError() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.status.ErrorCount++
+	w.mu.Unlock()
+}
+
+// Stop writes the final phase and stops the periodic writer
+func (w *Writer) Stop(finalPhase string) {
+	if w == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.SetPhase(finalPhase)
+}
+
+// emit writes a progress event with the current counts
+func (w *Writer) emit(event string) {
+	if w.events == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := Event{
+		Time:          time.Now().UTC(),
+		Event:         event,
+		Phase:         w.status.Phase,
+		Project:       w.status.Project,
+		RunsTotal:     w.status.RunsTotal,
+		RunsCompleted: w.status.RunsCompleted,
+		RunsFailed:    w.status.RunsFailed,
+	}
+	if e.RunsTotal > 0 {
+		e.Percent = min(float64(e.RunsCompleted*1000/e.RunsTotal)/10, 100)
+	}
+	if err := w.events.Encode(e); err != nil {
+		fmt.Printf("Warning: Failed to write progress event: %v\n", err)
+	}
+}
+
+func (w *Writer) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.write()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// write atomically replaces the status file with the current snapshot
+func (w *Writer) write() {
+	if w.path == "" {
+		return
+	}
+
+	w.mu.Lock()
+	w.status.UpdatedAt = time.Now()
+	if last := api.LastSuccessfulCall(); !last.IsZero() {
+		w.status.LastSuccessfulCall = &last
+	}
+	data, err := json.MarshalIndent(w.status, "", "  ")
+	w.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("Warning: Failed to marshal status: %v\n", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), ".status-*.json")
+	if err != nil {
+		fmt.Printf("Warning: Failed to write status file: %v\n", err)
+		return
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		fmt.Printf("Warning: Failed to write status file: %v\n", err)
+		return
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		os.Remove(tmp.Name())
+		fmt.Printf("Warning: Failed to write status file: %v\n", err)
+	}
+}
@@ -0,0 +1,337 @@
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// errLost is returned when refreshing or releasing a lock that another
+// process took over
+var errLost = errors.New("lock is no longer held")
+
+// TargetRun is the QASE_LOCK value that keeps the lock as a marker run in the
+// target project, so no shared storage is needed
+const TargetRun = "target"
+
+// Holder identifies the process holding a lock
+type Holder struct {
+	Owner      string    `json:"owner"`
+	Pair       string    `json:"pair"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// HeldError is returned by Acquire when another live process holds the lock
+type HeldError struct {
+	Location string
+	Holder   Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("migration %s is already running on %s (pid %d) since %s; lock %s expires at %s",
+		e.Holder.Pair, e.Holder.Host, e.Holder.PID, e.Holder.AcquiredAt.Format(time.RFC3339),
+		e.Location, e.Holder.ExpiresAt.Format(time.RFC3339))
+}
+
+// backend stores a lock
+type backend interface {
+	// acquire takes the lock, returning the current holder if it is held
+	acquire(h Holder) (*Holder, error)
+	// refresh extends the lock's expiry
+	refresh(h Holder) error
+	release(h Holder) error
+	describe() string
+}
+
+// Lock is a held migration lock for one source -> target project pair
+type Lock struct {
+	backend backend
+	holder  Holder
+	ttl     time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Acquire takes the lock for a project pair. location is a directory or
+// s3:// / gs:// prefix holding one lock object per pair, or TargetRun for a
+// marker run in the target project. A lock whose holder stopped refreshing it
+// for ttl is considered abandoned and taken over. A non-empty scope (e.g. a
+// shard) gives parallel jobs of the same pair separate locks. It returns nil
+// when location is empty; Release is safe to call on a nil Lock.
+func Acquire(location string, tgt *api.Client, srcProject, tgtProject, scope string, ttl time.Duration) (*Lock, error) {
+	if location == "" {
+		return nil, nil
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	pair := srcProject + " -> " + tgtProject
+	name := srcProject + "-" + tgtProject
+	if scope != "" {
+		pair += " (" + scope + ")"
+		name += "-" + scope
+	}
+
+	var b backend
+	if location == TargetRun {
+		b = newRunBackend(tgt, tgtProject, pair)
+	} else {
+		if _, err := artifact.For(location); err != nil {
+			return nil, err
+		}
+		object := artifact.Join(location, name+".lock")
+		if strings.Contains(location, "://") {
+			b = objectBackend{location: object}
+		} else {
+			b = fileBackend{path: object}
+		}
+	}
+
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	holder := Holder{
+		Owner:      newOwnerID(),
+		Pair:       pair,
+		Host:       host,
+		PID:        os.Getpid(),
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	current, err := b.acquire(holder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", b.describe(), err)
+	}
+	if current != nil {
+		return nil, &HeldError{Location: b.describe(), Holder: *current}
+	}
+
+	l := &Lock{
+		backend: b,
+		holder:  holder,
+		ttl:     ttl,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go l.keepAlive()
+
+	fmt.Printf("Acquired migration lock %s\n", b.describe())
+	return l, nil
+}
+
+// Release stops refreshing the lock and removes it
+func (l *Lock) Release() {
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+
+	if err := l.backend.release(l.holder); err != nil {
+		fmt.Printf("Warning: Failed to release migration lock %s: %v\n", l.backend.describe(), err)
+		return
+	}
+	fmt.Printf("Released migration lock %s\n", l.backend.describe())
+}
+
+// keepAlive extends the lock's expiry while the migration runs
+func (l *Lock) keepAlive() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.holder.ExpiresAt = time.Now().UTC().Add(l.ttl)
+			if err := l.backend.refresh(l.holder); err != nil {
+				fmt.Printf("Warning: Failed to refresh migration lock %s: %v\n", l.backend.describe(), err)
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// live reports whether a stored holder still owns the lock
+func live(h *Holder) bool {
+	return h != nil && time.Now().Before(h.ExpiresAt)
+}
+
+func newOwnerID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// fileBackend keeps the lock in a local file created exclusively
+type fileBackend struct {
+	path string
+}
+
+func (f fileBackend) acquire(h Holder) (*Holder, error) {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.Write(data)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			return nil, err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		current, err := readHolder(f.path)
+		if err != nil {
+			return nil, err
+		}
+		if live(current) {
+			return current, nil
+		}
+
+		// Abandoned lock: remove it and retry once
+		fmt.Printf("Taking over expired migration lock %s\n", f.path)
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("lock file was recreated concurrently")
+}
+
+func (f fileBackend) refresh(h Holder) error {
+	if err := owned(f.path, h); err != nil {
+		return err
+	}
+	return writeHolder(f.path, h)
+}
+
+func (f fileBackend) release(h Holder) error {
+	if err := owned(f.path, h); err != nil {
+		return err
+	}
+	return artifact.Delete(f.path)
+}
+
+func (f fileBackend) describe() string {
+	return f.path
+}
+
+// objectBackend keeps the lock in object storage. Object stores offer no
+// exclusive create through the artifact sinks, so the lock is written and
+// read back after a settle delay; a concurrent writer that won is detected.
+type objectBackend struct {
+	location string
+}
+
+// objectSettleDelay is how long to wait before confirming an object lock
+var objectSettleDelay = 2 * time.Second
+
+func (o objectBackend) acquire(h Holder) (*Holder, error) {
+	current, err := readHolder(o.location)
+	if err != nil {
+		return nil, err
+	}
+	if live(current) {
+		return current, nil
+	}
+
+	if err := writeHolder(o.location, h); err != nil {
+		return nil, err
+	}
+	time.Sleep(objectSettleDelay)
+
+	current, err = readHolder(o.location)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.Owner != h.Owner {
+		return current, nil
+	}
+	return nil, nil
+}
+
+func (o objectBackend) refresh(h Holder) error {
+	if err := owned(o.location, h); err != nil {
+		return err
+	}
+	return writeHolder(o.location, h)
+}
+
+func (o objectBackend) release(h Holder) error {
+	if err := owned(o.location, h); err != nil {
+		return err
+	}
+	return artifact.Delete(o.location)
+}
+
+func (o objectBackend) describe() string {
+	return o.location
+}
+
+// owned checks that the lock at location is still held by h, so that a
+// process whose lock was taken over neither extends nor removes the new
+// holder's lock
+func owned(location string, h Holder) error {
+	current, err := readHolder(location)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("%w: %s was removed", errLost, location)
+	}
+	if current.Owner != h.Owner {
+		return fmt.Errorf("%w: taken over by %s (pid %d) at %s", errLost, current.Host, current.PID, current.AcquiredAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// readHolder loads a stored holder, returning nil when there is none
+func readHolder(location string) (*Holder, error) {
+	data, err := artifact.Read(location)
+	if errors.Is(err, artifact.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		// An unreadable lock (e.g. half-written) is treated as abandoned
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func writeHolder(location string, h Holder) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return artifact.Write(location, data)
+}
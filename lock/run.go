@@ -0,0 +1,172 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// runBackend keeps the lock as an active marker run in the target project whose
+// description holds the holder. Concurrent creators are resolved by re-listing
+// after creation: the live marker run with the lowest ID wins and the others
+// delete their own run. Refreshing rewrites the description with the new
+// expiry.
+type runBackend struct {
+	client  *api.Client
+	lookup  *api.Client
+	project string
+	title   string
+	runID   int
+}
+
+func newRunBackend(tgt *api.Client, tgtProject, pair string) *runBackend {
+	return &runBackend{
+		client: tgt,
+		// Lookups bypass the response cache so other operators' runs are seen
+		lookup:  tgt.Uncached(),
+		project: tgtProject,
+		title:   "[migration-lock] " + pair,
+	}
+}
+
+// markerRun is a lock marker run with its decoded holder
+type markerRun struct {
+	id     int
+	holder *Holder
+}
+
+func (r *runBackend) acquire(h Holder) (*Holder, error) {
+	ttl := h.ExpiresAt.Sub(h.AcquiredAt)
+
+	markers, err := r.markers(ttl)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range markers {
+		if live(m.holder) {
+			return m.holder, nil
+		}
+	}
+	r.removeExpired(markers)
+
+	description, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	run, err := qase.CreateRun(r.client, r.project, r.title, string(description))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock run: %w", err)
+	}
+	r.runID = run.ID
+
+	// Another operator may have created a marker run at the same time
+	markers, err = r.markers(ttl)
+	if err != nil {
+		r.release(h)
+		return nil, err
+	}
+	for _, m := range markers {
+		if m.id == r.runID || !live(m.holder) {
+			continue
+		}
+		if m.id < r.runID {
+			r.release(h)
+			return m.holder, nil
+		}
+	}
+	return nil, nil
+}
+
+// markers lists the marker runs of this pair that could still be live
+func (r *runBackend) markers(ttl time.Duration) ([]markerRun, error) {
+	runs, err := qase.GetRuns(r.lookup, r.project, qase.RunListOptions{
+		FromStartTime: time.Now().Add(-ttl - time.Hour),
+		Status:        []string{qase.RunStatusActive},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock runs: %w", err)
+	}
+
+	var markers []markerRun
+	for _, run := range runs {
+		if run.Title != r.title {
+			continue
+		}
+		m := markerRun{id: run.ID}
+		if run.Description != nil {
+			var h Holder
+			if json.Unmarshal([]byte(*run.Description), &h) == nil {
+				m.holder = &h
+			}
+		}
+		markers = append(markers, m)
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].id < markers[j].id })
+	return markers, nil
+}
+
+// removeExpired deletes abandoned marker runs so they do not pile up
+func (r *runBackend) removeExpired(markers []markerRun) {
+	for _, m := range markers {
+		fmt.Printf("Taking over expired migration lock run %d\n", m.id)
+		if err := qase.DeleteRun(r.client, r.project, m.id); err != nil {
+			fmt.Printf("Warning: Failed to delete expired lock run %d: %v\n", m.id, err)
+		}
+	}
+}
+
+func (r *runBackend) refresh(h Holder) error {
+	if err := r.owned(h); err != nil {
+		return err
+	}
+	description, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return qase.UpdateRunDescription(r.client, r.project, r.runID, string(description))
+}
+
+func (r *runBackend) release(h Holder) error {
+	if r.runID == 0 {
+		return nil
+	}
+	if err := r.owned(h); err != nil {
+		if errors.Is(err, errLost) {
+			r.runID = 0
+		}
+		return err
+	}
+	if err := qase.DeleteRun(r.client, r.project, r.runID); err != nil {
+		return err
+	}
+	r.runID = 0
+	return nil
+}
+
+// owned checks that the marker run still exists and holds h
+func (r *runBackend) owned(h Holder) error {
+	run, err := qase.GetRunByID(r.lookup, r.project, r.runID)
+	if qase.IsNotFound(err) {
+		return fmt.Errorf("%w: lock run %d was deleted", errLost, r.runID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lock run: %w", err)
+	}
+	var current Holder
+	if run.Description == nil || json.Unmarshal([]byte(*run.Description), &current) != nil || current.Owner != h.Owner {
+		return fmt.Errorf("%w: lock run %d no longer holds this migration", errLost, r.runID)
+	}
+	return nil
+}
+
+func (r *runBackend) describe() string {
+	if r.runID != 0 {
+		return fmt.Sprintf("run %d in %s", r.runID, r.project)
+	}
+	return fmt.Sprintf("%q in %s", r.title, r.project)
+}
@@ -1,21 +1,86 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/checkpoint"
+	"github.com/adrianeortiz/clone-run-multi-ws/control"
+	"github.com/adrianeortiz/clone-run-multi-ws/dedupe"
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/errclass"
+	"github.com/adrianeortiz/clone-run-multi-ws/handoff"
+	"github.com/adrianeortiz/clone-run-multi-ws/heartbeat"
+	"github.com/adrianeortiz/clone-run-multi-ws/lock"
 	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/notify"
+	"github.com/adrianeortiz/clone-run-multi-ws/profiling"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/review"
+	"github.com/adrianeortiz/clone-run-multi-ws/settings"
+	"github.com/adrianeortiz/clone-run-multi-ws/target"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+	"github.com/adrianeortiz/clone-run-multi-ws/triage"
+	"github.com/adrianeortiz/clone-run-multi-ws/tui"
 	"github.com/adrianeortiz/clone-run-multi-ws/utils"
+	"github.com/adrianeortiz/clone-run-multi-ws/warehouse"
 )
 
 func main() {
+	// Map prefixed variables and check for unknown ones
+	if err := envcfg.Apply(); err != nil {
+		log.Fatalf("Invalid environment: %v", err)
+	}
+
+	// Post runs staged for review
+	if len(os.Args) > 1 && os.Args[1] == approveCommand {
+		runApprove(os.Args[2:])
+		return
+	}
+
+	// Migrations submitted over HTTP, each run as a child process
+	if len(os.Args) > 1 && os.Args[1] == serveCommand {
+		runServe(os.Args[2:])
+		return
+	}
+
+	// Bundle keys and encryption for fetch-only and post-only migrations
+	if len(os.Args) > 1 && os.Args[1] == bundleCommand {
+		runBundle(os.Args[2:])
+		return
+	}
+
+	// Machine-readable progress: JSON events on stdout, human output on stderr
+	var progress io.Writer
+	switch mode := getEnvDefault("QASE_PROGRESS", "text"); mode {
+	case "text":
+	case "json":
+		progress = os.Stdout
+		os.Stdout = os.Stderr
+	default:
+		log.Fatalf("Invalid QASE_PROGRESS: %s (must be text or json)", mode)
+	}
+
+	// Debug: report unexpected API payload shapes that are otherwise
+	// decoded leniently
+	if getEnvDefault("QASE_STRICT_DECODE", "false") == "true" || slices.Contains(os.Args[1:], "--strict-decode") {
+		qase.SetStrictDecode(true)
+	}
+
 	// Debug: Print environment variables (without secrets)
 	fmt.Println("=== Environment Debug ===")
 	fmt.Printf("QASE_SOURCE_PROJECT: %s\n", os.Getenv("QASE_SOURCE_PROJECT"))
@@ -23,6 +88,7 @@ func main() {
 	fmt.Printf("QASE_AFTER_DATE: %s\n", os.Getenv("QASE_AFTER_DATE"))
 	fmt.Printf("QASE_MATCH_MODE: %s\n", os.Getenv("QASE_MATCH_MODE"))
 	fmt.Printf("QASE_CF_ID: %s\n", os.Getenv("QASE_CF_ID"))
+	fmt.Printf("QASE_CF_NAME: %s\n", os.Getenv("QASE_CF_NAME"))
 	fmt.Printf("QASE_DRY_RUN: %s\n", os.Getenv("QASE_DRY_RUN"))
 	fmt.Printf("QASE_SOURCE_API_TOKEN: %s\n", maskToken(os.Getenv("QASE_SOURCE_API_TOKEN")))
 	fmt.Printf("QASE_TARGET_API_TOKEN: %s\n", maskToken(os.Getenv("QASE_TARGET_API_TOKEN")))
@@ -34,32 +100,322 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Create API clients
-	srcClient := api.NewClient(config.SourceBaseURL, config.SourceToken)
+	// Enable tracing when an OTLP endpoint is configured
+	tracing.Init("clone-run-multi-ws")
+	defer tracing.Shutdown()
+
+	// Split migration: fetch-only stops at the bundle, post-only starts from it
+	switch config.Role {
+	case fetchOnlyCommand:
+		if err := fetchBundle(config); err != nil {
+			tracing.Shutdown()
+			log.Fatalf("Fetch failed: %v", err)
+		}
+		return
+	case postOnlyCommand:
+		if err := loadBundle(config); err != nil {
+			log.Fatalf("Failed to load bundle: %v", err)
+		}
+	}
+
+	// Live heap, goroutine and CPU profiles for diagnosing long migrations
+	profiling.Start(config.PprofAddr)
+
+	// Start status heartbeat for external monitoring
+	status := heartbeat.Start(config.StatusFile, config.StatusInterval, progress)
+
+	// Operator controls: pause/resume/skip via signals or the control server
+	ctl := control.Start(config.ControlAddr)
+	defer ctl.Stop()
+
+	// Interactive dashboard in place of the scrolling log
+	if config.TUI {
+		if progress != nil {
+			log.Fatalf("QASE_TUI cannot be combined with QASE_PROGRESS=json")
+		}
+		config.Dashboard, err = tui.Start(ctl, config.TUILog)
+		if err != nil {
+			fmt.Printf("Warning: QASE_TUI ignored: %v\n", err)
+		}
+		defer config.Dashboard.Stop()
+	}
+
+	// Single project pair from the environment, several from a batch file,
+	// or every project of the source workspace
+	configs := []*Config{config}
+	if config.BatchFile != "" {
+		configs, err = loadBatchConfigs(config, config.BatchFile)
+		if err != nil {
+			log.Fatalf("Failed to load batch file: %v", err)
+		}
+	}
+	if config.Workspace {
+		configs, err = loadWorkspaceConfigs(config)
+		if err != nil {
+			log.Fatalf("Failed to list workspace projects: %v", err)
+		}
+	}
+
+	// Long-lived replicator: repeat the migration until terminated
+	if config.WatchInterval > 0 {
+		watch(config, configs, status, ctl)
+		status.Stop("stopped")
+		config.Dashboard.Stop()
+		return
+	}
+
+	if err := runCycle(config, configs, status, ctl); err != nil {
+		status.Stop("failed")
+		config.Dashboard.Stop()
+		tracing.Shutdown()
+		log.Fatalf("Migration failed: %v", err)
+	}
+	status.Stop("completed")
+	config.Dashboard.Stop()
+}
+
+// runCycle migrates the configured project pair, or every pair of a batch
+func runCycle(config *Config, configs []*Config, status *heartbeat.Writer, ctl *control.Controller) error {
+	// Latency per endpoint family helps tell a slow API from slow local work
+	api.ResetLatencyStats()
+	defer api.PrintLatencyStats()
+
+	if config.BatchFile == "" && !config.Workspace {
+		return migrateProject(configs[0], status, ctl)
+	}
+	if config.Workspace {
+		return migrateWorkspace(config, configs, status, ctl)
+	}
+
+	failedPairs := 0
+	for i, pairConfig := range configs {
+		fmt.Printf("\n##### Project pair %d/%d: %s -> %s #####\n", i+1, len(configs), pairConfig.SourceProject, pairConfig.TargetProject)
+		if err := migrateProject(pairConfig, status, ctl); err != nil {
+			log.Printf("Migration of %s -> %s failed: %v", pairConfig.SourceProject, pairConfig.TargetProject, err)
+			failedPairs++
+		}
+	}
+
+	fmt.Printf("\n=== Batch Summary ===\n")
+	fmt.Printf("Project pairs: %d\n", len(configs))
+	fmt.Printf("Failed pairs: %d\n", failedPairs)
+
+	if failedPairs > 0 {
+		return fmt.Errorf("%d of %d project pairs failed", failedPairs, len(configs))
+	}
+	return nil
+}
+
+// migrateProject migrates results for a single source -> target project pair
+func migrateProject(config *Config, status *heartbeat.Writer, ctl *control.Controller) (err error) {
+	// Alert on a failed migration or too many failed runs, and resolve the
+	// alert once the pair migrates cleanly again
+	var runsTotal, runsFailed int
+	defer func() {
+		if err != nil {
+			status.AddError()
+		}
+		config.Alerts.Report(config.SourceProject, config.TargetProject, err, runsTotal, runsFailed)
+	}()
+
+	// The migration ticket and email hear about every outcome, including
+	// failures before any run was migrated; a cycle with nothing to migrate
+	// is not news
+	quiet := false
+	summary := notify.Summary{
+		SourceProject: config.SourceProject,
+		TargetProject: config.TargetProject,
+		DryRun:        config.DryRun,
+		ReportURL:     config.ReportURL,
+	}
+	began := time.Now()
+	defer func() {
+		if summary.Duration == 0 {
+			summary.Duration = time.Since(began)
+		}
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		if config.Outcome != nil {
+			*config.Outcome = summary
+		}
+		if !quiet {
+			notifySummary(config, summary)
+		}
+	}()
+
+	// Run IDs are per project: skips of an earlier pair or cycle do not apply
+	ctl.ClearSkips()
+
+	// Refuse protected target projects before doing any work
+	if !config.DryRun {
+		if err := qase.CheckWritable(config.TargetProject, config.ProtectedProjects, config.ProtectedOverride); err != nil {
+			return err
+		}
+	}
+
+	// Everything left behind is collected into one needs-attention report
+	attention := triage.New()
+	defer func() {
+		if err := attention.Write(artifact.Join(config.ArtifactDir, config.NeedsAttentionFile), config.Force); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}()
+
+	// Create API clients; a post-only migration reads the source from its
+	// bundle and has no source client
+	var srcClient *api.Client
+	clients := []*api.Client{}
+	if config.Source == nil {
+		srcClient = api.NewClient(config.SourceBaseURL, config.SourceToken)
+		srcClient.SetTokens(config.SourceExtraTokens, config.TokenRPM)
+		clients = append(clients, srcClient)
+	}
+	src := source{client: srcClient, bundle: config.Source}
 	tgtClient := api.NewClient(config.TargetBaseURL, config.TargetToken)
+	tgtClient.SetTokens(config.TargetExtraTokens, config.TokenRPM)
+	clients = append(clients, tgtClient)
+
+	// Short-lived tokens from an external provider (SSO helpers)
+	if srcClient != nil && config.SourceTokenCommand != "" {
+		if err := srcClient.SetTokenProvider(api.CommandTokenProvider(config.SourceTokenCommand), config.TokenRefreshInterval); err != nil {
+			return fmt.Errorf("failed to obtain source token: %w", err)
+		}
+	}
+	if config.TargetTokenCommand != "" {
+		if err := tgtClient.SetTokenProvider(api.CommandTokenProvider(config.TargetTokenCommand), config.TokenRefreshInterval); err != nil {
+			return fmt.Errorf("failed to obtain target token: %w", err)
+		}
+	}
+	tgtClient.Breaker = api.NewBreaker(config.BreakerThreshold, config.BreakerCooldown)
+	tgtClient.RetryBudget = api.NewRetryBudget(config.RetryBudget)
+	tgtClient.Gate = ctl
+	tgtClient.MaxPayloadBytes = config.MaxPayloadBytes
+
+	// Partition the target request rate fairly among post workers
+	tgtClient.SetFairShare(config.TargetRPM)
+
+	// Read-through cache for cases, suites, runs and custom fields, in front
+	// of backoff for rate-limited or failing reads
+	for _, client := range clients {
+		client.SetReadRetry(config.ReadRetries, config.ReadRetryWait, config.ReadRetryMaxWait, config.ReadRetryBudget)
+		if config.RateLimitPacing {
+			client.SetRateLimitPacing(config.RateLimitHeadroom, config.Debug)
+		}
+		if err := client.SetCache(config.CacheDir, config.CacheTTL); err != nil {
+			return err
+		}
+	}
+
+	// A dry run or a review stage must never write to the target workspace
+	if config.readsOnly() {
+		tgtClient.SetReadOnly()
+	}
+
+	// Probe optional API features once instead of falling back per request
+	if srcClient != nil {
+		srcCaps := qase.DetectCapabilities(srcClient, config.SourceProject)
+		fmt.Printf("Source API capabilities: %s\n", srcCaps)
+	}
+	tgtCaps := qase.DetectCapabilities(tgtClient, config.TargetProject)
+	fmt.Printf("Target API capabilities: %s\n", tgtCaps)
+
+	// Fail early when a token cannot read or write what the migration needs
+	if err := checkPermissions(config, srcClient, tgtClient); err != nil {
+		return err
+	}
+
+	// One migration per project pair at a time (dry runs and review stages
+	// do not write)
+	if !config.readsOnly() {
+		scope := ""
+		if config.Shard.count > 1 {
+			scope = "shard-" + strings.ReplaceAll(config.Shard.String(), "/", "-of-")
+		}
+		pairLock, err := lock.Acquire(config.Lock, tgtClient, config.SourceProject, config.TargetProject, scope, config.LockTTL)
+		if err != nil {
+			return err
+		}
+		defer pairLock.Release()
+	}
+
+	// Results posted by any invocation sharing the index are never posted again
+	var postedIndex *dedupe.Index
+	if !config.readsOnly() {
+		var err error
+		postedIndex, err = dedupe.Open(config.DedupeIndex, config.DedupeClaimTTL)
+		if err != nil {
+			return err
+		}
+		defer postedIndex.Close()
+	}
+
+	// Phases are reported to the heartbeat and timed for the summary
+	phases := newPhaseTimer()
+	dash := config.Dashboard
+	dash.Project(config.SourceProject, config.TargetProject)
+	if srcClient != nil {
+		dash.Watch("source", srcClient)
+	}
+	dash.Watch("target", tgtClient)
+	setPhase := func(phase string) {
+		status.SetPhase(phase)
+		dash.SetPhase(phase)
+		phases.start(phase)
+	}
 
 	fmt.Printf("Starting cross-workspace migration from %s to %s\n", config.SourceProject, config.TargetProject)
 	fmt.Printf("Filtering runs after: %s\n", config.AfterDate.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Mapping mode: %s\n", config.MatchMode)
 
 	// Fetch cases from both workspaces
+	setPhase("fetching_cases")
 	fmt.Println("Fetching source cases...")
-	srcCases, err := qase.GetCases(srcClient, config.SourceProject)
+	span := tracing.Start("fetch.cases", nil)
+	span.SetAttr("qase.project", config.SourceProject)
+	srcCases, err := src.cases(config.SourceProject)
 	if err != nil {
-		log.Fatalf("Failed to fetch source cases: %v", err)
+		span.SetError(err)
+		span.End()
+		return fmt.Errorf("failed to fetch source cases: %w", err)
 	}
+	span.SetAttr("cases.count", len(srcCases))
+	span.End()
 
 	fmt.Println("Fetching target cases...")
-	tgtCases, err := qase.GetCases(tgtClient, config.TargetProject)
+	span = tracing.Start("fetch.cases", nil)
+	span.SetAttr("qase.project", config.TargetProject)
+	tgtCases, err := qase.GetCases(tgtClient, config.TargetProject, qase.CaseListOptions{})
 	if err != nil {
-		log.Fatalf("Failed to fetch target cases: %v", err)
+		span.SetError(err)
+		span.End()
+		return fmt.Errorf("failed to fetch target cases: %w", err)
 	}
+	span.SetAttr("cases.count", len(tgtCases))
+	span.End()
 
-	// Build mapping
+	// Build mapping; it is built again to re-map results of target cases
+	// deleted during the migration
 	var caseMapping map[int]int
+	buildMapping := func(tgtCases map[int]qase.Case) (map[int]int, error) {
+		if config.MatchMode == mapping.ModeExternalID {
+			return buildExternalIDMapping(config, src, tgtClient, srcCases, tgtCases)
+		}
+		return mapping.Build(
+			config.MatchMode,
+			srcCases,
+			tgtCases,
+			config.CustomFieldID,
+			config.CFValueRules,
+			config.MappingCSV,
+		)
+	}
+	setPhase("building_mapping")
+	span = tracing.Start("mapping.build", nil)
+	span.SetAttr("mapping.mode", string(config.MatchMode))
 
-	// Check if source and target projects are the same
-	if config.SourceProject == config.TargetProject {
+	// Check if source and target are the same project
+	if config.sameProject() {
 		fmt.Println("Source and target projects are the same - using direct case ID mapping")
 		caseMapping = make(map[int]int)
 		for caseID := range srcCases {
@@ -67,424 +423,1461 @@ func main() {
 		}
 		fmt.Printf("Built direct mapping with %d entries\n", len(caseMapping))
 	} else {
+		// Resolve the mapping field by name in the target project
+		if config.MatchMode == mapping.ModeCF && config.CustomFieldID == 0 {
+			cfID, err := qase.ResolveCustomFieldID(tgtClient, config.TargetProject, config.CustomFieldName)
+			if err != nil {
+				span.SetError(err)
+				span.End()
+				return fmt.Errorf("failed to resolve QASE_CF_NAME: %w", err)
+			}
+			fmt.Printf("Resolved custom field %q to ID %d\n", config.CustomFieldName, cfID)
+			config.CustomFieldID = cfID
+		}
+
 		fmt.Printf("Building mapping using %s mode...\n", config.MatchMode)
-		caseMapping, err = mapping.Build(
-			mapping.Mode(config.MatchMode),
-			srcCases,
-			tgtCases,
-			config.CustomFieldID,
-			config.MappingCSV,
-		)
+		caseMapping, err = buildMapping(tgtCases)
 		if err != nil {
-			log.Fatalf("Failed to build mapping: %v", err)
+			span.SetError(err)
+			span.End()
+			return fmt.Errorf("failed to build mapping: %w", err)
 		}
 		fmt.Printf("Built mapping with %d entries\n", len(caseMapping))
 	}
+	if cases := config.Transform.Cases; len(cases.Skip) > 0 || len(cases.Force) > 0 {
+		fmt.Printf("Skipping %d listed source cases, forcing %d to fixed target cases\n", len(cases.Skip), len(cases.Force))
+	}
+	span.SetAttr("mapping.entries", len(caseMapping))
+	span.End()
 
-	// Write mapping artifact
-	if err := writeMappingArtifact(caseMapping); err != nil {
+	// Report drift from the pair's previous mapping, then write this one
+	baseline := artifact.Join(config.ArtifactDir, mappingBaselineName(config.SourceProject, config.TargetProject))
+	if err := reportMappingDrift(caseMapping, srcCases, tgtCases, baseline, config.ArtifactDir, config.Force); err != nil {
+		log.Printf("Warning: Failed to compare with the previous mapping: %v", err)
+	}
+	if err := saveMappingBaseline(caseMapping, baseline); err != nil {
+		log.Printf("Warning: Failed to save the mapping for the next comparison: %v", err)
+	}
+	mappingLocation := artifact.Join(config.ArtifactDir, "case_map.out.csv")
+	if err := writeMappingArtifact(caseMapping, srcCases, tgtCases, config.MappingTitles, config.Force, mappingLocation); err != nil {
 		log.Printf("Warning: Failed to write mapping artifact: %v", err)
 	}
 
+	// Persist the mapping into the target custom field for future custom_field runs
+	if config.PersistCFID != 0 && config.SourceProject != config.TargetProject {
+		fmt.Printf("Persisting mapping to target custom field %d...\n", config.PersistCFID)
+		mapping.Persist(tgtClient, config.TargetProject, caseMapping, tgtCases, config.PersistCFID, config.Concurrency, config.DryRun)
+	}
+
 	// Fetch all results after the specified date using results API
+	setPhase("fetching_results")
 	fmt.Printf("Fetching results from source project after %s...\n", config.AfterDate.Format("2006-01-02"))
 
 	startTime := time.Now()
 
 	// Fetch all results after the date directly - this should be much faster
-	allResults, err := qase.GetResultsAfterDate(srcClient, config.SourceProject, config.AfterDate)
+	span = tracing.Start("fetch.results", nil)
+	span.SetAttr("qase.project", config.SourceProject)
+	allResults, err := src.results(config.SourceProject, qase.FetchOptions{
+		Mode:      config.FetchMode,
+		AfterDate: config.AfterDate,
+		RunIDs:    config.FetchRunIDs,
+	})
 	if err != nil {
-		log.Fatalf("Failed to fetch results: %v", err)
+		span.SetError(err)
+		span.End()
+		return fmt.Errorf("failed to fetch results: %w", err)
 	}
+	span.SetAttr("results.count", len(allResults))
+	span.End()
 
 	fmt.Printf("Fetched %d total results in %v\n", len(allResults), time.Since(startTime))
 
 	if len(allResults) == 0 {
 		fmt.Println("No results found for the specified runs. Nothing to migrate.")
-		return
+		quiet = true
+		return nil
+	}
+
+	// Verify the result statuses exist in the target workspace
+	statusMap, err := checkStatuses(src, tgtClient, config.Transform.StatusMap, allResults, config.CreateStatuses, config.readsOnly())
+	var missingStatuses *missingStatusesError
+	if errors.As(err, &missingStatuses) {
+		for _, name := range missingStatuses.Statuses() {
+			attention.Add(triage.Item{
+				Kind:        triage.KindInvalidStatus,
+				Status:      name,
+				Results:     missingStatuses.Results[name],
+				Detail:      fmt.Sprintf("status %q does not exist in the target workspace", name),
+				Remediation: "create the status in the target workspace settings (or with QASE_CREATE_STATUSES=true) or map it with QASE_STATUS_MAP",
+			})
+		}
+	}
+	if err != nil {
+		return err
+	}
+	config.Transform.StatusMap = statusMap
+
+	// Group results by run ID (or by date bucket)
+	runGroups, err := guardRunSizes(groupResults(allResults, config.RunBucket), config.MaxResultsPerRun, config.OversizedRuns)
+	if err != nil {
+		return err
 	}
 
-	// Group results by run ID
-	resultsByRun := make(map[int][]qase.Result)
-	for _, result := range allResults {
-		resultsByRun[result.RunID] = append(resultsByRun[result.RunID], result)
+	fmt.Printf("Grouped results into %d runs\n", len(runGroups))
+
+	// Keep only this job's share of the runs when migrating in parallel shards
+	if config.Shard.count > 1 {
+		total := len(runGroups)
+		runGroups = config.Shard.filter(runGroups)
+		fmt.Printf("Shard %s: migrating %d of %d runs\n", config.Shard, len(runGroups), total)
 	}
 
-	fmt.Printf("Grouped results into %d runs\n", len(resultsByRun))
+	// Trial migrations take the same subset of results on every attempt
+	if config.Sample.enabled() {
+		total := 0
+		for _, group := range runGroups {
+			total += len(group.results)
+		}
+		var kept int
+		runGroups, kept = config.Sample.filter(runGroups)
+		fmt.Printf("Sample %s: migrating %d of %d results in %d runs\n", config.Sample, kept, total, len(runGroups))
+		if !config.DryRun {
+			fmt.Printf("Warning: sampled results are posted to %s; use a scratch target project for trial migrations\n", config.TargetProject)
+		}
+	}
+
+	// Process runs in a reproducible order
+	orderGroups(runGroups, config.RunOrder, config.RunOrderDirection)
+
+	// Priority runs are migrated ahead of that order
+	if config.Priority.enabled() {
+		resolved, tagged, err := config.Priority.resolveTags(src, config.SourceProject, config.AfterDate)
+		if err != nil {
+			return err
+		}
+		if len(config.Priority.tags) > 0 {
+			fmt.Printf("Found %d source runs tagged %s\n", tagged, strings.Join(config.Priority.tags, ", "))
+		}
+		fmt.Printf("Migrating %d priority runs first\n", resolved.first(runGroups))
+	}
+
+	// Resume from the checkpoint, skipping runs completed by a previous attempt
+	cp, err := checkpoint.Open(config.CheckpointLocation, config.CheckpointInterval, config.SourceProject, config.TargetProject)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	defer cp.Close()
 
-	// Add timeout protection
-	timeout := 30 * time.Minute
-	timeoutTimer := time.NewTimer(timeout)
-	defer timeoutTimer.Stop()
+	pending := make([]runGroup, 0, len(runGroups))
+	for _, group := range runGroups {
+		if cp.Done(group.key) {
+			continue
+		}
+		pending = append(pending, group)
+	}
+	if resumed := len(runGroups) - len(pending); resumed > 0 {
+		fmt.Printf("Skipping %d runs already completed according to the checkpoint\n", resumed)
+	}
+	runGroups = pending
+
+	status.StartPair(config.SourceProject, config.TargetProject, len(runGroups))
+	dash.SetRunsTotal(len(runGroups))
+	setPhase("migrating")
+
+	// Optional limit on the time spent migrating runs; pauses do not count
+	var timeoutTimer *time.Timer
+	var timeoutC <-chan time.Time
+	if config.Timeout > 0 {
+		timeoutTimer = time.NewTimer(config.Timeout)
+		defer timeoutTimer.Stop()
+		timeoutC = timeoutTimer.C
+	}
+	migratingSince, pausedBefore := time.Now(), ctl.PausedFor()
 
 	// Process each run that has results
 	totalResults := 0
 	totalSkipped := 0
+	totalUpdated := 0
 	successfulRuns := 0
 	failedRuns := 0
+	skippedRuns := 0
+	failedGroups := make(map[int]bool)
+
+	// Zero and negative durations are counted across workers and warned once
+	var implausibleDurations atomic.Int64
 
 	// Create channels for coordination
 	type runResult struct {
 		runID       int
+		key         string // of the run group, unique to a part or bucket
+		title       string
+		targetRunID int
 		results     int
 		skipped     int
+		updated     int
 		success     bool
+		skippedRun  bool
 		error       error
+		items       []qase.BulkItem // being posted when the run failed
 		runDuration time.Duration
+		source      int // source results of the run
 	}
 
-	resultsChan := make(chan runResult, len(resultsByRun))
-	semaphore := make(chan struct{}, config.Concurrency)
-
-	fmt.Printf("Processing %d runs with results (concurrency: %d)\n", len(resultsByRun), config.Concurrency)
-
-	// Launch goroutines for each run that has results
-	runIndex := 0
-	for runID, results := range resultsByRun {
-		go func(runID int, results []qase.Result, index int) {
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			runStartTime := time.Now()
-			fmt.Printf("\n--- Processing run %d/%d: ID %d with %d results ---\n",
-				index+1, len(resultsByRun), runID, len(results))
-
-			// Create run details from results data
-			// Use the first result's end time to create a meaningful run title
-			var runTitle string
-			var runDescription string
-			if len(results) > 0 {
-				// Parse the end time from the first result
-				if endTime, err := time.Parse("2006-01-02T15:04:05-07:00", results[0].EndTime); err == nil {
-					runTitle = fmt.Sprintf("Migrated Run %d (%s)", runID, endTime.Format("2006-01-02 15:04"))
-				} else {
-					runTitle = fmt.Sprintf("Migrated Run %d", runID)
-				}
-				runDescription = fmt.Sprintf("Migrated run with %d results from source workspace", len(results))
+	// Only a warehouse export or a review stage leaves the target project
+	// untouched
+	writesTarget := (config.Warehouse == "" || config.WarehouseMode != warehouse.ModeOnly) && config.ReviewDir == ""
+
+	// Existing target runs are found in one listing instead of one per run
+	var targetRuns *qase.RunIndex
+	if config.Idempotent && writesTarget && !config.DryRun && len(runGroups) > 0 {
+		targetRuns, err = qase.BuildRunIndex(tgtClient, config.TargetProject)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Custom field values and the milestone set on every created target run
+	var runOptions qase.RunOptions
+	placeholders := strings.NewReplacer("{source_project}", config.SourceProject, "{target_project}", config.TargetProject)
+	if len(config.RunCustomFields) > 0 && len(runGroups) > 0 {
+		values := make(map[string]string, len(config.RunCustomFields))
+		for field, value := range config.RunCustomFields {
+			values[field] = placeholders.Replace(value)
+		}
+		runOptions.Fields, err = qase.ResolveRunCustomFields(tgtClient, config.TargetProject, values)
+		if err != nil {
+			return fmt.Errorf("failed to resolve QASE_RUN_CUSTOM_FIELDS: %w", err)
+		}
+	}
+	if config.Milestone != "" && len(runGroups) > 0 {
+		title := placeholders.Replace(config.Milestone)
+		if config.DryRun {
+			fmt.Printf("DRY RUN MODE - Would attach created runs to milestone %q\n", title)
+		} else {
+			description := fmt.Sprintf("Runs migrated from project %s", config.SourceProject)
+			runOptions.MilestoneID, err = qase.GetOrCreateMilestone(tgtClient, config.TargetProject, title, description)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Runs and results go through the target interface; Qase is the default,
+	// optionally mirrored to or replaced by a warehouse export
+	var sink target.Target = target.NewQase(tgtClient, config.TargetProject, targetRuns, config.BulkSize)
+	if config.Warehouse != "" && !config.DryRun {
+		export, err := warehouse.Open(config.Warehouse, config.TargetProject, config.WarehouseTablePrefix)
+		if err != nil {
+			return err
+		}
+		if writesTarget {
+			sink = target.NewTee(sink, export)
+		} else {
+			sink = export
+		}
+	}
+
+	// Runs staged for review are posted by the approve command
+	var stage *review.Stage
+	if config.ReviewDir != "" && !config.DryRun {
+		stage, err = review.Open(config.ReviewDir, config.SourceProject, config.TargetProject)
+		if err != nil {
+			return err
+		}
+		sink = stage
+	}
+
+	// Target cases and runs deleted after the mapping was built are handled
+	// per policy instead of failing the run
+	deleted := &deletedTargets{
+		cases:     config.DeletedCases,
+		runs:      config.DeletedRuns,
+		attention: attention,
+		original:  caseMapping,
+		rebuild: func() (map[int]int, error) {
+			if config.sameProject() {
+				return nil, nil
+			}
+			cases, err := qase.GetCases(tgtClient, config.TargetProject, qase.CaseListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch target cases: %w", err)
+			}
+			return buildMapping(cases)
+		},
+	}
+
+	// Runs are taken from a queue by a fixed pool of workers, and outcomes
+	// come back on a channel no larger than the pool, so a migration of many
+	// runs does not start a goroutine per run up front. Target runs are
+	// created by their own pool, up to RunCreateBatch runs ahead of result
+	// posting, so many tiny runs do not wait on each other's posts before
+	// their runs exist.
+	poolSize := config.Concurrency + config.RunCreateBatch
+	type runJob struct {
+		group runGroup
+		index int
+	}
+	jobs := make(chan runJob)
+	resultsChan := make(chan runResult, poolSize)
+
+	// Workers still running after a timeout drop their outcomes
+	done := make(chan struct{})
+	defer close(done)
+	sendResult := func(result runResult) {
+		select {
+		case resultsChan <- result:
+		case <-done:
+		}
+	}
+
+	createSemaphore := make(chan struct{}, config.RunCreateConcurrency)
+	postWorkers := make(chan int, config.Concurrency)
+	for worker := 1; worker <= config.Concurrency; worker++ {
+		postWorkers <- worker
+	}
+	workers := newWorkerStats()
+
+	fmt.Printf("Processing %d runs with results in %s order, %s (concurrency: %d, run creation: %d, created ahead: up to %d)\n",
+		len(runGroups), config.RunOrder, strings.ReplaceAll(config.RunOrderDirection, "_", " "), config.Concurrency, config.RunCreateConcurrency, config.RunCreateBatch)
+
+	migrateRun := func(group runGroup, index int) {
+		runID := group.id
+		results := group.results
+		// Every failure reported for the run marks its span failed
+		var runSpan *tracing.Span
+		send := func(result runResult) {
+			runSpan.SetError(result.error)
+			result.key = group.key
+			result.source = len(results)
+			sendResult(result)
+		}
+
+		// Hold while paused; honor operator skips before starting and before posting
+		ctl.Wait()
+		ctl.Begin(runID)
+		defer ctl.End(runID)
+
+		runStartTime := time.Now()
+		skipRun := func() {
+			fmt.Printf("Skipping run %d on operator request\n", runID)
+			send(runResult{runID: runID, skippedRun: true, runDuration: time.Since(runStartTime)})
+		}
+		if ctl.Skipped(runID) {
+			skipRun()
+			return
+		}
+
+		runSpan = tracing.Start("migrate.run", nil)
+		runSpan.SetAttr("source.run_id", runID)
+		runSpan.SetAttr("results.count", len(results))
+		defer runSpan.End()
+
+		fmt.Printf("\n--- Processing run %d/%d: ID %d with %d results ---\n",
+			index+1, len(runGroups), runID, len(results))
+		if config.RunBucket == BucketNone {
+			fmt.Printf("Source run: %s\n", qase.RunURL(config.SourceBaseURL, config.SourceProject, runID))
+		}
+
+		// Create run details from results data
+		runTitle := group.title
+		runDescription := group.description
+
+		// Transform results to target case IDs
+		fmt.Printf("Transforming %d results...\n", len(results))
+		transformSpan := tracing.Start("transform", runSpan)
+		bulkItems, skipped, durations := transform.Results(results, caseMapping, config.Transform)
+		transformSpan.SetAttr("results.mapped", len(bulkItems))
+		transformSpan.SetAttr("results.skipped", skipped)
+		transformSpan.End()
+		for _, long := range durations.Long {
+			if config.Transform.Durations.Drop {
+				attention.AddDropped(runID, long.CaseID, long.Seconds)
 			} else {
-				runTitle = fmt.Sprintf("Run %d", runID)
-				runDescription = "Migrated run"
+				attention.AddCapped(runID, long.CaseID, long.Seconds)
 			}
+		}
+		implausibleDurations.Add(int64(durations.Implausible))
 
-			// Transform results to target case IDs
-			fmt.Printf("Transforming %d results...\n", len(results))
-			bulkItems, skipped := transformResults(results, caseMapping, config.StatusMap)
+		fmt.Printf("Prepared %d results for posting, skipped %d unmapped results\n", len(bulkItems), skipped)
+		if skipped > 0 {
+			attention.AddUnmapped(runID, unmappedCases(results, caseMapping, config.Transform.Cases))
+		}
 
-			fmt.Printf("Prepared %d results for posting, skipped %d unmapped results\n", len(bulkItems), skipped)
+		// A run with nothing to post would be left empty in the target
+		if len(bulkItems) == 0 {
+			fmt.Printf("No results to migrate for run %d, not creating a target run\n", runID)
+			send(runResult{runID: runID, success: true, skipped: skipped, runDuration: time.Since(runStartTime)})
+			return
+		}
 
-			// Handle dry run mode
-			if config.DryRun {
-				fmt.Printf("DRY RUN MODE - Would create run '%s' with %d results\n", runTitle, len(bulkItems))
-				resultsChan <- runResult{
-					runID: runID, success: true, results: len(bulkItems), skipped: skipped,
-					runDuration: time.Since(runStartTime),
-				}
+		// Give reviewers the migrated counts and source links in the target run
+		if config.DescriptionStats {
+			runDescription = enrichDescription(runDescription, group, bulkItems, skipped, config.SourceBaseURL, config.SourceProject)
+		}
+
+		// Keep the full source results next to the run as a safety net
+		if config.RawAttachments == qase.RawAttachRun && !config.DryRun {
+			attachment, err := qase.UploadRawRun(tgtClient, config.TargetProject, group.key, results)
+			if err != nil {
+				log.Printf("Failed to attach source results for %s: %v", runTitle, err)
+				send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
 				return
 			}
+			runDescription = strings.TrimSpace(fmt.Sprintf("%s\n\nSource results: [%s](%s)", runDescription, attachment.Filename, attachment.URL))
+		}
 
-			var tgtRun *qase.Run
-			var err error
+		// Catch oversized payloads before the target run is created
+		if err := qase.ValidatePayloads(bulkItems, config.BulkSize, tgtClient.MaxPayloadBytes); err != nil {
+			log.Printf("Payload check failed for %s: %v", runTitle, err)
+			send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
+			return
+		}
 
-			if config.Idempotent {
-				// Create or get existing target run (idempotent)
-				fmt.Printf("Creating or finding target run: %s\n", runTitle)
-				tgtRun, err = qase.CreateOrGetRun(tgtClient, config.TargetProject, runTitle, runDescription)
-				if err != nil {
-					log.Printf("Failed to create/get target run for %s: %v", runTitle, err)
-					resultsChan <- runResult{runID: runID, success: false, error: err, runDuration: time.Since(runStartTime)}
-					return
-				}
+		// Handle dry run mode
+		if config.DryRun {
+			fmt.Printf("DRY RUN MODE - Would create run '%s' with %d results\n", runTitle, len(bulkItems))
+			send(runResult{
+				runID: runID, success: true, results: len(bulkItems), skipped: skipped,
+				runDuration: time.Since(runStartTime),
+			})
+			return
+		}
+
+		var tgtRun *qase.Run
+		var err error
+		updated := 0
+
+		// Create the target run with run-creation concurrency
+		marker := group.marker(config.SourceProject)
+		createSemaphore <- struct{}{}
+		if config.Idempotent {
+			// Reuse the run migrated from this source run, if the target has one
+			fmt.Printf("Creating or finding target run: %s\n", runTitle)
+			tgtRun, err = sink.FindRun(marker, runTitle)
+			if tgtRun != nil {
+				fmt.Printf("Found existing run: %s (ID: %d)\n", tgtRun.Title, tgtRun.ID)
+			}
+		} else {
+			// Non-idempotent mode: always create new runs
+			fmt.Printf("Creating target run: %s\n", runTitle)
+		}
+		if err == nil && tgtRun == nil {
+			tgtRun, err = sink.CreateRun(marker, runTitle, runDescription, runOptions)
+		}
+		<-createSemaphore
+		if err != nil {
+			log.Printf("Failed to create target run for %s: %v", runTitle, err)
+			send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
+			return
+		}
+
+		// Check and post with result-post concurrency, within the
+		// worker's share of the target rate
+		worker := <-postWorkers
+		workerStart := time.Now()
+		posted := 0
+		dash.WorkerStarted(worker, runID, runTitle, len(bulkItems))
+		defer func() {
+			workers.record(worker, posted, time.Since(workerStart))
+			dash.WorkerFinished(worker, posted)
+			postWorkers <- worker
+		}()
+		postClient := tgtClient.ForWorker(workerName(worker))
+		postTarget := target.ForWorker(sink, workerName(worker))
+
+		if config.Idempotent && writesTarget {
+			// Check if run already has results (idempotent)
+			hasResults, err := qase.CheckRunHasResults(postClient, config.TargetProject, tgtRun.ID)
+			if err != nil {
+				log.Printf("Failed to check existing results for run %d: %v", tgtRun.ID, err)
+				send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
+				return
+			}
 
-				// Check if run already has results (idempotent)
-				hasResults, err := qase.CheckRunHasResults(tgtClient, config.TargetProject, tgtRun.ID)
+			if hasResults && config.Resync {
+				fmt.Printf("Run %d already has results, re-syncing changed ones...\n", tgtRun.ID)
+				// Update changed results in place and keep only cases missing in the target
+				var stats qase.SyncStats
+				bulkItems, stats, err = qase.SyncResults(postClient, config.TargetProject, tgtRun.ID, bulkItems)
 				if err != nil {
-					log.Printf("Failed to check existing results for run %d: %v", tgtRun.ID, err)
-					resultsChan <- runResult{runID: runID, success: false, error: err, runDuration: time.Since(runStartTime)}
+					log.Printf("Failed to re-sync existing results for run %d: %v", tgtRun.ID, err)
+					send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
 					return
 				}
-
-				if hasResults {
-					fmt.Printf("Run %d already has results, filtering for new ones only...\n", tgtRun.ID)
-					// Filter out results that already exist
-					bulkItems, err = qase.FilterNewResults(tgtClient, config.TargetProject, tgtRun.ID, bulkItems)
-					if err != nil {
-						log.Printf("Failed to filter existing results for run %d: %v", tgtRun.ID, err)
-						resultsChan <- runResult{runID: runID, success: false, error: err, runDuration: time.Since(runStartTime)}
-						return
-					}
-				}
-
-				if len(bulkItems) == 0 {
-					fmt.Printf("No new results to post for run %d (all already exist)\n", tgtRun.ID)
-					resultsChan <- runResult{
-						runID: runID, success: true, results: 0, skipped: skipped,
-						runDuration: time.Since(runStartTime),
-					}
+				updated = stats.Updated
+				if stats.Failed > 0 {
+					err = fmt.Errorf("failed to update %d results in run %d", stats.Failed, tgtRun.ID)
+					log.Printf("%v", err)
+					send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
 					return
 				}
-
-				// Post only new results to target run
-				fmt.Printf("Posting %d new results to target run %d...\n", len(bulkItems), tgtRun.ID)
-			} else {
-				// Non-idempotent mode: always create new runs
-				fmt.Printf("Creating target run: %s\n", runTitle)
-				tgtRun, err = qase.CreateRun(tgtClient, config.TargetProject, runTitle, runDescription)
+			} else if hasResults {
+				fmt.Printf("Run %d already has results, filtering for new ones only...\n", tgtRun.ID)
+				// Filter out results that already exist
+				bulkItems, err = qase.FilterNewResults(postClient, config.TargetProject, tgtRun.ID, bulkItems)
 				if err != nil {
-					log.Printf("Failed to create target run for %s: %v", runTitle, err)
-					resultsChan <- runResult{runID: runID, success: false, error: err, runDuration: time.Since(runStartTime)}
+					log.Printf("Failed to filter existing results for run %d: %v", tgtRun.ID, err)
+					send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
 					return
 				}
+			}
 
-				// Post all results to target run
-				fmt.Printf("Posting %d results to target run %d...\n", len(bulkItems), tgtRun.ID)
+			if len(bulkItems) == 0 {
+				fmt.Printf("No new results to post for run %d (all already exist)\n", tgtRun.ID)
+				send(runResult{
+					runID: runID, title: runTitle, targetRunID: tgtRun.ID,
+					success: true, results: 0, skipped: skipped, updated: updated,
+					runDuration: time.Since(runStartTime),
+				})
+				return
 			}
-			if err := qase.PostBulkResults(tgtClient, config.TargetProject, tgtRun.ID, bulkItems, config.BulkSize); err != nil {
-				log.Printf("Failed to post results to run %d: %v", tgtRun.ID, err)
-				resultsChan <- runResult{runID: runID, success: false, error: err, runDuration: time.Since(runStartTime)}
+
+			// Post only new results to target run
+			fmt.Printf("Posting %d new results to target run %d...\n", len(bulkItems), tgtRun.ID)
+		} else {
+			// Post all results to target run
+			fmt.Printf("Posting %d results to target run %d...\n", len(bulkItems), tgtRun.ID)
+		}
+		if ctl.Skipped(runID) {
+			skipRun()
+			return
+		}
+
+		// Leave out results another invocation posted or is posting
+		toPost, claimed, err := claimResults(postedIndex, config.TargetProject, bulkItems)
+		if err != nil {
+			log.Printf("Failed to claim results for run %d: %v", tgtRun.ID, err)
+			send(runResult{runID: runID, title: runTitle, targetRunID: tgtRun.ID, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
+			return
+		}
+		bulkItems = toPost
+		defer func() {
+			if err := settleClaims(postedIndex, claimed, posted); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+		if len(bulkItems) == 0 {
+			fmt.Printf("No results left to post for run %d (all in the dedupe index)\n", tgtRun.ID)
+			send(runResult{
+				runID: runID, title: runTitle, targetRunID: tgtRun.ID,
+				success: true, results: 0, skipped: skipped, updated: updated,
+				runDuration: time.Since(runStartTime),
+			})
+			return
+		}
+
+		if config.RawAttachments == qase.RawAttachResult {
+			fmt.Printf("Attaching source result JSON to %d results...\n", len(bulkItems))
+			if err := qase.AttachRawResults(postClient, config.TargetProject, bulkItems, results); err != nil {
+				log.Printf("Failed to attach source results for run %d: %v", tgtRun.ID, err)
+				send(runResult{runID: runID, title: runTitle, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
 				return
 			}
+		}
+		runSpan.SetAttr("target.run_id", tgtRun.ID)
+		recreateRun := func() (*qase.Run, error) {
+			if targetRuns != nil {
+				targetRuns.Forget(tgtRun.ID)
+			}
+			createSemaphore <- struct{}{}
+			defer func() { <-createSemaphore }()
+			return sink.CreateRun(group.marker(config.SourceProject), runTitle, runDescription, runOptions)
+		}
+		postSpan := tracing.Start("post.results", runSpan)
+		var kept int
+		tgtRun, posted, kept, err = deleted.post(postTarget, tgtRun, bulkItems, claimed, runID, postSpan, recreateRun)
+		postSpan.SetError(err)
+		postSpan.End()
+		if err != nil {
+			log.Printf("Failed to post results to run %d (%s): %v", tgtRun.ID, qase.RunURL(config.TargetBaseURL, config.TargetProject, tgtRun.ID), err)
+			send(runResult{runID: runID, title: runTitle, targetRunID: tgtRun.ID, success: false, error: err, items: bulkItems, runDuration: time.Since(runStartTime)})
+			return
+		}
 
-			runDuration := time.Since(runStartTime)
-			fmt.Printf("Successfully migrated run %d -> %d (took %v)\n", runID, tgtRun.ID, runDuration)
-			resultsChan <- runResult{
-				runID: runID, success: true, results: len(bulkItems), skipped: skipped,
-				runDuration: runDuration,
+		skipped += len(bulkItems) - kept
+		runDuration := time.Since(runStartTime)
+		if stage != nil {
+			fmt.Printf("Staged run %d for review (took %v)\n", runID, runDuration)
+			send(runResult{runID: runID, title: runTitle, success: true, results: posted, skipped: skipped, runDuration: runDuration})
+			return
+		}
+		fmt.Printf("Successfully migrated run %d -> %d (took %v)\n", runID, tgtRun.ID, runDuration)
+		fmt.Printf("  source: %s\n  target: %s\n",
+			qase.RunURL(config.SourceBaseURL, config.SourceProject, runID),
+			qase.RunURL(config.TargetBaseURL, config.TargetProject, tgtRun.ID))
+		send(runResult{
+			runID: runID, title: runTitle, targetRunID: tgtRun.ID,
+			success: true, results: posted, skipped: skipped, updated: updated,
+			runDuration: runDuration,
+		})
+	}
+
+	// A timeout stops handing out runs; the runs already handed out finish,
+	// so the pair lock and dedupe index outlive every post
+	stop := make(chan struct{})
+	handedOut := make(chan int, 1)
+	go func() {
+		defer close(jobs)
+		for index, group := range runGroups {
+			select {
+			case jobs <- runJob{group: group, index: index}:
+			case <-stop:
+				handedOut <- index
+				return
+			case <-done:
+				return
+			}
+		}
+		handedOut <- len(runGroups)
+	}()
+	for i := 0; i < poolSize; i++ {
+		go func() {
+			for job := range jobs {
+				migrateRun(job.group, job.index)
 			}
-		}(runID, results, runIndex)
-		runIndex++
+		}()
 	}
 
 	// Collect results with timeout
+	errorSummary := errclass.NewSummary()
 	completed := 0
-	for completed < len(resultsByRun) {
+	throughput := newProgress(runGroups)
+	expected := len(runGroups)
+	for completed < expected {
 		select {
 		case result := <-resultsChan:
 			completed++
-			if result.success {
+			status.RunCompleted(result.success || result.skippedRun)
+			if result.success || result.skippedRun {
+				dash.RunFinished(result.runID, result.title, result.results, nil)
+			} else {
+				dash.RunFinished(result.runID, result.title, 0, result.error)
+			}
+			if result.skippedRun {
+				skippedRuns++
+			} else if result.success {
 				successfulRuns++
+				if !config.DryRun {
+					cp.Complete(result.key, result.targetRunID)
+				}
 				totalResults += result.results
 				totalSkipped += result.skipped
+				totalUpdated += result.updated
 			} else {
 				failedRuns++
+				status.AddError()
+				failedGroups[result.runID] = true
+				class := errorSummary.Record(result.error)
+				recordFailure(attention, result.runID, result.targetRunID, result.error, class)
+				if config.RunErrorFiles {
+					location, err := writeRunError(config.ArtifactDir, config.Force, result.key, result.runID, result.targetRunID, result.title, result.items, result.error, class)
+					if err != nil {
+						log.Printf("Warning: failed to write error file for run %d: %v", result.runID, err)
+					} else {
+						fmt.Printf("Wrote error details for run %d to %s\n", result.runID, location)
+					}
+				}
 			}
-			fmt.Printf("Completed %d/%d runs\n", completed, len(resultsByRun))
-
-		case <-timeoutTimer.C:
-			fmt.Printf("TIMEOUT: Migration exceeded %v limit. Completed %d/%d runs\n", timeout, completed, len(resultsByRun))
-			break
+			if result.skipped > 0 {
+				errorSummary.RecordClass(errclass.ClassMapping, result.skipped,
+					fmt.Sprintf("run %d: %d results had no mapped target case", result.runID, result.skipped))
+			}
+			throughput.add(result.source)
+			fmt.Printf("Completed %d/%d runs (%s)\n", completed, len(runGroups), throughput)
+
+		case <-timeoutC:
+			active := time.Since(migratingSince) - (ctl.PausedFor() - pausedBefore)
+			if remaining := config.Timeout - active; remaining > 0 {
+				timeoutTimer.Reset(remaining)
+				continue
+			}
+			close(stop)
+			timeoutC = nil
+			expected = <-handedOut
+			fmt.Printf("TIMEOUT: Migration exceeded %v limit. Completed %d/%d runs, waiting for %d runs in progress\n", config.Timeout, completed, len(runGroups), expected-completed)
 		}
 	}
 
 	totalDuration := time.Since(startTime)
+	runsTotal, runsFailed = len(runGroups), failedRuns
 
 	// Print summary
 	fmt.Printf("\n=== Migration Summary ===\n")
-	fmt.Printf("Total runs with results: %d\n", len(resultsByRun))
+	fmt.Printf("Total runs with results: %d\n", len(runGroups))
 	fmt.Printf("Successful migrations: %d\n", successfulRuns)
 	fmt.Printf("Failed migrations: %d\n", failedRuns)
+	if skippedRuns > 0 {
+		fmt.Printf("Runs skipped by operator: %d\n", skippedRuns)
+	}
 	fmt.Printf("Total results migrated: %d\n", totalResults)
 	fmt.Printf("Total results skipped: %d\n", totalSkipped)
+	if summary := attention.DurationSummary(); summary != "" {
+		fmt.Printf("Warning: %s (maximum %d seconds), see the needs-attention report\n", summary, config.Transform.Durations.Max())
+	}
+	if n := implausibleDurations.Load(); n > 0 {
+		fmt.Printf("Warning: %d results had a zero or negative duration and were posted without time\n", n)
+	}
+	if config.Resync {
+		fmt.Printf("Total results updated: %d\n", totalUpdated)
+	}
 	fmt.Printf("Total execution time: %v\n", totalDuration)
+	fmt.Printf("Throughput: %s\n", throughput.overall())
+	fmt.Printf("Phases: %s\n", phases)
+	if n := tgtClient.Breaker.TimesOpened(); n > 0 {
+		fmt.Printf("Circuit breaker opened: %d times\n", n)
+	}
+	if tgtClient.RetryBudget != nil {
+		fmt.Printf("Retries used: %d/%d\n", tgtClient.RetryBudget.Used(), config.RetryBudget)
+	}
+	readRetries, cacheHits, cacheMisses := 0, 0, 0
+	for _, client := range clients {
+		hits, misses := client.CacheStats()
+		readRetries, cacheHits, cacheMisses = readRetries+client.ReadRetries(), cacheHits+hits, cacheMisses+misses
+	}
+	if n := readRetries; n > 0 || config.ReadRetryBudget > 0 {
+		if config.ReadRetryBudget > 0 {
+			fmt.Printf("Read retries used: %d (budget %d per workspace)\n", n, config.ReadRetryBudget)
+		} else {
+			fmt.Printf("Read retries used: %d\n", n)
+		}
+	}
+	reportCountChecks(clients...)
+	workers.print(tgtClient.FairShare())
+	if config.CacheDir != "" {
+		fmt.Printf("API cache: %d hits, %d misses\n", cacheHits, cacheMisses)
+	}
+
+	errorSummary.Print()
+
+	// Command to retry only the failed runs once the cause is fixed
+	if runIDs := failedSourceRuns(runGroups, failedGroups); len(runIDs) > 0 {
+		command := rerunCommand(config, runIDs)
+		fmt.Printf("\nTo re-run the %d failed source runs:\n  %s\n", len(runIDs), command)
+		location, err := writeRerunScript(config, command, len(runIDs))
+		if err != nil {
+			log.Printf("Warning: failed to write re-run script: %v", err)
+		} else {
+			fmt.Printf("Re-run script written to %s\n", location)
+		}
+	}
+
+	if stage != nil {
+		printStaged(stage, config)
+	}
+
+	summary.TotalRuns = len(runGroups)
+	summary.SuccessfulRuns = successfulRuns
+	summary.FailedRuns = failedRuns
+	summary.TotalResults = totalResults
+	summary.TotalSkipped = totalSkipped
+	summary.Duration = totalDuration
+
+	if completed < len(runGroups) {
+		return fmt.Errorf("timed out after %v with %d/%d runs completed", config.Timeout, completed, len(runGroups))
+	}
 
 	if config.DryRun {
 		fmt.Println("\nDRY RUN MODE - No actual changes were made")
+	} else if stage != nil {
+		fmt.Println("\nStaging completed! Nothing was written to the target project")
 	} else {
 		fmt.Println("\nMigration completed!")
 	}
+
+	// Fail scheduled and CI jobs when drift or failures exceed the tolerances
+	if config.Gates.enabled() {
+		if err := config.Gates.check(throughput.resultsTotal, totalSkipped, failedRuns); err != nil {
+			return err
+		}
+		fmt.Println("Gates passed")
+	}
+	return nil
+}
+
+// notifySummary posts a migration's summary to the migration ticket and
+// emails it, if configured
+func notifySummary(config *Config, summary notify.Summary) {
+	if config.Jira.Enabled() {
+		if err := notify.PostJiraComment(config.Jira, summary); err != nil {
+			log.Printf("Warning: Failed to post Jira comment: %v", err)
+		}
+	}
+	if config.Email.Enabled() {
+		if err := notify.SendEmail(config.Email, summary); err != nil {
+			log.Printf("Warning: Failed to email summary: %v", err)
+		}
+	}
 }
 
 // Config holds all configuration values
 type Config struct {
-	// Source workspace
-	SourceToken   string
-	SourceBaseURL string
-	SourceProject string
-
-	// Target workspace
-	TargetToken   string
-	TargetBaseURL string
-	TargetProject string
+	// Source and target workspaces
+	settings.Workspaces
 
 	// Date filtering
 	AfterDate time.Time
 
+	// Batch of project pairs (JSON file), replacing the single pair above
+	BatchFile string
+	InBatch   bool // migrated as one pair of a batch file or workspace
+
+	// Every source project to its same-named or mapped target project (migrate-workspace)
+	Workspace      bool
+	ProjectMap     map[string]string // upper-case source code -> target code (QASE_PROJECT_MAP)
+	WorkspaceMatch string            // how the pair was matched, empty for no target project
+	Outcome        *notify.Summary   // filled in by migrateProject when set
+
+	// One stage of a migration split between two machines (fetch-only or post-only)
+	Role           string
+	BundleLocation string          // encrypted bundle handed from fetch-only to post-only (QASE_BUNDLE)
+	Source         *handoff.Bundle // source data of a post-only migration
+
 	// Mapping configuration
-	MatchMode     string
-	CustomFieldID int
-	MappingCSV    string
+	settings.Mapping
+	PersistCFID   int
+	MappingTitles bool
+
+	// Needs-attention report (JSON, or CSV for a .csv name) in ArtifactDir
+	NeedsAttentionFile string
+	RunErrorFiles      bool // errors/<run key>.json per failed run
+
+	// Custom field values (by field ID or title) set on created target runs
+	RunCustomFields map[string]string
+
+	// Milestone (created if missing) that created target runs are attached to
+	Milestone string
+
+	// Attach the source result JSON to each result or run (qase.RawAttach*)
+	RawAttachments string
+
+	// Create source custom result statuses missing in the target workspace
+	CreateStatuses bool
 
 	// Behavior
-	DryRun      bool
-	BulkSize    int
-	Concurrency int
-	StatusMap   map[string]string
-	Idempotent  bool
+	settings.Posting
+	Timeout           time.Duration // limit on migrating runs, not counting pauses (0: none)
+	ProtectedProjects []string
+	ProtectedOverride bool
+	MaxPayloadBytes   int
+	Concurrency       int
+	Transform         transform.Options
+	Idempotent        bool
+	Resync            bool
+	RunBucket         string
+	RunOrder          string
+	RunOrderDirection string
+
+	// Migration stats appended to created target run descriptions
+	DescriptionStats bool
+
+	// Source results fetch strategy
+	FetchMode   string
+	FetchRunIDs []int
+
+	// Target run creation, independent of result-post concurrency
+	RunCreateConcurrency int
+	RunCreateBatch       int
+
+	// Oversized run guard
+	MaxResultsPerRun int
+	OversizedRuns    string
+
+	// Handling of target cases and runs deleted after the mapping was built
+	DeletedCases string
+	DeletedRuns  string
+
+	// Parallel jobs splitting one migration
+	Shard shard
+
+	// Share of each run's results migrated in a trial migration
+	Sample sample
+
+	// Runs migrated before all others
+	Priority priority
+
+	// Tolerances that fail the migration when exceeded
+	Gates gates
+
+	// Lock preventing concurrent migrations of the same project pair
+	Lock    string
+	LockTTL time.Duration
+
+	// Warehouse export of migrated runs and results (warehouse.Mode*)
+	Warehouse            string
+	WarehouseMode        string
+	WarehouseTablePrefix string
+
+	// Stage transformed runs as pending bundles for approval instead of
+	// posting them
+	ReviewDir string
+
+	// Local index of posted source results shared by parallel invocations
+	DedupeIndex    string
+	DedupeClaimTTL time.Duration
+
+	// Token pooling
+	SourceExtraTokens []string
+	TargetExtraTokens []string
+	TokenRPM          int
+	TargetRPM         int
+	TargetRPMSet      bool // QASE_TARGET_RPM was set rather than derived from the pool
+
+	// Token refresh
+	SourceTokenCommand   string
+	TargetTokenCommand   string
+	TokenRefreshInterval time.Duration
+
+	// Resilience
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	RetryBudget      int
+
+	// Read path retries (independent of the post path)
+	ReadRetries      int
+	ReadRetryWait    time.Duration
+	ReadRetryMaxWait time.Duration
+	ReadRetryBudget  int
+
+	// Pacing by the API's rate limit headers
+	RateLimitPacing   bool
+	RateLimitHeadroom int
+	Debug             bool
+
+	// Response cache
+	CacheDir string
+	CacheTTL time.Duration
+
+	// Monitoring
+	StatusFile     string
+	ArtifactDir    string
+	Force          bool
+	StatusInterval time.Duration
+	ControlAddr    string
+	PprofAddr      string
+	TUI            bool
+	TUILog         string
+	Dashboard      *tui.Dashboard // nil unless the terminal dashboard is running
+
+	// Checkpointing
+	CheckpointLocation string
+	CheckpointInterval time.Duration
+
+	// Watch mode
+	WatchInterval      time.Duration
+	WatchOverlap       time.Duration
+	HealthAddr         string
+	HealthStallTimeout time.Duration
+
+	// Notifications
+	Jira      notify.JiraConfig
+	Email     notify.EmailConfig
+	Alerts    *notify.Alerter // nil when no alerting integration is configured
+	ReportURL string
 }
 
 // loadConfig loads configuration from environment variables
 func loadConfig() (*Config, error) {
+	var problems settings.Problems
 	config := &Config{
-		SourceBaseURL: getEnvDefault("QASE_SOURCE_API_BASE", "https://api.qase.io"),
-		TargetBaseURL: getEnvDefault("QASE_TARGET_API_BASE", "https://api.qase.io"),
-		MatchMode:     getEnvDefault("QASE_MATCH_MODE", "custom_field"),
-		DryRun:        getEnvDefault("QASE_DRY_RUN", "true") == "true",
-		BulkSize:      getIntDefault("QASE_BULK_SIZE", 200),
-		Concurrency:   getIntDefault("QASE_CONCURRENCY", 2),
-		Idempotent:    getEnvDefault("QASE_IDEMPOTENT", "true") == "true",
+		Workspaces: settings.Workspaces{
+			SourceBaseURL: getEnvDefault("QASE_SOURCE_API_BASE", settings.DefaultBaseURL),
+			TargetBaseURL: getEnvDefault("QASE_TARGET_API_BASE", settings.DefaultBaseURL),
+		},
+		Posting:           settings.LoadPosting(&problems),
+		Concurrency:       problems.Int("QASE_CONCURRENCY", 2),
+		Idempotent:        getEnvDefault("QASE_IDEMPOTENT", "true") == "true",
+		Resync:            getEnvDefault("QASE_RESYNC", "false") == "true",
+		Timeout:           time.Duration(problems.Int("QASE_TIMEOUT", 0)) * time.Second,
+		RunBucket:         getEnvDefault("QASE_RUN_BUCKET", BucketNone),
+		RunOrder:          getEnvDefault("QASE_RUN_ORDER", OrderID),
+		RunOrderDirection: getEnvDefault("QASE_RUN_ORDER_DIRECTION", OldestFirst),
+
+		MaxPayloadBytes:  problems.Int("QASE_MAX_PAYLOAD_BYTES", qase.DefaultMaxPayloadBytes),
+		MaxResultsPerRun: problems.Int("QASE_MAX_RESULTS_PER_RUN", 10000),
+		OversizedRuns:    getEnvDefault("QASE_OVERSIZED_RUNS", OversizedFail),
+		DeletedCases:     getEnvDefault("QASE_DELETED_CASES", DeletedFail),
+		DeletedRuns:      getEnvDefault("QASE_DELETED_RUNS", DeletedFail),
+
+		BreakerThreshold: problems.Int("QASE_BREAKER_THRESHOLD", 0),
+		BreakerCooldown:  time.Duration(problems.Int("QASE_BREAKER_COOLDOWN", 60)) * time.Second,
+		RetryBudget:      problems.Int("QASE_RETRY_BUDGET", 0),
+
+		ReadRetries:      problems.Int("QASE_READ_RETRIES", api.DefaultReadRetries),
+		ReadRetryWait:    time.Duration(problems.Int("QASE_READ_RETRY_WAIT_MS", 1000)) * time.Millisecond,
+		ReadRetryMaxWait: time.Duration(problems.Int("QASE_READ_RETRY_MAX_WAIT", 30)) * time.Second,
+		ReadRetryBudget:  problems.Int("QASE_READ_RETRY_BUDGET", 0),
+
+		RateLimitPacing:   getEnvDefault("QASE_RATE_LIMIT_PACING", "true") == "true",
+		RateLimitHeadroom: problems.Int("QASE_RATE_LIMIT_HEADROOM", 1),
+		Debug:             getEnvDefault("QASE_DEBUG", "false") == "true",
+
+		Jira:      notify.LoadJiraConfig(),
+		Email:     notify.LoadEmailConfig(),
+		ReportURL: os.Getenv("QASE_REPORT_URL"),
+	}
+
+	alerts, err := notify.LoadAlertConfig()
+	problems.Add(err)
+	config.Alerts = notify.NewAlerter(alerts)
+
+	// Token provider commands replace static tokens for short-lived credentials
+	config.SourceTokenCommand = os.Getenv("QASE_SOURCE_TOKEN_COMMAND")
+	config.TargetTokenCommand = os.Getenv("QASE_TARGET_TOKEN_COMMAND")
+	config.TokenRefreshInterval = time.Duration(problems.Int("QASE_TOKEN_REFRESH_INTERVAL", 0)) * time.Second
+
+	// Batch mode reads project pairs (with their own tokens and base URLs) from a file
+	config.BatchFile = os.Getenv("QASE_BATCH_FILE")
+
+	config.Workspace = len(os.Args) > 1 && os.Args[1] == workspaceCommand
+	if config.Workspace && config.BatchFile != "" {
+		problems.Add(fmt.Errorf("%s cannot be combined with QASE_BATCH_FILE", workspaceCommand))
+	}
+	if len(os.Args) > 1 && (os.Args[1] == fetchOnlyCommand || os.Args[1] == postOnlyCommand) {
+		config.Role = os.Args[1]
+		config.BundleLocation = problems.Required("QASE_BUNDLE", "where fetch-only writes the encrypted bundle and post-only reads it: a local path, s3:// or gs://")
+		if config.BatchFile != "" {
+			problems.Add(fmt.Errorf("%s cannot be combined with QASE_BATCH_FILE", config.Role))
+		}
+	}
+	config.ProjectMap, err = parseProjectMap(os.Getenv("QASE_PROJECT_MAP"))
+	problems.Add(err)
+	if config.ProjectMap != nil && !config.Workspace {
+		problems.Add(fmt.Errorf("QASE_PROJECT_MAP requires %s (use QASE_TARGET_PROJECT or a batch file for a single pair)", workspaceCommand))
 	}
 
 	// Required environment variables
-	config.SourceToken = mustEnv("QASE_SOURCE_API_TOKEN")
-	config.SourceProject = mustEnv("QASE_SOURCE_PROJECT")
+	if config.BatchFile != "" || config.Workspace {
+		config.SourceToken = os.Getenv("QASE_SOURCE_API_TOKEN")
+		config.TargetToken = os.Getenv("QASE_TARGET_API_TOKEN")
+	} else {
+		// Each stage of a split migration needs the token of its own workspace only
+		if config.Role == postOnlyCommand {
+			config.SourceProject = os.Getenv("QASE_SOURCE_PROJECT") // taken from the bundle
+		} else {
+			if config.SourceTokenCommand == "" {
+				config.SourceToken = problems.Required("QASE_SOURCE_API_TOKEN", "an API token of the source workspace, or set QASE_SOURCE_TOKEN_COMMAND")
+			}
+			config.SourceProject = problems.Required("QASE_SOURCE_PROJECT", "the source project code; go run ./cmd/projects list shows the codes a token can see")
+		}
 
-	config.TargetToken = mustEnv("QASE_TARGET_API_TOKEN")
-	config.TargetProject = mustEnv("QASE_TARGET_PROJECT")
+		if config.Role != fetchOnlyCommand {
+			if config.TargetTokenCommand == "" {
+				config.TargetToken = problems.Required("QASE_TARGET_API_TOKEN", "an API token of the target workspace, or set QASE_TARGET_TOKEN_COMMAND")
+			}
+			config.TargetProject = problems.Required("QASE_TARGET_PROJECT", "the target project code; go run ./cmd/projects list shows the codes a token can see")
+		}
+	}
+
+	// Date filtering - an explicit cutoff (or "all") is required; post-only
+	// defaults to the cutoff the bundle was fetched with
+	if config.Role != postOnlyCommand || os.Getenv("QASE_AFTER_DATE") != "" {
+		afterDate, err := utils.ParseAfterDate(os.Getenv("QASE_AFTER_DATE"), os.Getenv("QASE_TIMEZONE"))
+		problems.Add(err)
+		config.AfterDate = afterDate
+	}
 
-	// Date filtering - default to August 18th, 2025
-	afterDateStr := getEnvDefault("QASE_AFTER_DATE", "1755500400")
-	afterDate, err := utils.ParseUnixTimestamp(afterDateStr)
+	// Mapping configuration (validated per pair in batch mode, and in
+	// post-only for a split migration)
+	config.Mapping = settings.LoadMapping(&problems, config.BatchFile == "" && !config.Workspace && config.Role != fetchOnlyCommand)
+	if config.Role == fetchOnlyCommand && config.MatchMode == mapping.ModeExternalID && config.SourceExternalIDField == "" {
+		// Case mapping happens in post-only; external_id mode resolves the source field here
+		problems.Add(fmt.Errorf("QASE_SOURCE_EXTERNAL_ID_CF or QASE_EXTERNAL_ID_CF is required for external_id mode: the source custom field ID or title holding the external ID"))
+	}
+
+	// Run custom fields, e.g. "Migration batch:2025-Q3,Source project:{source_project}"
+	config.RunCustomFields, err = qase.ParseFieldValues(os.Getenv("QASE_RUN_CUSTOM_FIELDS"))
 	if err != nil {
-		return nil, fmt.Errorf("invalid QASE_AFTER_DATE format (must be Unix timestamp): %w", err)
+		problems.Add(fmt.Errorf("failed to parse QASE_RUN_CUSTOM_FIELDS: %w", err))
 	}
-	config.AfterDate = afterDate
 
-	// Mapping configuration
-	if config.MatchMode == "custom_field" {
-		config.CustomFieldID = getIntDefault("QASE_CF_ID", 0)
-		if config.CustomFieldID == 0 {
-			return nil, fmt.Errorf("QASE_CF_ID is required for custom_field mode")
+	config.Milestone = os.Getenv("QASE_MILESTONE")
+	config.RawAttachments = os.Getenv("QASE_RAW_ATTACHMENTS")
+	switch config.RawAttachments {
+	case qase.RawAttachNone, qase.RawAttachResult, qase.RawAttachRun:
+	default:
+		problems.Add(fmt.Errorf("invalid QASE_RAW_ATTACHMENTS: %s (expected result or run)", config.RawAttachments))
+	}
+
+	config.CreateStatuses = getEnvDefault("QASE_CREATE_STATUSES", "false") == "true"
+
+	// Optionally write the computed mapping back to a target custom field
+	config.PersistCFID = problems.Int("QASE_PERSIST_CF_ID", 0)
+	config.MappingTitles = getEnvDefault("QASE_MAPPING_TITLES", "false") == "true"
+	config.DescriptionStats = getEnvDefault("QASE_RUN_DESCRIPTION_STATS", "false") == "true"
+
+	// Additional tokens rotated across requests
+	config.SourceExtraTokens = api.ParseTokenList(os.Getenv("QASE_SOURCE_API_TOKENS"))
+	config.TargetExtraTokens = api.ParseTokenList(os.Getenv("QASE_TARGET_API_TOKENS"))
+	config.TokenRPM = problems.Int("QASE_TOKEN_RPM", 0)
+
+	for _, err := range tokenPoolConflicts(config) {
+		problems.Add(err)
+	}
+
+	config.TargetRPM = problems.Int("QASE_TARGET_RPM", defaultTargetRPM(config))
+	config.TargetRPMSet = os.Getenv("QASE_TARGET_RPM") != ""
+
+	// Response cache
+	config.CacheDir = os.Getenv("QASE_CACHE_DIR")
+	config.CacheTTL = time.Duration(problems.Int("QASE_CACHE_TTL", 300)) * time.Second
+
+	// Status heartbeat
+	config.StatusFile = os.Getenv("QASE_STATUS_FILE")
+	config.ArtifactDir = os.Getenv("QASE_ARTIFACT_DIR")
+	_, err = artifact.Encrypted() // load the artifact key up front, not at the first report
+	problems.Add(err)
+	config.Force = getEnvDefault("QASE_FORCE", "false") == "true"
+	config.NeedsAttentionFile = getEnvDefault("QASE_NEEDS_ATTENTION_FILE", "needs_attention.out.json")
+	config.RunErrorFiles = getEnvDefault("QASE_RUN_ERROR_FILES", "true") == "true"
+
+	// Target projects that are only written with an explicit override
+	config.ProtectedProjects = qase.ParseProjectList(os.Getenv("QASE_PROTECTED_PROJECTS"))
+	config.ProtectedOverride = getEnvDefault(qase.OverrideEnv, "false") == "true"
+	config.StatusInterval = time.Duration(problems.Int("QASE_STATUS_INTERVAL", 10)) * time.Second
+	config.ControlAddr = os.Getenv("QASE_CONTROL_ADDR")
+	config.PprofAddr = os.Getenv("QASE_PPROF_ADDR")
+	config.TUI = getEnvDefault("QASE_TUI", "false") == "true"
+	config.TUILog = os.Getenv("QASE_TUI_LOG")
+
+	// Checkpointing
+	config.CheckpointLocation = os.Getenv("QASE_CHECKPOINT")
+	config.CheckpointInterval = time.Duration(problems.Int("QASE_CHECKPOINT_INTERVAL", 30)) * time.Second
+
+	// Watch mode
+	config.WatchInterval = time.Duration(problems.Int("QASE_WATCH_INTERVAL", 0)) * time.Second
+	config.WatchOverlap = time.Duration(problems.Int("QASE_WATCH_OVERLAP", 300)) * time.Second
+	config.HealthAddr = os.Getenv("QASE_HEALTH_ADDR")
+	config.HealthStallTimeout = time.Duration(problems.Int("QASE_HEALTH_STALL_TIMEOUT", 900)) * time.Second
+	if config.Role != "" && config.WatchInterval > 0 {
+		problems.Add(fmt.Errorf("QASE_WATCH_INTERVAL cannot be combined with %s (a bundle does not change)", config.Role))
+	}
+
+	// Status mapping, comment normalization and durations, shared with the other tools
+	config.Transform, err = transform.LoadOptions()
+	problems.Add(err)
+
+	config.RunCreateConcurrency = problems.Int("QASE_RUN_CREATE_CONCURRENCY", config.Concurrency)
+	config.RunCreateBatch = problems.Int("QASE_RUN_CREATE_BATCH", 20)
+	if config.Concurrency <= 0 || config.RunCreateConcurrency <= 0 || config.RunCreateBatch < 0 {
+		problems.Add(fmt.Errorf("QASE_CONCURRENCY and QASE_RUN_CREATE_CONCURRENCY must be positive and QASE_RUN_CREATE_BATCH not negative"))
+	}
+
+	switch config.RunBucket {
+	case BucketNone, BucketDaily, BucketWeekly:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_RUN_BUCKET: %s (use none, daily or weekly)", config.RunBucket))
+	}
+
+	switch config.RunOrder {
+	case OrderID, OrderEndTime:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_RUN_ORDER: %s (use id or end_time)", config.RunOrder))
+	}
+	switch config.RunOrderDirection {
+	case OldestFirst, NewestFirst:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_RUN_ORDER_DIRECTION: %s (use oldest_first or newest_first)", config.RunOrderDirection))
+	}
+
+	switch config.DeletedCases {
+	case DeletedFail, DeletedSkip, DeletedRemap:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_DELETED_CASES: %s (use fail, skip or remap)", config.DeletedCases))
+	}
+	switch config.DeletedRuns {
+	case DeletedFail, DeletedSkip, DeletedRecreate:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_DELETED_RUNS: %s (use fail, skip or recreate)", config.DeletedRuns))
+	}
+
+	switch config.OversizedRuns {
+	case OversizedFail, OversizedSplit, OversizedAllow:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_OVERSIZED_RUNS: %s (use fail, split or allow)", config.OversizedRuns))
+	}
+
+	config.FetchMode = getEnvDefault("QASE_FETCH_MODE", qase.FetchGlobal)
+	switch config.FetchMode {
+	case qase.FetchGlobal, qase.FetchByRun, qase.FetchAuto:
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_FETCH_MODE: %s (use global, by_run or auto)", config.FetchMode))
+	}
+	config.FetchRunIDs, err = qase.ParseRunIDs(os.Getenv("QASE_FETCH_RUN_IDS"))
+	if err != nil {
+		problems.Add(fmt.Errorf("invalid QASE_FETCH_RUN_IDS: %w", err))
+	}
+
+	config.Shard, err = parseShard(os.Getenv("QASE_SHARD"))
+	problems.Add(err)
+
+	config.Sample, err = parseSample(os.Getenv("QASE_SAMPLE"))
+	problems.Add(err)
+
+	config.Priority, err = parsePriority(os.Getenv("QASE_PRIORITY_RUNS"), os.Getenv("QASE_PRIORITY_TAGS"))
+	problems.Add(err)
+
+	config.Gates, err = parseGates(os.Getenv("QASE_MAX_SKIPPED_PCT"), os.Getenv("QASE_MAX_FAILED_RUNS"))
+	problems.Add(err)
+
+	config.Lock = os.Getenv("QASE_LOCK")
+	config.LockTTL = time.Duration(problems.Int("QASE_LOCK_TTL", 3600)) * time.Second
+
+	config.DedupeIndex = os.Getenv("QASE_DEDUPE_INDEX")
+	config.DedupeClaimTTL = time.Duration(problems.Int("QASE_DEDUPE_CLAIM_TTL", 3600)) * time.Second
+
+	// Watch cycles revisit runs that receive new results, which a checkpoint would skip
+	if config.WatchInterval > 0 && config.CheckpointLocation != "" {
+		problems.Add(fmt.Errorf("QASE_CHECKPOINT cannot be combined with QASE_WATCH_INTERVAL (idempotent mode already skips posted results)"))
+	}
+
+	config.Warehouse = os.Getenv("QASE_WAREHOUSE")
+	config.WarehouseMode = getEnvDefault("QASE_WAREHOUSE_MODE", warehouse.ModeAlso)
+	config.WarehouseTablePrefix = getEnvDefault("QASE_WAREHOUSE_TABLE_PREFIX", "qase_")
+	switch config.WarehouseMode {
+	case warehouse.ModeAlso:
+	case warehouse.ModeOnly:
+		// Features that write to the target project have nothing to write to
+		if config.Warehouse == "" {
+			problems.Add(fmt.Errorf("QASE_WAREHOUSE_MODE=only requires QASE_WAREHOUSE"))
 		}
-	} else if config.MatchMode == "csv" {
-		config.MappingCSV = mustEnv("QASE_MAPPING_CSV")
-	} else {
-		return nil, fmt.Errorf("unsupported QASE_MATCH_MODE: %s", config.MatchMode)
+		if config.Milestone != "" || len(config.RunCustomFields) > 0 || config.RawAttachments != qase.RawAttachNone || config.Resync || config.PersistCFID != 0 {
+			problems.Add(fmt.Errorf("QASE_WAREHOUSE_MODE=only cannot be combined with QASE_MILESTONE, QASE_RUN_CUSTOM_FIELDS, QASE_RAW_ATTACHMENTS, QASE_RESYNC or QASE_PERSIST_CF_ID"))
+		}
+	default:
+		problems.Add(fmt.Errorf("unsupported QASE_WAREHOUSE_MODE: %s (use also or only)", config.WarehouseMode))
 	}
 
-	// Status mapping
-	if statusMapStr := os.Getenv("QASE_STATUS_MAP"); statusMapStr != "" {
-		statusMap, err := parseStatusMap(statusMapStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse QASE_STATUS_MAP: %w", err)
+	// A review stage writes nothing to the target project before approval
+	config.ReviewDir = os.Getenv("QASE_REVIEW_DIR")
+	if config.ReviewDir != "" {
+		if config.Warehouse != "" || config.Milestone != "" || config.RawAttachments != qase.RawAttachNone || config.Resync || config.PersistCFID != 0 {
+			problems.Add(fmt.Errorf("QASE_REVIEW_DIR cannot be combined with QASE_WAREHOUSE, QASE_MILESTONE, QASE_RAW_ATTACHMENTS, QASE_RESYNC or QASE_PERSIST_CF_ID"))
 		}
-		config.StatusMap = statusMap
 	}
 
+	// Re-sync updates results of runs found by title, so it needs idempotent mode
+	if config.Resync && !config.Idempotent {
+		problems.Add(fmt.Errorf("QASE_RESYNC requires QASE_IDEMPOTENT=true"))
+	}
+
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-// transformResults transforms source results to target case IDs
-func transformResults(results []qase.Result, caseMapping map[int]int, statusMap map[string]string) ([]qase.BulkItem, int) {
-	var bulkItems []qase.BulkItem
-	skipped := 0
-
-	// Maximum time allowed by Qase API (1 year in seconds)
-	const maxTimeSeconds = 31536000
+// buildExternalIDMapping resolves the external ID field in each workspace and
+// joins the source and target cases on it
+func buildExternalIDMapping(config *Config, src source, tgtClient *api.Client, srcCases, tgtCases map[int]qase.Case) (map[int]int, error) {
+	srcCFID, err := src.customField(config.SourceProject, config.SourceExternalIDField)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source external ID field: %w", err)
+	}
+	tgtCFID, err := qase.ResolveCustomField(tgtClient, config.TargetProject, config.TargetExternalIDField)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve target external ID field: %w", err)
+	}
+	fmt.Printf("Joining on external ID fields %d (source) and %d (target)\n", srcCFID, tgtCFID)
+	return mapping.BuildExternalID(srcCases, tgtCases, srcCFID, tgtCFID)
+}
 
+// unmappedCases returns the source case of each result without a target case,
+// leaving out skipped cases
+func unmappedCases(results []qase.Result, caseMapping map[int]int, overrides transform.CaseOverrides) []int {
+	var caseIDs []int
 	for _, result := range results {
-		targetCaseID, exists := caseMapping[result.CaseID]
-		if !exists {
-			skipped++
+		if overrides.Skip[result.CaseID] {
 			continue
 		}
-
-		// Apply status mapping if configured
-		status := result.Status
-		if statusMap != nil {
-			if mappedStatus, exists := statusMap[status]; exists {
-				status = mappedStatus
-			}
+		if _, exists := overrides.TargetCase(result.CaseID, caseMapping); !exists {
+			caseIDs = append(caseIDs, result.CaseID)
 		}
+	}
+	return caseIDs
+}
 
-		// Validate and cap time value if present
-		var timeSeconds *int
-		if result.Time != nil && *result.Time > 0 {
-			timeInSeconds := *result.Time
-			if timeInSeconds > maxTimeSeconds {
-				fmt.Printf("Warning: Capping time for case %d from %d seconds to %d seconds (max allowed)\n", 
-					result.CaseID, timeInSeconds, maxTimeSeconds)
-				timeInSeconds = maxTimeSeconds
-			}
-			timeSeconds = &timeInSeconds
+// claimResults claims the bulk items' source results in the dedupe index and
+// returns the items to post with their claims, by position. Items without a
+// source hash are always posted and have an empty claim.
+func claimResults(index *dedupe.Index, targetProject string, items []qase.BulkItem) ([]qase.BulkItem, []string, error) {
+	if index == nil {
+		return items, nil, nil
+	}
+	var keys []string
+	for _, item := range items {
+		if hash := qase.SourceHash(item.Comment); hash != "" {
+			keys = append(keys, dedupe.Key(targetProject, hash))
 		}
+	}
+	claimed, err := index.Claim(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[string]bool, len(claimed))
+	for _, key := range claimed {
+		owned[key] = true
+	}
 
-		bulkItem := qase.BulkItem{
-			CaseID:  targetCaseID,
-			Status:  status,
-			Time:    timeSeconds,
-			Comment: result.Comment,
+	var toPost []qase.BulkItem
+	var claims []string
+	for _, item := range items {
+		hash := qase.SourceHash(item.Comment)
+		if hash == "" {
+			toPost = append(toPost, item)
+			claims = append(claims, "")
+			continue
+		}
+		if key := dedupe.Key(targetProject, hash); owned[key] {
+			toPost = append(toPost, item)
+			claims = append(claims, key)
 		}
-
-		bulkItems = append(bulkItems, bulkItem)
 	}
-
-	return bulkItems, skipped
+	if dropped := len(items) - len(toPost); dropped > 0 {
+		fmt.Printf("Dedupe index: %d results already posted or being posted elsewhere\n", dropped)
+	}
+	return toPost, claims, nil
 }
 
-// writeMappingArtifact writes the case mapping to a CSV file
-func writeMappingArtifact(caseMapping map[int]int) error {
-	file, err := os.Create("case_map.out.csv")
-	if err != nil {
+// settleClaims marks the claims of the first posted items as done and
+// releases the rest
+func settleClaims(index *dedupe.Index, claims []string, posted int) error {
+	var done, released []string
+	for i, key := range claims {
+		switch {
+		case key == "":
+		case i < posted:
+			done = append(done, key)
+		default:
+			released = append(released, key)
+		}
+	}
+	if err := index.Commit(done); err != nil {
 		return err
 	}
-	defer file.Close()
+	return index.Release(released)
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// recordFailure adds a failed run to the needs-attention report, itemizing
+// oversized results and the chunk that failed to post
+func recordFailure(attention *triage.Report, runID, targetRunID int, err error, class errclass.Class) {
+	var payloadErr *qase.PayloadError
+	var chunkErr *qase.ChunkError
+	switch {
+	case errors.As(err, &payloadErr):
+		for _, chunk := range payloadErr.Chunks {
+			for _, item := range chunk.Largest {
+				attention.Add(triage.Item{
+					Kind:        triage.KindOversizedComment,
+					SourceRuns:  []int{runID},
+					CaseID:      item.CaseID,
+					Detail:      fmt.Sprintf("result is %d bytes (comment %d bytes) in chunk %d of %d bytes, over the %d byte limit", item.Size, item.CommentSize, chunk.Number, chunk.Size, payloadErr.Limit),
+					Remediation: "shorten the comment with QASE_COMMENT_HOOK or lower QASE_BULK_SIZE, then re-run",
+				})
+			}
+		}
+	case errors.As(err, &chunkErr):
+		attention.Add(triage.Item{
+			Kind:        triage.KindFailedChunk,
+			SourceRuns:  []int{runID},
+			TargetRunID: targetRunID,
+			Results:     chunkErr.Items,
+			Detail:      fmt.Sprintf("chunk %d/%d failed after %d results were posted: %v", chunkErr.Number, chunkErr.Total, chunkErr.Posted, chunkErr.Err),
+			Remediation: errclass.Hint(class),
+		})
+	default:
+		attention.Add(triage.Item{
+			Kind:        triage.KindFailedRun,
+			SourceRuns:  []int{runID},
+			TargetRunID: targetRunID,
+			Detail:      err.Error(),
+			Remediation: errclass.Hint(class),
+		})
+	}
+}
 
-	// Write header
-	if err := writer.Write([]string{"source_case_id", "target_case_id"}); err != nil {
+// writeMappingArtifact writes the case mapping as CSV to a local path or object
+// storage URL, sorted by source case ID so artifacts of different runs diff cleanly
+func writeMappingArtifact(caseMapping map[int]int, srcCases, tgtCases map[int]qase.Case, withTitles, force bool, location string) error {
+	data, err := encodeMapping(caseMapping, srcCases, tgtCases, withTitles)
+	if err != nil {
 		return err
 	}
 
-	// Write mappings
-	for sourceID, targetID := range caseMapping {
-		if err := writer.Write([]string{strconv.Itoa(sourceID), strconv.Itoa(targetID)}); err != nil {
-			return err
-		}
+	// Local files are written atomically, so a crash never leaves a truncated artifact
+	location, err = artifact.WriteProtected(location, data, force)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Mapping artifact written to case_map.out.csv")
+	fmt.Printf("Mapping artifact written to %s\n", location)
 	return nil
 }
 
-// parseStatusMap parses status mapping from environment variable
-func parseStatusMap(statusMapStr string) (map[string]string, error) {
-	statusMap := make(map[string]string)
+// encodeMapping formats the case mapping as CSV sorted by source case ID
+func encodeMapping(caseMapping map[int]int, srcCases, tgtCases map[int]qase.Case, withTitles bool) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
 
-	pairs := strings.Split(statusMapStr, ",")
-	for _, pair := range pairs {
-		parts := strings.SplitN(pair, ":", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid status mapping pair: %s", pair)
-		}
+	// Write header
+	header := []string{"source_case_id", "target_case_id"}
+	if withTitles {
+		header = append(header, "source_title", "target_title")
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		statusMap[key] = value
+	sourceIDs := make([]int, 0, len(caseMapping))
+	for sourceID := range caseMapping {
+		sourceIDs = append(sourceIDs, sourceID)
 	}
+	sort.Ints(sourceIDs)
 
-	return statusMap, nil
-}
+	// Write mappings
+	for _, sourceID := range sourceIDs {
+		targetID := caseMapping[sourceID]
+		record := []string{strconv.Itoa(sourceID), strconv.Itoa(targetID)}
+		if withTitles {
+			record = append(record, srcCases[sourceID].Title, tgtCases[targetID].Title)
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
 
-// Helper functions for environment variables
-func mustEnv(key string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		log.Fatalf("Required environment variable %s is not set", key)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
 	}
-	return value
+	return buf.Bytes(), nil
 }
 
-func mustInt(key string) int {
-	value := mustEnv(key)
-	intValue, err := strconv.Atoi(value)
-	if err != nil {
-		log.Fatalf("Environment variable %s must be an integer, got: %s", key, value)
+// tokenPoolConflicts reports token commands combined with a token pool: a
+// pool holds fixed tokens and would never use a refreshed one
+func tokenPoolConflicts(config *Config) []error {
+	var conflicts []error
+	for _, side := range []struct {
+		name, command string
+		extra         []string
+	}{
+		{"SOURCE", config.SourceTokenCommand, config.SourceExtraTokens},
+		{"TARGET", config.TargetTokenCommand, config.TargetExtraTokens},
+	} {
+		if side.command != "" && (len(side.extra) > 0 || config.TokenRPM > 0) {
+			conflicts = append(conflicts, fmt.Errorf("QASE_%s_TOKEN_COMMAND cannot be combined with QASE_%s_API_TOKENS or QASE_TOKEN_RPM", side.name, side.name))
+		}
 	}
-	return intValue
+	return conflicts
 }
 
+// defaultTargetRPM is the target rate shared by post workers when
+// QASE_TARGET_RPM is unset: the rate of the target token pool
+func defaultTargetRPM(config *Config) int {
+	return config.TokenRPM * (1 + len(config.TargetExtraTokens))
+}
+
+// Helper functions for environment variables
 func getEnvDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -511,3 +1904,22 @@ func maskToken(token string) string {
 	}
 	return token[:8] + "..." + token[len(token)-4:]
 }
+
+// reportCountChecks prints how the listings of the migration reconciled with
+// the totals the API reported, listing every disagreement
+func reportCountChecks(clients ...*api.Client) {
+	checked := 0
+	var mismatches []string
+	for _, client := range clients {
+		n, clientMismatches := client.CountChecks()
+		checked += n
+		mismatches = append(mismatches, clientMismatches...)
+	}
+	if checked == 0 {
+		return
+	}
+	fmt.Printf("Count reconciliation: %d listings checked against API totals, %d mismatches\n", checked, len(mismatches))
+	for _, mismatch := range mismatches {
+		fmt.Printf("  %s\n", mismatch)
+	}
+}
@@ -0,0 +1,116 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValueRules normalize the custom field value holding the source case ID(s).
+// Values are trimmed; a JSON array maps every ID it holds to the same target
+// case. Each ID may carry one of the configured prefixes (e.g. "SRC-1234"), or
+// be extracted by a pattern whose first capture group is the ID; plain
+// integers are always accepted. A nil *ValueRules only accepts plain integers
+// and JSON arrays of them.
+type ValueRules struct {
+	Prefixes []string       // stripped case-insensitively before parsing
+	Pattern  *regexp.Regexp // first capture group (or whole match) is the ID
+}
+
+// ParseValueRules builds value rules from a comma-separated prefix list and a
+// regular expression; both empty means no rules
+func ParseValueRules(prefixes, pattern string) (*ValueRules, error) {
+	rules := &ValueRules{}
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			rules.Prefixes = append(rules.Prefixes, prefix)
+		}
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom field value pattern: %w", err)
+		}
+		if re.NumSubexp() > 1 {
+			return nil, fmt.Errorf("custom field value pattern must have at most one capture group")
+		}
+		rules.Pattern = re
+	}
+	if len(rules.Prefixes) == 0 && rules.Pattern == nil {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// SourceIDs parses a custom field value into the source case IDs it holds
+func (r *ValueRules) SourceIDs(value string) ([]int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+
+	if strings.HasPrefix(value, "[") {
+		var items []interface{}
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		ids := make([]int, 0, len(items))
+		for _, item := range items {
+			var id int
+			var err error
+			switch v := item.(type) {
+			case float64:
+				id, err = int(v), nil
+				if float64(id) != v {
+					err = fmt.Errorf("%v is not an integer", v)
+				}
+			case string:
+				id, err = r.parseID(v)
+			default:
+				err = fmt.Errorf("unsupported array item %v", item)
+			}
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("empty array")
+		}
+		return ids, nil
+	}
+
+	id, err := r.parseID(value)
+	if err != nil {
+		return nil, err
+	}
+	return []int{id}, nil
+}
+
+// parseID applies the prefix and pattern rules to a single ID
+func (r *ValueRules) parseID(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if r != nil {
+		for _, prefix := range r.Prefixes {
+			if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+				value = strings.TrimSpace(value[len(prefix):])
+				break
+			}
+		}
+		if _, err := strconv.Atoi(value); err != nil && r.Pattern != nil {
+			match := r.Pattern.FindStringSubmatch(value)
+			if match == nil {
+				return 0, fmt.Errorf("%q does not match the value pattern", value)
+			}
+			value = match[len(match)-1]
+		}
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%q is not a case ID", value)
+	}
+	return id, nil
+}
@@ -0,0 +1,41 @@
+package mapping
+
+import "sort"
+
+// Kinds of mapping changes
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeRouted  = "changed" // the source case maps to another target case
+)
+
+// Change is a difference between two case mappings. Target IDs are 0 where
+// the source case is not mapped.
+type Change struct {
+	Kind           string
+	SourceID       int
+	PreviousTarget int
+	Target         int
+}
+
+// Diff returns the changes from a previous mapping to the current one,
+// sorted by source case ID
+func Diff(previous, current map[int]int) []Change {
+	var changes []Change
+	for sourceID, targetID := range current {
+		previousID, existed := previous[sourceID]
+		switch {
+		case !existed:
+			changes = append(changes, Change{Kind: ChangeAdded, SourceID: sourceID, Target: targetID})
+		case previousID != targetID:
+			changes = append(changes, Change{Kind: ChangeRouted, SourceID: sourceID, PreviousTarget: previousID, Target: targetID})
+		}
+	}
+	for sourceID, previousID := range previous {
+		if _, exists := current[sourceID]; !exists {
+			changes = append(changes, Change{Kind: ChangeRemoved, SourceID: sourceID, PreviousTarget: previousID})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].SourceID < changes[j].SourceID })
+	return changes
+}
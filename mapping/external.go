@@ -0,0 +1,70 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// ModeExternalID joins source and target cases on an identifier both carry,
+// such as an automation test's fully qualified name
+const ModeExternalID Mode = "external_id"
+
+// BuildExternalID maps source cases to the target cases holding the same
+// value in their external ID custom field. Each workspace has its own field
+// (srcCFID on source cases, tgtCFID on target cases). Values are compared
+// after trimming; target cases sharing a value are ambiguous and left unmapped.
+func BuildExternalID(srcCases, tgtCases map[int]qase.Case, srcCFID, tgtCFID int) (map[int]int, error) {
+	if srcCFID == 0 || tgtCFID == 0 {
+		return nil, fmt.Errorf("source and target external ID fields are required for external_id mode")
+	}
+
+	// Index target cases by external ID, dropping values used more than once
+	targets := make(map[string]int)
+	ambiguous := make(map[string]bool)
+	for _, tgtCase := range tgtCases {
+		externalID := externalIDValue(tgtCase, tgtCFID)
+		if externalID == "" || ambiguous[externalID] {
+			continue
+		}
+		if _, exists := targets[externalID]; exists {
+			delete(targets, externalID)
+			ambiguous[externalID] = true
+			continue
+		}
+		targets[externalID] = tgtCase.ID
+	}
+
+	mapping := make(map[int]int)
+	missing, skipped := 0, 0
+	for _, srcCase := range srcCases {
+		externalID := externalIDValue(srcCase, srcCFID)
+		if externalID == "" {
+			missing++
+			continue
+		}
+		if ambiguous[externalID] {
+			fmt.Printf("Skipping case %d: external ID %q is on several target cases\n", srcCase.ID, externalID)
+			skipped++
+			continue
+		}
+		if targetID, ok := targets[externalID]; ok {
+			mapping[srcCase.ID] = targetID
+		}
+	}
+
+	fmt.Printf("Built external ID mapping: %d entries (%d source cases without an external ID, %d ambiguous)\n",
+		len(mapping), missing, skipped)
+	return mapping, nil
+}
+
+// externalIDValue returns a case's trimmed external ID, or ""
+func externalIDValue(c qase.Case, cfID int) string {
+	for _, field := range c.CustomFields {
+		if field.ID == cfID {
+			return strings.TrimSpace(field.Value)
+		}
+	}
+	return ""
+}
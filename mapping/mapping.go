@@ -1,12 +1,13 @@
 package mapping
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
 	"github.com/adrianeortiz/clone-run-multi-ws/qase"
 )
 
@@ -14,63 +15,88 @@ import (
 type Mode string
 
 const (
-	ModeCSV = "csv"
-	ModeCF  = "custom_field"
+	ModeCSV Mode = "csv"
+	ModeCF  Mode = "custom_field"
 )
 
-// Build creates a mapping from source case ID to target case ID
-func Build(mode Mode, srcCases map[int]qase.Case, tgtCases map[int]qase.Case, cfID int, csvPath string) (map[int]int, error) {
+// Modes lists the supported mapping modes
+var Modes = []Mode{ModeCF, ModeCSV, ModeExternalID}
+
+// ParseMode returns the mapping mode named by value, or an error listing the
+// supported modes
+func ParseMode(value string) (Mode, error) {
+	names := make([]string, len(Modes))
+	for i, mode := range Modes {
+		if string(mode) == value {
+			return mode, nil
+		}
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("unsupported match mode: %s (use %s)", value, strings.Join(names, ", "))
+}
+
+// Build creates a mapping from source case ID to target case ID. In
+// custom_field mode, rules normalize the field values (nil for plain IDs).
+func Build(mode Mode, srcCases map[int]qase.Case, tgtCases map[int]qase.Case, cfID int, rules *ValueRules, csvPath string) (map[int]int, error) {
 	switch mode {
 	case ModeCSV:
 		return buildCSVMapping(csvPath)
 	case ModeCF:
-		return buildCustomFieldMapping(tgtCases, cfID)
+		return buildCustomFieldMapping(tgtCases, cfID, rules)
 	default:
 		return nil, fmt.Errorf("unsupported mapping mode: %s", mode)
 	}
 }
 
+// LoadCSV reads a mapping CSV, such as a case_map.out.csv artifact
+func LoadCSV(location string) (map[int]int, error) {
+	return buildCSVMapping(location)
+}
+
 // buildCSVMapping creates mapping from CSV file
 func buildCSVMapping(csvPath string) (map[int]int, error) {
 	if csvPath == "" {
 		return nil, fmt.Errorf("CSV path is required for csv mode")
 	}
 
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV file: %w", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	records, err := readCSV(csvPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+		return nil, err
 	}
 
 	if len(records) < 2 {
 		return nil, fmt.Errorf("CSV file must have at least a header and one data row")
 	}
 
+	// Select columns by header name, falling back to the first two columns
+	sourceCol, targetCol, err := mappingColumns(records[0])
+	if err != nil {
+		return nil, err
+	}
+
 	// Skip header row
 	records = records[1:]
 
 	mapping := make(map[int]int)
 	for i, record := range records {
-		if len(record) < 2 {
+		if isBlankRecord(record) {
+			continue
+		}
+
+		if len(record) <= sourceCol || len(record) <= targetCol {
 			fmt.Printf("Skipping invalid row %d: insufficient columns\n", i+2)
 			continue
 		}
 
-		sourceID, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		sourceID, err := strconv.Atoi(strings.TrimSpace(record[sourceCol]))
 		if err != nil {
-			fmt.Printf("Skipping invalid row %d: invalid source case ID '%s'\n", i+2, record[0])
+			fmt.Printf("Skipping invalid row %d: invalid source case ID '%s'\n", i+2, record[sourceCol])
 			continue
 		}
 
-		targetID, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		targetID, err := strconv.Atoi(strings.TrimSpace(record[targetCol]))
 		if err != nil {
-			fmt.Printf("Skipping invalid row %d: invalid target case ID '%s'\n", i+2, record[1])
+			fmt.Printf("Skipping invalid row %d: invalid target case ID '%s'\n", i+2, record[targetCol])
 			continue
 		}
 
@@ -81,8 +107,111 @@ func buildCSVMapping(csvPath string) (map[int]int, error) {
 	return mapping, nil
 }
 
+// Header names accepted for the source and target case ID columns
+var (
+	sourceHeaders = []string{"source_case_id", "source_id", "source", "src_case_id", "src"}
+	targetHeaders = []string{"target_case_id", "target_id", "target", "tgt_case_id", "tgt"}
+)
+
+// readCSV reads a mapping CSV exported by spreadsheets or scripts, handling a
+// UTF-8 BOM, comma/semicolon/tab delimiters, quoted fields, and ragged rows
+func readCSV(csvPath string) ([][]string, error) {
+	data, err := artifact.Read(csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+	}
+
+	return records, nil
+}
+
+// detectDelimiter picks the most frequent delimiter in the header line,
+// ignoring characters inside quotes
+func detectDelimiter(data []byte) rune {
+	line := data
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		line = data[:idx]
+	}
+
+	counts := map[rune]int{}
+	inQuotes := false
+	for _, r := range string(line) {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ',' || r == ';' || r == '\t'):
+			counts[r]++
+		}
+	}
+
+	delimiter := ','
+	for _, candidate := range []rune{';', '\t'} {
+		if counts[candidate] > counts[delimiter] {
+			delimiter = candidate
+		}
+	}
+	return delimiter
+}
+
+// mappingColumns finds the source and target column indexes from the header row
+func mappingColumns(header []string) (int, int, error) {
+	sourceCol := findColumn(header, sourceHeaders)
+	targetCol := findColumn(header, targetHeaders)
+
+	if sourceCol == -1 && targetCol == -1 {
+		if len(header) < 2 {
+			return 0, 0, fmt.Errorf("CSV header must have at least two columns")
+		}
+		fmt.Println("CSV header has no source/target column names, using the first two columns")
+		return 0, 1, nil
+	}
+
+	if sourceCol == -1 || targetCol == -1 {
+		return 0, 0, fmt.Errorf("CSV header must name both columns (e.g. %s,%s), got: %s",
+			sourceHeaders[0], targetHeaders[0], strings.Join(header, ","))
+	}
+
+	return sourceCol, targetCol, nil
+}
+
+// findColumn returns the index of the first header matching one of names
+func findColumn(header []string, names []string) int {
+	for _, name := range names {
+		for i, col := range header {
+			normalized := strings.ToLower(strings.TrimSpace(col))
+			normalized = strings.NewReplacer(" ", "_", "-", "_").Replace(normalized)
+			if normalized == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isBlankRecord reports whether every field in the record is empty
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
 // buildCustomFieldMapping creates mapping from custom field values
-func buildCustomFieldMapping(tgtCases map[int]qase.Case, cfID int) (map[int]int, error) {
+func buildCustomFieldMapping(tgtCases map[int]qase.Case, cfID int, rules *ValueRules) (map[int]int, error) {
 	if cfID == 0 {
 		return nil, fmt.Errorf("custom field ID is required for custom_field mode")
 	}
@@ -92,12 +221,14 @@ func buildCustomFieldMapping(tgtCases map[int]qase.Case, cfID int) (map[int]int,
 	for _, tgtCase := range tgtCases {
 		for _, field := range tgtCase.CustomFields {
 			if field.ID == cfID {
-				sourceID, err := strconv.Atoi(field.Value)
+				sourceIDs, err := rules.SourceIDs(field.Value)
 				if err != nil {
-					fmt.Printf("Skipping case %d: invalid custom field value '%s'\n", tgtCase.ID, field.Value)
-					continue
+					fmt.Printf("Skipping case %d: invalid custom field value '%s': %v\n", tgtCase.ID, field.Value, err)
+					break
+				}
+				for _, sourceID := range sourceIDs {
+					mapping[sourceID] = tgtCase.ID
 				}
-				mapping[sourceID] = tgtCase.ID
 				break
 			}
 		}
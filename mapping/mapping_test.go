@@ -0,0 +1,69 @@
+package mapping_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+func BenchmarkMappingBuild(b *testing.B) {
+	fixture := mockserver.Generate(mockserver.FixtureOptions{Cases: 100000, Runs: 1, ResultsPerRun: 1, Seed: 1})
+	srcCases := make(map[int]qase.Case, len(fixture.SourceCases))
+	for _, c := range fixture.SourceCases {
+		srcCases[c.ID] = c
+	}
+	tgtCases := make(map[int]qase.Case, len(fixture.TargetCases))
+	for _, c := range fixture.TargetCases {
+		tgtCases[c.ID] = c
+	}
+
+	// The same mapping as a CSV, from the custom field values
+	var csv strings.Builder
+	csv.WriteString("source_id,target_id\n")
+	for _, c := range fixture.TargetCases {
+		for _, field := range c.CustomFields {
+			if field.ID == fixture.CustomFieldID {
+				csv.WriteString(field.Value + "," + strconv.Itoa(c.ID) + "\n")
+			}
+		}
+	}
+	csvPath := filepath.Join(b.TempDir(), "mapping.csv")
+	if err := os.WriteFile(csvPath, []byte(csv.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	// Builds print progress; keep it out of the report
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+
+	b.Run("custom_field", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := mapping.Build(mapping.ModeCF, srcCases, tgtCases, fixture.CustomFieldID, nil, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("csv", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := mapping.Build(mapping.ModeCSV, nil, nil, 0, nil, csvPath); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// PersistResult summarizes a mapping persistence pass
+type PersistResult struct {
+	Updated   int
+	Unchanged int
+	Conflicts int
+	Failed    int
+}
+
+// Persist writes each mapped source case ID into the target case's custom
+// field so future migrations can use custom_field mode directly. Target cases
+// mapped from several source cases are reported as conflicts and left as is.
+func Persist(c *api.Client, project string, caseMapping map[int]int, tgtCases map[int]qase.Case, cfID int, concurrency int, dryRun bool) PersistResult {
+	var result PersistResult
+
+	// Invert the mapping to find the source ID each target case should carry
+	sourcesByTarget := make(map[int][]int)
+	for sourceID, targetID := range caseMapping {
+		sourcesByTarget[targetID] = append(sourcesByTarget[targetID], sourceID)
+	}
+
+	targetIDs := make([]int, 0, len(sourcesByTarget))
+	for targetID := range sourcesByTarget {
+		targetIDs = append(targetIDs, targetID)
+	}
+	sort.Ints(targetIDs)
+
+	var pending []int
+	for _, targetID := range targetIDs {
+		sources := sourcesByTarget[targetID]
+		if len(sources) > 1 {
+			sort.Ints(sources)
+			fmt.Printf("Skipping target case %d: mapped from multiple source cases %v\n", targetID, sources)
+			result.Conflicts++
+			continue
+		}
+		if currentValue(tgtCases[targetID], cfID) == strconv.Itoa(sources[0]) {
+			result.Unchanged++
+			continue
+		}
+		pending = append(pending, targetID)
+	}
+
+	if dryRun {
+		fmt.Printf("DRY RUN MODE - Would write custom field %d on %d target cases\n", cfID, len(pending))
+		result.Updated = len(pending)
+		return result
+	}
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+
+	for _, targetID := range pending {
+		wg.Add(1)
+		go func(targetID, sourceID int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			err := qase.UpdateCaseCustomField(c, project, targetID, cfID, strconv.Itoa(sourceID))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Printf("Failed to write custom field on target case %d: %v\n", targetID, err)
+				result.Failed++
+				return
+			}
+			result.Updated++
+		}(targetID, sourcesByTarget[targetID][0])
+	}
+	wg.Wait()
+
+	fmt.Printf("Persisted mapping to custom field %d: %d updated, %d unchanged, %d conflicts, %d failed\n",
+		cfID, result.Updated, result.Unchanged, result.Conflicts, result.Failed)
+	return result
+}
+
+// currentValue returns the value of a custom field on a case, or ""
+func currentValue(c qase.Case, cfID int) string {
+	for _, field := range c.CustomFields {
+		if field.ID == cfID {
+			return field.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package mapping
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// Match types reported by Suggest, from most to least reliable
+const (
+	MatchTitleAndSuite = "title+suite"
+	MatchTitleUnique   = "title"
+	MatchTitleAmbig    = "title_ambiguous"
+	MatchFuzzy         = "fuzzy"
+	MatchNone          = "unmatched"
+)
+
+// Suggestion is a proposed source -> target case match with a confidence score
+type Suggestion struct {
+	SourceID    int
+	TargetID    int // 0 when unmatched
+	Confidence  float64
+	MatchType   string
+	SourceTitle string
+	TargetTitle string
+	SourceSuite string
+	TargetSuite string
+}
+
+// CaseInfo is a case with its resolved suite path, used for matching
+type CaseInfo struct {
+	ID    int
+	Title string
+	Suite string
+}
+
+// NewCaseInfos resolves suite paths for a project's cases
+func NewCaseInfos(cases map[int]qase.Case, suites map[int]qase.Suite) []CaseInfo {
+	infos := make([]CaseInfo, 0, len(cases))
+	for _, c := range cases {
+		infos = append(infos, CaseInfo{ID: c.ID, Title: c.Title, Suite: qase.SuitePath(suites, c.SuiteID)})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Suggest auto-matches source cases to target cases by title and suite path.
+// Fuzzy matches below minFuzzy token similarity are reported as unmatched.
+func Suggest(src, tgt []CaseInfo, minFuzzy float64) []Suggestion {
+	byTitle := make(map[string][]CaseInfo)
+	byToken := make(map[string][]int)
+	tgtByID := make(map[int]CaseInfo)
+	tgtTokens := make(map[int]map[string]bool)
+
+	for _, t := range tgt {
+		byTitle[normalizeTitle(t.Title)] = append(byTitle[normalizeTitle(t.Title)], t)
+		tgtByID[t.ID] = t
+		tgtTokens[t.ID] = tokenSet(t.Title)
+		for token := range tgtTokens[t.ID] {
+			byToken[token] = append(byToken[token], t.ID)
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, len(src))
+	for _, s := range src {
+		suggestion := Suggestion{
+			SourceID:    s.ID,
+			SourceTitle: s.Title,
+			SourceSuite: s.Suite,
+			MatchType:   MatchNone,
+		}
+
+		if candidates := byTitle[normalizeTitle(s.Title)]; len(candidates) > 0 {
+			best, sameSuite := bestBySuite(s, candidates)
+			suggestion.TargetID = best.ID
+			suggestion.TargetTitle = best.Title
+			suggestion.TargetSuite = best.Suite
+			switch {
+			case sameSuite:
+				suggestion.MatchType = MatchTitleAndSuite
+				suggestion.Confidence = 1.0
+			case len(candidates) == 1:
+				suggestion.MatchType = MatchTitleUnique
+				suggestion.Confidence = 0.9
+			default:
+				suggestion.MatchType = MatchTitleAmbig
+				suggestion.Confidence = 0.6
+			}
+		} else {
+			// Fuzzy: score only targets sharing at least one title token
+			srcTokens := tokenSet(s.Title)
+			scored := make(map[int]bool)
+			bestScore := 0.0
+			bestID := 0
+			for token := range srcTokens {
+				for _, id := range byToken[token] {
+					if scored[id] {
+						continue
+					}
+					scored[id] = true
+					score := jaccard(srcTokens, tgtTokens[id])
+					if score > bestScore || (score == bestScore && id < bestID) {
+						bestScore = score
+						bestID = id
+					}
+				}
+			}
+			if bestID != 0 && bestScore >= minFuzzy {
+				best := tgtByID[bestID]
+				suggestion.TargetID = best.ID
+				suggestion.TargetTitle = best.Title
+				suggestion.TargetSuite = best.Suite
+				suggestion.MatchType = MatchFuzzy
+				suggestion.Confidence = bestScore * 0.8
+				if normalizeTitle(best.Suite) == normalizeTitle(s.Suite) {
+					suggestion.Confidence += 0.1
+				}
+			}
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions
+}
+
+// bestBySuite picks the candidate whose suite path matches the source best
+func bestBySuite(src CaseInfo, candidates []CaseInfo) (CaseInfo, bool) {
+	srcSuite := normalizeTitle(src.Suite)
+	best := candidates[0]
+	bestScore := -1.0
+	for _, c := range candidates {
+		if normalizeTitle(c.Suite) == srcSuite {
+			return c, true
+		}
+		score := jaccard(tokenSet(src.Suite), tokenSet(c.Suite))
+		if score > bestScore || (score == bestScore && c.ID < best.ID) {
+			best = c
+			bestScore = score
+		}
+	}
+	return best, false
+}
+
+// normalizeTitle lowercases and collapses whitespace and punctuation
+func normalizeTitle(title string) string {
+	return strings.Join(tokens(title), " ")
+}
+
+func tokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func tokenSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range tokens(s) {
+		set[t] = true
+	}
+	return set
+}
+
+// jaccard returns the token-set similarity of two strings (0..1)
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	intersection := 0
+	for t := range a {
+		if b[t] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
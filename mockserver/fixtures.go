@@ -0,0 +1,283 @@
+package mockserver
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// FixtureOptions controls the size and shape of generated data
+type FixtureOptions struct {
+	SourceProject string
+	TargetProject string
+	Cases         int       // source cases (default 1000)
+	Runs          int       // source runs (default 50)
+	ResultsPerRun int       // median results per run (default 200)
+	CustomFieldID int       // target case field holding the source case ID (default 1)
+	Since         time.Time // start of the run window (default 90 days before Until)
+	Until         time.Time // end of the run window (default now)
+	Seed          uint64    // same seed, same data
+}
+
+// Fixture is a generated source project with its target counterpart
+type Fixture struct {
+	SourceProject string
+	TargetProject string
+	CustomFieldID int
+	Suites        []qase.Suite
+	SourceCases   []qase.Case
+	TargetCases   []qase.Case
+	Runs          []qase.Run
+	Results       []qase.Result
+}
+
+// statusWeights is the share of each status in a healthy run
+var statusWeights = []struct {
+	status string
+	weight float64
+}{
+	{"passed", 0.86},
+	{"failed", 0.07},
+	{"skipped", 0.04},
+	{"blocked", 0.02},
+	{"invalid", 0.01},
+}
+
+var (
+	titleSubjects = []string{"login", "checkout", "search", "profile", "cart", "invoice", "report", "upload", "export", "settings", "notification", "payment", "session", "dashboard", "API token"}
+	titleActions  = []string{"succeeds", "fails gracefully", "is rejected", "is saved", "is displayed", "times out", "is retried", "is audited"}
+	titleContexts = []string{"with valid input", "with an expired session", "for a guest user", "on mobile", "after a page reload", "with unicode data", "under load", "with two tabs open"}
+	suiteNames    = []string{"Authentication", "Billing", "Catalog", "Accounts", "Reporting", "Integrations", "Admin", "Mobile", "Regression", "Smoke"}
+)
+
+// Generate synthesizes cases, runs and results with realistic distributions:
+// log-normal run sizes and durations, a few unstable runs with many failures,
+// failure comments with stack traces (some with ANSI colors, as pasted from CI
+// logs), and target cases carrying the source case ID in a custom field.
+// About 2% of target cases are left unmapped.
+func Generate(opts FixtureOptions) *Fixture {
+	if opts.SourceProject == "" {
+		opts.SourceProject = "SRC"
+	}
+	if opts.TargetProject == "" {
+		opts.TargetProject = "TGT"
+	}
+	if opts.Cases <= 0 {
+		opts.Cases = 1000
+	}
+	if opts.Runs <= 0 {
+		opts.Runs = 50
+	}
+	if opts.ResultsPerRun <= 0 {
+		opts.ResultsPerRun = 200
+	}
+	if opts.CustomFieldID <= 0 {
+		opts.CustomFieldID = 1
+	}
+	if opts.Until.IsZero() {
+		opts.Until = time.Now().UTC().Truncate(time.Second)
+	}
+	if opts.Since.IsZero() || !opts.Since.Before(opts.Until) {
+		opts.Since = opts.Until.AddDate(0, 0, -90)
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15))
+	f := &Fixture{
+		SourceProject: opts.SourceProject,
+		TargetProject: opts.TargetProject,
+		CustomFieldID: opts.CustomFieldID,
+	}
+
+	// Suites: a shallow tree, nested under an earlier suite 60% of the time
+	suiteCount := max(1, opts.Cases/25)
+	for i := 1; i <= suiteCount; i++ {
+		suite := qase.Suite{ID: i, Title: suiteNames[(i-1)%len(suiteNames)]}
+		if i > 1 && rng.Float64() < 0.6 {
+			parent := rng.IntN(i-1) + 1
+			suite.ParentID = &parent
+			suite.Title = fmt.Sprintf("%s %d", suite.Title, i)
+		}
+		f.Suites = append(f.Suites, suite)
+	}
+
+	// Cases, mirrored in the target with the source ID in the custom field
+	const targetIDOffset = 100000
+	for id := 1; id <= opts.Cases; id++ {
+		suiteID := rng.IntN(suiteCount) + 1
+		title := fmt.Sprintf("%s %s %s",
+			pick(rng, titleSubjects), pick(rng, titleActions), pick(rng, titleContexts))
+		title = strings.ToUpper(title[:1]) + title[1:]
+
+		f.SourceCases = append(f.SourceCases, qase.Case{ID: id, Title: title, SuiteID: &suiteID})
+
+		target := qase.Case{ID: targetIDOffset + id, Title: title, SuiteID: &suiteID}
+		if rng.Float64() >= 0.02 {
+			target.CustomFields = []qase.CustomField{{ID: opts.CustomFieldID, Value: strconv.Itoa(id)}}
+		}
+		f.TargetCases = append(f.TargetCases, target)
+	}
+
+	// Runs spread over the window, with log-normal sizes around the median
+	window := opts.Until.Sub(opts.Since)
+	starts := make([]time.Time, opts.Runs)
+	for i := range starts {
+		starts[i] = opts.Since.Add(time.Duration(rng.Int64N(int64(window))))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	for i, start := range starts {
+		runID := i + 1
+		size := int(float64(opts.ResultsPerRun) * math.Exp(rng.NormFloat64()*0.8))
+		size = min(max(size, 1), opts.Cases)
+
+		// One run in ten is unstable (e.g. a broken environment)
+		failureBoost := 1.0
+		tags := []interface{}{map[string]interface{}{"title": "nightly"}}
+		if rng.Float64() < 0.1 {
+			failureBoost = 6
+			tags = append(tags, map[string]interface{}{"title": "unstable"})
+		}
+
+		f.Runs = append(f.Runs, qase.Run{
+			ID:         runID,
+			Title:      fmt.Sprintf("Nightly %s #%d", start.Format("2006-01-02"), runID),
+			StatusText: "complete",
+			StartTime:  start,
+			EndTime:    start.Add(time.Duration(size) * 10 * time.Second),
+			Stats:      map[string]interface{}{"total": float64(size)},
+			Tags:       tags,
+		})
+
+		end := start
+		for _, idx := range rng.Perm(opts.Cases)[:size] {
+			seconds := max(1, int(8*math.Exp(rng.NormFloat64()*1.2)))
+			end = end.Add(time.Duration(seconds) * time.Second)
+
+			status := pickStatus(rng, failureBoost)
+			result := qase.Result{
+				Hash:        fmt.Sprintf("%016x%016x", rng.Uint64(), rng.Uint64()),
+				RunID:       runID,
+				CaseID:      idx + 1,
+				Status:      status,
+				Time:        &seconds,
+				TimeSpentMs: seconds * 1000,
+				EndTime:     end.Format("2006-01-02 15:04:05"),
+				EndedAt:     end,
+			}
+			result.Comment = fixtureComment(rng, status)
+			f.Results = append(f.Results, result)
+		}
+	}
+
+	return f
+}
+
+// Load adds a fixture's suites, cases, runs and results to the server. The
+// target project gets the same suites and the mirrored cases, but no runs.
+func (s *Server) Load(f *Fixture) {
+	s.AddSuites(f.SourceProject, f.Suites...)
+	s.AddSuites(f.TargetProject, f.Suites...)
+	s.AddCases(f.SourceProject, f.SourceCases...)
+	s.AddCases(f.TargetProject, f.TargetCases...)
+	s.AddCustomFields(qase.EntityCase, qase.CustomFieldDefinition{
+		ID:            f.CustomFieldID,
+		Title:         "Source Case ID",
+		ProjectsCodes: []string{f.TargetProject},
+	})
+	for _, run := range f.Runs {
+		s.AddRun(f.SourceProject, run)
+	}
+	s.AddResults(f.SourceProject, f.Results...)
+}
+
+// Summary describes a fixture's distributions in one line per aspect
+func (f *Fixture) Summary() string {
+	statuses := make(map[string]int)
+	runSizes := make(map[int]int)
+	commentBytes := 0
+	for _, result := range f.Results {
+		statuses[result.Status]++
+		runSizes[result.RunID]++
+		commentBytes += len(result.Comment)
+	}
+
+	sizes := make([]int, 0, len(runSizes))
+	for _, n := range runSizes {
+		sizes = append(sizes, n)
+	}
+	sort.Ints(sizes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Suites: %d, cases: %d, runs: %d, results: %d\n", len(f.Suites), len(f.SourceCases), len(f.Runs), len(f.Results))
+	if len(sizes) > 0 {
+		fmt.Fprintf(&b, "Results per run: min %d, median %d, max %d\n", sizes[0], sizes[len(sizes)/2], sizes[len(sizes)-1])
+	}
+	for _, w := range statusWeights {
+		fmt.Fprintf(&b, "  %-8s %d\n", w.status, statuses[w.status])
+	}
+	fmt.Fprintf(&b, "Comment bytes: %d", commentBytes)
+	return b.String()
+}
+
+// pickStatus draws a status, multiplying the non-passing weights by boost
+func pickStatus(rng *rand.Rand, boost float64) string {
+	total := 0.0
+	for _, w := range statusWeights {
+		total += weight(w.status, w.weight, boost)
+	}
+	r := rng.Float64() * total
+	for _, w := range statusWeights {
+		r -= weight(w.status, w.weight, boost)
+		if r < 0 {
+			return w.status
+		}
+	}
+	return statusWeights[0].status
+}
+
+func weight(status string, w, boost float64) float64 {
+	if status == "passed" {
+		return w
+	}
+	return w * boost
+}
+
+// fixtureComment returns an empty comment for most passes and an assertion
+// with a stack trace of log-normal length for failures
+func fixtureComment(rng *rand.Rand, status string) string {
+	switch status {
+	case "failed":
+		var b strings.Builder
+		message := fmt.Sprintf("AssertionError: expected %d but got %d", rng.IntN(500), rng.IntN(500))
+		if rng.Float64() < 0.3 {
+			message = "\x1b[31m" + message + "\x1b[0m"
+		}
+		b.WriteString(message)
+		frames := min(200, int(6*math.Exp(rng.NormFloat64())))
+		for i := 0; i < frames; i++ {
+			fmt.Fprintf(&b, "\n    at %s.%s (src/%s.js:%d:%d)",
+				pick(rng, suiteNames), pick(rng, []string{"run", "assert", "step", "call", "wait"}),
+				strings.ToLower(pick(rng, suiteNames)), rng.IntN(900)+1, rng.IntN(80)+1)
+		}
+		return b.String()
+	case "blocked", "skipped":
+		if rng.Float64() < 0.5 {
+			return "Blocked by " + pick(rng, titleSubjects) + " outage"
+		}
+	case "passed":
+		if rng.Float64() < 0.05 {
+			return "Verified manually"
+		}
+	}
+	return ""
+}
+
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.IntN(len(values))]
+}
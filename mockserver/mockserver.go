@@ -0,0 +1,743 @@
+package mockserver
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// Post is a bulk result request received by the server
+type Post struct {
+	Project string          `json:"project"`
+	RunID   int             `json:"run_id"`
+	Results []qase.BulkItem `json:"results"`
+}
+
+// Server is an in-memory stand-in for the Qase API covering the endpoints the
+// migration uses: cases, suites, runs, result lists, bulk posts and result
+// updates. It is used to simulate migrations and to test against generated
+// fixtures without touching a real workspace.
+type Server struct {
+	mu       sync.Mutex
+	projects map[string]*project
+	posts    []Post
+	nextRun  int
+	fields   map[string][]qase.CustomFieldDefinition
+
+	// Optional fixed-window rate limit (see SetRateLimit)
+	rateLimit   int
+	rateWindow  time.Duration
+	windowStart time.Time
+	windowCount int
+	throttled   int
+
+	// Optional misbehavior of list endpoints (see SetPageFault)
+	pageFault string
+
+	readOnlyTokens map[string]bool
+
+	// Custom result statuses added to the built-in ones (see AddResultStatuses)
+	statuses []qase.ResultStatus
+
+	http     *http.Server
+	listener net.Listener
+}
+
+// builtinStatuses lists the built-in result statuses
+var builtinStatuses = []qase.ResultStatus{
+	{ID: 1, Title: "Passed", Slug: "passed", IsDefault: true},
+	{ID: 2, Title: "Failed", Slug: "failed", IsDefault: true},
+	{ID: 3, Title: "Blocked", Slug: "blocked", IsDefault: true},
+	{ID: 4, Title: "Skipped", Slug: "skipped", IsDefault: true},
+	{ID: 5, Title: "Invalid", Slug: "invalid", IsDefault: true},
+}
+
+type project struct {
+	cases      map[int]qase.Case
+	suites     map[int]qase.Suite
+	runs       map[int]*qase.Run
+	results    []qase.Result
+	milestones []qase.Milestone
+}
+
+// New creates an empty server
+func New() *Server {
+	return &Server{projects: make(map[string]*project), fields: make(map[string][]qase.CustomFieldDefinition), nextRun: 1}
+}
+
+// Start serves the API on addr (e.g. "127.0.0.1:0" for a free port)
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start mock server: %w", err)
+	}
+	s.listener = listener
+	s.http = &http.Server{Handler: s}
+	go s.http.Serve(listener)
+	return nil
+}
+
+// URL returns the base URL to pass to api.NewClient
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	if s.http == nil {
+		return nil
+	}
+	return s.http.Close()
+}
+
+// AddCases adds cases to a project
+func (s *Server) AddCases(code string, cases ...qase.Case) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.project(code)
+	for _, c := range cases {
+		p.cases[c.ID] = c
+	}
+}
+
+// AddSuites adds suites to a project
+func (s *Server) AddSuites(code string, suites ...qase.Suite) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.project(code)
+	for _, suite := range suites {
+		p.suites[suite.ID] = suite
+	}
+}
+
+// AddRun adds a run to a project, assigning an ID when it has none
+func (s *Server) AddRun(code string, run qase.Run) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.ID == 0 {
+		run.ID = s.nextRun
+	}
+	if run.ID >= s.nextRun {
+		s.nextRun = run.ID + 1
+	}
+	s.project(code).runs[run.ID] = &run
+	return run.ID
+}
+
+// AddResults adds results to a project; each result's RunID selects its run
+func (s *Server) AddResults(code string, results ...qase.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.project(code)
+	p.results = append(p.results, results...)
+}
+
+// AddCustomFields adds custom field definitions of an entity type (case or
+// run) to the workspace
+func (s *Server) AddCustomFields(entity string, fields ...qase.CustomFieldDefinition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fields[entity] = append(s.fields[entity], fields...)
+}
+
+// Posts returns the bulk result requests received so far
+func (s *Server) Posts() []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Post(nil), s.posts...)
+}
+
+// Runs returns a project's runs ordered by ID
+func (s *Server) Runs(code string) []qase.Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var runs []qase.Run
+	for _, run := range s.project(code).runs {
+		runs = append(runs, *run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID < runs[j].ID })
+	return runs
+}
+
+// projectList returns the projects with their counts ordered by code; callers
+// hold s.mu
+func (s *Server) projectList() []qase.Project {
+	projects := make([]qase.Project, 0, len(s.projects))
+	for code, p := range s.projects {
+		project := qase.Project{Title: code, Code: code}
+		project.Counts.Cases = len(p.cases)
+		project.Counts.Suites = len(p.suites)
+		project.Counts.Milestones = len(p.milestones)
+		project.Counts.Runs.Total = len(p.runs)
+		for _, run := range p.runs {
+			if run.Status == 0 {
+				project.Counts.Runs.Active++
+			}
+		}
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Code < projects[j].Code })
+	return projects
+}
+
+// project returns a project, creating it on first use; callers hold s.mu
+func (s *Server) project(code string) *project {
+	p, ok := s.projects[code]
+	if !ok {
+		p = &project{
+			cases:  make(map[int]qase.Case),
+			suites: make(map[int]qase.Suite),
+			runs:   make(map[int]*qase.Run),
+		}
+		s.projects[code] = p
+	}
+	return p
+}
+
+// SetRateLimit allows limit requests per window, reporting the budget in
+// X-RateLimit-* headers and rejecting requests over it with 429
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = limit
+	s.rateWindow = window
+}
+
+// Throttled returns the number of requests rejected by the rate limit
+func (s *Server) Throttled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.throttled
+}
+
+// admit counts a request against the rate limit and sets the rate limit
+// headers, reporting false when it is over the limit
+func (s *Server) admit(w http.ResponseWriter) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rateLimit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.rateWindow {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+	reset := s.windowStart.Add(s.rateWindow)
+	resetSeconds := int(time.Until(reset).Seconds() + 0.999)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+	if s.windowCount >= s.rateLimit {
+		s.throttled++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", strconv.Itoa(resetSeconds))
+		return false
+	}
+	s.windowCount++
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.rateLimit-s.windowCount))
+	return true
+}
+
+// Pagination faults of list endpoints, to exercise client paging
+const (
+	PageShort        = "short"         // pages hold half the requested limit, as when the API caps it
+	PageIgnoreOffset = "ignore_offset" // every page repeats the first one
+	PageHTML         = "html"          // pages after the first are a gateway's HTML error page, with status 200
+	PageTruncated    = "truncated"     // pages after the first are cut off mid-body
+	PageNoTotal      = "no_total"      // pages report neither total nor filtered count
+)
+
+func (s *Server) readOnly(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readOnlyTokens[token]
+}
+
+// SetPageFault makes list endpoints page as fault (Page*), or normally for ""
+func (s *Server) SetPageFault(fault string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageFault = fault
+}
+
+// SetReadOnlyTokens makes every write request with one of tokens fail with
+// 403, as for a token without write permissions
+func (s *Server) SetReadOnlyTokens(tokens []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnlyTokens = make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		s.readOnlyTokens[token] = true
+	}
+}
+
+// AddResultStatuses adds custom result statuses to the built-in ones
+func (s *Server) AddResultStatuses(statuses ...qase.ResultStatus) {
+	for _, status := range statuses {
+		s.addResultStatus(status)
+	}
+}
+
+// addResultStatus adds a custom result status unless its slug exists,
+// returning its ID
+func (s *Server) addResultStatus(status qase.ResultStatus) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range append(append([]qase.ResultStatus(nil), builtinStatuses...), s.statuses...) {
+		if existing.Slug == status.Slug {
+			return 0, false
+		}
+	}
+	status.ID = len(builtinStatuses) + len(s.statuses) + 1
+	status.IsDefault = false
+	s.statuses = append(s.statuses, status)
+	return status.ID, true
+}
+
+// ServeHTTP routes /v1 and /v2 API requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.admit(w) {
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if r.Method != http.MethodGet && s.readOnly(r.Header.Get("Token")) {
+		writeError(w, http.StatusForbidden, "token has no write permission")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 2 && parts[1] == "system_field" && r.Method == http.MethodGet {
+		s.mu.Lock()
+		options := append(append([]qase.ResultStatus(nil), builtinStatuses...), s.statuses...)
+		s.mu.Unlock()
+		writeJSON(w, map[string]interface{}{"status": true, "result": []map[string]interface{}{{"slug": "result_status", "options": options}}})
+		return
+	}
+	if len(parts) == 3 && parts[1] == "system_field" && parts[2] == "result_status" && r.Method == http.MethodPost {
+		var status qase.ResultStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil || status.Title == "" || status.Slug == "" {
+			writeError(w, http.StatusBadRequest, "title and slug are required")
+			return
+		}
+		if id, ok := s.addResultStatus(status); ok {
+			writeJSON(w, map[string]interface{}{"status": true, "result": map[string]int{"id": id}})
+		} else {
+			writeError(w, http.StatusUnprocessableEntity, "slug already exists")
+		}
+		return
+	}
+	if len(parts) == 2 && parts[1] == "custom_field" && r.Method == http.MethodGet {
+		s.mu.Lock()
+		fields := append([]qase.CustomFieldDefinition(nil), s.fields[r.URL.Query().Get("entity")]...)
+		fault := s.pageFault
+		s.mu.Unlock()
+		writeList(w, r, fields, fault)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "project" && r.Method == http.MethodGet {
+		s.mu.Lock()
+		projects := s.projectList()
+		fault := s.pageFault
+		s.mu.Unlock()
+		writeList(w, r, projects, fault)
+		return
+	}
+	if len(parts) < 3 || (parts[0] != "v1" && parts[0] != "v2") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	resource, code, rest := parts[1], parts[2], parts[3:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Reads of a project that does not exist are not found, as in Qase;
+	// writes create it, so a blank server accepts replayed posts
+	if _, ok := s.projects[code]; !ok && r.Method == http.MethodGet {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	if resource == "project" && r.Method == http.MethodGet && len(rest) == 0 {
+		for _, project := range s.projectList() {
+			if project.Code == code {
+				writeJSON(w, map[string]interface{}{"status": true, "result": project})
+			}
+		}
+		return
+	}
+	p := s.project(code)
+
+	switch {
+	case resource == "case" && r.Method == http.MethodGet && len(rest) == 0:
+		writeList(w, r, sortedValues(p.cases, func(c qase.Case) int { return c.ID }), s.pageFault)
+	case resource == "case" && r.Method == http.MethodDelete && len(rest) == 1:
+		id, err := strconv.Atoi(rest[0])
+		if _, ok := p.cases[id]; err != nil || !ok {
+			writeError(w, http.StatusNotFound, "case not found")
+			return
+		}
+		delete(p.cases, id)
+		writeJSON(w, map[string]interface{}{"status": true, "result": map[string]int{"id": id}})
+	case resource == "suite" && r.Method == http.MethodGet && len(rest) == 0:
+		writeList(w, r, sortedValues(p.suites, func(suite qase.Suite) int { return suite.ID }), s.pageFault)
+	case resource == "run":
+		s.serveRun(w, r, p, rest)
+	case resource == "result":
+		s.serveResult(w, r, code, p, rest)
+	case resource == "milestone" && len(rest) == 0:
+		s.serveMilestone(w, r, p)
+	case resource == "attachment" && r.Method == http.MethodPost && len(rest) == 0:
+		serveAttachment(w, r, code)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// serveAttachment accepts a multipart upload and returns its content hash
+func serveAttachment(w http.ResponseWriter, r *http.Request, code string) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	writeJSON(w, map[string]interface{}{"status": true, "result": []qase.Attachment{{
+		Hash:     hash,
+		Filename: header.Filename,
+		Mime:     header.Header.Get("Content-Type"),
+		Size:     len(data),
+		URL:      fmt.Sprintf("http://%s/attachment/%s/%s/%s", r.Host, code, hash, header.Filename),
+	}}})
+}
+
+func (s *Server) serveMilestone(w http.ResponseWriter, r *http.Request, p *project) {
+	switch r.Method {
+	case http.MethodGet:
+		search := strings.ToLower(r.URL.Query().Get("search"))
+		var milestones []qase.Milestone
+		for _, milestone := range p.milestones {
+			if strings.Contains(strings.ToLower(milestone.Title), search) {
+				milestones = append(milestones, milestone)
+			}
+		}
+		writeList(w, r, milestones, s.pageFault)
+	case http.MethodPost:
+		var milestone qase.Milestone
+		if err := json.NewDecoder(r.Body).Decode(&milestone); err != nil || milestone.Title == "" {
+			writeError(w, http.StatusBadRequest, "title is required")
+			return
+		}
+		milestone.ID = len(p.milestones) + 1
+		p.milestones = append(p.milestones, milestone)
+		writeJSON(w, map[string]interface{}{"status": true, "result": map[string]int{"id": milestone.ID}})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveRun(w http.ResponseWriter, r *http.Request, p *project, rest []string) {
+	if len(rest) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			// from_start_time is a Unix timestamp
+			from, _ := strconv.ParseInt(r.URL.Query().Get("from_start_time"), 10, 64)
+			var runs []qase.Run
+			for _, run := range p.runs {
+				if run.StartTime.Unix() >= from {
+					runs = append(runs, *run)
+				}
+			}
+			sort.Slice(runs, func(i, j int) bool { return runs[i].ID < runs[j].ID })
+			writeList(w, r, runs, s.pageFault)
+		case http.MethodPost:
+			var req qase.CreateRunRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Title == "" {
+				writeError(w, http.StatusBadRequest, "title is required")
+				return
+			}
+			description := req.Description
+			run := &qase.Run{
+				ID:          s.nextRun,
+				Title:       req.Title,
+				Description: &description,
+				StatusText:  "active",
+				StartTime:   time.Now().UTC(),
+			}
+			if req.MilestoneID != 0 {
+				run.Milestone = &map[string]interface{}{"id": req.MilestoneID}
+			}
+			for id, value := range req.CustomField {
+				fieldID, _ := strconv.Atoi(id)
+				run.CustomFields = append(run.CustomFields, map[string]interface{}{"id": fieldID, "value": value})
+			}
+			s.nextRun++
+			p.runs[run.ID] = run
+			writeJSON(w, map[string]interface{}{"status": true, "result": map[string]int{"id": run.ID}})
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(rest[0])
+	run, ok := p.runs[id]
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"status": true, "result": run})
+	case http.MethodPatch:
+		var req struct {
+			Description *string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request")
+			return
+		}
+		if req.Description != nil {
+			description := *req.Description
+			run.Description = &description
+		}
+		writeJSON(w, map[string]interface{}{"status": true, "result": map[string]int{"id": run.ID}})
+	case http.MethodDelete:
+		delete(p.runs, id)
+		kept := p.results[:0]
+		for _, result := range p.results {
+			if result.RunID != id {
+				kept = append(kept, result)
+			}
+		}
+		p.results = kept
+		writeJSON(w, map[string]interface{}{"status": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveResult(w http.ResponseWriter, r *http.Request, code string, p *project, rest []string) {
+	if len(rest) == 0 && r.Method == http.MethodGet {
+		results := filterResults(p.results, r)
+		if strings.HasPrefix(r.URL.Path, "/v2/") {
+			v2 := make([]qase.ResultV2, 0, len(results))
+			for _, result := range results {
+				v2 = append(v2, qase.NewResultV2(result))
+			}
+			writeList(w, r, v2, s.pageFault)
+			return
+		}
+		writeList(w, r, results, s.pageFault)
+		return
+	}
+	if len(rest) != 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	runID, err := strconv.Atoi(rest[0])
+	if _, ok := p.runs[runID]; err != nil || !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+
+	// Bulk posts: /v1/result/{code}/{run}/bulk and /v2/result/{code}/{run}/results
+	if r.Method == http.MethodPost && (rest[1] == "bulk" || rest[1] == "results") {
+		var req qase.BulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		// Projects with cases reject results of unknown cases, as Qase does
+		if len(p.cases) > 0 {
+			var fields []map[string]string
+			for i, item := range req.Results {
+				if _, ok := p.cases[item.CaseID]; !ok {
+					field := fmt.Sprintf("results.%d.case_id", i)
+					fields = append(fields, map[string]string{"field": field, "error": fmt.Sprintf("The selected %s is invalid.", field)})
+				}
+			}
+			if len(fields) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "errorMessage": "Data is invalid.", "errorFields": fields})
+				return
+			}
+		}
+		s.posts = append(s.posts, Post{Project: code, RunID: runID, Results: req.Results})
+
+		bulk := make([]map[string]interface{}, 0, len(req.Results))
+		for _, item := range req.Results {
+			result := qase.Result{
+				Hash:    newHash(code, runID, len(p.results)),
+				Comment: item.Comment,
+				RunID:   runID,
+				CaseID:  item.CaseID,
+				Status:  item.Status,
+				Time:    item.Time,
+				EndTime: time.Now().UTC().Format("2006-01-02 15:04:05"),
+			}
+			p.results = append(p.results, result)
+			bulk = append(bulk, map[string]interface{}{"id": len(p.results), "status": true})
+		}
+		writeJSON(w, map[string]interface{}{"status": true, "result": map[string]interface{}{"bulk": bulk}})
+		return
+	}
+
+	// Single result updates: /v1/result/{code}/{run}/{hash}
+	for i, result := range p.results {
+		if result.RunID != runID || result.Hash != rest[1] {
+			continue
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			var update map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			if status, ok := update["status"].(string); ok {
+				p.results[i].Status = status
+			}
+			if comment, ok := update["comment"].(string); ok {
+				p.results[i].Comment = comment
+			}
+		case http.MethodDelete:
+			p.results = append(p.results[:i], p.results[i+1:]...)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"status": true, "result": map[string]string{"hash": rest[1]}})
+		return
+	}
+	writeError(w, http.StatusNotFound, "result not found")
+}
+
+// filterResults applies the run_id[] and from_end_time filters of the result list API
+func filterResults(results []qase.Result, r *http.Request) []qase.Result {
+	query := r.URL.Query()
+	runs := make(map[int]bool)
+	for _, v := range query["run_id[]"] {
+		if id, err := strconv.Atoi(v); err == nil {
+			runs[id] = true
+		}
+	}
+	var from time.Time
+	if v := query.Get("from_end_time"); v != "" {
+		from, _ = time.Parse("2006-01-02 15:04:05", v)
+	}
+
+	var filtered []qase.Result
+	for _, result := range results {
+		if len(runs) > 0 && !runs[result.RunID] {
+			continue
+		}
+		if !from.IsZero() {
+			ended, err := time.Parse("2006-01-02 15:04:05", result.EndTime)
+			if err != nil {
+				ended, err = time.Parse(time.RFC3339, result.EndTime)
+			}
+			if err != nil || ended.Before(from) {
+				continue
+			}
+		}
+		filtered = append(filtered, result)
+	}
+	return filtered
+}
+
+// writeList writes one page of entities, honoring limit and offset
+func writeList[T any](w http.ResponseWriter, r *http.Request, entities []T, fault string) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	switch fault {
+	case PageShort:
+		limit = max(1, limit/2)
+	case PageIgnoreOffset:
+		offset = 0
+	}
+
+	start := min(max(offset, 0), len(entities))
+	end := min(start+limit, len(entities))
+	page := entities[start:end]
+	if page == nil {
+		page = []T{}
+	}
+
+	response := map[string]interface{}{
+		"status": true,
+		"result": map[string]interface{}{
+			"total":    len(entities),
+			"filtered": len(entities),
+			"count":    len(page),
+			"entities": page,
+		},
+	}
+	switch {
+	case fault == PageHTML && start > 0:
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>502 Bad Gateway</title></head><body><h1>502 Bad Gateway</h1></body></html>")
+	case fault == PageTruncated && start > 0:
+		body, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body[:len(body)/2])
+	case fault == PageNoTotal:
+		result := response["result"].(map[string]interface{})
+		delete(result, "total")
+		delete(result, "filtered")
+		writeJSON(w, response)
+	default:
+		writeJSON(w, response)
+	}
+}
+
+func sortedValues[T any](m map[int]T, id func(T) int) []T {
+	values := make([]T, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return id(values[i]) < id(values[j]) })
+	return values
+}
+
+func newHash(code string, runID, n int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s/%d/%d", code, runID, n)))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "errorMessage": message})
+}
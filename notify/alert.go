@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default alerting endpoints; Opsgenie's EU instance is api.eu.opsgenie.com
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieURL  = "https://api.opsgenie.com"
+)
+
+// AlertConfig holds the incident alerting settings for failed migrations
+type AlertConfig struct {
+	PagerDutyKey string  // Events API v2 routing key
+	PagerDutyURL string  // events endpoint
+	OpsgenieKey  string  // API integration key
+	OpsgenieURL  string  // API base URL
+	ErrorRate    float64 // share of failed runs that alerts (0 alerts only on failed migrations)
+}
+
+// LoadAlertConfig reads alerting settings from environment variables
+func LoadAlertConfig() (AlertConfig, error) {
+	cfg := AlertConfig{
+		PagerDutyKey: os.Getenv("QASE_PAGERDUTY_ROUTING_KEY"),
+		PagerDutyURL: os.Getenv("QASE_PAGERDUTY_URL"),
+		OpsgenieKey:  os.Getenv("QASE_OPSGENIE_API_KEY"),
+		OpsgenieURL:  strings.TrimRight(os.Getenv("QASE_OPSGENIE_URL"), "/"),
+	}
+	if cfg.PagerDutyURL == "" {
+		cfg.PagerDutyURL = defaultPagerDutyURL
+	}
+	if cfg.OpsgenieURL == "" {
+		cfg.OpsgenieURL = defaultOpsgenieURL
+	}
+	if value := os.Getenv("QASE_ALERT_ERROR_RATE"); value != "" {
+		rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || rate <= 0 || rate > 100 {
+			return cfg, fmt.Errorf("invalid QASE_ALERT_ERROR_RATE: %s (use a percentage of failed runs such as 20)", value)
+		}
+		cfg.ErrorRate = rate / 100
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether an alerting integration is configured
+func (c AlertConfig) Enabled() bool {
+	return c.PagerDutyKey != "" || c.OpsgenieKey != ""
+}
+
+// Alerter raises and resolves incidents for project pairs. It remembers the
+// alerts it raised so a later healthy migration resolves them. A nil Alerter
+// is disabled; all methods are safe to call on it.
+type Alerter struct {
+	cfg    AlertConfig
+	client *http.Client
+
+	mu   sync.Mutex
+	open map[string]bool
+}
+
+// NewAlerter returns an Alerter, or nil when no integration is configured
+func NewAlerter(cfg AlertConfig) *Alerter {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Alerter{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}, open: make(map[string]bool)}
+}
+
+// Report triggers an alert for a failed migration of a project pair, or one
+// where the share of failed runs reached the threshold, and resolves the
+// pair's alert once a migration is healthy again
+func (a *Alerter) Report(source, target string, err error, runs, failed int) {
+	if a == nil {
+		return
+	}
+	key := fmt.Sprintf("clone-run-multi-ws:%s:%s", source, target)
+
+	var problem string
+	switch {
+	case err != nil:
+		problem = fmt.Sprintf("Migration %s -> %s failed: %v", source, target, err)
+	case a.cfg.ErrorRate > 0 && runs > 0 && float64(failed)/float64(runs) >= a.cfg.ErrorRate:
+		problem = fmt.Sprintf("Migration %s -> %s failed %d of %d runs (alert threshold %g%%)", source, target, failed, runs, a.cfg.ErrorRate*100)
+	}
+
+	if problem == "" {
+		a.mu.Lock()
+		open := a.open[key]
+		delete(a.open, key)
+		a.mu.Unlock()
+		if open {
+			a.send(key, "", map[string]string{"source_project": source, "target_project": target})
+		}
+		return
+	}
+
+	details := map[string]string{
+		"source_project": source,
+		"target_project": target,
+		"runs":           strconv.Itoa(runs),
+		"failed_runs":    strconv.Itoa(failed),
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	a.mu.Lock()
+	a.open[key] = true
+	a.mu.Unlock()
+	a.send(key, problem, details)
+}
+
+// send triggers (with a summary) or resolves (without one) the alert in
+// every configured integration; failures are logged, not returned, so
+// alerting never changes the outcome of a migration
+func (a *Alerter) send(key, summary string, details map[string]string) {
+	action := "Resolved"
+	if summary != "" {
+		action = "Triggered"
+	}
+	if a.cfg.PagerDutyKey != "" {
+		if err := a.pagerDuty(key, summary, details); err != nil {
+			fmt.Printf("Warning: Failed to send PagerDuty event: %v\n", err)
+		} else {
+			fmt.Printf("%s PagerDuty alert %s\n", action, key)
+		}
+	}
+	if a.cfg.OpsgenieKey != "" {
+		if err := a.opsgenie(key, summary, details); err != nil {
+			fmt.Printf("Warning: Failed to send Opsgenie alert: %v\n", err)
+		} else {
+			fmt.Printf("%s Opsgenie alert %s\n", action, key)
+		}
+	}
+}
+
+// pagerDutyEvent is an Events API v2 event
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func (a *Alerter) pagerDuty(key, summary string, details map[string]string) error {
+	event := pagerDutyEvent{RoutingKey: a.cfg.PagerDutyKey, EventAction: "resolve", DedupKey: key}
+	if summary != "" {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{Summary: summary, Source: "clone-run-multi-ws", Severity: "critical", CustomDetails: details}
+	}
+	return a.post(a.cfg.PagerDutyURL, "", event)
+}
+
+// opsgenieAlert is an Opsgenie alert creation request
+type opsgenieAlert struct {
+	Message  string            `json:"message"`
+	Alias    string            `json:"alias"`
+	Priority string            `json:"priority"`
+	Source   string            `json:"source"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+func (a *Alerter) opsgenie(key, summary string, details map[string]string) error {
+	auth := "GenieKey " + a.cfg.OpsgenieKey
+	if summary == "" {
+		closeURL := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", a.cfg.OpsgenieURL, url.PathEscape(key))
+		return a.post(closeURL, auth, map[string]string{"source": "clone-run-multi-ws"})
+	}
+	// Opsgenie limits messages to 130 characters
+	message := summary
+	if len(message) > 130 {
+		message = message[:127] + "..."
+	}
+	alert := opsgenieAlert{Message: message, Alias: key, Priority: "P1", Source: "clone-run-multi-ws", Details: details}
+	return a.post(a.cfg.OpsgenieURL+"/v2/alerts", auth, alert)
+}
+
+func (a *Alerter) post(endpoint, auth string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
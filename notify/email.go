@@ -0,0 +1,259 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// EmailConfig holds the SMTP settings used to email the migration summary
+type EmailConfig struct {
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	From        string
+	To          []string
+	Attachments []string // files attached next to the HTML report
+}
+
+// LoadEmailConfig reads SMTP settings from environment variables
+func LoadEmailConfig() EmailConfig {
+	cfg := EmailConfig{
+		Host:     os.Getenv("QASE_SMTP_HOST"),
+		Port:     os.Getenv("QASE_SMTP_PORT"),
+		User:     os.Getenv("QASE_SMTP_USER"),
+		Password: os.Getenv("QASE_SMTP_PASSWORD"),
+		From:     os.Getenv("QASE_SMTP_FROM"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.User
+	}
+	cfg.To = splitList(os.Getenv("QASE_SMTP_TO"))
+	cfg.Attachments = splitList(os.Getenv("QASE_SMTP_ATTACH"))
+	return cfg
+}
+
+// Enabled reports whether enough settings are present to send an email
+func (c EmailConfig) Enabled() bool {
+	return c.Host != "" && c.From != "" && len(c.To) > 0
+}
+
+// SendEmail emails the migration summary with an HTML report attached. Port
+// 465 uses implicit TLS; other ports upgrade with STARTTLS when offered.
+func SendEmail(cfg EmailConfig, summary Summary) error {
+	if !cfg.Enabled() {
+		return fmt.Errorf("email is not configured")
+	}
+
+	message, err := emailMessage(cfg, summary)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)
+	}
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	if cfg.Port == "465" {
+		err = sendImplicitTLS(addr, cfg.Host, auth, cfg.From, cfg.To, message)
+	} else {
+		err = smtp.SendMail(addr, auth, cfg.From, cfg.To, message)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", addr, err)
+	}
+
+	fmt.Printf("Emailed migration summary to %s\n", strings.Join(cfg.To, ", "))
+	return nil
+}
+
+func sendImplicitTLS(addr, host string, auth smtp.Auth, from string, to []string, message []byte) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailMessage builds a multipart message with the plain-text summary, the
+// HTML report and the configured attachments
+func emailMessage(cfg EmailConfig, summary Summary) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writePart(writer, "text/plain; charset=utf-8", "", []byte(summary.Text())); err != nil {
+		return nil, err
+	}
+
+	report, err := summary.HTML()
+	if err != nil {
+		return nil, err
+	}
+	if err := writePart(writer, "text/html; charset=utf-8", "migration-report.html", report); err != nil {
+		return nil, err
+	}
+
+	for _, path := range cfg.Attachments {
+		// Attachments are artifacts, possibly in a bucket and encrypted
+		data, err := artifact.Read(path)
+		if err != nil {
+			// Reports are only written when there is something to report
+			if errors.Is(err, artifact.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read attachment: %w", err)
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if err := writePart(writer, contentType, filepath.Base(path), data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", summary.Subject()))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// writePart adds a base64-encoded part, as an attachment when named
+func writePart(writer *multipart.Writer, contentType, filename string, data []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	if filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+
+	// Lines of at most 76 characters, as MIME requires
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	_, err = fmt.Fprintf(part, "%s\r\n", encoded)
+	return err
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: sans-serif">
+<h2>{{.Subject}}</h2>
+<table cellpadding="4" style="border-collapse: collapse">
+<tr><td>Source project</td><td>{{.SourceProject}}</td></tr>
+<tr><td>Target project</td><td>{{.TargetProject}}</td></tr>
+{{if .Error}}<tr><td>Error</td><td style="color: #c00">{{.Error}}</td></tr>{{end}}
+<tr><td>Total runs with results</td><td>{{.TotalRuns}}</td></tr>
+<tr><td>Successful migrations</td><td>{{.SuccessfulRuns}}</td></tr>
+<tr><td>Failed migrations</td><td{{if .FailedRuns}} style="color: #c00"{{end}}>{{.FailedRuns}}</td></tr>
+<tr><td>Total results migrated</td><td>{{.TotalResults}}</td></tr>
+<tr><td>Total results skipped</td><td>{{.TotalSkipped}}</td></tr>
+<tr><td>Total execution time</td><td>{{.Elapsed}}</td></tr>
+{{if .ReportURL}}<tr><td>Report</td><td><a href="{{.ReportURL}}">{{.ReportURL}}</a></td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// Subject is a one-line description of the migration and its outcome
+func (s Summary) Subject() string {
+	mode := "Migration"
+	if s.DryRun {
+		mode = "Dry run migration"
+	}
+	outcome := "completed"
+	switch {
+	case s.Error != "":
+		outcome = "failed"
+	case s.FailedRuns > 0:
+		outcome = fmt.Sprintf("completed with %d failed runs", s.FailedRuns)
+	}
+	return fmt.Sprintf("%s %s -> %s %s", mode, s.SourceProject, s.TargetProject, outcome)
+}
+
+// HTML renders the summary as a standalone HTML report
+func (s Summary) HTML() ([]byte, error) {
+	var b bytes.Buffer
+	data := struct {
+		Summary
+		Elapsed time.Duration
+	}{s, s.Duration.Round(time.Second)}
+	if err := reportTemplate.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
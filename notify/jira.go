@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JiraConfig holds the Jira settings used to comment on the migration ticket
+type JiraConfig struct {
+	BaseURL  string
+	User     string
+	Token    string
+	IssueKey string
+}
+
+// LoadJiraConfig reads Jira settings from environment variables
+func LoadJiraConfig() JiraConfig {
+	return JiraConfig{
+		BaseURL:  strings.TrimRight(os.Getenv("QASE_JIRA_BASE_URL"), "/"),
+		User:     os.Getenv("QASE_JIRA_USER"),
+		Token:    os.Getenv("QASE_JIRA_API_TOKEN"),
+		IssueKey: os.Getenv("QASE_JIRA_ISSUE"),
+	}
+}
+
+// Enabled reports whether enough settings are present to post a comment
+func (c JiraConfig) Enabled() bool {
+	return c.BaseURL != "" && c.Token != "" && c.IssueKey != ""
+}
+
+// jiraCommentRequest represents the Jira REST v2 comment payload
+type jiraCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// PostJiraComment posts the migration summary as a comment on the configured issue
+func PostJiraComment(cfg JiraConfig, summary Summary) error {
+	if !cfg.Enabled() {
+		return fmt.Errorf("jira integration is not configured")
+	}
+
+	body, err := json.Marshal(jiraCommentRequest{Body: summary.Text()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal comment: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/comment", cfg.BaseURL, cfg.IssueKey)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Jira Cloud uses email + API token basic auth, Server/DC accepts a bearer PAT
+	if cfg.User != "" {
+		req.SetBasicAuth(cfg.User, cfg.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("jira request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	fmt.Printf("Posted migration summary to Jira issue %s\n", cfg.IssueKey)
+	return nil
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Summary holds the outcome and totals of a migration for external
+// notifications
+type Summary struct {
+	SourceProject  string
+	TargetProject  string
+	TotalRuns      int
+	SuccessfulRuns int
+	FailedRuns     int
+	TotalResults   int
+	TotalSkipped   int
+	Duration       time.Duration
+	DryRun         bool
+	ReportURL      string
+	Error          string // why the migration failed or stopped early; empty when it completed
+}
+
+// Text renders the summary as plain text suitable for comments and messages
+func (s Summary) Text() string {
+	var b strings.Builder
+
+	mode := "Migration"
+	if s.DryRun {
+		mode = "Dry run migration"
+	}
+
+	if s.Error != "" {
+		fmt.Fprintf(&b, "%s from %s to %s failed: %s\n\n", mode, s.SourceProject, s.TargetProject, s.Error)
+	} else {
+		fmt.Fprintf(&b, "%s from %s to %s completed.\n\n", mode, s.SourceProject, s.TargetProject)
+	}
+	fmt.Fprintf(&b, "Total runs with results: %d\n", s.TotalRuns)
+	fmt.Fprintf(&b, "Successful migrations: %d\n", s.SuccessfulRuns)
+	fmt.Fprintf(&b, "Failed migrations: %d\n", s.FailedRuns)
+	fmt.Fprintf(&b, "Total results migrated: %d\n", s.TotalResults)
+	fmt.Fprintf(&b, "Total results skipped: %d\n", s.TotalSkipped)
+	fmt.Fprintf(&b, "Total execution time: %v\n", s.Duration.Round(time.Second))
+
+	if s.ReportURL != "" {
+		fmt.Fprintf(&b, "\nReport: %s\n", s.ReportURL)
+	}
+
+	return b.String()
+}
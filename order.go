@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Run processing orders for QASE_RUN_ORDER
+const (
+	OrderID      = "id"       // by source run ID (or bucket date)
+	OrderEndTime = "end_time" // by the earliest result end time, undated runs last
+)
+
+// Directions for QASE_RUN_ORDER_DIRECTION
+const (
+	OldestFirst = "oldest_first" // chronological backfill
+	NewestFirst = "newest_first" // recent results usable in the target first
+)
+
+// orderGroups sorts groups into processing order, oldest or newest first.
+// Runs are dispatched to the workers in this order, so logs and checkpoints
+// of repeated migrations line up. Ties are broken by ID in the same
+// direction; undated runs always come last.
+func orderGroups(groups []runGroup, order, direction string) {
+	newest := direction == NewestFirst
+	byID := func(i, j int) bool {
+		if newest {
+			return groups[i].id > groups[j].id
+		}
+		return groups[i].id < groups[j].id
+	}
+
+	switch order {
+	case OrderEndTime:
+		ended := make(map[string]time.Time, len(groups))
+		for _, group := range groups {
+			ended[group.key] = group.endedAt()
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			a, b := ended[groups[i].key], ended[groups[j].key]
+			if a.Equal(b) {
+				return byID(i, j)
+			}
+			if a.IsZero() || b.IsZero() {
+				return b.IsZero()
+			}
+			return a.Before(b) != newest
+		})
+	default:
+		// Undated buckets have ID 0
+		sort.SliceStable(groups, func(i, j int) bool {
+			if (groups[i].id == 0) != (groups[j].id == 0) {
+				return groups[j].id == 0
+			}
+			return byID(i, j)
+		})
+	}
+}
+
+// endedAt returns the earliest end time of a group's results, or zero when
+// none has one
+func (g runGroup) endedAt() time.Time {
+	var earliest time.Time
+	for _, result := range g.results {
+		if !result.EndedAt.IsZero() && (earliest.IsZero() || result.EndedAt.Before(earliest)) {
+			earliest = result.EndedAt
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// checkPermissions probes what the source and target tokens may do, prints
+// the result as a matrix and fails when the migration could not complete,
+// instead of failing on the first write an hour in. Outcomes the probe
+// cannot classify are warnings.
+func checkPermissions(config *Config, srcClient, tgtClient *api.Client) error {
+	// A post-only migration has no source token; its source reads came from the bundle
+	src := &qase.Permissions{Project: qase.PermissionSkipped, ReadCases: qase.PermissionSkipped, ReadResults: qase.PermissionSkipped, CreateRuns: qase.PermissionSkipped, PostResults: qase.PermissionSkipped, UploadAttachments: qase.PermissionSkipped, CreateStatuses: qase.PermissionSkipped}
+	if srcClient != nil {
+		src = qase.ProbePermissions(srcClient, config.SourceProject, false, false)
+	}
+	tgt := qase.ProbePermissions(tgtClient, config.TargetProject, !config.readsOnly(), config.CreateStatuses)
+
+	fmt.Printf("Token permissions:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  PERMISSION\tSOURCE (%s)\tTARGET (%s)\n", config.SourceProject, config.TargetProject)
+	srcMatrix, tgtMatrix := src.Matrix(), tgt.Matrix()
+	for i := range srcMatrix {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", srcMatrix[i][0], srcMatrix[i][1], tgtMatrix[i][1])
+	}
+	w.Flush()
+	for _, entry := range srcMatrix {
+		if detail, exists := src.Details[entry[0]]; exists {
+			fmt.Printf("  source %s: %s\n", entry[0], detail)
+		}
+	}
+	for _, entry := range tgtMatrix {
+		if detail, exists := tgt.Details[entry[0]]; exists {
+			fmt.Printf("  target %s: %s\n", entry[0], detail)
+		}
+	}
+
+	var missing []string
+	require := func(side, name, outcome string) {
+		switch outcome {
+		case qase.PermissionDenied:
+			missing = append(missing, side+" "+name)
+		case qase.PermissionUnknown:
+			fmt.Printf("Warning: could not determine whether the %s token may %s\n", side, name)
+		}
+	}
+	require("source", "access project", src.Project)
+	require("source", "read cases", src.ReadCases)
+	require("source", "read results", src.ReadResults)
+	require("target", "access project", tgt.Project)
+	require("target", "read cases", tgt.ReadCases)
+	if !config.readsOnly() {
+		require("target", "create runs", tgt.CreateRuns)
+		require("target", "post results", tgt.PostResults)
+		if config.RawAttachments != qase.RawAttachNone {
+			require("target", "upload attachments", tgt.UploadAttachments)
+		}
+		if config.CreateStatuses {
+			require("target", "create statuses", tgt.CreateStatuses)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("tokens lack permissions needed for the migration: %s (use tokens with these permissions, or QASE_DRY_RUN=true to only read)", strings.Join(missing, ", "))
+	}
+	return nil
+}
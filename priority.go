@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// priority selects source runs migrated before all others, by ID or by run
+// tag
+type priority struct {
+	runIDs map[int]bool
+	tags   []string
+}
+
+// parsePriority parses QASE_PRIORITY_RUNS (comma-separated run IDs) and
+// QASE_PRIORITY_TAGS (comma-separated run tag titles)
+func parsePriority(runIDs, tags string) (priority, error) {
+	var p priority
+	ids, err := qase.ParseRunIDs(runIDs)
+	if err != nil {
+		return p, fmt.Errorf("invalid QASE_PRIORITY_RUNS: %w", err)
+	}
+	p.runIDs = make(map[int]bool, len(ids))
+	for _, id := range ids {
+		p.runIDs[id] = true
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			p.tags = append(p.tags, tag)
+		}
+	}
+	return p, nil
+}
+
+func (p priority) enabled() bool {
+	return len(p.runIDs) > 0 || len(p.tags) > 0
+}
+
+// resolveTags returns the priority with the source runs started after the
+// date that carry a priority tag (case-insensitive) added to its run IDs,
+// and how many tagged runs were found
+func (p priority) resolveTags(src source, project string, after time.Time) (priority, int, error) {
+	if len(p.tags) == 0 {
+		return p, 0, nil
+	}
+	runs, err := src.runs(project, after)
+	if err != nil {
+		return p, 0, fmt.Errorf("failed to list source runs for QASE_PRIORITY_TAGS: %w", err)
+	}
+	resolved := priority{runIDs: make(map[int]bool, len(p.runIDs)), tags: p.tags}
+	for id := range p.runIDs {
+		resolved.runIDs[id] = true
+	}
+	found := 0
+	for _, run := range runs {
+		if p.tagged(run) {
+			resolved.runIDs[run.ID] = true
+			found++
+		}
+	}
+	return resolved, found, nil
+}
+
+func (p priority) tagged(run qase.Run) bool {
+	for _, title := range run.TagTitles() {
+		for _, tag := range p.tags {
+			if strings.EqualFold(title, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// first moves the groups with results of a priority run to the front,
+// keeping the processing order within both lanes, and returns how many
+// were moved
+func (p priority) first(groups []runGroup) int {
+	prioritized := make([]runGroup, 0, len(groups))
+	var rest []runGroup
+	for _, group := range groups {
+		if p.includes(group) {
+			prioritized = append(prioritized, group)
+		} else {
+			rest = append(rest, group)
+		}
+	}
+	moved := len(prioritized)
+	copy(groups, append(prioritized, rest...))
+	return moved
+}
+
+func (p priority) includes(group runGroup) bool {
+	for _, result := range group.results {
+		if p.runIDs[result.RunID] {
+			return true
+		}
+	}
+	return false
+}
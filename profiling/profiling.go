@@ -0,0 +1,39 @@
+package profiling
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// Start serves the net/http/pprof endpoints under /debug/pprof/ on addr, so
+// heap, goroutine and CPU profiles of a long migration can be taken without
+// rebuilding. It does nothing when addr is empty.
+//
+// Profiles expose internals of the process, so bind to a local address
+// (e.g. 127.0.0.1:6060) unless the port is otherwise protected.
+func Start(addr string) {
+	if addr == "" {
+		return
+	}
+
+	// Blocking and mutex contention are only sampled when enabled
+	runtime.SetBlockProfileRate(int(1e6))
+	runtime.SetMutexProfileFraction(100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Printf("Warning: Profiling server stopped: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Profiling endpoints listening on %s (/debug/pprof/)\n", addr)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// progressWindow is how far back the current rates look, so they follow
+// changes in concurrency and rate limits instead of the overall average
+const progressWindow = time.Minute
+
+// progress tracks migration throughput and estimates the time left from the
+// source results still to process. It is used by the collector only.
+type progress struct {
+	started      time.Time
+	runsTotal    int
+	resultsTotal int
+	runs         int
+	results      int
+	samples      []progressSample // completions within the window, oldest first
+}
+
+type progressSample struct {
+	at      time.Time
+	runs    int
+	results int
+}
+
+func newProgress(groups []runGroup) *progress {
+	p := &progress{started: time.Now(), runsTotal: len(groups)}
+	for _, group := range groups {
+		p.resultsTotal += len(group.results)
+	}
+	p.samples = []progressSample{{at: p.started}}
+	return p
+}
+
+// add records a finished run with its number of source results
+func (p *progress) add(results int) {
+	now := time.Now()
+	p.runs++
+	p.results += results
+	p.samples = append(p.samples, progressSample{at: now, runs: p.runs, results: p.results})
+
+	// Keep the newest sample older than the window as the base of the rates
+	cutoff := now.Add(-progressWindow)
+	for len(p.samples) > 2 && p.samples[1].at.Before(cutoff) {
+		p.samples = p.samples[1:]
+	}
+}
+
+// rates returns the current source results per second and runs per minute
+func (p *progress) rates() (resultsPerSecond, runsPerMinute float64) {
+	base, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := last.at.Sub(base.at).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	return float64(last.results-base.results) / elapsed, float64(last.runs-base.runs) / elapsed * 60
+}
+
+// eta returns the estimated time until every run is processed, or false
+// while there is no rate to estimate from
+func (p *progress) eta() (time.Duration, bool) {
+	resultsPerSecond, runsPerMinute := p.rates()
+	switch {
+	case p.runs >= p.runsTotal:
+		return 0, true
+	case resultsPerSecond > 0:
+		return time.Duration(float64(p.resultsTotal-p.results)/resultsPerSecond) * time.Second, true
+	case runsPerMinute > 0:
+		return time.Duration(float64(p.runsTotal-p.runs)/runsPerMinute*60) * time.Second, true
+	}
+	return 0, false
+}
+
+// String formats the current rates and ETA, e.g.
+// "152.3 results/s, 41.0 runs/min, ETA 3m20s"
+func (p *progress) String() string {
+	resultsPerSecond, runsPerMinute := p.rates()
+	eta := "unknown"
+	if d, ok := p.eta(); ok {
+		eta = d.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%.1f results/s, %.1f runs/min, ETA %s", resultsPerSecond, runsPerMinute, eta)
+}
+
+// overall formats the average rates since the migration phase started
+func (p *progress) overall() string {
+	elapsed := time.Since(p.started).Seconds()
+	if elapsed <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f results/s, %.1f runs/min", float64(p.results)/elapsed, float64(p.runs)/elapsed*60)
+}
+
+// phaseTimer records how long each migration phase took
+type phaseTimer struct {
+	phases    []string
+	durations map[string]time.Duration
+	current   string
+	since     time.Time
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{durations: make(map[string]time.Duration)}
+}
+
+// start ends the current phase and starts the named one
+func (t *phaseTimer) start(phase string) {
+	now := time.Now()
+	if t.current != "" {
+		t.durations[t.current] += now.Sub(t.since)
+	}
+	if _, seen := t.durations[phase]; !seen {
+		t.phases = append(t.phases, phase)
+	}
+	t.current, t.since = phase, now
+}
+
+// String lists the phase durations in order, the current phase up to now
+func (t *phaseTimer) String() string {
+	parts := make([]string, len(t.phases))
+	for i, phase := range t.phases {
+		d := t.durations[phase]
+		if phase == t.current {
+			d += time.Since(t.since)
+		}
+		parts[i] = fmt.Sprintf("%s %v", strings.ReplaceAll(phase, "_", " "), d.Round(time.Millisecond))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,114 @@
+package qase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Raw source result attachment modes for QASE_RAW_ATTACHMENTS
+const (
+	RawAttachNone   = ""       // no raw JSON attachments
+	RawAttachResult = "result" // one JSON file per migrated result
+	RawAttachRun    = "run"    // one JSON file per run, linked from its description
+)
+
+// AttachmentUploadResponse represents the API response for attachment uploads
+type AttachmentUploadResponse struct {
+	Status bool         `json:"status"`
+	Result []Attachment `json:"result"`
+}
+
+// UploadAttachment uploads a file to a project and returns the stored attachment
+func UploadAttachment(c *api.Client, project, filename string, data []byte) (*Attachment, error) {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write form file: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close form: %w", err)
+	}
+
+	req, err := c.NewRequest("POST", fmt.Sprintf("/attachment/%s", project), buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response AttachmentUploadResponse
+	if err := decodeJSON(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !response.Status || len(response.Result) == 0 {
+		return nil, fmt.Errorf("attachment upload failed: %s", string(body))
+	}
+	return &response.Result[0], nil
+}
+
+// AttachRawResults uploads the source result behind each bulk item as JSON
+// and attaches it to the item. Items are matched to their source result by the
+// hash marker in their comment; items without one are left as they are.
+func AttachRawResults(c *api.Client, project string, items []BulkItem, results []Result) error {
+	byHash := make(map[string]Result, len(results))
+	for _, result := range results {
+		if result.Hash != "" {
+			byHash[result.Hash] = result
+		}
+	}
+
+	for i := range items {
+		hash := SourceHash(items[i].Comment)
+		result, ok := byHash[hash]
+		if !ok {
+			continue
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal source result %s: %w", hash, err)
+		}
+		attachment, err := UploadAttachment(c, project, fmt.Sprintf("source-result-%s.json", hash), data)
+		if err != nil {
+			return fmt.Errorf("failed to upload source result %s: %w", hash, err)
+		}
+		items[i].Attachments = append(items[i].Attachments, attachment.Hash)
+	}
+	return nil
+}
+
+// UploadRawRun uploads a run's source results as one JSON file
+func UploadRawRun(c *api.Client, project, name string, results []Result) (*Attachment, error) {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal source results: %w", err)
+	}
+	attachment, err := UploadAttachment(c, project, fmt.Sprintf("source-results-%s.json", name), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload source results: %w", err)
+	}
+	return attachment, nil
+}
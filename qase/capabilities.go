@@ -0,0 +1,52 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// DetectCapabilities probes the workspace for optional API features and
+// records them on the client, so later calls pick the right endpoint instead
+// of falling back after a failure. Probes are single-item reads in project.
+func DetectCapabilities(c *api.Client, project string) *api.Capabilities {
+	caps := &api.Capabilities{}
+
+	req, err := c.NewV2Request("GET", fmt.Sprintf("/result/%s?limit=1", project), nil)
+	if err == nil {
+		caps.ResultsV2 = probe(c, req)
+	}
+
+	if _, err := GetResultStatuses(c); err == nil {
+		caps.CustomStatuses = true
+	}
+
+	req, err = c.NewRequest("GET", fmt.Sprintf("/run/%s?limit=1&include=external_issue", project), nil)
+	if err == nil {
+		caps.ExternalIssues = probe(c, req)
+	}
+
+	c.Capabilities = caps
+	return caps
+}
+
+// probe reports whether req succeeds with a status true response
+func probe(c *api.Client, req *http.Request) bool {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	var response struct {
+		Status bool `json:"status"`
+	}
+	return decodeJSON(body, &response) == nil && response.Status
+}
@@ -5,15 +5,94 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
 )
 
-// Case represents a Qase test case
+// Case represents a Qase test case. Steps, Params and Tags are only filled
+// when requested with CaseListOptions.
 type Case struct {
-	ID           int           `json:"id"`
-	Title        string        `json:"title"`
-	CustomFields []CustomField `json:"custom_fields"`
+	ID           int                 `json:"id"`
+	Title        string              `json:"title"`
+	SuiteID      *int                `json:"suite_id"`
+	CustomFields []CustomField       `json:"custom_fields"`
+	Steps        []CaseStep          `json:"steps,omitempty"`
+	Params       map[string][]string `json:"params,omitempty"` // parameter -> values
+	Tags         []CaseTag           `json:"tags,omitempty"`
+}
+
+// CaseStep is a step of a case, possibly with nested steps
+type CaseStep struct {
+	Position       int        `json:"position"`
+	Action         string     `json:"action"`
+	ExpectedResult string     `json:"expected_result,omitempty"`
+	Data           string     `json:"data,omitempty"`
+	Steps          []CaseStep `json:"steps,omitempty"`
+}
+
+// CaseTag is a tag of a case
+type CaseTag struct {
+	Title      string `json:"title"`
+	InternalID int    `json:"internal_id,omitempty"`
+}
+
+// CaseListOptions selects the case details GetCases requests (include=) and
+// keeps. Mapping only needs IDs, titles and custom fields, so by default the
+// rest is neither requested nor decoded, keeping large projects small in
+// memory.
+type CaseListOptions struct {
+	IncludeSteps  bool
+	IncludeParams bool
+	IncludeTags   bool
+}
+
+// include returns the include= value requesting the selected details, empty
+// when none are selected
+func (o CaseListOptions) include() string {
+	var details []string
+	if o.IncludeSteps {
+		details = append(details, "steps")
+	}
+	if o.IncludeParams {
+		details = append(details, "params")
+	}
+	if o.IncludeTags {
+		details = append(details, "tags")
+	}
+	return strings.Join(details, ",")
+}
+
+// caseEntity decodes a listed case, holding the optional details undecoded
+// until they are known to be wanted
+type caseEntity struct {
+	Case
+	Steps  json.RawMessage `json:"steps"`
+	Params json.RawMessage `json:"params"`
+	Tags   json.RawMessage `json:"tags"`
+}
+
+// decode returns the case with the details selected by opts
+func (e caseEntity) decode(opts CaseListOptions) (Case, error) {
+	c := e.Case
+	if opts.IncludeSteps && len(e.Steps) > 0 && string(e.Steps) != "null" {
+		if err := decodeJSON(e.Steps, &c.Steps); err != nil {
+			return c, fmt.Errorf("failed to parse steps of case %d: %w", c.ID, err)
+		}
+	}
+	// Cases without parameters carry an empty array instead of an object
+	if opts.IncludeParams && len(e.Params) > 0 && e.Params[0] == '{' {
+		if err := decodeJSON(e.Params, &c.Params); err != nil {
+			return c, fmt.Errorf("failed to parse params of case %d: %w", c.ID, err)
+		}
+	}
+	if opts.IncludeTags && len(e.Tags) > 0 && string(e.Tags) != "null" {
+		if err := decodeJSON(e.Tags, &c.Tags); err != nil {
+			return c, fmt.Errorf("failed to parse tags of case %d: %w", c.ID, err)
+		}
+	}
+	return c, nil
 }
 
 // CustomField represents a custom field in a Qase case
@@ -26,23 +105,31 @@ type CustomField struct {
 type CaseListResponse struct {
 	Status bool `json:"status"`
 	Result struct {
-		Total    int    `json:"total"`
-		Entities []Case `json:"entities"`
+		Total    *int         `json:"total"`
+		Filtered *int         `json:"filtered"` // entities matching the filters
+		Entities []caseEntity `json:"entities"`
 	} `json:"result"`
 }
 
-// GetCases fetches all cases for a project with pagination
-func GetCases(c *api.Client, project string) (map[int]Case, error) {
+// GetCases fetches all cases for a project with pagination. Pages are
+// requested until the reported total is reached, so short pages (a server
+// capping the limit) and sparse case IDs don't end the listing early. Without
+// a reported total the listing ends at the first short page.
+func GetCases(c *api.Client, project string, opts CaseListOptions) (map[int]Case, error) {
 	cases := make(map[int]Case)
 	offset := 0
 	limit := 100
-	maxPages := 1000 // Safety limit to prevent infinite loops
+	total := -1
+	maxPages := 10000 // Safety limit to prevent infinite loops
 
 	fmt.Printf("Fetching cases for project %s...\n", project)
 
 	for page := 1; page <= maxPages; page++ {
 		// Build URL with offset-based pagination
 		u := fmt.Sprintf("/case/%s?limit=%d&offset=%d", project, limit, offset)
+		if include := opts.include(); include != "" {
+			u += "&include=" + include
+		}
 
 		req, err := c.NewRequest("GET", u, nil)
 		if err != nil {
@@ -53,54 +140,102 @@ func GetCases(c *api.Client, project string) (map[int]Case, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to make request: %w", err)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-		}
 
 		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			return nil, fmt.Errorf("failed to read response: %w", err)
 		}
 
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
 		var response CaseListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
+		if err := decodeList(body, &response); err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
-		// Check if we got any new cases
+		total = listTotal(response.Result.Total, response.Result.Filtered)
 		newCasesCount := 0
-		for _, case_ := range response.Result.Entities {
-			if _, exists := cases[case_.ID]; !exists {
+		for _, entity := range response.Result.Entities {
+			if _, exists := cases[entity.ID]; !exists {
+				case_, err := entity.decode(opts)
+				if err != nil {
+					return nil, err
+				}
 				cases[case_.ID] = case_
 				newCasesCount++
 			}
 		}
 
-		fmt.Printf("Page %d (offset %d): %d cases returned, %d new cases (total unique: %d)\n",
-			page, offset, len(response.Result.Entities), newCasesCount, len(cases))
+		fmt.Printf("Page %d (offset %d): %d cases returned, %d new (total so far: %d/%s)\n",
+			page, offset, len(response.Result.Entities), newCasesCount, len(cases), formatTotal(total))
 
-		// Check if we've fetched all cases
-		if len(response.Result.Entities) < limit {
-			fmt.Printf("Reached end of cases (got %d < limit %d)\n", len(response.Result.Entities), limit)
+		if len(response.Result.Entities) == 0 {
 			break
 		}
 
-		// Safety check: if we got no new cases, we might be in a loop
-		if newCasesCount == 0 {
-			fmt.Printf("Warning: No new cases found on page %d, stopping to prevent infinite loop\n", page)
+		// A page of only known cases while some are missing (or, without a
+		// total, while pages are still full) means the offset was ignored;
+		// the listing would repeat forever, and stopping would lose cases
+		if newCasesCount == 0 && !listDone(len(response.Result.Entities), len(cases), limit, total) {
+			return nil, fmt.Errorf("page at offset %d of project %s repeated %d already fetched cases (%d of %s fetched)",
+				offset, project, len(response.Result.Entities), len(cases), formatTotal(total))
+		}
+
+		if listDone(len(response.Result.Entities), offset+len(response.Result.Entities), limit, total) {
 			break
 		}
 
-		offset += limit
+		offset += len(response.Result.Entities)
 	}
 
 	if len(cases) == 0 {
 		return nil, fmt.Errorf("no cases found for project %s", project)
 	}
+	reconcileCount(c, "cases", "project "+project, len(cases), total)
 
 	fmt.Printf("Total unique cases fetched: %d\n", len(cases))
 	return cases, nil
 }
+
+// UpdateCaseRequest represents a request to update a case's custom fields
+type UpdateCaseRequest struct {
+	CustomField map[string]string `json:"custom_field"`
+}
+
+// UpdateCaseCustomField sets a custom field value on a case
+func UpdateCaseCustomField(c *api.Client, project string, caseID, cfID int, value string) error {
+	reqBody := UpdateCaseRequest{
+		CustomField: map[string]string{strconv.Itoa(cfID): value},
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	path := fmt.Sprintf("/case/%s/%d", project, caseID)
+	req, err := c.NewRequest("PATCH", path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp.StatusCode, body)
+	}
+
+	return nil
+}
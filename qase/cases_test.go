@@ -0,0 +1,143 @@
+package qase_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// serveCases serves n cases of project PRJ with the given page fault and
+// counts the case list requests
+func serveCases(t *testing.T, n int, fault string) (*api.Client, *atomic.Int32) {
+	t.Helper()
+	server := mockserver.New()
+	cases := make([]qase.Case, n)
+	for i := range cases {
+		cases[i] = qase.Case{ID: i + 1, Title: fmt.Sprintf("Case %d", i+1)}
+	}
+	server.AddCases("PRJ", cases...)
+	server.SetPageFault(fault)
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/case/") {
+			requests.Add(1)
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return api.NewClient(ts.URL, "test"), &requests
+}
+
+func TestGetCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		cases    int
+		fault    string
+		requests int32
+	}{
+		{"last page short", 250, "", 3},
+		{"last page exactly at the limit", 200, "", 2},
+		{"capped page size", 250, mockserver.PageShort, 5},
+		{"total missing", 250, mockserver.PageNoTotal, 3},
+		{"total missing, last page exactly at the limit", 200, mockserver.PageNoTotal, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := serveCases(t, tt.cases, tt.fault)
+			cases, err := qase.GetCases(client, "PRJ", qase.CaseListOptions{})
+			if err != nil {
+				t.Fatalf("GetCases: %v", err)
+			}
+			if len(cases) != tt.cases {
+				t.Errorf("got %d cases, want %d", len(cases), tt.cases)
+			}
+			for id := 1; id <= tt.cases; id++ {
+				if _, ok := cases[id]; !ok {
+					t.Errorf("case %d missing", id)
+					break
+				}
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("got %d page requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestGetCasesOffsetIgnored(t *testing.T) {
+	for _, n := range []int{250, 100} {
+		t.Run(fmt.Sprintf("%d cases", n), func(t *testing.T) {
+			client, requests := serveCases(t, n, mockserver.PageIgnoreOffset)
+			_, err := qase.GetCases(client, "PRJ", qase.CaseListOptions{})
+			if n <= 100 {
+				// One page holds every case; nothing repeats
+				if err != nil {
+					t.Fatalf("GetCases: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "repeated") {
+				t.Fatalf("got error %v, want a repeated page error", err)
+			}
+			if got := requests.Load(); got != 2 {
+				t.Errorf("got %d page requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestGetCasesInclude(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    qase.CaseListOptions
+		include string
+	}{
+		{"default", qase.CaseListOptions{}, ""},
+		{"steps", qase.CaseListOptions{IncludeSteps: true}, "steps"},
+		{"all", qase.CaseListOptions{IncludeSteps: true, IncludeParams: true, IncludeTags: true}, "steps,params,tags"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := mockserver.New()
+			server.AddCases("PRJ", qase.Case{
+				ID:     1,
+				Title:  "Case 1",
+				Steps:  []qase.CaseStep{{Position: 1, Action: "Open"}},
+				Params: map[string][]string{"browser": {"firefox"}},
+				Tags:   []qase.CaseTag{{Title: "smoke"}},
+			})
+			var include atomic.Value
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				include.Store(r.URL.Query().Get("include"))
+				server.ServeHTTP(w, r)
+			}))
+			t.Cleanup(ts.Close)
+
+			cases, err := qase.GetCases(api.NewClient(ts.URL, "test"), "PRJ", tt.opts)
+			if err != nil {
+				t.Fatalf("GetCases: %v", err)
+			}
+			if got := include.Load(); got != tt.include {
+				t.Errorf("got include=%q, want %q", got, tt.include)
+			}
+			c := cases[1]
+			if got := len(c.Steps) > 0; got != tt.opts.IncludeSteps {
+				t.Errorf("steps kept: %v, want %v", got, tt.opts.IncludeSteps)
+			}
+			if got := len(c.Params) > 0; got != tt.opts.IncludeParams {
+				t.Errorf("params kept: %v, want %v", got, tt.opts.IncludeParams)
+			}
+			if got := len(c.Tags) > 0; got != tt.opts.IncludeTags {
+				t.Errorf("tags kept: %v, want %v", got, tt.opts.IncludeTags)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// CustomFieldDefinition is a custom field defined in a workspace
+type CustomFieldDefinition struct {
+	ID                      int      `json:"id"`
+	Title                   string   `json:"title"`
+	IsEnabledForAllProjects bool     `json:"is_enabled_for_all_projects"`
+	ProjectsCodes           []string `json:"projects_codes"`
+}
+
+// EnabledFor reports whether the field is available in project
+func (f CustomFieldDefinition) EnabledFor(project string) bool {
+	if f.IsEnabledForAllProjects {
+		return true
+	}
+	for _, code := range f.ProjectsCodes {
+		if strings.EqualFold(code, project) {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomFieldListResponse represents the API response for custom field list
+type CustomFieldListResponse struct {
+	Status bool `json:"status"`
+	Result struct {
+		Total    int                     `json:"total"`
+		Entities []CustomFieldDefinition `json:"entities"`
+	} `json:"result"`
+}
+
+// Custom field entities
+const (
+	EntityCase = "case"
+	EntityRun  = "run"
+)
+
+// GetCustomFields fetches all custom fields of an entity type in the workspace
+func GetCustomFields(c *api.Client, entity string) ([]CustomFieldDefinition, error) {
+	var fields []CustomFieldDefinition
+	offset := 0
+	limit := 100
+
+	for {
+		u := fmt.Sprintf("/custom_field?entity=%s&limit=%d&offset=%d", entity, limit, offset)
+
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
+		var response CustomFieldListResponse
+		if err := decodeList(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		fields = append(fields, response.Result.Entities...)
+
+		if len(response.Result.Entities) < limit {
+			break
+		}
+		offset += limit
+	}
+
+	return fields, nil
+}
+
+// ResolveCustomFieldID finds the ID of the case custom field titled name
+// (case-insensitive) that is enabled in project. When there is no such field,
+// or several, the error lists the fields available in the project.
+func ResolveCustomFieldID(c *api.Client, project, name string) (int, error) {
+	return resolveCustomFieldID(c, EntityCase, project, name)
+}
+
+// resolveCustomFieldID finds a custom field of entity by title in project
+func resolveCustomFieldID(c *api.Client, entity, project, name string) (int, error) {
+	fields, err := GetCustomFields(c, entity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list custom fields: %w", err)
+	}
+
+	var available, matches []CustomFieldDefinition
+	for _, field := range fields {
+		if !field.EnabledFor(project) {
+			continue
+		}
+		available = append(available, field)
+		if strings.EqualFold(strings.TrimSpace(field.Title), strings.TrimSpace(name)) {
+			matches = append(matches, field)
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0].ID, nil
+	case 0:
+		return 0, fmt.Errorf("%s custom field %q not found in project %s; available fields: %s", entity, name, project, describeFields(available))
+	default:
+		return 0, fmt.Errorf("%s custom field name %q is ambiguous in project %s: %s; set the ID instead", entity, name, project, describeFields(matches))
+	}
+}
+
+// describeFields lists fields as `12 "Title"`, ordered by ID
+func describeFields(fields []CustomFieldDefinition) string {
+	if len(fields) == 0 {
+		return "none"
+	}
+	sorted := append([]CustomFieldDefinition(nil), fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	parts := make([]string, len(sorted))
+	for i, field := range sorted {
+		parts[i] = fmt.Sprintf("%d %q", field.ID, field.Title)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ResolveCustomField returns the ID of a custom field given by ID or by title
+func ResolveCustomField(c *api.Client, project, ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if id, err := strconv.Atoi(ref); err == nil && id > 0 {
+		return id, nil
+	}
+	return ResolveCustomFieldID(c, project, ref)
+}
+
+// ParseFieldValues parses "Field:Value" pairs separated by commas, where each
+// field is given by ID or title
+func ParseFieldValues(value string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid custom field value pair: %s", pair)
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values, nil
+}
+
+// ResolveRunCustomFields resolves run custom field values given by field ID or
+// title to values keyed by field ID
+func ResolveRunCustomFields(c *api.Client, project string, values map[string]string) (map[int]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	resolved := make(map[int]string, len(values))
+	for ref, value := range values {
+		id, err := strconv.Atoi(ref)
+		if err != nil || id <= 0 {
+			if id, err = resolveCustomFieldID(c, EntityRun, project, ref); err != nil {
+				return nil, err
+			}
+		}
+		resolved[id] = value
+	}
+	return resolved, nil
+}
@@ -0,0 +1,395 @@
+package qase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// strictDecode reports unexpected payload shapes (see SetStrictDecode)
+var strictDecode bool
+
+// SetStrictDecode turns on reporting of every unknown field and coerced
+// value in API responses, once per field, to spot Qase schema changes.
+// Decoding stays lenient either way.
+func SetStrictDecode(enabled bool) {
+	strictDecode = enabled
+}
+
+var (
+	reportedShapes sync.Map // "path: issue" -> true
+	lenientNoted   sync.Once
+)
+
+// requiredFields name the identifiers and statuses a response is useless
+// without: a value that cannot be converted is an error instead of a zero
+// value that would post results to case 0 or with no status
+var requiredFields = map[string]bool{"id": true, "case_id": true, "run_id": true, "status": true}
+
+// decodeJSON decodes an API response into v. Minor schema changes do not
+// stop a migration: unknown fields are ignored (as by json.Unmarshal),
+// numbers sent as strings and strings sent as numbers are converted, and
+// values of the wrong shape (such as the empty array PHP sends for an empty
+// object) are left at their zero value, except in requiredFields. Malformed
+// JSON is still an error.
+func decodeJSON(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	var typeErr *json.UnmarshalTypeError
+	if err == nil && !strictDecode {
+		return nil
+	}
+	if err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if decodeErr := decoder.Decode(&value); decodeErr != nil {
+		return decodeErr
+	}
+	t := reflect.TypeOf(v).Elem()
+	var issues, lost []string
+	normalized := normalizeJSON(value, t, typeName(t), &issues, &lost)
+	reportShapes(issues)
+	if len(lost) > 0 {
+		return fmt.Errorf("unexpected value for %s in API response", strings.Join(lost, ", "))
+	}
+	if err == nil {
+		return nil
+	}
+
+	fixed, marshalErr := json.Marshal(normalized)
+	if marshalErr != nil {
+		return err
+	}
+	if retryErr := json.Unmarshal(fixed, v); retryErr != nil {
+		return err
+	}
+	return nil
+}
+
+// listEnvelope is the shape every list response shares
+type listEnvelope struct {
+	Status json.RawMessage `json:"status"`
+	Result *struct {
+		Count    json.RawMessage `json:"count"`
+		Entities json.RawMessage `json:"entities"`
+	} `json:"result"`
+}
+
+// decodeList decodes one page of a list response after checking it is one.
+// A page without an entities list, or with fewer entities than its own
+// count, is an error rather than an empty or last page, so a gateway's JSON
+// error or a cut-off page cannot silently end a listing early.
+func decodeList(data []byte, v interface{}) error {
+	var envelope listEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	var entities []byte
+	if envelope.Result != nil {
+		entities = bytes.TrimSpace(envelope.Result.Entities)
+	}
+	switch {
+	case string(envelope.Status) == "false":
+		return fmt.Errorf("list response reported failure: %s", bodySnippet(data))
+	case envelope.Result == nil:
+		return fmt.Errorf("list response has no result: %s", bodySnippet(data))
+	case len(entities) == 0 || entities[0] != '[':
+		return fmt.Errorf("list response has no entities list: %s", bodySnippet(data))
+	}
+
+	if count, err := strconv.Atoi(strings.Trim(string(envelope.Result.Count), `"`)); err == nil {
+		var list []json.RawMessage
+		if err := json.Unmarshal(entities, &list); err != nil {
+			return err
+		}
+		if len(list) != count {
+			return fmt.Errorf("list page holds %d entities but reports a count of %d (truncated response)", len(list), count)
+		}
+	}
+	return decodeJSON(data, v)
+}
+
+// bodySnippet returns the start of a response body on one line
+func bodySnippet(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if len(text) > 120 {
+		text = text[:120] + "..."
+	}
+	return text
+}
+
+// reportShapes prints each unexpected shape once in strict mode, and
+// otherwise notes once that responses needed lenient decoding
+func reportShapes(issues []string) {
+	if len(issues) == 0 {
+		return
+	}
+	if !strictDecode {
+		lenientNoted.Do(func() {
+			fmt.Printf("Note: Decoded Qase API responses with unexpected field types leniently (set QASE_STRICT_DECODE=true for details)\n")
+		})
+		return
+	}
+	for _, issue := range issues {
+		if _, seen := reportedShapes.LoadOrStore(issue, true); !seen {
+			fmt.Printf("Strict decode: %s\n", issue)
+		}
+	}
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// normalizeJSON converts a value decoded with UseNumber toward the shape of
+// t, appending what did not fit to issues, and the paths of requiredFields
+// that could not be converted to lost
+func normalizeJSON(value interface{}, t reflect.Type, path string, issues, lost *[]string) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if value == nil || t == rawMessageType || t.Kind() == reflect.Interface {
+		return value
+	}
+
+	// Custom decoders get their input as is, unless it is an object or list
+	// whose members can still be normalized
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		switch value.(type) {
+		case map[string]interface{}:
+			if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+				return value
+			}
+		case []interface{}:
+			if t.Kind() != reflect.Slice {
+				return value
+			}
+		default:
+			return value
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			*issues = append(*issues, fmt.Sprintf("%s: expected an object, got %s", path, describeJSON(value)))
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(object) {
+			field, known := fields[key]
+			if !known {
+				field, known = fields[strings.ToLower(key)]
+			}
+			if !known {
+				*issues = append(*issues, fmt.Sprintf("%s.%s: unknown field", path, key))
+				continue
+			}
+			normalized := normalizeJSON(object[key], field, path+"."+key, issues, lost)
+			if normalized == nil && object[key] != nil && requiredFields[key] {
+				*lost = append(*lost, path+"."+key)
+			}
+			object[key] = normalized
+		}
+		return object
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			// PHP encodes an empty map as an empty array
+			if list, isList := value.([]interface{}); !isList || len(list) > 0 {
+				*issues = append(*issues, fmt.Sprintf("%s: expected an object, got %s", path, describeJSON(value)))
+			}
+			return nil
+		}
+		for key, member := range object {
+			object[key] = normalizeJSON(member, t.Elem(), path+"{}", issues, lost)
+		}
+		return object
+
+	case reflect.Slice, reflect.Array:
+		list, ok := value.([]interface{})
+		if !ok {
+			*issues = append(*issues, fmt.Sprintf("%s: expected a list, got %s", path, describeJSON(value)))
+			return nil
+		}
+		for i, member := range list {
+			list[i] = normalizeJSON(member, t.Elem(), path+"[]", issues, lost)
+		}
+		return list
+
+	case reflect.String:
+		switch v := value.(type) {
+		case json.Number:
+			*issues = append(*issues, fmt.Sprintf("%s: number sent, expected a string", path))
+			return v.String()
+		case bool:
+			*issues = append(*issues, fmt.Sprintf("%s: boolean sent, expected a string", path))
+			return strconv.FormatBool(v)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return normalizeNumber(value, t, path, issues)
+
+	case reflect.Bool:
+		switch v := value.(type) {
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				*issues = append(*issues, fmt.Sprintf("%s: boolean sent as a string", path))
+				return b
+			}
+			*issues = append(*issues, fmt.Sprintf("%s: expected a boolean, got a string", path))
+			return nil
+		case json.Number:
+			*issues = append(*issues, fmt.Sprintf("%s: boolean sent as a number", path))
+			return v.String() != "0"
+		}
+	}
+	return value
+}
+
+// normalizeNumber converts numbers sent as strings, and fractional numbers
+// for integer fields
+func normalizeNumber(value interface{}, t reflect.Type, path string, issues *[]string) interface{} {
+	integer := t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64
+	var number json.Number
+	switch v := value.(type) {
+	case json.Number:
+		number = v
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			*issues = append(*issues, fmt.Sprintf("%s: empty string sent, expected a number", path))
+			return nil
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			*issues = append(*issues, fmt.Sprintf("%s: expected a number, got a string", path))
+			return nil
+		}
+		*issues = append(*issues, fmt.Sprintf("%s: number sent as a string", path))
+		number = json.Number(s)
+	case bool:
+		*issues = append(*issues, fmt.Sprintf("%s: boolean sent, expected a number", path))
+		if v {
+			return json.Number("1")
+		}
+		return json.Number("0")
+	default:
+		*issues = append(*issues, fmt.Sprintf("%s: expected a number, got %s", path, describeJSON(value)))
+		return nil
+	}
+
+	if integer && strings.ContainsAny(number.String(), ".eE") {
+		f, err := number.Float64()
+		if err != nil || f != math.Trunc(f) {
+			*issues = append(*issues, fmt.Sprintf("%s: expected an integer, got a fraction", path))
+			return nil
+		}
+		return json.Number(strconv.FormatInt(int64(f), 10))
+	}
+	return number
+}
+
+// jsonFields returns the JSON names of a struct's fields, including those of
+// embedded structs, keyed by name and by lower-case name as encoding/json
+// matches them. Shallower fields win, as in encoding/json.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(map[string]reflect.Type)
+	}
+	fields := make(map[string]reflect.Type)
+	level := []reflect.Type{t}
+	for depth := 0; len(level) > 0 && depth < 8; depth++ {
+		var next []reflect.Type
+		found := make(map[string]reflect.Type)
+		for _, st := range level {
+			for i := 0; i < st.NumField(); i++ {
+				field := st.Field(i)
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, _, _ := strings.Cut(tag, ",")
+				ft := field.Type
+				if field.Anonymous && name == "" {
+					for ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, ft)
+						continue
+					}
+				}
+				if !field.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = field.Name
+				}
+				found[name] = field.Type
+			}
+		}
+		for name, ft := range found {
+			if _, exists := fields[name]; !exists {
+				fields[name] = ft
+			}
+			if lower := strings.ToLower(name); fields[lower] == nil {
+				fields[lower] = ft
+			}
+		}
+		level = next
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+var fieldCache sync.Map // reflect.Type -> map[string]reflect.Type
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func describeJSON(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return "response"
+}
@@ -0,0 +1,133 @@
+package qase
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxTimeSeconds is the longest result time the API accepts (1 year)
+const MaxTimeSeconds = 31536000
+
+// What happens to durations over the maximum
+const (
+	DurationCap  = "cap"
+	DurationDrop = "drop"
+)
+
+// How millisecond durations become whole seconds
+const (
+	RoundDown    = "down"
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+)
+
+// DurationPolicy decides the time posted for a source result duration. The
+// zero value caps at MaxTimeSeconds and rounds milliseconds down.
+type DurationPolicy struct {
+	MaxSeconds int    // longest time posted (0 for MaxTimeSeconds)
+	Drop       bool   // post longer durations without time instead of capping them
+	Rounding   string // Round* for millisecond durations ("" for RoundDown)
+}
+
+// LongDuration is a source duration over the maximum
+type LongDuration struct {
+	CaseID  int
+	Seconds int
+}
+
+// DurationStats collects the durations a policy did not post as recorded
+type DurationStats struct {
+	Long        []LongDuration // over the maximum, capped or dropped
+	Implausible int            // zero or negative, posted without time
+}
+
+// NewDurationPolicy parses the maximum in seconds (empty for MaxTimeSeconds),
+// the handling of longer durations (cap or drop, empty for cap) and the
+// rounding of milliseconds (down, nearest or up, empty for down)
+func NewDurationPolicy(maxSeconds, overMax, rounding string) (DurationPolicy, error) {
+	var policy DurationPolicy
+	if maxSeconds != "" {
+		n, err := strconv.Atoi(maxSeconds)
+		if err != nil || n <= 0 || n > MaxTimeSeconds {
+			return policy, fmt.Errorf("invalid maximum duration %q (use 1 to %d seconds)", maxSeconds, MaxTimeSeconds)
+		}
+		policy.MaxSeconds = n
+	}
+
+	switch overMax {
+	case "", DurationCap:
+	case DurationDrop:
+		policy.Drop = true
+	default:
+		return policy, fmt.Errorf("unsupported handling of long durations %q (use cap or drop)", overMax)
+	}
+
+	switch rounding {
+	case "", RoundDown, RoundNearest, RoundUp:
+		policy.Rounding = rounding
+	default:
+		return policy, fmt.Errorf("unsupported duration rounding %q (use down, nearest or up)", rounding)
+	}
+	return policy, nil
+}
+
+// Seconds returns the time to post for a duration in seconds, or nil for
+// none, recording long and implausible durations in stats (if not nil)
+func (p DurationPolicy) Seconds(caseID int, seconds *int, stats *DurationStats) *int {
+	if seconds == nil {
+		return nil
+	}
+	return p.apply(caseID, *seconds, stats)
+}
+
+// Milliseconds is Seconds for a duration in milliseconds, where 0 means the
+// duration was not recorded
+func (p DurationPolicy) Milliseconds(caseID, ms int, stats *DurationStats) *int {
+	if ms == 0 {
+		return nil
+	}
+	if ms < 0 {
+		return p.apply(caseID, ms, stats)
+	}
+
+	seconds := ms / 1000
+	switch p.Rounding {
+	case RoundNearest:
+		seconds = (ms + 500) / 1000
+	case RoundUp:
+		seconds = (ms + 999) / 1000
+	}
+	if seconds == 0 {
+		// Sub-second results are plausible; post them as 0 rather than warn
+		zero := 0
+		return &zero
+	}
+	return p.apply(caseID, seconds, stats)
+}
+
+func (p DurationPolicy) apply(caseID, seconds int, stats *DurationStats) *int {
+	if seconds <= 0 {
+		if stats != nil {
+			stats.Implausible++
+		}
+		return nil
+	}
+	if max := p.Max(); seconds > max {
+		if stats != nil {
+			stats.Long = append(stats.Long, LongDuration{CaseID: caseID, Seconds: seconds})
+		}
+		if p.Drop {
+			return nil
+		}
+		seconds = max
+	}
+	return &seconds
+}
+
+// Max returns the longest time posted, in seconds
+func (p DurationPolicy) Max() int {
+	if p.MaxSeconds <= 0 {
+		return MaxTimeSeconds
+	}
+	return p.MaxSeconds
+}
@@ -0,0 +1,238 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Source fetch modes for QASE_FETCH_MODE
+const (
+	FetchGlobal = "global" // one result query filtered by end time
+	FetchByRun  = "by_run" // list runs, then fetch each run's results
+	FetchAuto   = "auto"   // pick whichever needs fewer sequential requests
+)
+
+// fetchByRunConcurrency is how many runs are fetched in parallel in by_run mode
+const fetchByRunConcurrency = 4
+
+// FetchOptions selects the source results to migrate
+type FetchOptions struct {
+	Mode      string    // FetchGlobal, FetchByRun or FetchAuto (default global)
+	AfterDate time.Time // results ended after (by_run: of the runs started after)
+	RunIDs    []int     // explicit source runs, whatever their start (by_run and auto only fetch these)
+}
+
+// FetchResults fetches source results with the global results query or run
+// by run. The global query pages sequentially through every result, which is
+// best for many small runs; fetching by run pages shallowly and in parallel,
+// which is best for few dense runs. Auto mode estimates both from the run list
+// and the result count and picks the cheaper one.
+func FetchResults(c *api.Client, project string, opts FetchOptions) ([]Result, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = FetchGlobal
+	}
+
+	var runIDs []int
+	if mode != FetchGlobal {
+		if len(opts.RunIDs) > 0 {
+			runIDs = opts.RunIDs
+			mode = FetchByRun
+		} else {
+			runs, err := GetRuns(c, project, RunListOptions{FromStartTime: opts.AfterDate})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list source runs: %w", err)
+			}
+			if mode == FetchAuto {
+				mode = chooseFetchMode(c, project, opts.AfterDate, runs)
+			}
+			for _, run := range runs {
+				runIDs = append(runIDs, run.ID)
+			}
+		}
+	}
+
+	if mode == FetchGlobal {
+		results, err := GetResultsAfterDate(c, project, opts.AfterDate)
+		if err != nil || len(opts.RunIDs) == 0 {
+			return results, err
+		}
+		return filterRunResults(results, opts.RunIDs), nil
+	}
+
+	fmt.Printf("Fetching results of %d runs (%d in parallel)...\n", len(runIDs), fetchByRunConcurrency)
+	results, err := getResultsByRun(c, project, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	// The same end time cutoff as the global query, so both modes migrate
+	// the same results
+	return filterEndedAfter(results, opts.AfterDate), nil
+}
+
+// endTimeCutoff is the from_end_time of the global results query: the start
+// of the cutoff's day
+func endTimeCutoff(afterDate time.Time) time.Time {
+	return time.Date(afterDate.Year(), afterDate.Month(), afterDate.Day(), 0, 0, 0, 0, afterDate.Location())
+}
+
+// filterEndedAfter keeps the results the global query returns for afterDate:
+// those ended at or after its cutoff. A zero afterDate keeps every result.
+func filterEndedAfter(results []Result, afterDate time.Time) []Result {
+	if afterDate.IsZero() {
+		return results
+	}
+	cutoff := endTimeCutoff(afterDate)
+	filtered := results[:0]
+	for _, result := range results {
+		if !result.EndedAt.IsZero() && !result.EndedAt.Before(cutoff) {
+			filtered = append(filtered, result)
+		}
+	}
+	if dropped := len(results) - len(filtered); dropped > 0 {
+		fmt.Printf("Left out %d results of the fetched runs ended before %s\n", dropped, cutoff.Format("2006-01-02"))
+	}
+	return filtered
+}
+
+// chooseFetchMode compares the sequential requests of a global scan with the
+// per-run requests spread over the parallel by-run fetchers
+func chooseFetchMode(c *api.Client, project string, afterDate time.Time, runs []Run) string {
+	total, err := CountResultsAfterDate(c, project, afterDate)
+	if err != nil {
+		fmt.Printf("Could not count results (%v), using the global results query\n", err)
+		return FetchGlobal
+	}
+
+	const pageSize = 100
+	globalRequests := max(1, (total+pageSize-1)/pageSize)
+	byRunRequests := 0
+	for _, run := range runs {
+		byRunRequests += max(1, (runResultTotal(run)+pageSize-1)/pageSize)
+	}
+	byRunSequential := (byRunRequests + fetchByRunConcurrency - 1) / fetchByRunConcurrency
+
+	mode := FetchGlobal
+	if len(runs) > 0 && byRunSequential < globalRequests {
+		mode = FetchByRun
+	}
+	fmt.Printf("Fetch mode auto: %d results in %d runs; global needs %d requests, by run %d (%d in parallel): using %s\n",
+		total, len(runs), globalRequests, byRunRequests, fetchByRunConcurrency, mode)
+	return mode
+}
+
+// runResultTotal returns the result count from a run's stats (0 if absent)
+func runResultTotal(run Run) int {
+	if total, ok := run.Stats["total"].(float64); ok {
+		return int(total)
+	}
+	return 0
+}
+
+// CountResultsAfterDate returns the number of results ended after afterDate,
+// as reported by the results API
+func CountResultsAfterDate(c *api.Client, project string, afterDate time.Time) (int, error) {
+	u := fmt.Sprintf("/result/%s?limit=1&offset=0&from_end_time=%s",
+		project, url.QueryEscape(afterDate.Format("2006-01-02 00:00:00")))
+
+	req, err := c.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response ResultListResponse
+	if err := decodeList(body, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if response.Result.Total == nil {
+		return 0, fmt.Errorf("the API reported no total")
+	}
+	return *response.Result.Total, nil
+}
+
+// getResultsByRun fetches the results of each run in parallel, returning them
+// in run order
+func getResultsByRun(c *api.Client, project string, runIDs []int) ([]Result, error) {
+	sorted := append([]int(nil), runIDs...)
+	sort.Ints(sorted)
+
+	byRun := make([][]Result, len(sorted))
+	errs := make([]error, len(sorted))
+	semaphore := make(chan struct{}, fetchByRunConcurrency)
+	var wg sync.WaitGroup
+	for i, runID := range sorted {
+		wg.Add(1)
+		go func(i, runID int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			byRun[i], errs[i] = GetRunResults(c, project, runID)
+		}(i, runID)
+	}
+	wg.Wait()
+
+	var results []Result
+	for i, runID := range sorted {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to fetch results of run %d: %w", runID, errs[i])
+		}
+		results = append(results, byRun[i]...)
+	}
+	fmt.Printf("Total results fetched from %d runs: %d\n", len(sorted), len(results))
+	return results, nil
+}
+
+// filterRunResults keeps the results of the given runs
+func filterRunResults(results []Result, runIDs []int) []Result {
+	keep := make(map[int]bool, len(runIDs))
+	for _, id := range runIDs {
+		keep[id] = true
+	}
+	var filtered []Result
+	for _, result := range results {
+		if keep[result.RunID] {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// ParseRunIDs parses a comma-separated list of run IDs
+func ParseRunIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid run ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,26 @@
+package qase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AppURL returns the web app base URL for an API base URL, e.g.
+// https://app.qase.io for https://api.qase.io
+func AppURL(apiBase string) string {
+	u, err := url.Parse(apiBase)
+	if err != nil || u.Host == "" {
+		return "https://app.qase.io"
+	}
+	if host, ok := strings.CutPrefix(u.Host, "api."); ok {
+		u.Host = "app." + host
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api")
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// RunURL returns the web app link to a run's dashboard
+func RunURL(apiBase, project string, runID int) string {
+	return fmt.Sprintf("%s/run/%s/dashboard/%d", AppURL(apiBase), project, runID)
+}
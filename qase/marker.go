@@ -0,0 +1,65 @@
+package qase
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// The bulk API does not accept a result hash, so the source result hash is
+// recorded as a marker line at the end of the comment instead
+const sourceMarkerFormat = "[migrated-from:%s]"
+
+var sourceMarkerPattern = regexp.MustCompile(`\n*\[migrated-from:([A-Za-z0-9]+)\]\s*$`)
+
+// WithSourceMarker appends the source result hash marker to a comment
+func WithSourceMarker(comment, hash string) string {
+	if hash == "" {
+		return comment
+	}
+	comment = StripSourceMarker(comment)
+	marker := fmt.Sprintf(sourceMarkerFormat, hash)
+	if comment == "" {
+		return marker
+	}
+	return comment + "\n\n" + marker
+}
+
+// SourceHash returns the source result hash recorded in a comment, if any
+func SourceHash(comment string) string {
+	if match := sourceMarkerPattern.FindStringSubmatch(comment); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// StripSourceMarker removes the source result hash marker from a comment
+func StripSourceMarker(comment string) string {
+	return sourceMarkerPattern.ReplaceAllString(comment, "")
+}
+
+// Target runs record the source run (or bucket) they were migrated from as a
+// marker line at the end of the description, so reruns can find them without
+// relying on the title
+const runMarkerFormat = "[migrated-run:%s]"
+
+var runMarkerPattern = regexp.MustCompile(`\n*\[migrated-run:([^\]\s]+)\]\s*$`)
+
+// WithRunMarker appends the source run marker to a run description
+func WithRunMarker(description, marker string) string {
+	if marker == "" {
+		return description
+	}
+	description = runMarkerPattern.ReplaceAllString(description, "")
+	if description == "" {
+		return fmt.Sprintf(runMarkerFormat, marker)
+	}
+	return description + "\n\n" + fmt.Sprintf(runMarkerFormat, marker)
+}
+
+// RunMarker returns the source run marker recorded in a run description, if any
+func RunMarker(description string) string {
+	if match := runMarkerPattern.FindStringSubmatch(description); match != nil {
+		return match[1]
+	}
+	return ""
+}
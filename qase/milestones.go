@@ -0,0 +1,137 @@
+package qase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Milestone represents a project milestone
+type Milestone struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// MilestoneListResponse represents the API response for milestone list
+type MilestoneListResponse struct {
+	Status bool `json:"status"`
+	Result struct {
+		Total    int         `json:"total"`
+		Entities []Milestone `json:"entities"`
+	} `json:"result"`
+}
+
+// FindMilestone returns the milestone titled title (case-insensitive), or nil
+func FindMilestone(c *api.Client, project, title string) (*Milestone, error) {
+	offset := 0
+	limit := 100
+
+	for {
+		query := url.Values{}
+		query.Set("search", title)
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		u := fmt.Sprintf("/milestone/%s?%s", project, query.Encode())
+
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
+		var response MilestoneListResponse
+		if err := decodeList(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		// The search is a substring match, so compare the whole title
+		for _, milestone := range response.Result.Entities {
+			if strings.EqualFold(strings.TrimSpace(milestone.Title), strings.TrimSpace(title)) {
+				return &milestone, nil
+			}
+		}
+
+		if len(response.Result.Entities) < limit {
+			return nil, nil
+		}
+		offset += limit
+	}
+}
+
+// CreateMilestone creates a milestone and returns its ID
+func CreateMilestone(c *api.Client, project, title, description string) (int, error) {
+	body, err := json.Marshal(map[string]string{"title": title, "description": description})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.NewRequest("POST", fmt.Sprintf("/milestone/%s", project), body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response CreateRunResponse
+	if err := decodeJSON(body, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !response.Status {
+		return 0, fmt.Errorf("milestone creation failed: %s", string(body))
+	}
+	return response.Result.ID, nil
+}
+
+// GetOrCreateMilestone returns the ID of the milestone titled title, creating
+// it when the project has none
+func GetOrCreateMilestone(c *api.Client, project, title, description string) (int, error) {
+	milestone, err := FindMilestone(c, project, title)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find milestone: %w", err)
+	}
+	if milestone != nil {
+		fmt.Printf("Using milestone: %s (ID: %d)\n", milestone.Title, milestone.ID)
+		return milestone.ID, nil
+	}
+
+	id, err := CreateMilestone(c, project, title, description)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create milestone: %w", err)
+	}
+	fmt.Printf("Created milestone: %s (ID: %d)\n", title, id)
+	return id, nil
+}
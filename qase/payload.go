@@ -0,0 +1,112 @@
+package qase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxPayloadBytes is the bulk request size limit used when the client
+// does not set one
+const DefaultMaxPayloadBytes = 8 << 20
+
+// maxReportedItems caps how many offending results are listed per chunk
+const maxReportedItems = 5
+
+// PayloadError reports bulk chunks whose serialized size exceeds the API limit
+type PayloadError struct {
+	Limit  int
+	Chunks []OversizedChunk
+}
+
+// OversizedChunk is a chunk over the payload limit with its largest results
+type OversizedChunk struct {
+	Number  int
+	Size    int
+	Largest []ItemSize
+}
+
+// ItemSize is the serialized size of one result and its comment
+type ItemSize struct {
+	CaseID      int
+	Size        int
+	CommentSize int
+}
+
+func (e *PayloadError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d chunk(s) exceed the %s payload limit", len(e.Chunks), formatBytes(e.Limit))
+	for _, chunk := range e.Chunks {
+		fmt.Fprintf(&b, "; chunk %d is %s, largest results:", chunk.Number, formatBytes(chunk.Size))
+		for i, item := range chunk.Largest {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, " case %d (comment %s)", item.CaseID, formatBytes(item.CommentSize))
+		}
+	}
+	b.WriteString(" (lower QASE_BULK_SIZE, shorten these comments with QASE_COMMENT_HOOK, or raise QASE_MAX_PAYLOAD_BYTES)")
+	return b.String()
+}
+
+// HTTPStatus classifies payload errors like the API's 413 response
+func (e *PayloadError) HTTPStatus() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// ValidatePayloads checks the serialized size of every chunk before anything
+// is posted, so oversized payloads fail up front with the offending case IDs
+// instead of an opaque 413 or 500 response part-way through a run
+func ValidatePayloads(items []BulkItem, chunkSize, limit int) error {
+	if limit <= 0 {
+		limit = DefaultMaxPayloadBytes
+	}
+	if chunkSize <= 0 {
+		chunkSize = 200
+	}
+
+	payloadErr := &PayloadError{Limit: limit}
+	for i := 0; i < len(items); i += chunkSize {
+		chunk := items[i:min(i+chunkSize, len(items))]
+
+		body, err := json.Marshal(BulkRequest{Results: chunk})
+		if err != nil {
+			return fmt.Errorf("failed to marshal chunk %d: %w", i/chunkSize+1, err)
+		}
+		if len(body) <= limit {
+			continue
+		}
+
+		sizes := make([]ItemSize, 0, len(chunk))
+		for _, item := range chunk {
+			data, _ := json.Marshal(item)
+			sizes = append(sizes, ItemSize{CaseID: item.CaseID, Size: len(data), CommentSize: len(item.Comment)})
+		}
+		sort.SliceStable(sizes, func(a, b int) bool { return sizes[a].Size > sizes[b].Size })
+
+		payloadErr.Chunks = append(payloadErr.Chunks, OversizedChunk{
+			Number:  i/chunkSize + 1,
+			Size:    len(body),
+			Largest: sizes[:min(maxReportedItems, len(sizes))],
+		})
+	}
+
+	if len(payloadErr.Chunks) > 0 {
+		return payloadErr
+	}
+	return nil
+}
+
+// formatBytes renders a byte count for error messages
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
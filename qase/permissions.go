@@ -0,0 +1,134 @@
+package qase
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Outcomes of a permission probe
+const (
+	PermissionAllowed = "yes"
+	PermissionDenied  = "no"
+	PermissionUnknown = "?"
+	PermissionSkipped = "-"
+)
+
+// Permissions records what a token may do in a project, as probed. Write
+// probes send malformed requests the API can only reject, so nothing is
+// created: a validation error means the token passed the permission check.
+// A write probe answered with not found is unknown, since the API may look
+// up the path before checking the token's scope.
+type Permissions struct {
+	Project           string // the project exists and the token can see it
+	ReadCases         string
+	ReadResults       string
+	CreateRuns        string
+	PostResults       string
+	UploadAttachments string
+	CreateStatuses    string // workspace-wide
+
+	// Details explains denied and unknown outcomes by permission name
+	Details map[string]string
+}
+
+// ProbePermissions probes the read permissions of a token in project, and its
+// write permissions when write is set. Write probes are skipped for
+// read-only (dry run) clients. Creating result statuses is probed only when
+// statuses is set as well.
+func ProbePermissions(c *api.Client, project string, write, statuses bool) *Permissions {
+	p := &Permissions{CreateStatuses: PermissionSkipped, Details: make(map[string]string)}
+
+	// Every other probe needs a project the token can see: a mistyped code
+	// would otherwise pass the write probes as a not found error
+	p.Project = p.probe(c, "access project", "GET", fmt.Sprintf("/project/%s", project), nil, "")
+	if p.Project == PermissionDenied {
+		p.ReadCases, p.ReadResults = PermissionSkipped, PermissionSkipped
+		p.CreateRuns, p.PostResults, p.UploadAttachments = PermissionSkipped, PermissionSkipped, PermissionSkipped
+		return p
+	}
+
+	p.ReadCases = p.probe(c, "read cases", "GET", fmt.Sprintf("/case/%s?limit=1", project), nil, "")
+	p.ReadResults = p.probe(c, "read results", "GET", fmt.Sprintf("/result/%s?limit=1", project), nil, "")
+
+	if !write {
+		p.CreateRuns, p.PostResults, p.UploadAttachments = PermissionSkipped, PermissionSkipped, PermissionSkipped
+		return p
+	}
+	// A body that is not JSON cannot create anything, however lenient the
+	// validation. Run 0 does not exist, so posting results reads as unknown
+	// unless the API checks the token or the body first.
+	p.CreateRuns = p.probe(c, "create runs", "POST", fmt.Sprintf("/run/%s", project), malformedBody, "")
+	p.PostResults = p.probe(c, "post results", "POST", fmt.Sprintf("/result/%s/0/bulk", project), malformedBody, "")
+
+	// An upload without a file fails validation
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.Close()
+	p.UploadAttachments = p.probe(c, "upload attachments", "POST", fmt.Sprintf("/attachment/%s", project), form.Bytes(), writer.FormDataContentType())
+
+	if statuses {
+		p.CreateStatuses = p.probe(c, "create statuses", "POST", "/system_field/result_status", malformedBody, "")
+	}
+	return p
+}
+
+// malformedBody is the body of the JSON write probes
+var malformedBody = []byte(`{"probe":`)
+
+// probe classifies the response to one probe request
+func (p *Permissions) probe(c *api.Client, name, method, path string, body []byte, contentType string) string {
+	req, err := c.NewRequest(method, path, body)
+	if err != nil {
+		p.Details[name] = err.Error()
+		return PermissionUnknown
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		p.Details[name] = err.Error()
+		return PermissionUnknown
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	respBody = bytes.TrimSpace(respBody)
+
+	write := method != "GET"
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		// A project the token cannot see reads as not found
+		resp.StatusCode == http.StatusNotFound && !write:
+		p.Details[name] = newHTTPError(resp.StatusCode, respBody).Error()
+		return PermissionDenied
+	case resp.StatusCode < 300,
+		// The malformed body was validated, so the permission check passed
+		write && (resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity):
+		return PermissionAllowed
+	case resp.StatusCode == http.StatusNotFound:
+		p.Details[name] = newHTTPError(resp.StatusCode, respBody).Error() + " (the API did not reach the permission check)"
+		return PermissionUnknown
+	default:
+		p.Details[name] = newHTTPError(resp.StatusCode, respBody).Error()
+		return PermissionUnknown
+	}
+}
+
+// Matrix returns the permission names with their outcomes, in probe order
+func (p *Permissions) Matrix() [][2]string {
+	return [][2]string{
+		{"access project", p.Project},
+		{"read cases", p.ReadCases},
+		{"read results", p.ReadResults},
+		{"create runs", p.CreateRuns},
+		{"post results", p.PostResults},
+		{"upload attachments", p.UploadAttachments},
+		{"create statuses", p.CreateStatuses},
+	}
+}
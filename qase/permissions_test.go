@@ -0,0 +1,41 @@
+package qase_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+func TestProbePermissionsReadOnlyToken(t *testing.T) {
+	server := mockserver.New()
+	server.AddCases("PRJ", qase.Case{ID: 1, Title: "Case 1"})
+	server.SetReadOnlyTokens([]string{"read-only"})
+
+	// An API that looks the run up before checking the token's scope
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/result/PRJ/0/bulk") {
+			http.Error(w, `{"status":false,"errorMessage":"Run not found"}`, http.StatusNotFound)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	p := qase.ProbePermissions(api.NewClient(ts.URL, "read-only"), "PRJ", true, false)
+	want := map[string]string{
+		"access project": qase.PermissionAllowed,
+		"read cases":     qase.PermissionAllowed,
+		"create runs":    qase.PermissionDenied,
+		"post results":   qase.PermissionUnknown,
+	}
+	for _, row := range p.Matrix() {
+		if outcome, ok := want[row[0]]; ok && row[1] != outcome {
+			t.Errorf("%s = %q, want %q (%s)", row[0], row[1], outcome, p.Details[row[0]])
+		}
+	}
+}
@@ -2,12 +2,17 @@ package qase
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
 )
 
 // BulkItem represents a single result item for bulk posting
@@ -16,6 +21,12 @@ type BulkItem struct {
 	Status  string `json:"status"`
 	Time    *int   `json:"time,omitempty"`
 	Comment string `json:"comment,omitempty"`
+
+	// Attachment hashes of files uploaded to the project
+	Attachments []string `json:"attachments,omitempty"`
+
+	// Parameter set of a result of a parameterized case
+	Param Params `json:"param,omitempty"`
 }
 
 // BulkRequest represents the bulk results request
@@ -36,6 +47,12 @@ type BulkResponse struct {
 
 // PostBulkResults posts results in chunks with retries
 func PostBulkResults(c *api.Client, project string, runID int, items []BulkItem, chunkSize int) error {
+	return PostBulkResultsWithSpan(c, project, runID, items, chunkSize, nil)
+}
+
+// PostBulkResultsWithSpan posts results in chunks with retries, recording a
+// tracing span per chunk under the given parent span
+func PostBulkResultsWithSpan(c *api.Client, project string, runID int, items []BulkItem, chunkSize int, parent *tracing.Span) error {
 	if len(items) == 0 {
 		fmt.Println("No items to post")
 		return nil
@@ -45,6 +62,10 @@ func PostBulkResults(c *api.Client, project string, runID int, items []BulkItem,
 		chunkSize = 200
 	}
 
+	if err := ValidatePayloads(items, chunkSize, c.MaxPayloadBytes); err != nil {
+		return err
+	}
+
 	totalChunks := (len(items) + chunkSize - 1) / chunkSize
 	fmt.Printf("Posting %d items in %d chunks of %d\n", len(items), totalChunks, chunkSize)
 
@@ -59,24 +80,61 @@ func PostBulkResults(c *api.Client, project string, runID int, items []BulkItem,
 
 		fmt.Printf("Posting chunk %d/%d (%d items)\n", chunkNum, totalChunks, len(chunk))
 
+		span := tracing.Start("post.chunk", parent)
+		span.SetAttr("qase.project", project)
+		span.SetAttr("qase.run_id", runID)
+		span.SetAttr("chunk.number", chunkNum)
+		span.SetAttr("chunk.size", len(chunk))
+
 		if err := postChunkWithRetry(c, project, runID, chunk, chunkNum, totalChunks); err != nil {
-			return fmt.Errorf("failed to post chunk %d: %w", chunkNum, err)
+			span.SetError(err)
+			span.End()
+			return &ChunkError{Number: chunkNum, Total: totalChunks, Items: len(chunk), Posted: i, Err: err}
 		}
+		span.End()
 	}
 
 	fmt.Println("All chunks posted successfully")
 	return nil
 }
 
+// ChunkError is returned when a chunk could not be posted; the chunks before
+// it were posted
+type ChunkError struct {
+	Number int // 1-based chunk number
+	Total  int
+	Items  int // results in the chunk
+	Posted int // results posted before the chunk
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("failed to post chunk %d: %v", e.Number, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
 // postChunkWithRetry posts a single chunk with exponential backoff retries
 func postChunkWithRetry(c *api.Client, project string, runID int, chunk []BulkItem, chunkNum, totalChunks int) error {
 	backoffDelays := []time.Duration{200 * time.Millisecond, 1 * time.Second, 3 * time.Second, 5 * time.Second}
 
+	var lastErr error
 	for attempt := 0; attempt < len(backoffDelays); attempt++ {
+		// Pause while an operator has paused the migration or the target
+		// workspace circuit breaker is open
+		if c.Gate != nil {
+			c.Gate.Wait()
+		}
+		c.Breaker.Wait()
+
 		err := postChunk(c, project, runID, chunk)
+		c.Breaker.Record(err == nil || !isTransientError(err))
 		if err == nil {
 			return nil
 		}
+		lastErr = err
 
 		// Check if it's a retryable error
 		if !isRetryableError(err) {
@@ -84,13 +142,16 @@ func postChunkWithRetry(c *api.Client, project string, runID int, chunk []BulkIt
 		}
 
 		if attempt < len(backoffDelays)-1 {
+			if !c.RetryBudget.Take() {
+				return fmt.Errorf("chunk %d/%d failed and retry budget is exhausted: %w", chunkNum, totalChunks, err)
+			}
 			delay := backoffDelays[attempt]
 			fmt.Printf("Chunk %d/%d attempt %d failed, retrying in %v: %v\n", chunkNum, totalChunks, attempt+1, delay, err)
 			time.Sleep(delay)
 		}
 	}
 
-	return fmt.Errorf("chunk %d/%d failed after %d attempts", chunkNum, totalChunks, len(backoffDelays))
+	return fmt.Errorf("chunk %d/%d failed after %d attempts: %w", chunkNum, totalChunks, len(backoffDelays), lastErr)
 }
 
 // postChunk posts a single chunk of results
@@ -102,7 +163,11 @@ func postChunk(c *api.Client, project string, runID int, chunk []BulkItem) error
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Try v2 API first
+	// Try v2 API first, unless probing found it unsupported
+	v2, probed := c.UseV2Results()
+	if !v2 {
+		return postChunkV1(c, project, runID, chunk)
+	}
 	path := fmt.Sprintf("/result/%s/%d/results", project, runID)
 	req, err := c.NewV2Request("POST", path, body)
 	if err != nil {
@@ -120,6 +185,11 @@ func postChunk(c *api.Client, project string, runID int, chunk []BulkItem) error
 		return fmt.Errorf("failed to read v2 response: %w", err)
 	}
 
+	// Probed workspaces support v2, so a failure is the chunk's own
+	if probed && resp.StatusCode != http.StatusOK {
+		return newChunkHTTPError(resp.StatusCode, body, chunk)
+	}
+
 	// If v2 fails, fallback to v1
 	if resp.StatusCode != http.StatusOK {
 		fmt.Printf("v2 API failed with status %d, falling back to v1: %s\n", resp.StatusCode, string(body))
@@ -130,7 +200,7 @@ func postChunk(c *api.Client, project string, runID int, chunk []BulkItem) error
 	fmt.Printf("v2 API response: %s\n", string(body))
 
 	var response BulkResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := decodeJSON(body, &response); err != nil {
 		fmt.Printf("v2 API response parsing failed, falling back to v1: %v\n", err)
 		return postChunkV1(c, project, runID, chunk)
 	}
@@ -171,11 +241,11 @@ func postChunkV1(c *api.Client, project string, runID int, chunk []BulkItem) err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("v1 API request failed with status %d: %s", resp.StatusCode, string(body))
+		return newChunkHTTPError(resp.StatusCode, body, chunk)
 	}
 
 	var response BulkResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := decodeJSON(body, &response); err != nil {
 		return fmt.Errorf("failed to parse v1 response: %w", err)
 	}
 
@@ -187,21 +257,113 @@ func postChunkV1(c *api.Client, project string, runID int, chunk []BulkItem) err
 	return nil
 }
 
+// DeletedCasesError is returned when the target rejected a chunk for results
+// of cases that no longer exist, such as cases deleted after the mapping was
+// built
+type DeletedCasesError struct {
+	CaseIDs []int // target cases of the rejected results
+	Err     error
+}
+
+func (e *DeletedCasesError) Error() string {
+	return fmt.Sprintf("target cases %v do not exist: %v", e.CaseIDs, e.Err)
+}
+
+func (e *DeletedCasesError) Unwrap() error {
+	return e.Err
+}
+
+// validationResponse is the body of a request rejected by validation, with
+// errorFields such as {"field": "results.3.case_id", "error": "..."}
+type validationResponse struct {
+	ErrorFields []struct {
+		Field string `json:"field"`
+		Error string `json:"error"`
+	} `json:"errorFields"`
+}
+
+// newChunkHTTPError creates the error for a rejected chunk, a
+// DeletedCasesError when validation failed on the case IDs of its results
+func newChunkHTTPError(statusCode int, body []byte, chunk []BulkItem) error {
+	err := newHTTPError(statusCode, body)
+	if statusCode != http.StatusBadRequest && statusCode != http.StatusUnprocessableEntity {
+		return err
+	}
+	var response validationResponse
+	if decodeJSON(body, &response) != nil {
+		return err
+	}
+
+	seen := make(map[int]bool)
+	var caseIDs []int
+	for _, field := range response.ErrorFields {
+		parts := strings.Split(field.Field, ".")
+		if len(parts) != 3 || parts[0] != "results" || parts[2] != "case_id" {
+			continue
+		}
+		i, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || i < 0 || i >= len(chunk) {
+			continue
+		}
+		if id := chunk[i].CaseID; !seen[id] {
+			seen[id] = true
+			caseIDs = append(caseIDs, id)
+		}
+	}
+	if len(caseIDs) == 0 {
+		return err
+	}
+	return &DeletedCasesError{CaseIDs: caseIDs, Err: err}
+}
+
+// IsNotFound reports whether err is a 404 response; for a result post it
+// means the target run no longer exists
+func IsNotFound(err error) bool {
+	var httpErr *httpError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
 // isRetryableError checks if an error is retryable
 func isRetryableError(err error) bool {
 	// Check for HTTP 429 (rate limit) or 5xx errors
-	if httpErr, ok := err.(*httpError); ok {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
 		return httpErr.StatusCode == 429 || (httpErr.StatusCode >= 500 && httpErr.StatusCode < 600)
 	}
 	return false
 }
 
+// isTransientError checks if an error indicates the workspace is unavailable
+// (rate limiting, 5xx, or network failures) rather than a bad request
+func isTransientError(err error) bool {
+	if isRetryableError(err) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
 // httpError represents an HTTP error
 type httpError struct {
 	StatusCode int
 	Message    string
 }
 
+// newHTTPError creates an httpError from a non-OK response
+func newHTTPError(statusCode int, body []byte) error {
+	return &httpError{StatusCode: statusCode, Message: string(body)}
+}
+
 func (e *httpError) Error() string {
-	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// HTTPStatus returns the response status code, used for error classification
+func (e *httpError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// ResponseBody returns the body of the failed response
+func (e *httpError) ResponseBody() string {
+	return e.Message
 }
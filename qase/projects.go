@@ -0,0 +1,82 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Project represents a Qase project visible to a token
+type Project struct {
+	Title  string        `json:"title"`
+	Code   string        `json:"code"`
+	Counts ProjectCounts `json:"counts"`
+}
+
+// ProjectCounts are the entity counts reported with a project
+type ProjectCounts struct {
+	Cases      int `json:"cases"`
+	Suites     int `json:"suites"`
+	Milestones int `json:"milestones"`
+	Runs       struct {
+		Total  int `json:"total"`
+		Active int `json:"active"`
+	} `json:"runs"`
+}
+
+// ProjectListResponse represents the API response for project list
+type ProjectListResponse struct {
+	Status bool `json:"status"`
+	Result struct {
+		Total    int       `json:"total"`
+		Entities []Project `json:"entities"`
+	} `json:"result"`
+}
+
+// GetProjects fetches all projects of the token's workspace with pagination
+func GetProjects(c *api.Client) ([]Project, error) {
+	var projects []Project
+	offset := 0
+	limit := 100
+
+	for {
+		u := fmt.Sprintf("/project?limit=%d&offset=%d", limit, offset)
+
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
+		var response ProjectListResponse
+		if err := decodeList(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		projects = append(projects, response.Result.Entities...)
+
+		if len(response.Result.Entities) < limit || len(projects) >= response.Result.Total {
+			break
+		}
+
+		offset += limit
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,36 @@
+package qase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OverrideEnv is the variable that allows writing to a protected project
+const OverrideEnv = "QASE_I_KNOW_WHAT_IM_DOING"
+
+// CheckWritable refuses writes to a project on the protected list (e.g.
+// production) unless the operator explicitly overrides it
+func CheckWritable(project string, protected []string, override bool) error {
+	for _, code := range protected {
+		if !strings.EqualFold(strings.TrimSpace(code), project) {
+			continue
+		}
+		if override {
+			fmt.Printf("Warning: Writing to protected project %s (%s=true)\n", project, OverrideEnv)
+			return nil
+		}
+		return fmt.Errorf("target project %s is protected (QASE_PROTECTED_PROJECTS); set %s=true to write to it", project, OverrideEnv)
+	}
+	return nil
+}
+
+// ParseProjectList splits a comma-separated list of project codes
+func ParseProjectList(value string) []string {
+	var codes []string
+	for _, code := range strings.Split(value, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,59 @@
+package qase
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// reconcileCount compares the entities a listing accumulated with the total
+// the API reported for it (negative if it reported none) and warns when they
+// disagree, an early sign of a pagination bug or of data changing while it
+// was listed. The outcome is recorded on the client for the summary.
+func reconcileCount(c *api.Client, entity, scope string, fetched, reported int) {
+	if reported < 0 {
+		return
+	}
+	mismatch := ""
+	if fetched != reported {
+		mismatch = fmt.Sprintf("%s of %s: fetched %d, API reported %d", entity, scope, fetched, reported)
+		fmt.Printf("Warning: fetched %d %s of %s but the API reported a total of %d (pagination problem or data changed during the listing)\n",
+			fetched, entity, scope, reported)
+	}
+	c.RecordCount(mismatch)
+}
+
+// listTotal returns the number of entities a filtered listing should yield:
+// the filtered count when the API reports one, otherwise the total, or -1
+// when the response carries neither
+func listTotal(total, filtered *int) int {
+	switch {
+	case filtered != nil:
+		return *filtered
+	case total != nil:
+		return *total
+	}
+	return -1
+}
+
+// listDone reports whether a listing that requested limit entities per page
+// is complete after a page of n entities ending at end: at the reported
+// total, or without one at a short or empty page
+func listDone(n, end, limit, total int) bool {
+	if n == 0 {
+		return true
+	}
+	if total < 0 {
+		return n < limit
+	}
+	return end >= total
+}
+
+// formatTotal formats a reported total for progress output, "?" when unknown
+func formatTotal(total int) string {
+	if total < 0 {
+		return "?"
+	}
+	return strconv.Itoa(total)
+}
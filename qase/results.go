@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/utils"
 )
 
 // Result represents a test result
@@ -24,6 +25,75 @@ type Result struct {
 	IsAPIResult bool   `json:"is_api_result"`
 	TimeSpentMs int    `json:"time_spent_ms"`
 	EndTime     string `json:"end_time"`
+
+	// Param is the parameter set of a result of a parameterized case
+	Param Params `json:"param,omitempty"`
+
+	// Fields and Attachments are filled from the v2 result API; v1 returns
+	// attachments only
+	Fields      map[string]string `json:"fields,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+
+	// EndedAt is EndTime parsed with utils.ParseDateFlexible (zero if absent or unparseable)
+	EndedAt time.Time `json:"-"`
+}
+
+// Params maps parameter names to the values a result ran with
+type Params map[string]string
+
+// UnmarshalJSON decodes params, accepting the empty array the v1 API returns
+// for results without parameters and a list of {title, value} pairs. Other
+// lists are left out and reported like any unexpected shape, so one result
+// cannot fail its page.
+func (p *Params) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		var list []struct {
+			Title *string `json:"title"`
+			Value *string `json:"value"`
+		}
+		*p = nil
+		if err := json.Unmarshal(data, &list); err == nil {
+			params := make(Params, len(list))
+			for _, param := range list {
+				if param.Title == nil || param.Value == nil {
+					params = nil
+					break
+				}
+				params[*param.Title] = *param.Value
+			}
+			if params != nil {
+				if len(params) > 0 {
+					*p = params
+				}
+				return nil
+			}
+		}
+		reportShapes([]string{"Result.param: expected an object, got a list"})
+		return nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*p = values
+	return nil
+}
+
+// UnmarshalJSON decodes a result and parses its end time
+func (r *Result) UnmarshalJSON(data []byte) error {
+	type rawResult Result
+	var raw rawResult
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = Result(raw)
+
+	if r.EndTime != "" {
+		if endedAt, err := utils.ParseDateFlexible(r.EndTime); err == nil {
+			r.EndedAt = endedAt
+		}
+	}
+	return nil
 }
 
 // Step represents a test step
@@ -38,67 +108,69 @@ type Step struct {
 type ResultListResponse struct {
 	Status bool `json:"status"`
 	Result struct {
-		Total    int      `json:"total"`
+		Total    *int     `json:"total"`
+		Filtered *int     `json:"filtered"` // entities matching the filters
 		Entities []Result `json:"entities"`
 	} `json:"result"`
 }
 
-// GetRunResults fetches all results for a specific run with pagination
+// GetRunResults fetches all results for a specific run with pagination,
+// reading from the v2 API when available
 func GetRunResults(c *api.Client, project string, runID int) ([]Result, error) {
 	var allResults []Result
-	page := 1
+	seen := make(map[string]bool)
+	lister := newResultLister(c, project)
+	offset := 0
 	limit := 100
 
-	for {
-		// Build URL with pagination and run filter
-		u := fmt.Sprintf("/result/%s?limit=%d&page=%d&run_id[]=%d", project, limit, page, runID)
-
-		req, err := c.NewRequest("GET", u, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.HTTP.Do(req)
+	for page := 1; ; page++ {
+		// Pagination and run filter
+		results, err := lister.page(fmt.Sprintf("limit=%d&offset=%d&run_id[]=%d", limit, offset, runID))
 		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
+			return nil, err
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		// Add results not seen on an earlier page
+		newResults := 0
+		for _, result := range results {
+			if result.Hash != "" {
+				if seen[result.Hash] {
+					continue
+				}
+				seen[result.Hash] = true
+			}
+			allResults = append(allResults, result)
+			newResults++
 		}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
+		fmt.Printf("Fetched page %d (offset %d): %d results, %d new (total so far: %d/%s)\n",
+			page, offset, len(results), newResults, len(allResults), formatTotal(lister.total))
 
-		var response ResultListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
+		// A page of only known results while some are missing means the
+		// offset was ignored; the listing would repeat forever
+		if newResults == 0 && !listDone(len(results), len(allResults), limit, lister.total) {
+			return nil, fmt.Errorf("page at offset %d of run %d repeated %d already fetched results (%d of %s fetched)",
+				offset, runID, len(results), len(allResults), formatTotal(lister.total))
 		}
 
-		// Add results to slice
-		allResults = append(allResults, response.Result.Entities...)
-
-		fmt.Printf("Fetched page %d: %d results (total so far: %d)\n", page, len(response.Result.Entities), len(allResults))
-
 		// Check if we've fetched all results
-		if len(response.Result.Entities) < limit {
+		if listDone(len(results), offset+len(results), limit, lister.total) {
 			break
 		}
 
-		page++
+		offset += len(results)
 	}
 
+	reconcileCount(c, "results", fmt.Sprintf("run %d", runID), len(allResults), lister.total)
 	fmt.Printf("Total results fetched: %d\n", len(allResults))
 	return allResults, nil
 }
 
-// GetResultsAfterDate fetches all results after a specific date using the bulk API
+// GetResultsAfterDate fetches all results after a specific date using the bulk API,
+// reading from the v2 API when available
 func GetResultsAfterDate(c *api.Client, project string, afterDate time.Time) ([]Result, error) {
 	var allResults []Result
+	lister := newResultLister(c, project)
 	offset := 0
 	limit := 100
 
@@ -107,51 +179,30 @@ func GetResultsAfterDate(c *api.Client, project string, afterDate time.Time) ([]
 	pageCount := 0
 	for {
 		pageCount++
-		// Build URL with pagination and date filter using from_end_time parameter
-		u := fmt.Sprintf("/result/%s?limit=%d&offset=%d&from_end_time=%s",
-			project, limit, offset, url.QueryEscape(afterDate.Format("2006-01-02 00:00:00")))
-
-		fmt.Printf("API Call %d: %s\n", pageCount, u)
+		// Pagination and date filter using from_end_time parameter
+		query := fmt.Sprintf("limit=%d&offset=%d&from_end_time=%s",
+			limit, offset, url.QueryEscape(endTimeCutoff(afterDate).Format("2006-01-02 15:04:05")))
 
-		req, err := c.NewRequest("GET", u, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
+		fmt.Printf("API Call %d: /result/%s?%s\n", pageCount, project, query)
 
 		start := time.Now()
-		resp, err := c.HTTP.Do(req)
+		results, err := lister.page(query)
 		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
+			return nil, err
 		}
-		defer resp.Body.Close()
 
 		apiDuration := time.Since(start)
 		fmt.Printf("API call %d completed in %v\n", pageCount, apiDuration)
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		var response ResultListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
-		}
-
 		// Add results to slice
-		allResults = append(allResults, response.Result.Entities...)
+		allResults = append(allResults, results...)
 
 		fmt.Printf("Page %d: %d results (total: %d) - API took %v\n",
-			pageCount, len(response.Result.Entities), len(allResults), apiDuration)
+			pageCount, len(results), len(allResults), apiDuration)
 
 		// Check if we've fetched all results
-		if len(response.Result.Entities) < limit {
-			fmt.Printf("Reached end of results (got %d < limit %d)\n", len(response.Result.Entities), limit)
+		if len(results) < limit {
+			fmt.Printf("Reached end of results (got %d < limit %d)\n", len(results), limit)
 			break
 		}
 
@@ -161,6 +212,7 @@ func GetResultsAfterDate(c *api.Client, project string, afterDate time.Time) ([]
 		time.Sleep(200 * time.Millisecond)
 	}
 
+	reconcileCount(c, "results", fmt.Sprintf("project %s after %s", project, afterDate.Format("2006-01-02")), len(allResults), lister.total)
 	fmt.Printf("Total results fetched after %s: %d (in %d API calls)\n", afterDate.Format("2006-01-02"), len(allResults), pageCount)
 	return allResults, nil
 }
@@ -170,6 +222,7 @@ func GetResultsForRuns(c *api.Client, project string, runIDs []int) ([]Result, e
 	var allResults []Result
 	offset := 0
 	limit := 100
+	total := -1
 
 	fmt.Printf("Fetching results for %d runs in project %s...\n", len(runIDs), project)
 
@@ -206,7 +259,7 @@ func GetResultsForRuns(c *api.Client, project string, runIDs []int) ([]Result, e
 
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+			return nil, newHTTPError(resp.StatusCode, body)
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -215,12 +268,13 @@ func GetResultsForRuns(c *api.Client, project string, runIDs []int) ([]Result, e
 		}
 
 		var response ResultListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
+		if err := decodeList(body, &response); err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
 		// Add results to slice
 		allResults = append(allResults, response.Result.Entities...)
+		total = listTotal(response.Result.Total, response.Result.Filtered)
 
 		fmt.Printf("Page %d: %d results (total: %d) - API took %v\n",
 			pageCount, len(response.Result.Entities), len(allResults), apiDuration)
@@ -237,6 +291,7 @@ func GetResultsForRuns(c *api.Client, project string, runIDs []int) ([]Result, e
 		time.Sleep(100 * time.Millisecond)
 	}
 
+	reconcileCount(c, "results", fmt.Sprintf("%d runs", len(runIDs)), len(allResults), total)
 	fmt.Printf("Total results fetched for %d runs: %d (in %d API calls)\n", len(runIDs), len(allResults), pageCount)
 	return allResults, nil
 }
@@ -245,7 +300,7 @@ func GetResultsForRuns(c *api.Client, project string, runIDs []int) ([]Result, e
 // This is a lightweight check that only fetches the first page
 func CheckRunHasResults(c *api.Client, project string, runID int) (bool, error) {
 	// Build URL to get just the first page of results for this run
-	u := fmt.Sprintf("/result/%s?limit=1&page=1&run_id[]=%d", project, 1, runID)
+	u := fmt.Sprintf("/result/%s?limit=1&offset=0&run_id[]=%d", project, runID)
 
 	req, err := c.NewRequest("GET", u, nil)
 	if err != nil {
@@ -260,7 +315,7 @@ func CheckRunHasResults(c *api.Client, project string, runID int) (bool, error)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return false, newHTTPError(resp.StatusCode, body)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -269,74 +324,97 @@ func CheckRunHasResults(c *api.Client, project string, runID int) (bool, error)
 	}
 
 	var response ResultListResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := decodeList(body, &response); err != nil {
 		return false, fmt.Errorf("failed to parse response: %w", err)
 	}
-	
+
 	return len(response.Result.Entities) > 0, nil
 }
 
-// FilterNewResults filters out results that already exist in the target run
-// This is an optimized version that only fetches case IDs, not full results
+// FilterNewResults filters out results that already exist in the target run.
+// Results carrying a source hash marker are matched by hash; results posted
+// without a marker are matched by case ID.
 func FilterNewResults(c *api.Client, project string, runID int, newResults []BulkItem) ([]BulkItem, error) {
-	// Get existing case IDs for this run (optimized query)
-	existingCaseIDs, err := getExistingCaseIDs(c, project, runID)
+	existing, err := getExistingResultKeys(c, project, runID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get existing case IDs: %w", err)
+		return nil, fmt.Errorf("failed to get existing results: %w", err)
 	}
-	
+
 	// Filter out results that already exist
 	var filteredResults []BulkItem
 	for _, result := range newResults {
-		if !existingCaseIDs[result.CaseID] {
-			filteredResults = append(filteredResults, result)
+		if existing.contains(result) {
+			continue
 		}
+		filteredResults = append(filteredResults, result)
 	}
-	
+
 	fmt.Printf("Filtered results: %d new, %d already exist\n", len(filteredResults), len(newResults)-len(filteredResults))
 	return filteredResults, nil
 }
 
-// getExistingCaseIDs efficiently fetches only case IDs from existing results
-func getExistingCaseIDs(c *api.Client, project string, runID int) (map[int]bool, error) {
-	existingCaseIDs := make(map[int]bool)
+// existingResultKeys identifies the results already present in a run
+type existingResultKeys struct {
+	sourceHashes    map[string]bool
+	unmarkedCaseIDs map[int]bool
+}
+
+// contains reports whether an item was already posted to the run
+func (k existingResultKeys) contains(item BulkItem) bool {
+	if hash := SourceHash(item.Comment); hash != "" && k.sourceHashes[hash] {
+		return true
+	}
+	return k.unmarkedCaseIDs[item.CaseID]
+}
+
+// getExistingResultKeys fetches the source hashes and case IDs of existing results
+func getExistingResultKeys(c *api.Client, project string, runID int) (existingResultKeys, error) {
+	keys := existingResultKeys{
+		sourceHashes:    make(map[string]bool),
+		unmarkedCaseIDs: make(map[int]bool),
+	}
 	offset := 0
 	limit := 100
+	fetched, total := 0, -1
 
 	for {
-		// Build URL with pagination and run filter, only fetch case_id
+		// Build URL with pagination and run filter
 		u := fmt.Sprintf("/result/%s?limit=%d&offset=%d&run_id[]=%d", project, limit, offset, runID)
 
 		req, err := c.NewRequest("GET", u, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+			return keys, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		resp, err := c.HTTP.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+			return keys, fmt.Errorf("failed to make request: %w", err)
 		}
 
 		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
+			return keys, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return keys, newHTTPError(resp.StatusCode, body)
 		}
 
 		var response ResultListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
+		if err := decodeList(body, &response); err != nil {
+			return keys, fmt.Errorf("failed to parse response: %w", err)
 		}
 
-		// Add case IDs to map
 		for _, result := range response.Result.Entities {
-			existingCaseIDs[result.CaseID] = true
+			if hash := SourceHash(result.Comment); hash != "" {
+				keys.sourceHashes[hash] = true
+			} else {
+				keys.unmarkedCaseIDs[result.CaseID] = true
+			}
 		}
+		fetched += len(response.Result.Entities)
+		total = listTotal(response.Result.Total, response.Result.Filtered)
 
 		// Check if we've fetched all results
 		if len(response.Result.Entities) < limit {
@@ -346,5 +424,6 @@ func getExistingCaseIDs(c *api.Client, project string, runID int) (map[int]bool,
 		offset += limit
 	}
 
-	return existingCaseIDs, nil
+	reconcileCount(c, "results", fmt.Sprintf("target run %d", runID), fetched, total)
+	return keys, nil
 }
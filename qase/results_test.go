@@ -0,0 +1,92 @@
+package qase_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+func TestParamsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want qase.Params
+	}{
+		{"object", `{"browser":"firefox"}`, qase.Params{"browser": "firefox"}},
+		{"empty list", `[]`, nil},
+		{"title and value list", `[{"title":"browser","value":"firefox"}]`, qase.Params{"browser": "firefox"}},
+		{"unexpected list", `["firefox",1]`, nil},
+		{"list of other objects", `[{"name":"browser"}]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result qase.Result
+			if err := json.Unmarshal([]byte(`{"case_id":1,"status":"passed","param":`+tt.json+`}`), &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(result.Param, tt.want) {
+				t.Errorf("got params %v, want %v", result.Param, tt.want)
+			}
+			if result.CaseID != 1 {
+				t.Errorf("got case %d, want 1", result.CaseID)
+			}
+		})
+	}
+}
+
+// serveRunResults serves n results of run 1 in project PRJ with the given
+// page fault
+func serveRunResults(t *testing.T, n int, fault string) *api.Client {
+	t.Helper()
+	server := mockserver.New()
+	server.AddRun("PRJ", qase.Run{ID: 1, Title: "Run 1"})
+	results := make([]qase.Result, n)
+	for i := range results {
+		results[i] = qase.Result{Hash: fmt.Sprintf("hash-%d", i+1), RunID: 1, CaseID: i + 1, Status: "passed"}
+	}
+	server.AddResults("PRJ", results...)
+	server.SetPageFault(fault)
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return api.NewClient(ts.URL, "test")
+}
+
+func TestGetRunResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results int
+		fault   string
+		wantErr bool
+	}{
+		{"last page short", 250, "", false},
+		{"last page exactly at the limit", 200, "", false},
+		{"capped page size", 250, mockserver.PageShort, false},
+		{"total missing", 250, mockserver.PageNoTotal, false},
+		{"offset ignored", 250, mockserver.PageIgnoreOffset, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := serveRunResults(t, tt.results, tt.fault)
+			results, err := qase.GetRunResults(client, "PRJ", 1)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d results, want an error for a repeated page", len(results))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetRunResults: %v", err)
+			}
+			if len(results) != tt.results {
+				t.Errorf("got %d results, want %d", len(results), tt.results)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/utils"
+)
+
+// Attachment is a file attached to a result
+type Attachment struct {
+	Hash     string `json:"hash,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Mime     string `json:"mime,omitempty"`
+	Size     int    `json:"size,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ResultV2 is a result as returned by the v2 result API
+type ResultV2 struct {
+	ID          int               `json:"id,omitempty"`
+	Hash        string            `json:"hash"`
+	RunID       int               `json:"run_id"`
+	CaseID      int               `json:"case_id"`
+	Title       string            `json:"title,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Execution   ExecutionV2       `json:"execution"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+	Params      Params            `json:"params,omitempty"`
+}
+
+// ExecutionV2 holds the status and timing of a v2 result. Times are Unix
+// seconds and duration is in milliseconds.
+type ExecutionV2 struct {
+	Status    string  `json:"status"`
+	StartTime float64 `json:"start_time,omitempty"`
+	EndTime   float64 `json:"end_time,omitempty"`
+	Duration  int     `json:"duration,omitempty"`
+}
+
+// ResultV2ListResponse represents the v2 API response for result list
+type ResultV2ListResponse struct {
+	Status bool `json:"status"`
+	Result struct {
+		Total    *int       `json:"total"`
+		Filtered *int       `json:"filtered"` // entities matching the filters
+		Entities []ResultV2 `json:"entities"`
+	} `json:"result"`
+}
+
+// Result converts a v2 result to the common Result model
+func (r ResultV2) Result() Result {
+	result := Result{
+		Hash:        r.Hash,
+		Comment:     r.Message,
+		RunID:       r.RunID,
+		CaseID:      r.CaseID,
+		Status:      r.Execution.Status,
+		TimeSpentMs: r.Execution.Duration,
+		Fields:      r.Fields,
+		Attachments: r.Attachments,
+		Param:       r.Params,
+	}
+	if r.Execution.Duration > 0 {
+		seconds := r.Execution.Duration / 1000
+		result.Time = &seconds
+	}
+	if r.Execution.EndTime > 0 {
+		result.EndedAt = time.UnixMilli(int64(r.Execution.EndTime * 1000)).UTC()
+		result.EndTime = result.EndedAt.Format("2006-01-02 15:04:05")
+	}
+	return result
+}
+
+// NewResultV2 converts a Result to its v2 representation
+func NewResultV2(r Result) ResultV2 {
+	v2 := ResultV2{
+		Hash:        r.Hash,
+		RunID:       r.RunID,
+		CaseID:      r.CaseID,
+		Message:     r.Comment,
+		Execution:   ExecutionV2{Status: r.Status, Duration: r.TimeSpentMs},
+		Fields:      r.Fields,
+		Attachments: r.Attachments,
+		Params:      r.Param,
+	}
+	if v2.Execution.Duration == 0 && r.Time != nil {
+		v2.Execution.Duration = *r.Time * 1000
+	}
+	endedAt := r.EndedAt
+	if endedAt.IsZero() && r.EndTime != "" {
+		endedAt, _ = utils.ParseDateFlexible(r.EndTime)
+	}
+	if !endedAt.IsZero() {
+		v2.Execution.EndTime = float64(endedAt.UnixMilli()) / 1000
+	}
+	return v2
+}
+
+// resultLister fetches result list pages, preferring the v2 API for its
+// richer fields. On clients without probed capabilities it falls back to v1
+// for the rest of the listing once v2 is unavailable.
+type resultLister struct {
+	c       *api.Client
+	project string
+	v1Only  bool
+	probed  bool
+	total   int // entities the last page reported for the listing
+}
+
+// newResultLister creates a lister using the client's probed capabilities
+func newResultLister(c *api.Client, project string) *resultLister {
+	v2, probed := c.UseV2Results()
+	return &resultLister{c: c, project: project, v1Only: !v2, probed: probed, total: -1}
+}
+
+// page fetches one page of results; query holds the pagination and filter
+// parameters shared by both API versions
+func (l *resultLister) page(query string) ([]Result, error) {
+	path := fmt.Sprintf("/result/%s?%s", l.project, query)
+
+	if !l.v1Only {
+		results, err := l.pageV2(path)
+		if err == nil || l.probed {
+			return results, err
+		}
+		fmt.Printf("v2 result API unavailable, falling back to v1: %v\n", err)
+		l.v1Only = true
+	}
+
+	req, err := l.c.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := l.c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response ResultListResponse
+	if err := decodeList(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	l.total = listTotal(response.Result.Total, response.Result.Filtered)
+	return response.Result.Entities, nil
+}
+
+// pageV2 fetches one page of results from the v2 API
+func (l *resultLister) pageV2(path string) ([]Result, error) {
+	req, err := l.c.NewV2Request("GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create v2 request: %w", err)
+	}
+
+	resp, err := l.c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make v2 request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read v2 response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response ResultV2ListResponse
+	if err := decodeList(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse v2 response: %w", err)
+	}
+	if !response.Status {
+		return nil, fmt.Errorf("v2 API returned status false")
+	}
+	l.total = listTotal(response.Result.Total, response.Result.Filtered)
+
+	results := make([]Result, 0, len(response.Result.Entities))
+	for _, entity := range response.Result.Entities {
+		results = append(results, entity.Result())
+	}
+	return results, nil
+}
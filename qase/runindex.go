@@ -0,0 +1,155 @@
+package qase
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// RunIndex finds existing target runs by source run marker, falling back to
+// the title for runs migrated before markers were recorded. It is built from
+// one listing of the project's runs instead of a full listing per run, and is
+// shared by all workers: runs they create are added to it, and concurrent
+// requests for the same run wait for a single creation.
+type RunIndex struct {
+	mu       sync.RWMutex
+	byMarker map[string]*Run
+	byTitle  map[string]*Run
+	creating map[string]*pendingRun
+}
+
+// pendingRun is a run creation other workers can wait for
+type pendingRun struct {
+	done chan struct{}
+	run  *Run
+	err  error
+}
+
+// BuildRunIndex lists the runs of a project once and indexes them
+func BuildRunIndex(c *api.Client, project string) (*RunIndex, error) {
+	runs, err := GetRuns(c, project, RunListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target runs: %w", err)
+	}
+
+	idx := &RunIndex{
+		byMarker: make(map[string]*Run),
+		byTitle:  make(map[string]*Run),
+		creating: make(map[string]*pendingRun),
+	}
+	markers := 0
+	for i := range runs {
+		run := &runs[i]
+		if run.Description != nil {
+			if marker := RunMarker(*run.Description); marker != "" {
+				if _, exists := idx.byMarker[marker]; !exists {
+					idx.byMarker[marker] = run
+					markers++
+				}
+			}
+		}
+		if _, exists := idx.byTitle[run.Title]; !exists {
+			idx.byTitle[run.Title] = run
+		}
+	}
+
+	fmt.Printf("Indexed %d target runs (%d with source run markers)\n", len(runs), markers)
+	return idx, nil
+}
+
+// Find returns the run migrated from marker, or else a run titled title
+// without a marker of its own, or nil
+func (idx *RunIndex) Find(marker, title string) *Run {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.find(marker, title)
+}
+
+func (idx *RunIndex) find(marker, title string) *Run {
+	if run, exists := idx.byMarker[marker]; exists && marker != "" {
+		return run
+	}
+	if run, exists := idx.byTitle[title]; exists {
+		if run.Description == nil || RunMarker(*run.Description) == "" {
+			return run
+		}
+	}
+	return nil
+}
+
+// Add records a run created during the migration
+func (idx *RunIndex) Add(marker string, run *Run) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.add(marker, run)
+}
+
+func (idx *RunIndex) add(marker string, run *Run) {
+	if marker != "" {
+		idx.byMarker[marker] = run
+	}
+	if _, exists := idx.byTitle[run.Title]; !exists {
+		idx.byTitle[run.Title] = run
+	}
+}
+
+// Forget drops a run deleted in the target, so the next request for it
+// creates a new run
+func (idx *RunIndex) Forget(runID int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for marker, run := range idx.byMarker {
+		if run.ID == runID {
+			delete(idx.byMarker, marker)
+		}
+	}
+	for title, run := range idx.byTitle {
+		if run.ID == runID {
+			delete(idx.byTitle, title)
+		}
+	}
+}
+
+// CreateOrGetIndexedRun returns the indexed run for marker or title, or
+// creates one with the marker recorded in its description and the given run
+// options. Workers asking for a run that is being created wait for it instead
+// of creating a duplicate.
+func CreateOrGetIndexedRun(c *api.Client, idx *RunIndex, project, marker, title, description string, opts RunOptions) (*Run, error) {
+	key := marker
+	if key == "" {
+		key = "title:" + title
+	}
+
+	idx.mu.Lock()
+	if run := idx.find(marker, title); run != nil {
+		idx.mu.Unlock()
+		fmt.Printf("Found existing run: %s (ID: %d)\n", run.Title, run.ID)
+		return run, nil
+	}
+	if pending, exists := idx.creating[key]; exists {
+		idx.mu.Unlock()
+		<-pending.done
+		if pending.err != nil {
+			return nil, pending.err
+		}
+		fmt.Printf("Found run created by another worker: %s (ID: %d)\n", pending.run.Title, pending.run.ID)
+		return pending.run, nil
+	}
+	pending := &pendingRun{done: make(chan struct{})}
+	idx.creating[key] = pending
+	idx.mu.Unlock()
+
+	fmt.Printf("Creating new run: %s\n", title)
+	pending.run, pending.err = CreateRunWithOptions(c, project, title, WithRunMarker(description, marker), opts)
+
+	idx.mu.Lock()
+	delete(idx.creating, key)
+	if pending.err == nil {
+		idx.add(marker, pending.run)
+	}
+	idx.mu.Unlock()
+	close(pending.done)
+
+	return pending.run, pending.err
+}
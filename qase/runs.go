@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/adrianeortiz/clone-run-multi-ws/api"
@@ -29,6 +32,25 @@ type Run struct {
 	CustomFields   []interface{}           `json:"custom_fields"`
 	Tags           []interface{}           `json:"tags"`
 	Configurations []interface{}           `json:"configurations"`
+	Cases          []int                   `json:"cases,omitempty"`
+	PlanID         *int                    `json:"plan_id,omitempty"`
+}
+
+// TagTitles returns the titles of a run's tags, which the API returns as
+// strings or as objects with a title
+func (r Run) TagTitles() []string {
+	var titles []string
+	for _, tag := range r.Tags {
+		switch tag := tag.(type) {
+		case string:
+			titles = append(titles, tag)
+		case map[string]interface{}:
+			if title, ok := tag["title"].(string); ok {
+				titles = append(titles, title)
+			}
+		}
+	}
+	return titles
 }
 
 // CreateRunRequest represents a request to create a new run
@@ -36,6 +58,16 @@ type CreateRunRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Include     string `json:"include"`
+
+	// Custom field values keyed by field ID
+	CustomField map[string]string `json:"custom_field,omitempty"`
+	MilestoneID int               `json:"milestone_id,omitempty"`
+}
+
+// RunOptions are the extra attributes set on created runs
+type RunOptions struct {
+	Fields      map[int]string // run custom field values by field ID
+	MilestoneID int            // milestone to attach the run to (0 for none)
 }
 
 // CreateRunResponse represents the response from creating a run
@@ -48,10 +80,23 @@ type CreateRunResponse struct {
 
 // CreateRun creates a new test run in the target project
 func CreateRun(c *api.Client, project string, title, description string) (*Run, error) {
+	return CreateRunWithOptions(c, project, title, description, RunOptions{})
+}
+
+// CreateRunWithOptions creates a new test run with custom field values and a
+// milestone
+func CreateRunWithOptions(c *api.Client, project string, title, description string, opts RunOptions) (*Run, error) {
 	reqBody := CreateRunRequest{
 		Title:       title,
 		Description: description,
 		Include:     "cases",
+		MilestoneID: opts.MilestoneID,
+	}
+	if len(opts.Fields) > 0 {
+		reqBody.CustomField = make(map[string]string, len(opts.Fields))
+		for id, value := range opts.Fields {
+			reqBody.CustomField[strconv.Itoa(id)] = value
+		}
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -77,11 +122,11 @@ func CreateRun(c *api.Client, project string, title, description string) (*Run,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(resp.StatusCode, body)
 	}
 
 	var response CreateRunResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := decodeJSON(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -116,7 +161,7 @@ func GetRunByID(c *api.Client, project string, runID int) (*Run, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(resp.StatusCode, body)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -129,7 +174,7 @@ func GetRunByID(c *api.Client, project string, runID int) (*Run, error) {
 		Result Run  `json:"result"`
 	}
 
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := decodeJSON(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -144,11 +189,107 @@ func GetRunByID(c *api.Client, project string, runID int) (*Run, error) {
 type RunListResponse struct {
 	Status bool `json:"status"`
 	Result struct {
-		Total    int   `json:"total"`
+		Total    *int  `json:"total"`
+		Filtered *int  `json:"filtered"` // entities matching the filters
 		Entities []Run `json:"entities"`
 	} `json:"result"`
 }
 
+// Run status filters accepted by the run list API
+const (
+	RunStatusActive   = "active"
+	RunStatusComplete = "complete"
+	RunStatusAbort    = "abort"
+)
+
+// RunListOptions controls filtering for GetRuns
+type RunListOptions struct {
+	FromStartTime time.Time // only runs started at or after this time (zero = no filter)
+	ToStartTime   time.Time // only runs started before this time (zero = no filter)
+	Status        []string  // any of RunStatusActive, RunStatusComplete, RunStatusAbort
+	IncludeCases  bool      // include the case IDs of each run
+}
+
+// GetRuns fetches all runs for a project matching the options, paginating
+// until the total reported by the API has been collected
+func GetRuns(c *api.Client, project string, opts RunListOptions) ([]Run, error) {
+	var allRuns []Run
+	seen := make(map[int]bool)
+	offset := 0
+	limit := 100
+	total := -1
+	maxPages := 10000 // Safety limit to prevent infinite loops
+
+	query := url.Values{}
+	if !opts.FromStartTime.IsZero() {
+		query.Set("from_start_time", strconv.FormatInt(opts.FromStartTime.Unix(), 10))
+	}
+	if !opts.ToStartTime.IsZero() {
+		query.Set("to_start_time", strconv.FormatInt(opts.ToStartTime.Unix(), 10))
+	}
+	if len(opts.Status) > 0 {
+		query.Set("status", strings.Join(opts.Status, ","))
+	}
+	if opts.IncludeCases {
+		query.Set("include", "cases")
+	}
+
+	fmt.Printf("Fetching runs for project %s...\n", project)
+
+	for page := 1; page <= maxPages; page++ {
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		u := fmt.Sprintf("/run/%s?%s", project, query.Encode())
+
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
+		var response RunListResponse
+		if err := decodeList(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		total = listTotal(response.Result.Total, response.Result.Filtered)
+		for _, run := range response.Result.Entities {
+			if !seen[run.ID] {
+				seen[run.ID] = true
+				allRuns = append(allRuns, run)
+			}
+		}
+
+		fmt.Printf("Page %d (offset %d): %d runs returned (total so far: %d/%s)\n",
+			page, offset, len(response.Result.Entities), len(allRuns), formatTotal(total))
+
+		if listDone(len(response.Result.Entities), offset+len(response.Result.Entities), limit, total) {
+			break
+		}
+
+		offset += len(response.Result.Entities)
+	}
+
+	reconcileCount(c, "runs", "project "+project, len(allRuns), total)
+
+	fmt.Printf("Total runs fetched: %d\n", len(allRuns))
+	return allRuns, nil
+}
+
 // FindRunByTitle searches for a run with the given title in the target project
 func FindRunByTitle(c *api.Client, project string, title string) (*Run, error) {
 	offset := 0
@@ -171,7 +312,7 @@ func FindRunByTitle(c *api.Client, project string, title string) (*Run, error) {
 
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+			return nil, newHTTPError(resp.StatusCode, body)
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -180,7 +321,7 @@ func FindRunByTitle(c *api.Client, project string, title string) (*Run, error) {
 		}
 
 		var response RunListResponse
-		if err := json.Unmarshal(body, &response); err != nil {
+		if err := decodeList(body, &response); err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
@@ -202,6 +343,55 @@ func FindRunByTitle(c *api.Client, project string, title string) (*Run, error) {
 	return nil, nil // Run not found
 }
 
+// DeleteRun deletes a run and its results from a project
+func DeleteRun(c *api.Client, project string, runID int) error {
+	path := fmt.Sprintf("/run/%s/%d", project, runID)
+	req, err := c.NewRequest("DELETE", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newHTTPError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// UpdateRunDescription replaces the description of a run
+func UpdateRunDescription(c *api.Client, project string, runID int, description string) error {
+	body, err := json.Marshal(map[string]string{"description": description})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	path := fmt.Sprintf("/run/%s/%d", project, runID)
+	req, err := c.NewRequest("PATCH", path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newHTTPError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
 // CreateOrGetRun creates a new run or returns existing one if it already exists
 func CreateOrGetRun(c *api.Client, project string, title, description string) (*Run, error) {
 	// First, check if a run with this title already exists
@@ -0,0 +1,165 @@
+package qase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// ResultStatus is a result status of a workspace, built-in or custom
+type ResultStatus struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Slug      string `json:"slug"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// systemField is a workspace system field with its selectable options
+type systemField struct {
+	Slug    string         `json:"slug"`
+	Options []ResultStatus `json:"options"`
+}
+
+// GetResultStatuses fetches the result statuses of a workspace, keyed by slug
+func GetResultStatuses(c *api.Client) (map[string]ResultStatus, error) {
+	req, err := c.NewRequest("GET", "/system_field", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response struct {
+		Status bool          `json:"status"`
+		Result []systemField `json:"result"`
+	}
+	if err := decodeJSON(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	for _, field := range response.Result {
+		if field.Slug != "result_status" {
+			continue
+		}
+		statuses := make(map[string]ResultStatus, len(field.Options))
+		for _, status := range field.Options {
+			statuses[status.Slug] = status
+		}
+		return statuses, nil
+	}
+
+	return nil, fmt.Errorf("result_status system field not found")
+}
+
+// CreateResultStatus creates a custom result status in the workspace and
+// returns it
+func CreateResultStatus(c *api.Client, title, slug string) (ResultStatus, error) {
+	body, err := json.Marshal(map[string]string{"title": title, "slug": slug})
+	if err != nil {
+		return ResultStatus{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.NewRequest("POST", "/system_field/result_status", body)
+	if err != nil {
+		return ResultStatus{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return ResultStatus{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return ResultStatus{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return ResultStatus{}, newHTTPError(resp.StatusCode, body)
+	}
+
+	var response CreateRunResponse
+	if err := decodeJSON(body, &response); err != nil {
+		return ResultStatus{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !response.Status {
+		return ResultStatus{}, fmt.Errorf("status creation failed: %s", string(body))
+	}
+	return ResultStatus{ID: response.Result.ID, Title: title, Slug: slug}, nil
+}
+
+// Status is a result status slug
+type Status string
+
+// Built-in result statuses of every workspace; custom statuses have their
+// own slugs
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusBlocked Status = "blocked"
+	StatusSkipped Status = "skipped"
+	StatusInvalid Status = "invalid"
+)
+
+// BuiltinStatuses lists the built-in result statuses
+var BuiltinStatuses = []Status{StatusPassed, StatusFailed, StatusBlocked, StatusSkipped, StatusInvalid}
+
+// ParseStatus checks a status given by slug or title before the workspace's
+// statuses are known. Built-in statuses are returned by slug whatever their
+// case; other names are kept as given for ResolveStatus to match against the
+// workspace's custom statuses.
+func ParseStatus(name string) (Status, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("empty status (use %s or a custom status slug or title)", statusNames(BuiltinStatuses))
+	}
+	for _, status := range BuiltinStatuses {
+		if strings.EqualFold(string(status), name) {
+			return status, nil
+		}
+	}
+	if strings.ContainsAny(name, ",:") {
+		return "", fmt.Errorf("invalid status %q (use %s or a custom status slug or title)", name, statusNames(BuiltinStatuses))
+	}
+	return Status(name), nil
+}
+
+func statusNames(statuses []Status) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ResolveStatus returns the slug of the status matching name by slug or
+// (case-insensitive) title
+func ResolveStatus(statuses map[string]ResultStatus, name string) (string, bool) {
+	if _, exists := statuses[name]; exists {
+		return name, true
+	}
+	for slug, status := range statuses {
+		if strings.EqualFold(status.Title, name) || strings.EqualFold(slug, name) {
+			return slug, true
+		}
+	}
+	return "", false
+}
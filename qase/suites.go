@@ -0,0 +1,96 @@
+package qase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// Suite represents a Qase test suite
+type Suite struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// SuiteListResponse represents the API response for suite list
+type SuiteListResponse struct {
+	Status bool `json:"status"`
+	Result struct {
+		Total    int     `json:"total"`
+		Entities []Suite `json:"entities"`
+	} `json:"result"`
+}
+
+// GetSuites fetches all suites for a project with pagination
+func GetSuites(c *api.Client, project string) (map[int]Suite, error) {
+	suites := make(map[int]Suite)
+	offset := 0
+	limit := 100
+
+	for {
+		u := fmt.Sprintf("/suite/%s?limit=%d&offset=%d", project, limit, offset)
+
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp.StatusCode, body)
+		}
+
+		var response SuiteListResponse
+		if err := decodeList(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		for _, suite := range response.Result.Entities {
+			suites[suite.ID] = suite
+		}
+
+		if len(response.Result.Entities) < limit {
+			break
+		}
+
+		offset += limit
+	}
+
+	fmt.Printf("Fetched %d suites for project %s\n", len(suites), project)
+	return suites, nil
+}
+
+// SuitePath returns the " / "-joined titles from the root suite down to suiteID
+func SuitePath(suites map[int]Suite, suiteID *int) string {
+	if suiteID == nil {
+		return ""
+	}
+
+	var parts []string
+	seen := make(map[int]bool)
+	for id := suiteID; id != nil; {
+		suite, exists := suites[*id]
+		if !exists || seen[suite.ID] {
+			break
+		}
+		seen[suite.ID] = true
+		parts = append([]string{suite.Title}, parts...)
+		id = suite.ParentID
+	}
+
+	return strings.Join(parts, " / ")
+}
@@ -0,0 +1,172 @@
+package qase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// UpdateResultRequest represents a request to update an existing result
+type UpdateResultRequest struct {
+	Status  string `json:"status,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// UpdateResult updates the status and comment of an existing result in a run
+func UpdateResult(c *api.Client, project string, runID int, hash string, update UpdateResultRequest) error {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	path := fmt.Sprintf("/result/%s/%d/%s", project, runID, hash)
+	req, err := c.NewRequest("PATCH", path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// DeleteResult deletes a result from a run
+func DeleteResult(c *api.Client, project string, runID int, hash string) error {
+	path := fmt.Sprintf("/result/%s/%d/%s", project, runID, hash)
+	req, err := c.NewRequest("DELETE", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newHTTPError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// SyncStats summarizes a re-sync of a target run
+type SyncStats struct {
+	New       int
+	Updated   int
+	Unchanged int
+	Failed    int
+}
+
+// SyncResults compares the desired items with the results already in the
+// target run. Results whose status or comment changed in the source are
+// updated in place; items without a target result are returned so they can
+// be posted as new results. Items are matched to target results by source
+// hash marker, falling back to case ID for results posted without one.
+func SyncResults(c *api.Client, project string, runID int, items []BulkItem) ([]BulkItem, SyncStats, error) {
+	var stats SyncStats
+
+	existing, err := GetRunResults(c, project, runID)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to fetch existing results: %w", err)
+	}
+
+	existingByHash := make(map[string]Result)
+	unmarkedByCase := make(map[int][]Result)
+	for _, result := range existing {
+		if hash := SourceHash(result.Comment); hash != "" {
+			existingByHash[hash] = result
+		} else {
+			unmarkedByCase[result.CaseID] = append(unmarkedByCase[result.CaseID], result)
+		}
+	}
+
+	// Items with a marker matching a target result are compared one to one;
+	// for the rest only the latest item per case is compared
+	latestByCase := make(map[int]BulkItem)
+	var order []int
+	var newItems []BulkItem
+	for _, item := range items {
+		if target, exists := existingByHash[SourceHash(item.Comment)]; exists {
+			syncResult(c, project, runID, []Result{target}, item, &stats)
+			continue
+		}
+		if _, exists := latestByCase[item.CaseID]; !exists {
+			order = append(order, item.CaseID)
+		}
+		latestByCase[item.CaseID] = item
+	}
+
+	for _, caseID := range order {
+		item := latestByCase[caseID]
+		targets := unmarkedByCase[caseID]
+
+		if len(targets) == 0 {
+			newItems = append(newItems, item)
+			stats.New++
+			continue
+		}
+
+		syncResult(c, project, runID, targets, item, &stats)
+	}
+
+	fmt.Printf("Re-sync run %d: %d new, %d updated, %d unchanged, %d failed\n",
+		runID, stats.New, stats.Updated, stats.Unchanged, stats.Failed)
+	return newItems, stats, nil
+}
+
+// syncResult updates the latest of the target results unless one already matches the item
+func syncResult(c *api.Client, project string, runID int, targets []Result, item BulkItem, stats *SyncStats) {
+	if resultMatches(targets, item) {
+		stats.Unchanged++
+		return
+	}
+
+	target := latestResult(targets)
+	update := UpdateResultRequest{Status: item.Status, Comment: item.Comment}
+	if err := UpdateResult(c, project, runID, target.Hash, update); err != nil {
+		fmt.Printf("Failed to update result %s for case %d: %v\n", target.Hash, item.CaseID, err)
+		stats.Failed++
+		return
+	}
+	stats.Updated++
+}
+
+// resultMatches reports whether any existing result already reflects the item
+func resultMatches(results []Result, item BulkItem) bool {
+	for _, result := range results {
+		if result.Status == item.Status && result.Comment == item.Comment {
+			return true
+		}
+	}
+	return false
+}
+
+// latestResult returns the result with the most recent end time
+func latestResult(results []Result) Result {
+	latest := results[0]
+	for _, result := range results[1:] {
+		if result.EndedAt.After(latest.EndedAt) {
+			latest = result
+		}
+	}
+	return latest
+}
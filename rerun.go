@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// failedSourceRuns returns the source runs behind failed groups, sorted. A
+// date bucket stands for every source run with results in it.
+func failedSourceRuns(groups []runGroup, failed map[int]bool) []int {
+	seen := make(map[int]bool)
+	var runIDs []int
+	for _, group := range groups {
+		if !failed[group.id] {
+			continue
+		}
+		for _, result := range group.results {
+			if result.RunID != 0 && !seen[result.RunID] {
+				seen[result.RunID] = true
+				runIDs = append(runIDs, result.RunID)
+			}
+		}
+	}
+	sort.Ints(runIDs)
+	return runIDs
+}
+
+// rerunCommand returns the command that migrates only the given source runs
+// again, in the environment of the current migration
+func rerunCommand(config *Config, runIDs []int) string {
+	ids := make([]string, len(runIDs))
+	for i, id := range runIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+
+	assignments := []string{
+		"QASE_FETCH_RUN_IDS=" + strings.Join(ids, ","),
+		"QASE_FETCH_MODE=" + qase.FetchByRun,
+	}
+	// A batch pair is re-run on its own
+	if config.InBatch {
+		assignments = append(assignments,
+			"QASE_BATCH_FILE=",
+			"QASE_SOURCE_PROJECT="+shellQuote(config.SourceProject),
+			"QASE_TARGET_PROJECT="+shellQuote(config.TargetProject))
+	}
+	command := strings.Join(assignments, " ") + " " + executable()
+	if config.Role == postOnlyCommand {
+		command += " " + postOnlyCommand
+	}
+	return command
+}
+
+// writeRerunScript writes a shell script running the re-run command to the
+// artifact directory and returns its location
+func writeRerunScript(config *Config, command string, failed int) (string, error) {
+	name := "rerun-failed.sh"
+	if config.InBatch {
+		name = fmt.Sprintf("rerun-failed-%s-%s.sh", config.SourceProject, config.TargetProject)
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Re-runs the %d failed runs of %s -> %s (migration of %s).\n", failed, config.SourceProject, config.TargetProject, time.Now().UTC().Format("2006-01-02 15:04 UTC"))
+	b.WriteString("# Run it with the environment of that migration; it only narrows the source runs.\n")
+	if config.InBatch {
+		b.WriteString("# Overrides from the batch file (tokens, API bases, mapping) must be set again.\n")
+	}
+	b.WriteString(command + " \"$@\"\n")
+
+	return artifact.WriteProtected(artifact.Join(config.ArtifactDir, name), []byte(b.String()), config.Force)
+}
+
+// executable names the running binary as it was started, quoted for sh, or
+// "go run ." for a binary built by go run
+func executable() string {
+	name := os.Args[0]
+	if strings.Contains(filepath.ToSlash(name), "/go-build") {
+		return "go run ."
+	}
+	return shellQuote(name)
+}
+
+// shellQuote quotes a value for sh when it contains anything but safe characters
+func shellQuote(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:,=") == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
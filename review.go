@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/review"
+	"github.com/adrianeortiz/clone-run-multi-ws/settings"
+)
+
+// approveCommand posts runs staged for review with QASE_REVIEW_DIR
+const approveCommand = "approve"
+
+// readsOnly reports whether the migration leaves the target workspace
+// untouched: a dry run, or a review stage whose runs are posted on approval
+func (c *Config) readsOnly() bool {
+	return c.DryRun || c.ReviewDir != ""
+}
+
+// printStaged lists the runs staged for review with the command approving
+// each
+func printStaged(stage *review.Stage, config *Config) {
+	staged := stage.Staged()
+	fmt.Printf("\n=== Review Queue ===\n")
+	fmt.Printf("Staged %d runs for review in %s\n", len(staged), config.ReviewDir)
+	for _, bundle := range staged {
+		fmt.Printf("  %s: '%s', %d results (%s)\n", bundle.Run, bundle.Title, len(bundle.Results), formatCounts(bundle.Counts))
+		fmt.Printf("    approve: QASE_REVIEW_DIR=%s %s %s --project %s --run %s\n",
+			shellQuote(config.ReviewDir), executable(), approveCommand, shellQuote(bundle.SourceProject), shellQuote(bundle.Run))
+	}
+}
+
+// formatCounts formats status counts as "failed 3, passed 12"
+func formatCounts(counts map[string]int) string {
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%s %d", status, counts[status])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runApprove posts staged runs to their target projects
+// (`clone-run-multi-ws approve --run <id>[,<id>...]`). The target workspace
+// comes from QASE_TARGET_API_TOKEN (or QASE_TARGET_TOKEN_COMMAND) and
+// QASE_TARGET_API_BASE; protected target projects are refused as in a
+// migration.
+func runApprove(args []string) {
+	flags := flag.NewFlagSet(approveCommand, flag.ExitOnError)
+	runs := flags.String("run", "", "source run ID (or date bucket key) of the staged run; several separated by commas")
+	project := flags.String("project", os.Getenv("QASE_SOURCE_PROJECT"), "source project of the staged run (QASE_SOURCE_PROJECT)")
+	dir := flags.String("dir", os.Getenv("QASE_REVIEW_DIR"), "review queue location (QASE_REVIEW_DIR)")
+	flags.Parse(args)
+	if *runs == "" || *project == "" || *dir == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s --run <id> [--project <code>] [--dir <location>]\n", os.Args[0], approveCommand)
+		os.Exit(2)
+	}
+
+	baseURL := getEnvDefault("QASE_TARGET_API_BASE", "https://api.qase.io")
+	client := api.NewClient(baseURL, os.Getenv("QASE_TARGET_API_TOKEN"))
+	if command := os.Getenv("QASE_TARGET_TOKEN_COMMAND"); command != "" {
+		if err := client.SetTokenProvider(api.CommandTokenProvider(command), 0); err != nil {
+			log.Fatalf("Failed to obtain target token: %v", err)
+		}
+	} else if os.Getenv("QASE_TARGET_API_TOKEN") == "" {
+		log.Fatalf("QASE_TARGET_API_TOKEN or QASE_TARGET_TOKEN_COMMAND is required to approve runs")
+	}
+	// Posting limits are loaded and validated as in a migration; approving
+	// is the decision to write, so QASE_DRY_RUN does not apply
+	var problems settings.Problems
+	posting := settings.LoadPosting(&problems)
+	client.MaxPayloadBytes = settings.LoadClient(&problems).MaxPayloadBytes
+	if err := problems.Err(); err != nil {
+		log.Fatal(err)
+	}
+	protected := qase.ParseProjectList(os.Getenv("QASE_PROTECTED_PROJECTS"))
+	override := getEnvDefault(qase.OverrideEnv, "false") == "true"
+
+	failed := 0
+	for _, run := range strings.Split(*runs, ",") {
+		run = strings.TrimSpace(run)
+		bundle, err := review.Load(*dir, *project, run)
+		if err == nil {
+			err = qase.CheckWritable(bundle.TargetProject, protected, override)
+		}
+		if err != nil {
+			log.Printf("Cannot approve run %s: %v", run, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("Approving '%s' (%s run %s -> %s): %d results (%s), staged %s\n", bundle.Title, bundle.SourceProject, bundle.Run,
+			bundle.TargetProject, len(bundle.Results), formatCounts(bundle.Counts), bundle.StagedAt.Format("2006-01-02 15:04:05 UTC"))
+		runID, posted, err := review.Approve(client, *dir, bundle, posting.BulkSize)
+		if err != nil {
+			log.Printf("Failed to approve run %s: %v", run, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Approved run %s: posted %d results to %s\n", run, posted, qase.RunURL(baseURL, bundle.TargetProject, runID))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
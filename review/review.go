@@ -0,0 +1,223 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/target"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+)
+
+// Bundle is a transformed run waiting for approval: everything needed to
+// create the target run and post its results
+type Bundle struct {
+	SourceProject string          `json:"source_project"`
+	Run           string          `json:"run"`    // source run ID, or the date bucket or split part
+	Marker        string          `json:"marker"` // recorded in the target run
+	TargetProject string          `json:"target_project"`
+	Title         string          `json:"title"`
+	Description   string          `json:"description"`
+	Fields        map[int]string  `json:"fields,omitempty"`
+	MilestoneID   int             `json:"milestone_id,omitempty"`
+	Results       []qase.BulkItem `json:"results"`
+	StagedAt      time.Time       `json:"staged_at"`
+	ApprovedAt    *time.Time      `json:"approved_at,omitempty"`
+	TargetRunID   int             `json:"target_run_id,omitempty"`
+	Counts        map[string]int  `json:"status_counts"`
+}
+
+// Location returns where the bundle of a source run is kept in dir while
+// pending, or once approved
+func Location(dir, sourceProject, run string, approved bool) string {
+	state := "pending"
+	if approved {
+		state = "approved"
+	}
+	name := strings.NewReplacer("/", "-", "\\", "-").Replace(sourceProject + "-" + run)
+	return artifact.Join(dir, state+"/"+name+".json")
+}
+
+// Stage writes transformed runs as pending bundles instead of posting them.
+// It implements target.Target, so it replaces the target project for a
+// migration that needs a human approval before anything is written.
+type Stage struct {
+	dir           string
+	sourceProject string
+	targetProject string
+
+	mu      sync.Mutex
+	bundles map[int]*Bundle // by staged run ID
+	markers map[string]int  // staged run ID by marker
+	staged  []*Bundle
+}
+
+// Open creates a stage writing bundles to dir (a local path, s3:// or gs://)
+func Open(dir, sourceProject, targetProject string) (*Stage, error) {
+	if _, err := artifact.For(dir); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Staging runs for review in %s\n", dir)
+	return &Stage{
+		dir:           dir,
+		sourceProject: sourceProject,
+		targetProject: targetProject,
+		bundles:       make(map[int]*Bundle),
+		markers:       make(map[string]int),
+	}, nil
+}
+
+// FindRun returns a run staged by this stage for marker, or nil
+func (s *Stage) FindRun(marker, title string) (*qase.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.markers[marker]; ok {
+		return &qase.Run{ID: id, Title: s.bundles[id].Title}, nil
+	}
+	return nil, nil
+}
+
+// CreateRun starts a bundle for the run. The returned run ID only exists in
+// the stage; the target run is created on approval.
+func (s *Stage) CreateRun(marker, title, description string, opts qase.RunOptions) (*qase.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.markers[marker]; ok {
+		return &qase.Run{ID: id, Title: title}, nil
+	}
+	bundle := &Bundle{
+		SourceProject: s.sourceProject,
+		Run:           strings.TrimPrefix(strings.TrimPrefix(marker, s.sourceProject+"/"), "run-"),
+		Marker:        marker,
+		TargetProject: s.targetProject,
+		Title:         title,
+		Description:   description,
+		Fields:        opts.Fields,
+		MilestoneID:   opts.MilestoneID,
+	}
+	id := len(s.bundles) + 1
+	s.bundles[id] = bundle
+	s.markers[marker] = id
+	return &qase.Run{ID: id, Title: title, Description: &description}, nil
+}
+
+// PostResults adds results to the run's bundle and writes it as pending
+func (s *Stage) PostResults(runID int, items []qase.BulkItem, span *tracing.Span) error {
+	s.mu.Lock()
+	bundle, ok := s.bundles[runID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("run %d was not staged", runID)
+	}
+	first := len(bundle.Results) == 0
+	bundle.Results = append(bundle.Results, items...)
+	bundle.StagedAt = time.Now().UTC()
+	bundle.Counts = statusCounts(bundle.Results)
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if first && err == nil {
+		s.staged = append(s.staged, bundle)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+
+	location := Location(s.dir, bundle.SourceProject, bundle.Run, false)
+	if err := artifact.Write(location, data); err != nil {
+		return fmt.Errorf("failed to stage run %s: %w", bundle.Run, err)
+	}
+	fmt.Printf("Staged %d results of '%s' for review in %s\n", len(bundle.Results), bundle.Title, location)
+	return nil
+}
+
+// Staged returns the bundles staged so far, in staging order
+func (s *Stage) Staged() []*Bundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Bundle(nil), s.staged...)
+}
+
+func statusCounts(items []qase.BulkItem) map[string]int {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.Status]++
+	}
+	return counts
+}
+
+// Load reads the pending bundle of a source run
+func Load(dir, sourceProject, run string) (*Bundle, error) {
+	data, err := artifact.Read(Location(dir, sourceProject, run, false))
+	if errors.Is(err, artifact.ErrNotExist) {
+		if approved, _ := artifact.Exists(Location(dir, sourceProject, run, true)); approved {
+			return nil, fmt.Errorf("run %s of %s was already approved", run, sourceProject)
+		}
+		return nil, fmt.Errorf("no pending bundle for run %s of %s in %s", run, sourceProject, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// Approve creates the target run of a pending bundle and posts its results,
+// then moves the bundle to approved. Approval is idempotent: the run is
+// found by its marker and results already in it are not posted again, so
+// an interrupted approval can be repeated. It returns the target run ID and
+// the number of results posted.
+func Approve(c *api.Client, dir string, bundle *Bundle, bulkSize int) (int, int, error) {
+	runs, err := qase.BuildRunIndex(c, bundle.TargetProject)
+	if err != nil {
+		return 0, 0, err
+	}
+	t := target.NewQase(c, bundle.TargetProject, runs, bulkSize)
+	opts := qase.RunOptions{Fields: bundle.Fields, MilestoneID: bundle.MilestoneID}
+	run, err := t.FindRun(bundle.Marker, bundle.Title)
+	if err == nil && run == nil {
+		run, err = t.CreateRun(bundle.Marker, bundle.Title, bundle.Description, opts)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create target run: %w", err)
+	}
+
+	items := bundle.Results
+	hasResults, err := qase.CheckRunHasResults(c, bundle.TargetProject, run.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check existing results of run %d: %w", run.ID, err)
+	}
+	if hasResults {
+		if items, err = qase.FilterNewResults(c, bundle.TargetProject, run.ID, items); err != nil {
+			return 0, 0, fmt.Errorf("failed to filter existing results of run %d: %w", run.ID, err)
+		}
+	}
+	if len(items) > 0 {
+		if err := t.PostResults(run.ID, items, nil); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	now := time.Now().UTC()
+	bundle.ApprovedAt = &now
+	bundle.TargetRunID = run.ID
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	if err := artifact.Write(Location(dir, bundle.SourceProject, bundle.Run, true), data); err != nil {
+		return 0, 0, fmt.Errorf("failed to record approval: %w", err)
+	}
+	if err := artifact.Delete(Location(dir, bundle.SourceProject, bundle.Run, false)); err != nil {
+		return 0, 0, fmt.Errorf("failed to remove pending bundle: %w", err)
+	}
+	return run.ID, len(items), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// maxDescriptionLinks caps the source run links of a bucketed target run
+const maxDescriptionLinks = 20
+
+// enrichDescription appends a markdown summary of the migrated results to a
+// target run description: counts by status, unmapped results, and links back
+// to the source runs
+func enrichDescription(description string, group runGroup, items []qase.BulkItem, skipped int, srcBaseURL, srcProject string) string {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.Status]++
+	}
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if counts[statuses[i]] != counts[statuses[j]] {
+			return counts[statuses[i]] > counts[statuses[j]]
+		}
+		return statuses[i] < statuses[j]
+	})
+
+	var b strings.Builder
+	b.WriteString(description)
+	b.WriteString("\n\n**Migration summary**\n\n| Status | Results |\n|---|---|\n")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "| %s | %d |\n", status, counts[status])
+	}
+	fmt.Fprintf(&b, "| **Total** | **%d** |\n", len(items))
+	if skipped > 0 {
+		fmt.Fprintf(&b, "\nUnmapped results not migrated: %d\n", skipped)
+	}
+
+	seen := make(map[int]bool)
+	var runIDs []int
+	for _, result := range group.results {
+		if !seen[result.RunID] {
+			seen[result.RunID] = true
+			runIDs = append(runIDs, result.RunID)
+		}
+	}
+	sort.Ints(runIDs)
+
+	links := make([]string, 0, min(len(runIDs), maxDescriptionLinks))
+	for _, runID := range runIDs[:min(len(runIDs), maxDescriptionLinks)] {
+		links = append(links, fmt.Sprintf("[%s #%d](%s)", srcProject, runID, qase.RunURL(srcBaseURL, srcProject, runID)))
+	}
+	label := "Source run"
+	if len(runIDs) > 1 {
+		label = "Source runs"
+	}
+	fmt.Fprintf(&b, "\n%s: %s", label, strings.Join(links, ", "))
+	if more := len(runIDs) - len(links); more > 0 {
+		fmt.Fprintf(&b, " and %d more", more)
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/errclass"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// runErrorSampleSize bounds the results copied into an error file
+const runErrorSampleSize = 5
+
+// runErrorFile is written for each failed run so it can be debugged without
+// re-running the migration
+type runErrorFile struct {
+	Key         string    `json:"key"`
+	SourceRunID int       `json:"source_run_id"`
+	TargetRunID int       `json:"target_run_id,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	FailedAt    time.Time `json:"failed_at"`
+	Error       string    `json:"error"`
+	Class       string    `json:"class"`
+	Remediation string    `json:"remediation,omitempty"`
+
+	// The API response of the failed request, when the failure was one
+	StatusCode int    `json:"status_code,omitempty"`
+	Response   string `json:"response,omitempty"`
+
+	// FailedChunk describes the chunk that failed, when posting failed part way
+	FailedChunk *failedChunk `json:"failed_chunk,omitempty"`
+
+	// Target cases of the results that were not posted and a sample of them
+	// as sent
+	CaseIDs       []int           `json:"case_ids,omitempty"`
+	PayloadSample []qase.BulkItem `json:"payload_sample,omitempty"`
+}
+
+type failedChunk struct {
+	Number int `json:"number"`
+	Total  int `json:"total"`
+	Items  int `json:"items"`
+	Posted int `json:"posted_before"`
+}
+
+// writeRunError writes errors/<key>.json under the artifact directory for a
+// failed run with the results it was posting, and returns the location. The
+// key of the run group (run-<id>, a date bucket or a part of either) names
+// the file, so the parts of a split run do not overwrite each other.
+func writeRunError(dir string, force bool, key string, sourceRunID, targetRunID int, title string, items []qase.BulkItem, err error, class errclass.Class) (string, error) {
+	file := runErrorFile{
+		Key:         key,
+		SourceRunID: sourceRunID,
+		TargetRunID: targetRunID,
+		Title:       title,
+		FailedAt:    time.Now().UTC(),
+		Error:       err.Error(),
+		Class:       string(class),
+		Remediation: errclass.Hint(class),
+	}
+
+	var response interface {
+		HTTPStatus() int
+		ResponseBody() string
+	}
+	if errors.As(err, &response) {
+		file.StatusCode = response.HTTPStatus()
+		file.Response = response.ResponseBody()
+	}
+
+	// Only the failed chunk and later ones were not posted
+	var chunkErr *qase.ChunkError
+	if errors.As(err, &chunkErr) {
+		file.FailedChunk = &failedChunk{Number: chunkErr.Number, Total: chunkErr.Total, Items: chunkErr.Items, Posted: chunkErr.Posted}
+		if chunkErr.Posted <= len(items) {
+			items = items[chunkErr.Posted:]
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, item := range items {
+		if !seen[item.CaseID] {
+			seen[item.CaseID] = true
+			file.CaseIDs = append(file.CaseIDs, item.CaseID)
+		}
+	}
+	sort.Ints(file.CaseIDs)
+	file.PayloadSample = items[:min(len(items), runErrorSampleSize)]
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode error file: %w", err)
+	}
+	return artifact.WriteProtected(artifact.Join(dir, "errors/"+key+".json"), data, force)
+}
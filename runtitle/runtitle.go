@@ -0,0 +1,85 @@
+package runtitle
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// Prefix starts the title of every target run migrated from one source run
+const Prefix = "Migrated Run"
+
+// ForSourceRun returns the title and description of the target run for a
+// source run, dated by the first result's end time when known
+func ForSourceRun(runID int, results []qase.Result) (title, description string) {
+	title = fmt.Sprintf("%s %d", Prefix, runID)
+	if len(results) > 0 {
+		if endTime := results[0].EndedAt; !endTime.IsZero() {
+			title = fmt.Sprintf("%s %d (%s)", Prefix, runID, endTime.Format("2006-01-02 15:04"))
+		}
+	}
+	description = fmt.Sprintf("Migrated run with %d results from source workspace", len(results))
+	return title, description
+}
+
+// Target runs are identified across migrations by a marker recorded in their
+// description (see qase.WithRunMarker): the source project and the key of
+// the source run or date bucket, plus the part of a split run
+var markerPattern = regexp.MustCompile(`^([^/]+)/(?:run-(\d+)|([a-z]+)-(\d+))(?:-part-(\d+)-of-(\d+))?$`)
+
+// RunKey returns the key of a target run migrated from one source run
+func RunKey(runID int) string {
+	return fmt.Sprintf("run-%d", runID)
+}
+
+// BucketKey returns the key of a target run holding the results of a date
+// bucket, dated YYYYMMDD (0 for undated results)
+func BucketKey(bucket string, date int) string {
+	return fmt.Sprintf("%s-%d", bucket, date)
+}
+
+// PartKey returns the key of one part of a run split into parts
+func PartKey(key string, part, parts int) string {
+	return fmt.Sprintf("%s-part-%d-of-%d", key, part, parts)
+}
+
+// Marker returns the marker of the target run with key migrated from srcProject
+func Marker(srcProject, key string) string {
+	return srcProject + "/" + key
+}
+
+// Source is what a target run was migrated from, as recorded in its marker
+type Source struct {
+	Project string
+	Key     string // key of the whole source run or bucket, without the part
+	RunID   int    // source run, 0 for a date bucket
+	Bucket  string // date bucket (daily or weekly), empty for a source run
+	Date    int    // bucket start as YYYYMMDD, 0 for undated results
+	Part    int    // part of a split run, from 1; 0 when not split
+	Parts   int
+}
+
+// ParseMarker parses a marker made by Marker
+func ParseMarker(marker string) (Source, error) {
+	match := markerPattern.FindStringSubmatch(marker)
+	if match == nil {
+		return Source{}, fmt.Errorf("unrecognized run marker %q", marker)
+	}
+	var src Source
+	src.Project = match[1]
+	if match[2] != "" {
+		src.RunID, _ = strconv.Atoi(match[2])
+		src.Key = RunKey(src.RunID)
+	} else {
+		src.Bucket = match[3]
+		src.Date, _ = strconv.Atoi(match[4])
+		src.Key = BucketKey(src.Bucket, src.Date)
+	}
+	if match[5] != "" {
+		src.Part, _ = strconv.Atoi(match[5])
+		src.Parts, _ = strconv.Atoi(match[6])
+	}
+	return src, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// sample is the share of each run's results migrated in a trial migration:
+// a percentage of the results, or a number of results per run
+type sample struct {
+	percent float64 // 0 when sampling by count
+	perRun  int     // 0 when sampling by percentage
+}
+
+// parseSample parses a QASE_SAMPLE value such as "5%" or "20". An empty
+// value migrates every result.
+func parseSample(value string) (sample, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return sample{}, nil
+	}
+	if number, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return sample{}, fmt.Errorf("invalid QASE_SAMPLE: %s (expected a percentage such as 5%% or results per run such as 20)", value)
+		}
+		return sample{percent: percent}, nil
+	}
+	perRun, err := strconv.Atoi(value)
+	if err != nil || perRun < 1 {
+		return sample{}, fmt.Errorf("invalid QASE_SAMPLE: %s (expected a percentage such as 5%% or results per run such as 20)", value)
+	}
+	return sample{perRun: perRun}, nil
+}
+
+func (s sample) enabled() bool {
+	return s.percent > 0 || s.perRun > 0
+}
+
+// rank orders results pseudo-randomly by a hash of their source hash, so
+// every attempt picks the same sample
+func rank(result qase.Result) uint32 {
+	h := fnv.New32a()
+	if result.Hash != "" {
+		h.Write([]byte(result.Hash))
+	} else {
+		fmt.Fprintf(h, "%d/%d/%s", result.RunID, result.CaseID, result.EndTime)
+	}
+	return h.Sum32()
+}
+
+// pick returns the sampled results of a run in their original order
+func (s sample) pick(results []qase.Result) []qase.Result {
+	if s.percent > 0 {
+		threshold := uint32(s.percent / 100 * float64(1<<32-1))
+		var picked []qase.Result
+		for _, result := range results {
+			if rank(result) <= threshold {
+				picked = append(picked, result)
+			}
+		}
+		return picked
+	}
+
+	if len(results) <= s.perRun {
+		return results
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return rank(results[order[a]]) < rank(results[order[b]]) })
+	order = order[:s.perRun]
+	sort.Ints(order)
+	picked := make([]qase.Result, len(order))
+	for i, index := range order {
+		picked[i] = results[index]
+	}
+	return picked
+}
+
+// filter returns the run groups with their sampled results, leaving out
+// groups with none, and the number of results kept
+func (s sample) filter(groups []runGroup) ([]runGroup, int) {
+	sampled := make([]runGroup, 0, len(groups))
+	kept := 0
+	for _, group := range groups {
+		group.results = s.pick(group.results)
+		if len(group.results) == 0 {
+			continue
+		}
+		kept += len(group.results)
+		sampled = append(sampled, group)
+	}
+	return sampled, kept
+}
+
+func (s sample) String() string {
+	if s.percent > 0 {
+		return strconv.FormatFloat(s.percent, 'f', -1, 64) + "%"
+	}
+	return fmt.Sprintf("%d results per run", s.perRun)
+}
@@ -0,0 +1,108 @@
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of an AES-256 key
+const KeySize = 32
+
+// magic starts every sealed file, so sealed and plain data can be told apart
+var magic = []byte("QASESEAL1\n")
+
+// ErrNotSealed is returned by Open for data that was not sealed
+var ErrNotSealed = errors.New("data is not encrypted")
+
+// ParseKey decodes a 32-byte key given as base64 (standard or URL alphabet)
+// or as 64 hex digits
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+	if len(value) == 2*KeySize {
+		if key, err := hex.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(value); err == nil {
+			if len(key) != KeySize {
+				return nil, fmt.Errorf("key is %d bytes, expected %d", len(key), KeySize)
+			}
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("key is neither base64 nor hex")
+}
+
+// GenerateKey returns a new random key, base64 encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Seal encrypts and authenticates data with AES-256-GCM. The result holds
+// the magic header, a random nonce and the ciphertext.
+func Seal(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := make([]byte, 0, len(magic)+len(nonce)+len(data)+aead.Overhead())
+	sealed = append(sealed, magic...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, data, magic), nil
+}
+
+// Open decrypts data sealed with Seal, failing when the key is wrong or the
+// data was modified
+func Open(key, sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, ErrNotSealed
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := sealed[len(magic):]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	data, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong key or modified data)")
+	}
+	return data, nil
+}
+
+// IsSealed reports whether data was sealed with Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %d bytes, expected %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/service"
+)
+
+// serveCommand runs migrations submitted over HTTP
+const serveCommand = "serve"
+
+// runServe serves the job API (`clone-run-multi-ws serve`) until SIGTERM or
+// SIGINT, then stops the running jobs. Each job is a migration of one
+// project pair run by this binary with the service environment plus the
+// job's options. Jobs are kept in a SQLite database (QASE_SERVE_DB, "off"
+// keeps them in memory), so queued and interrupted jobs run after a restart.
+func runServe(args []string) {
+	flags := flag.NewFlagSet(serveCommand, flag.ExitOnError)
+	addr := flags.String("addr", getEnvDefault("QASE_SERVE_ADDR", "127.0.0.1:8080"), "listen address (QASE_SERVE_ADDR)")
+	dir := flags.String("dir", getEnvDefault("QASE_SERVE_DIR", "jobs"), "directory for job logs and artifacts (QASE_SERVE_DIR)")
+	db := flags.String("db", os.Getenv("QASE_SERVE_DB"), "job database (QASE_SERVE_DB; default: jobs.db in the job directory, off to keep jobs in memory)")
+	maxJobs := flags.Int("max-jobs", getIntDefault("QASE_SERVE_MAX_JOBS", 1), "migrations run at the same time (QASE_SERVE_MAX_JOBS)")
+	perProject := flags.Int("max-jobs-per-project", getIntDefault("QASE_SERVE_MAX_JOBS_PER_PROJECT", 1), "migrations run at the same time into one target project (QASE_SERVE_MAX_JOBS_PER_PROJECT)")
+	retention := flags.Int("retention", getIntDefault("QASE_SERVE_RETENTION_DAYS", 30), "days finished jobs are kept, 0 for ever (QASE_SERVE_RETENTION_DAYS)")
+	flags.Parse(args)
+
+	switch *db {
+	case "":
+		*db = filepath.Join(*dir, "jobs.db")
+	case "off":
+		*db = ""
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to locate the migration binary: %v", err)
+	}
+	authToken := os.Getenv("QASE_SERVE_AUTH_TOKEN")
+	if authToken == "" {
+		fmt.Println("Warning: QASE_SERVE_AUTH_TOKEN is not set, every local user can start migrations")
+	}
+
+	server, err := service.Start(service.Config{
+		Addr:              *addr,
+		Executable:        executable,
+		Dir:               *dir,
+		AuthToken:         authToken,
+		DB:                *db,
+		SQLite:            os.Getenv("QASE_SERVE_SQLITE"),
+		MaxJobs:           *maxJobs,
+		MaxJobsPerProject: *perProject,
+		Retention:         time.Duration(*retention) * 24 * time.Hour,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start job server: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	fmt.Printf("Received %v, stopping jobs and shutting down\n", sig)
+	server.Stop()
+}
@@ -0,0 +1,799 @@
+package service
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/envcfg"
+	"github.com/adrianeortiz/clone-run-multi-ws/heartbeat"
+)
+
+// Job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+// cancelGrace is how long a canceled migration has to exit before it is killed
+const cancelGrace = 30 * time.Second
+
+// logLines is how many log lines of a job are kept in memory for streaming;
+// the whole log is in the job directory
+const logLines = 5000
+
+// Request is the body of a job submission. Options are configuration
+// variables for the migration, with or without the QASE_ prefix (e.g.
+// "AFTER_DATE": "30d"); everything else comes from the service environment.
+type Request struct {
+	SourceProject string            `json:"source_project"`
+	TargetProject string            `json:"target_project"`
+	Options       map[string]string `json:"options,omitempty"`
+}
+
+// Job is a submitted migration as reported by the API
+type Job struct {
+	ID            string            `json:"id"`
+	State         string            `json:"state"`
+	SourceProject string            `json:"source_project"`
+	TargetProject string            `json:"target_project"`
+	Options       map[string]string `json:"options,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	StartedAt     *time.Time        `json:"started_at,omitempty"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+	Progress      *heartbeat.Event  `json:"progress,omitempty"` // latest progress event
+	Error         string            `json:"error,omitempty"`
+	Dir           string            `json:"dir"` // working directory with the log and artifacts
+}
+
+// job is a Job with its process and log
+type job struct {
+	Job
+	env      []string
+	cmd      *exec.Cmd
+	canceled bool
+	requeue  bool // stopped by a service shutdown, to run again after restart
+	lines    []string
+	dropped  int           // lines dropped from the front of lines
+	changed  chan struct{} // closed and replaced when the log or state changes
+}
+
+// Config configures the job server
+type Config struct {
+	Addr       string
+	Executable string // the migration binary, run once per job
+	Dir        string // a directory per job holds its log and artifacts
+	AuthToken  string // required as a bearer token on every request; optional on loopback addresses
+
+	// DB is the SQLite database keeping jobs across restarts, or empty to
+	// keep them in memory. SQLite is the sqlite3 command (default: sqlite3).
+	DB     string
+	SQLite string
+
+	MaxJobs           int           // migrations running at the same time
+	MaxJobsPerProject int           // of those, into one target project
+	Retention         time.Duration // finished jobs are removed after this; 0 keeps them
+}
+
+// Server runs migration jobs submitted over HTTP. Each job runs the
+// migration binary as a child process in its own directory, at most MaxJobs
+// at a time and MaxJobsPerProject per target project; jobs wait in
+// submission order. With a database, jobs survive restarts: queued jobs
+// stay queued and jobs interrupted while running are queued again.
+type Server struct {
+	config Config
+	store  *store
+	srv    *http.Server
+	done   chan struct{}
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []string // job IDs in submission order
+	running int
+	project map[string]int // running jobs per target project
+	closed  bool
+}
+
+// Start restores the jobs kept in the database, resumes the queue and serves
+// the job API
+func Start(config Config) (*Server, error) {
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = 1
+	}
+	if config.MaxJobsPerProject <= 0 || config.MaxJobsPerProject > config.MaxJobs {
+		config.MaxJobsPerProject = config.MaxJobs
+	}
+	if config.AuthToken == "" && !loopback(config.Addr) {
+		return nil, fmt.Errorf("an auth token is required to serve on %s; without one, listen on a loopback address such as 127.0.0.1:8080", config.Addr)
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	s := &Server{
+		config:  config,
+		done:    make(chan struct{}),
+		jobs:    make(map[string]*job),
+		project: make(map[string]int),
+	}
+
+	if config.DB != "" {
+		var err error
+		if s.store, err = openStore(config.DB, config.SQLite); err != nil {
+			return nil, err
+		}
+		if err := s.restore(); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	s.purge()
+	s.schedule()
+	s.mu.Unlock()
+	if config.Retention > 0 {
+		go s.purgeLoop()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.submit)
+	mux.HandleFunc("GET /jobs", s.list)
+	mux.HandleFunc("GET /jobs/{id}", s.get)
+	mux.HandleFunc("GET /jobs/{id}/logs", s.logs)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.cancel)
+	s.srv = &http.Server{Addr: config.Addr, Handler: s.authorize(mux)}
+
+	go func() {
+		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Warning: Job server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("Job server listening on %s (POST /jobs; GET /jobs, /jobs/{id}, /jobs/{id}/logs?follow=true; POST /jobs/{id}/cancel)\n", config.Addr)
+	return s, nil
+}
+
+// Stop stops accepting requests and stops the running jobs, waiting for them
+// to exit. With a database, queued and stopped jobs run again after a
+// restart; otherwise they are canceled.
+func (s *Server) Stop() {
+	s.srv.Close()
+	close(s.done)
+	s.mu.Lock()
+	s.closed = true
+	var running []*job
+	for _, id := range s.order {
+		j := s.jobs[id]
+		switch {
+		case j.State == StateQueued && s.store == nil:
+			s.finish(j, StateCanceled, "service stopped")
+		case j.State == StateRunning:
+			j.requeue = s.store != nil
+			s.stopProcess(j)
+			running = append(running, j)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range running {
+		s.wait(j)
+	}
+}
+
+// loopback reports whether addr only accepts connections from this host
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorize checks the bearer token
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.AuthToken != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AuthToken)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+		return
+	}
+	env, options, err := jobEnv(req.SourceProject, req.TargetProject, req.Options)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	j := &job{
+		Job: Job{
+			ID:            id,
+			State:         StateQueued,
+			SourceProject: req.SourceProject,
+			TargetProject: req.TargetProject,
+			Options:       options,
+			CreatedAt:     time.Now().UTC(),
+			Dir:           filepath.Join(s.config.Dir, id),
+		},
+		env:     env,
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		http.Error(w, "service is stopping", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Printf("Job %s submitted: %s -> %s\n", id, req.SourceProject, req.TargetProject)
+	s.jobs[id] = j
+	s.order = append(s.order, id)
+	s.save(j)
+	s.schedule()
+	snapshot := j.Job
+	s.mu.Unlock()
+
+	respond(w, http.StatusAccepted, snapshot)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id].Job)
+	}
+	s.mu.Unlock()
+	respond(w, http.StatusOK, jobs)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	var snapshot Job
+	if ok {
+		snapshot = j.Job
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	respond(w, http.StatusOK, snapshot)
+}
+
+// logs writes the job's log as plain text. With follow=true the response
+// stays open and streams new lines until the job ends.
+func (s *Server) logs(w http.ResponseWriter, r *http.Request) {
+	follow := r.URL.Query().Get("follow") == "true"
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+
+	next := 0 // index of the next line to write, counting dropped lines
+	for {
+		s.mu.Lock()
+		j, ok := s.jobs[r.PathValue("id")]
+		if !ok {
+			s.mu.Unlock()
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		if next < j.dropped {
+			next = j.dropped
+		}
+		lines := append([]string(nil), j.lines[next-j.dropped:]...)
+		next += len(lines)
+		done := j.FinishedAt != nil
+		changed := j.changed
+		s.mu.Unlock()
+
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+		if !follow || done {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	if !ok {
+		s.mu.Unlock()
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	switch j.State {
+	case StateQueued:
+		s.finish(j, StateCanceled, "canceled before it started")
+	case StateRunning:
+		s.stopProcess(j)
+	default:
+		snapshot := j.Job
+		s.mu.Unlock()
+		respond(w, http.StatusConflict, snapshot)
+		return
+	}
+	snapshot := j.Job
+	s.mu.Unlock()
+
+	fmt.Printf("Job %s: cancel requested\n", j.ID)
+	respond(w, http.StatusAccepted, snapshot)
+}
+
+// schedule starts queued jobs in submission order while there is capacity,
+// overall and in their target project; callers hold s.mu
+func (s *Server) schedule() {
+	for _, id := range s.order {
+		if s.running >= s.config.MaxJobs || s.closed {
+			return
+		}
+		j := s.jobs[id]
+		if j.State == StateQueued && s.project[projectKey(j.TargetProject)] < s.config.MaxJobsPerProject {
+			if err := s.launch(j); err != nil {
+				s.finish(j, StateFailed, err.Error())
+			}
+		}
+	}
+}
+
+// launch starts a job's migration; callers hold s.mu
+func (s *Server) launch(j *job) error {
+	if err := os.MkdirAll(j.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create job directory: %w", err)
+	}
+	// A job run again after a restart continues its log
+	logFile, err := os.OpenFile(filepath.Join(j.Dir, "job.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create job log: %w", err)
+	}
+
+	cmd := exec.Command(s.config.Executable)
+	cmd.Dir = j.Dir
+	cmd.Env = j.env
+	events, err := cmd.StdoutPipe()
+	if err != nil {
+		logFile.Close()
+		return err
+	}
+	output, err := cmd.StderrPipe()
+	if err != nil {
+		logFile.Close()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return fmt.Errorf("failed to start migration: %w", err)
+	}
+
+	now := time.Now().UTC()
+	j.cmd = cmd
+	j.canceled = false
+	j.State = StateRunning
+	j.StartedAt = &now
+	j.Error = ""
+	s.running++
+	s.project[projectKey(j.TargetProject)]++
+	s.save(j)
+	s.notify(j)
+	fmt.Printf("Job %s started: %s -> %s\n", j.ID, j.SourceProject, j.TargetProject)
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		s.readEvents(j, events)
+	}()
+	go func() {
+		defer readers.Done()
+		s.readLog(j, output, logFile)
+	}()
+	go func() {
+		readers.Wait()
+		err := cmd.Wait()
+		logFile.Close()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.running--
+		s.project[projectKey(j.TargetProject)]--
+		switch {
+		case j.requeue:
+			j.State = StateQueued
+			j.StartedAt = nil
+			j.Error = "interrupted by a service shutdown, runs again after restart"
+			s.save(j)
+			s.notify(j)
+			fmt.Printf("Job %s stopped, queued for restart\n", j.ID)
+			return
+		case j.canceled:
+			s.finish(j, StateCanceled, "canceled")
+		case err != nil:
+			s.finish(j, StateFailed, err.Error())
+		default:
+			s.finish(j, StateSucceeded, "")
+		}
+		fmt.Printf("Job %s %s\n", j.ID, j.State)
+		s.schedule()
+	}()
+	return nil
+}
+
+// readEvents records the migration's progress events (QASE_PROGRESS=json)
+func (s *Server) readEvents(j *job, events io.Reader) {
+	scanner := bufio.NewScanner(events)
+	for scanner.Scan() {
+		var event heartbeat.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		s.mu.Lock()
+		phaseChanged := j.Progress == nil || j.Progress.Phase != event.Phase
+		j.Progress = &event
+		if phaseChanged {
+			s.save(j)
+		}
+		s.notify(j)
+		s.mu.Unlock()
+	}
+}
+
+// readLog keeps the migration's output in memory and in the job log
+func (s *Server) readLog(j *job, output io.Reader, logFile *os.File) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(logFile, line)
+		s.mu.Lock()
+		j.lines = append(j.lines, line)
+		if len(j.lines) > logLines {
+			j.dropped += len(j.lines) - logLines
+			j.lines = j.lines[len(j.lines)-logLines:]
+		}
+		s.notify(j)
+		s.mu.Unlock()
+	}
+}
+
+// stopProcess asks a running migration to exit and kills it after
+// cancelGrace; callers hold s.mu
+func (s *Server) stopProcess(j *job) {
+	if j.canceled {
+		return
+	}
+	j.canceled = true
+	process := j.cmd.Process
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		process.Kill()
+		return
+	}
+	go func() {
+		time.Sleep(cancelGrace)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if j.cmd.Process == process && j.State == StateRunning {
+			process.Kill()
+		}
+	}()
+}
+
+// wait blocks until a running job has exited
+func (s *Server) wait(j *job) {
+	for {
+		s.mu.Lock()
+		done, changed := j.State != StateRunning, j.changed
+		s.mu.Unlock()
+		if done {
+			return
+		}
+		<-changed
+	}
+}
+
+// finish records the end of a job; callers hold s.mu
+func (s *Server) finish(j *job, state, message string) {
+	now := time.Now().UTC()
+	j.State = state
+	j.Error = message
+	j.FinishedAt = &now
+	s.save(j)
+	s.notify(j)
+}
+
+// save writes a job to the database; callers hold s.mu
+func (s *Server) save(j *job) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.save(j.Job); err != nil {
+		fmt.Printf("Warning: failed to save job %s: %v\n", j.ID, err)
+	}
+}
+
+// restore loads the jobs kept in the database. Jobs that were running when
+// the service stopped are queued again; migrations are idempotent, so they
+// pick up where they left off.
+func (s *Server) restore() error {
+	jobs, err := s.store.load()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued := 0
+	for _, saved := range jobs {
+		j := &job{Job: saved, changed: make(chan struct{})}
+		if j.State == StateRunning {
+			j.State = StateQueued
+			j.StartedAt = nil
+			j.Error = "interrupted while running, runs again after restart"
+			s.save(j)
+		}
+		if j.State == StateQueued {
+			j.env, _, err = jobEnv(j.SourceProject, j.TargetProject, j.Options)
+			if err != nil {
+				s.finish(j, StateFailed, err.Error())
+			} else {
+				queued++
+			}
+		}
+		j.loadLog()
+		s.jobs[j.ID] = j
+		s.order = append(s.order, j.ID)
+	}
+	fmt.Printf("Restored %d jobs from %s (%d queued)\n", len(jobs), s.config.DB, queued)
+	return nil
+}
+
+// loadLog reads the end of a restored job's log
+func (j *job) loadLog() {
+	file, err := os.Open(filepath.Join(j.Dir, "job.log"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		j.lines = append(j.lines, scanner.Text())
+		if len(j.lines) > logLines {
+			j.dropped += len(j.lines) - logLines
+			j.lines = j.lines[len(j.lines)-logLines:]
+		}
+	}
+}
+
+// purge removes finished jobs older than the retention, with their
+// directories; callers hold s.mu
+func (s *Server) purge() {
+	if s.config.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-s.config.Retention)
+	var expired []string
+	kept := s.order[:0]
+	for _, id := range s.order {
+		j := s.jobs[id]
+		if j.FinishedAt == nil || j.FinishedAt.After(cutoff) {
+			kept = append(kept, id)
+			continue
+		}
+		expired = append(expired, id)
+		delete(s.jobs, id)
+		if err := os.RemoveAll(j.Dir); err != nil {
+			fmt.Printf("Warning: failed to remove directory of job %s: %v\n", id, err)
+		}
+	}
+	s.order = kept
+	if len(expired) == 0 {
+		return
+	}
+	if s.store != nil {
+		if err := s.store.delete(expired); err != nil {
+			fmt.Printf("Warning: failed to remove expired jobs: %v\n", err)
+		}
+	}
+	fmt.Printf("Removed %d jobs finished more than %v ago\n", len(expired), s.config.Retention)
+}
+
+func (s *Server) purgeLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.purge()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// notify wakes up log followers; callers hold s.mu
+func (s *Server) notify(j *job) {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// jobOptions are the configuration variables a job may set, without
+// prefix: how one project pair is migrated. Everything else stays with the
+// service: credentials, API bases, shell commands, write protection,
+// listeners, integrations and shared state. Options marked true are paths,
+// which must stay inside the job directory.
+var jobOptions = map[string]bool{
+	"AFTER_DATE":             false,
+	"BULK_SIZE":              false,
+	"CF_ID":                  false,
+	"CF_NAME":                false,
+	"CF_VALUE_PATTERN":       false,
+	"CF_VALUE_PREFIX":        false,
+	"CHECKPOINT":             true,
+	"COMMENT_NORMALIZE":      false,
+	"CONCURRENCY":            false,
+	"CREATE_STATUSES":        false,
+	"DEBUG":                  false,
+	"DELETED_CASES":          false,
+	"DELETED_RUNS":           false,
+	"DRY_RUN":                false,
+	"DURATION_OVER_MAX":      false,
+	"DURATION_ROUNDING":      false,
+	"EXTERNAL_ID_CF":         false,
+	"FETCH_MODE":             false,
+	"FETCH_RUN_IDS":          false,
+	"FORCE":                  false,
+	"FORCE_CASES":            false,
+	"IDEMPOTENT":             false,
+	"MAPPING_CSV":            true,
+	"MAPPING_TITLES":         false,
+	"MATCH_MODE":             false,
+	"MAX_DURATION":           false,
+	"MAX_FAILED_RUNS":        false,
+	"MAX_PAYLOAD_BYTES":      false,
+	"MAX_RESULTS_PER_RUN":    false,
+	"MAX_SKIPPED_PCT":        false,
+	"MILESTONE":              false,
+	"NEEDS_ATTENTION_FILE":   true,
+	"OVERSIZED_RUNS":         false,
+	"PARAMS_MODE":            false,
+	"PRIORITY_RUNS":          false,
+	"PRIORITY_TAGS":          false,
+	"RAW_ATTACHMENTS":        false,
+	"RESYNC":                 false,
+	"RUN_BUCKET":             false,
+	"RUN_CREATE_BATCH":       false,
+	"RUN_CREATE_CONCURRENCY": false,
+	"RUN_CUSTOM_FIELDS":      false,
+	"RUN_DESCRIPTION_STATS":  false,
+	"RUN_ERROR_FILES":        false,
+	"RUN_ORDER":              false,
+	"RUN_ORDER_DIRECTION":    false,
+	"SAMPLE":                 false,
+	"SHARD":                  false,
+	"SKIP_CASES":             false,
+	"SOURCE_EXTERNAL_ID_CF":  false,
+	"STATUS_MAP":             false,
+	"TIMEOUT":                false,
+	"TIMEZONE":               false,
+}
+
+// checkOption reports why a job may not set a configuration variable, if it
+// may not
+func checkOption(name, value string) error {
+	path, allowed := jobOptions[strings.TrimPrefix(name, envcfg.Prefix)]
+	if !allowed {
+		return fmt.Errorf("option %s cannot be set by a job", name)
+	}
+	if path && value != "" {
+		if strings.Contains(value, "://") || filepath.IsAbs(value) || !filepath.IsLocal(value) {
+			return fmt.Errorf("option %s must be a relative path inside the job directory", name)
+		}
+	}
+	return nil
+}
+
+// jobEnv builds the environment of a job's migration: the service
+// environment, the job's options and the project pair. It returns the
+// options under their QASE_ names.
+func jobEnv(sourceProject, targetProject string, requested map[string]string) ([]string, map[string]string, error) {
+	if sourceProject == "" || targetProject == "" {
+		return nil, nil, fmt.Errorf("source_project and target_project are required")
+	}
+
+	options := make(map[string]string, len(requested)+2)
+	for name, value := range requested {
+		name = envcfg.Prefix + strings.TrimPrefix(strings.ToUpper(name), envcfg.Prefix)
+		if !envcfg.Known(name) {
+			return nil, nil, fmt.Errorf("unknown option: %s", name)
+		}
+		if err := checkOption(name, value); err != nil {
+			return nil, nil, err
+		}
+		options[name] = value
+	}
+	options["QASE_SOURCE_PROJECT"] = sourceProject
+	options["QASE_TARGET_PROJECT"] = targetProject
+
+	var env []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, overridden := options[name]; overridden || name == "QASE_PROGRESS" || name == "QASE_TUI" || strings.HasPrefix(name, "QASE_SERVE_") {
+			continue
+		}
+		env = append(env, entry)
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+options[name])
+	}
+	env = append(env, "QASE_PROGRESS=json")
+	return env, options, nil
+}
+
+// projectKey identifies a job's target project for the per-project limit.
+// Every job writes with the service's token, so jobs share one workspace
+// and differ only by project; project codes are case-insensitive.
+func projectKey(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func newID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create job ID: %w", err)
+	}
+	return time.Now().UTC().Format("20060102-150405-") + hex.EncodeToString(b), nil
+}
+
+func respond(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleHoldsBackJobsForABusyProject(t *testing.T) {
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "migrate")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		config:  Config{Executable: executable, Dir: dir, MaxJobs: 3, MaxJobsPerProject: 1},
+		jobs:    make(map[string]*job),
+		project: make(map[string]int),
+	}
+	submit := func(id, target string) *job {
+		j := &job{
+			Job:     Job{ID: id, State: StateQueued, SourceProject: "SRC", TargetProject: target, Dir: filepath.Join(dir, id)},
+			changed: make(chan struct{}),
+		}
+		s.jobs[id] = j
+		s.order = append(s.order, id)
+		return j
+	}
+	first := submit("first", "DEMO")
+	second := submit("second", "demo") // project codes are case-insensitive
+	other := submit("other", "OTHER")
+
+	s.mu.Lock()
+	s.schedule()
+	states := []string{first.State, second.State, other.State}
+	s.mu.Unlock()
+	t.Cleanup(func() {
+		s.mu.Lock()
+		s.closed = true
+		var running []*job
+		for _, j := range s.jobs {
+			if j.State == StateRunning {
+				s.stopProcess(j)
+				running = append(running, j)
+			}
+		}
+		s.mu.Unlock()
+		for _, j := range running {
+			s.wait(j)
+		}
+	})
+
+	if states[0] != StateRunning || states[1] != StateQueued || states[2] != StateRunning {
+		t.Fatalf("states = %v, want first and other running, second queued", states)
+	}
+
+	// The second job starts once the first one ends
+	s.mu.Lock()
+	s.stopProcess(first)
+	s.mu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		state := second.State
+		s.mu.Unlock()
+		if state == StateRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("second job did not start after the first ended, state %s", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
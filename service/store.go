@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// store keeps jobs in a SQLite database by piping SQL to the sqlite3 command
+// line shell, so no database driver is needed. The command defaults to
+// sqlite3 and can be replaced with QASE_SERVE_SQLITE.
+type store struct {
+	path    string
+	command string
+	mu      sync.Mutex // one sqlite3 process at a time
+}
+
+// openStore opens or creates the job database at path
+func openStore(path, command string) (*store, error) {
+	if command == "" {
+		command = "sqlite3"
+	}
+	if err := checkSQLite(command); err != nil {
+		return nil, err
+	}
+	s := &store{path: path, command: command}
+	err := s.exec(`CREATE TABLE IF NOT EXISTS jobs (
+  id text PRIMARY KEY,
+  state text NOT NULL,
+  created_at text NOT NULL,
+  finished_at text,
+  job text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS jobs_created_at ON jobs (created_at);
+`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job database %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// save inserts or updates a job
+func (s *store) save(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	finished := "NULL"
+	if job.FinishedAt != nil {
+		finished = sqlString(job.FinishedAt.Format(time.RFC3339Nano))
+	}
+	return s.exec(fmt.Sprintf(`INSERT INTO jobs (id, state, created_at, finished_at, job) VALUES (%s, %s, %s, %s, %s)
+ON CONFLICT (id) DO UPDATE SET state = excluded.state, finished_at = excluded.finished_at, job = excluded.job;
+`, sqlString(job.ID), sqlString(job.State), sqlString(job.CreatedAt.Format(time.RFC3339Nano)), finished, sqlString(string(data))), nil)
+}
+
+// load returns all jobs in submission order
+func (s *store) load() ([]Job, error) {
+	var out bytes.Buffer
+	if err := s.exec("SELECT job FROM jobs ORDER BY created_at, id;\n", &out); err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(out.Bytes())) == 0 {
+		return nil, nil // no rows
+	}
+	var rows []struct {
+		Job string `json:"job"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		return nil, fmt.Errorf("failed to read job database: %w", err)
+	}
+	jobs := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		var job Job
+		if err := json.Unmarshal([]byte(row.Job), &job); err != nil {
+			return nil, fmt.Errorf("failed to read job database: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// delete removes jobs
+func (s *store) delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = sqlString(id)
+	}
+	return s.exec(fmt.Sprintf("DELETE FROM jobs WHERE id IN (%s);\n", strings.Join(quoted, ", ")), nil)
+}
+
+// exec runs SQL through sqlite3, stopping at the first error. Query results
+// are written to out as a JSON array.
+func (s *store) exec(sql string, out *bytes.Buffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmd := exec.Command("sh", "-c", s.command+` -bail -json "$SERVE_DB"`)
+	cmd.Env = append(os.Environ(), "SERVE_DB="+s.path)
+	cmd.Stdin = strings.NewReader(".timeout 5000\n" + sql)
+	if out != nil {
+		cmd.Stdout = out
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sqlite3 failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// checkSQLite verifies that command runs a sqlite3 shell recent enough for
+// -json output (3.33)
+func checkSQLite(command string) error {
+	hint := "install it, point QASE_SERVE_SQLITE at one, or set QASE_SERVE_DB=off to keep jobs in memory"
+	out, err := exec.Command("sh", "-c", command+" -version").Output()
+	if err != nil {
+		return fmt.Errorf("the job database needs the sqlite3 command line shell 3.33 or later, but %q failed: %w (%s)", command, err, hint)
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(out), "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("the job database needs the sqlite3 command line shell 3.33 or later, but %q reported version %q (%s)", command, strings.TrimSpace(string(out)), hint)
+	}
+	if major < 3 || major == 3 && minor < 33 {
+		return fmt.Errorf("the job database needs the sqlite3 command line shell 3.33 or later, found %d.%d (%s)", major, minor, hint)
+	}
+	return nil
+}
+
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,57 @@
+package settings
+
+import (
+	"fmt"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+)
+
+// Mapping configures how source cases are matched to target cases
+type Mapping struct {
+	MatchMode       mapping.Mode
+	CustomFieldID   int
+	CustomFieldName string // resolved to CustomFieldID at runtime when no ID is set
+	CFValueRules    *mapping.ValueRules
+	MappingCSV      string
+
+	// External ID fields (ID or title) for external_id mode
+	SourceExternalIDField string
+	TargetExternalIDField string
+}
+
+// LoadMapping loads the case mapping settings. With validate set, the
+// settings the match mode needs are required; without, every setting is
+// loaded as is, for callers validating them later (e.g. per batch pair).
+func LoadMapping(p *Problems, validate bool) Mapping {
+	var m Mapping
+	var err error
+	m.MatchMode, err = mapping.ParseMode(Get("QASE_MATCH_MODE", string(mapping.ModeCF)))
+	if err != nil {
+		p.Add(fmt.Errorf("invalid QASE_MATCH_MODE: %w", err))
+	}
+
+	switch {
+	case !validate:
+		m.CustomFieldID = p.Int("QASE_CF_ID", 0)
+		m.CustomFieldName = Get("QASE_CF_NAME", "")
+		m.MappingCSV = Get("QASE_MAPPING_CSV", "")
+		m.TargetExternalIDField = Get("QASE_EXTERNAL_ID_CF", "")
+		m.SourceExternalIDField = Get("QASE_SOURCE_EXTERNAL_ID_CF", m.TargetExternalIDField)
+	case m.MatchMode == mapping.ModeCF:
+		m.CustomFieldID = p.Int("QASE_CF_ID", 0)
+		m.CustomFieldName = Get("QASE_CF_NAME", "")
+		if m.CustomFieldID == 0 && m.CustomFieldName == "" {
+			p.Add(fmt.Errorf("QASE_CF_ID or QASE_CF_NAME is required for custom_field mode (the custom field holding the linked case ID, or choose another QASE_MATCH_MODE)"))
+		}
+	case m.MatchMode == mapping.ModeCSV:
+		m.MappingCSV = p.Required("QASE_MAPPING_CSV", "required for csv mode: a source,target case ID CSV, e.g. from go run ./cmd/generate-mapping")
+	case m.MatchMode == mapping.ModeExternalID:
+		m.TargetExternalIDField = p.Required("QASE_EXTERNAL_ID_CF", "required for external_id mode: the custom field ID or title holding the external ID")
+		m.SourceExternalIDField = Get("QASE_SOURCE_EXTERNAL_ID_CF", m.TargetExternalIDField)
+	}
+
+	// Prefix and pattern rules for non-numeric custom field values
+	m.CFValueRules, err = mapping.ParseValueRules(Get("QASE_CF_VALUE_PREFIX", ""), Get("QASE_CF_VALUE_PATTERN", ""))
+	p.Add(err)
+	return m
+}
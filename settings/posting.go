@@ -0,0 +1,23 @@
+package settings
+
+import "fmt"
+
+// Posting holds how results are written to the target workspace
+type Posting struct {
+	DryRun   bool
+	BulkSize int
+}
+
+// LoadPosting loads the posting settings. Every binary is a dry run unless
+// QASE_DRY_RUN=false, so the same environment never writes in one binary
+// and only reports in another.
+func LoadPosting(p *Problems) Posting {
+	posting := Posting{
+		DryRun:   Get("QASE_DRY_RUN", "true") == "true",
+		BulkSize: p.Int("QASE_BULK_SIZE", 200),
+	}
+	if posting.BulkSize <= 0 {
+		p.Add(fmt.Errorf("QASE_BULK_SIZE must be positive"))
+	}
+	return posting
+}
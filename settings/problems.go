@@ -0,0 +1,70 @@
+// Package settings loads the configuration shared by the migration binaries
+// (the main migration, migrate-data and repair) from the environment.
+package settings
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Problems collects configuration problems so loaders report all of them at
+// once instead of stopping at the first
+type Problems []error
+
+// Add records err, if not nil
+func (p *Problems) Add(err error) {
+	if err != nil {
+		*p = append(*p, err)
+	}
+}
+
+// Required returns the value of key, recording a problem with a remediation
+// hint when it is unset
+func (p *Problems) Required(key, hint string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		p.Add(fmt.Errorf("%s is not set (%s)", key, hint))
+	}
+	return value
+}
+
+// Int returns the integer value of key or defaultValue when unset,
+// recording values that are not integers instead of silently using the
+// default
+func (p *Problems) Int(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		p.Add(fmt.Errorf("%s must be an integer, got %q", key, value))
+		return defaultValue
+	}
+	return intValue
+}
+
+// Err returns nil without problems, else one error listing every problem
+func (p Problems) Err() error {
+	switch len(p) {
+	case 0:
+		return nil
+	case 1:
+		return p[0]
+	}
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = "  - " + problem.Error()
+	}
+	return fmt.Errorf("%d configuration problems:\n%s", len(p), strings.Join(lines, "\n"))
+}
+
+// Get returns the value of key, or defaultValue when unset
+func Get(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,56 @@
+package settings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// DefaultBaseURL is the API base of both workspaces unless set
+const DefaultBaseURL = "https://api.qase.io"
+
+// Workspaces are the source and target workspaces and projects of a migration
+type Workspaces struct {
+	// Source workspace
+	SourceToken   string
+	SourceBaseURL string
+	SourceProject string
+
+	// Target workspace
+	TargetToken   string
+	TargetBaseURL string
+	TargetProject string
+}
+
+// LoadWorkspaces loads both workspaces, requiring their tokens and projects
+func LoadWorkspaces(p *Problems) Workspaces {
+	return Workspaces{
+		SourceToken:   p.Required("QASE_SOURCE_API_TOKEN", "an API token of the source workspace"),
+		SourceBaseURL: Get("QASE_SOURCE_API_BASE", DefaultBaseURL),
+		SourceProject: p.Required("QASE_SOURCE_PROJECT", "the source project code; go run ./cmd/projects list shows the codes a token can see"),
+		TargetToken:   p.Required("QASE_TARGET_API_TOKEN", "an API token of the target workspace"),
+		TargetBaseURL: Get("QASE_TARGET_API_BASE", DefaultBaseURL),
+		TargetProject: p.Required("QASE_TARGET_PROJECT", "the target project code; go run ./cmd/projects list shows the codes a token can see"),
+	}
+}
+
+// Client holds the API client settings
+type Client struct {
+	MaxPayloadBytes int
+	CacheDir        string
+	CacheTTL        time.Duration
+}
+
+// LoadClient loads the API client settings
+func LoadClient(p *Problems) Client {
+	client := Client{
+		MaxPayloadBytes: p.Int("QASE_MAX_PAYLOAD_BYTES", qase.DefaultMaxPayloadBytes),
+		CacheDir:        Get("QASE_CACHE_DIR", ""),
+		CacheTTL:        time.Duration(p.Int("QASE_CACHE_TTL", 300)) * time.Second,
+	}
+	if client.MaxPayloadBytes <= 0 || client.CacheTTL < 0 {
+		p.Add(fmt.Errorf("QASE_MAX_PAYLOAD_BYTES must be positive and QASE_CACHE_TTL not negative"))
+	}
+	return client
+}
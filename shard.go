@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// shard is this process's slice of the run groups when several jobs migrate
+// one project cooperatively (index is 1-based)
+type shard struct {
+	index int
+	count int
+}
+
+// parseShard parses a QASE_SHARD value such as "2/5". An empty value means
+// a single shard that takes every run.
+func parseShard(value string) (shard, error) {
+	if value == "" {
+		return shard{index: 1, count: 1}, nil
+	}
+
+	indexStr, countStr, ok := strings.Cut(value, "/")
+	index, indexErr := strconv.Atoi(strings.TrimSpace(indexStr))
+	count, countErr := strconv.Atoi(strings.TrimSpace(countStr))
+	if !ok || indexErr != nil || countErr != nil || count < 1 || index < 1 || index > count {
+		return shard{}, fmt.Errorf("invalid QASE_SHARD: %s (expected i/n with 1 <= i <= n, e.g. 2/5)", value)
+	}
+	return shard{index: index, count: count}, nil
+}
+
+// owns reports whether a run group belongs to this shard. Groups are assigned
+// by a hash of the group key (the source run, bucket or part), which unlike
+// the title does not depend on the fetched results, so every shard computes
+// the same partition and each target run is written by exactly one job.
+func (s shard) owns(group runGroup) bool {
+	if s.count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(group.key))
+	return int(h.Sum32()%uint32(s.count)) == s.index-1
+}
+
+// filter returns the run groups owned by this shard
+func (s shard) filter(groups []runGroup) []runGroup {
+	if s.count <= 1 {
+		return groups
+	}
+	owned := make([]runGroup, 0, len(groups)/s.count+1)
+	for _, group := range groups {
+		if s.owns(group) {
+			owned = append(owned, group)
+		}
+	}
+	return owned
+}
+
+func (s shard) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// checkStatuses resolves QASE_STATUS_MAP entries given by title to slugs and
+// verifies that every status the results will be posted with exists in the
+// target workspace. Source statuses missing in the target are mapped
+// automatically to a target status with the same title, or created in the
+// target when create is set.
+func checkStatuses(src source, tgtClient *api.Client, statusMap map[string]string, results []qase.Result, create, dryRun bool) (map[string]string, error) {
+	if caps := tgtClient.Capabilities; caps != nil && !caps.CustomStatuses {
+		fmt.Printf("Target workspace does not list result statuses, skipping status check\n")
+		return statusMap, nil
+	}
+	tgtStatuses, err := qase.GetResultStatuses(tgtClient)
+	if err != nil {
+		fmt.Printf("Warning: Could not fetch target result statuses, skipping status check: %v\n", err)
+		return statusMap, nil
+	}
+	srcStatuses, err := src.resultStatuses()
+	if err != nil {
+		fmt.Printf("Warning: Could not fetch source result statuses: %v\n", err)
+	}
+
+	resolved := make(map[string]string, len(statusMap))
+	for from, to := range statusMap {
+		if slug, ok := qase.ResolveStatus(srcStatuses, from); ok {
+			from = slug
+		}
+		if slug, ok := qase.ResolveStatus(tgtStatuses, to); ok {
+			to = slug
+		}
+		resolved[from] = to
+	}
+
+	missing := make(map[string]int)
+	for _, result := range results {
+		status := result.Status
+		if mapped, exists := resolved[status]; exists {
+			status = mapped
+		}
+		if _, exists := tgtStatuses[status]; exists {
+			continue
+		}
+
+		// Match custom statuses whose slugs differ between workspaces by title
+		if src, exists := srcStatuses[status]; exists && status == result.Status {
+			if slug, ok := qase.ResolveStatus(tgtStatuses, src.Title); ok {
+				fmt.Printf("Mapping status '%s' to target status '%s' by title\n", status, slug)
+				resolved[result.Status] = slug
+				continue
+			}
+		}
+		missing[status]++
+	}
+
+	if create {
+		for _, slug := range (&missingStatusesError{Results: missing}).Statuses() {
+			// Only custom statuses of the source workspace can be recreated
+			status, exists := srcStatuses[slug]
+			if !exists || status.IsDefault {
+				continue
+			}
+			if dryRun {
+				fmt.Printf("DRY RUN MODE - Would create result status '%s' (%s) in the target workspace\n", status.Title, slug)
+				delete(missing, slug)
+				continue
+			}
+			created, err := qase.CreateResultStatus(tgtClient, status.Title, slug)
+			if err != nil {
+				fmt.Printf("Warning: Failed to create result status '%s' in the target workspace: %v\n", slug, err)
+				continue
+			}
+			fmt.Printf("Created result status '%s' (%s) in the target workspace\n", created.Title, created.Slug)
+			tgtStatuses[created.Slug] = created
+			delete(missing, slug)
+		}
+	}
+
+	if len(missing) > 0 {
+		available := make([]string, 0, len(tgtStatuses))
+		for slug := range tgtStatuses {
+			available = append(available, slug)
+		}
+		sort.Strings(available)
+		return nil, &missingStatusesError{Results: missing, Available: available}
+	}
+
+	fmt.Printf("All result statuses are available in the target workspace (%d statuses)\n", len(tgtStatuses))
+	return resolved, nil
+}
+
+// missingStatusesError lists the statuses missing in the target workspace with
+// the number of results posted with each
+type missingStatusesError struct {
+	Results   map[string]int
+	Available []string // target statuses, sorted
+}
+
+// Statuses returns the missing statuses in order
+func (e *missingStatusesError) Statuses() []string {
+	names := make([]string, 0, len(e.Results))
+	for name := range e.Results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *missingStatusesError) Error() string {
+	return fmt.Sprintf("target workspace has no result statuses %v: create them in the target workspace settings (or with QASE_CREATE_STATUSES=true) or map them with QASE_STATUS_MAP to one of %s",
+		e.Statuses(), strings.Join(e.Available, ", "))
+}
@@ -0,0 +1,50 @@
+package target
+
+import (
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+)
+
+// Qase is the default target: runs and results in a Qase project
+type Qase struct {
+	Client   *api.Client
+	Project  string
+	Runs     *qase.RunIndex // existing runs are reused when set; nil always creates
+	BulkSize int
+}
+
+// NewQase creates a Qase target for a project
+func NewQase(c *api.Client, project string, runs *qase.RunIndex, bulkSize int) *Qase {
+	return &Qase{Client: c, Project: project, Runs: runs, BulkSize: bulkSize}
+}
+
+// FindRun looks the run up in the run index
+func (q *Qase) FindRun(marker, title string) (*qase.Run, error) {
+	if q.Runs == nil {
+		return nil, nil
+	}
+	return q.Runs.Find(marker, title), nil
+}
+
+// CreateRun returns the indexed run for marker when there is a run index, and
+// otherwise always creates a new run
+func (q *Qase) CreateRun(marker, title, description string, opts qase.RunOptions) (*qase.Run, error) {
+	if q.Runs != nil {
+		return qase.CreateOrGetIndexedRun(q.Client, q.Runs, q.Project, marker, title, description, opts)
+	}
+	return qase.CreateRunWithOptions(q.Client, q.Project, title, qase.WithRunMarker(description, marker), opts)
+}
+
+// PostResults posts results in chunks of BulkSize
+func (q *Qase) PostResults(runID int, items []qase.BulkItem, span *tracing.Span) error {
+	return qase.PostBulkResultsWithSpan(q.Client, q.Project, runID, items, q.BulkSize, span)
+}
+
+// ForWorker returns a view of the target whose requests count against the
+// worker's share of the target rate
+func (q *Qase) ForWorker(worker string) Target {
+	view := *q
+	view.Client = q.Client.ForWorker(worker)
+	return &view
+}
@@ -0,0 +1,39 @@
+package target
+
+import (
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+)
+
+// Target receives migrated runs and their results. Qase is the default;
+// other implementations let fetched and transformed results be pushed to
+// another test management system or an exporter without touching the fetch
+// and transform code.
+type Target interface {
+	// FindRun returns the run previously migrated from marker (or titled
+	// title), or nil if there is none. Idempotent migrations call it before
+	// CreateRun.
+	FindRun(marker, title string) (*qase.Run, error)
+
+	// CreateRun creates a run recording the source marker. Targets that
+	// reuse existing runs return the run found for marker instead.
+	CreateRun(marker, title, description string, opts qase.RunOptions) (*qase.Run, error)
+
+	// PostResults adds results to a run
+	PostResults(runID int, items []qase.BulkItem, span *tracing.Span) error
+}
+
+// workerTarget is implemented by targets that share a request rate among
+// post workers
+type workerTarget interface {
+	ForWorker(worker string) Target
+}
+
+// ForWorker returns t with its requests attributed to a post worker, or t
+// itself when the target does not track workers
+func ForWorker(t Target, worker string) Target {
+	if w, ok := t.(workerTarget); ok {
+		return w.ForWorker(worker)
+	}
+	return t
+}
@@ -0,0 +1,99 @@
+package target
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+)
+
+// Tee writes runs and results to a primary target and mirrors them to
+// copies, such as a warehouse export next to the Qase project. Callers see
+// the primary's run IDs; each copy's own run IDs are tracked per run.
+type Tee struct {
+	primary Target
+	copies  []Target
+	runs    *teeRuns
+}
+
+type teeRuns struct {
+	mu  sync.Mutex
+	ids map[int][]int // primary run ID -> run ID in each copy
+}
+
+// NewTee creates a target writing to primary and mirroring to copies
+func NewTee(primary Target, copies ...Target) *Tee {
+	return &Tee{primary: primary, copies: copies, runs: &teeRuns{ids: make(map[int][]int)}}
+}
+
+// FindRun returns the primary's run for marker when every copy has one
+// too. Otherwise it returns nil, and CreateRun reuses the primary's run and
+// creates the missing copies.
+func (t *Tee) FindRun(marker, title string) (*qase.Run, error) {
+	run, err := t.primary.FindRun(marker, title)
+	if err != nil || run == nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(t.copies))
+	for i, c := range t.copies {
+		copyRun, err := c.FindRun(marker, title)
+		if err != nil || copyRun == nil {
+			return nil, err
+		}
+		ids[i] = copyRun.ID
+	}
+
+	t.runs.mu.Lock()
+	t.runs.ids[run.ID] = ids
+	t.runs.mu.Unlock()
+	return run, nil
+}
+
+// CreateRun creates the run in the primary target, then in every copy
+func (t *Tee) CreateRun(marker, title, description string, opts qase.RunOptions) (*qase.Run, error) {
+	run, err := t.primary.CreateRun(marker, title, description, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(t.copies))
+	for i, c := range t.copies {
+		copyRun, err := c.CreateRun(marker, title, description, opts)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = copyRun.ID
+	}
+
+	t.runs.mu.Lock()
+	t.runs.ids[run.ID] = ids
+	t.runs.mu.Unlock()
+	return run, nil
+}
+
+// PostResults mirrors results to the copies first, then posts them to the
+// primary target. A failed copy leaves the primary untouched, so a rerun
+// delivers the results to both.
+func (t *Tee) PostResults(runID int, items []qase.BulkItem, span *tracing.Span) error {
+	t.runs.mu.Lock()
+	ids, ok := t.runs.ids[runID]
+	t.runs.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("run %d was not created through this target", runID)
+	}
+
+	for i, c := range t.copies {
+		if err := c.PostResults(ids[i], items, span); err != nil {
+			return err
+		}
+	}
+	return t.primary.PostResults(runID, items, span)
+}
+
+// ForWorker returns a view whose primary requests count against the
+// worker's share of the target rate
+func (t *Tee) ForWorker(worker string) Target {
+	return &Tee{primary: ForWorker(t.primary, worker), copies: t.copies, runs: t.runs}
+}
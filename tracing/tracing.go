@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span represents a timed operation in a migration phase
+type Span struct {
+	name       string
+	traceID    string
+	spanID     string
+	parentID   string
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+	mu         sync.Mutex
+}
+
+// tracer buffers finished spans and exports them in batches
+type tracer struct {
+	serviceName string
+	exporter    *otlpExporter
+	batchSize   int
+
+	mu      sync.Mutex
+	pending []*Span
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalTracer *tracer
+)
+
+// Init enables tracing when an OTLP endpoint is configured through the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables.
+// When no endpoint is set, all spans are no-ops.
+func Init(defaultServiceName string) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	globalMu.Lock()
+	globalTracer = &tracer{
+		serviceName: serviceName,
+		exporter:    newOTLPExporter(endpoint, parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))),
+		batchSize:   512,
+	}
+	globalMu.Unlock()
+
+	fmt.Printf("Tracing enabled: exporting spans to %s\n", endpoint)
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalTracer != nil
+}
+
+// Start begins a new span. A nil parent starts a new trace.
+func Start(name string, parent *Span) *Span {
+	if !Enabled() {
+		return nil
+	}
+
+	span := &Span{
+		name:       name,
+		spanID:     randomHex(8),
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+
+	return span
+}
+
+// SetAttr records an attribute on the span
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	globalMu.RLock()
+	t := globalTracer
+	globalMu.RUnlock()
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	var batch []*Span
+	if len(t.pending) >= t.batchSize {
+		batch = t.pending
+		t.pending = nil
+	}
+	t.mu.Unlock()
+
+	if batch != nil {
+		t.export(batch)
+	}
+}
+
+// Shutdown flushes any buffered spans to the exporter
+func Shutdown() {
+	globalMu.RLock()
+	t := globalTracer
+	globalMu.RUnlock()
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(batch) > 0 {
+		t.export(batch)
+	}
+}
+
+func (t *tracer) export(batch []*Span) {
+	if err := t.exporter.Export(t.serviceName, batch); err != nil {
+		fmt.Printf("Warning: Failed to export %d spans: %v\n", len(batch), err)
+	}
+}
+
+// parseHeaders parses the OTEL "key1=value1,key2=value2" header format
+func parseHeaders(headersStr string) map[string]string {
+	headers := make(map[string]string)
+	if headersStr == "" {
+		return headers
+	}
+
+	for _, pair := range strings.Split(headersStr, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strings.Repeat("0", n*2)
+	}
+	return hex.EncodeToString(b)
+}
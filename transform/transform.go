@@ -0,0 +1,251 @@
+package transform
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/comment"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// Where the parameter set of a parameterized result goes
+const (
+	ParamsPost    = "post"    // as the result's params
+	ParamsComment = "comment" // as a "Parameters:" line in the comment
+	ParamsDrop    = "drop"
+)
+
+// Options control how source results become target bulk items. Every tool
+// posting or previewing results loads them with LoadOptions, so the same
+// variables give the same output everywhere.
+type Options struct {
+	StatusMap map[string]string // source status -> target status
+	Comments  *comment.Pipeline
+	Durations qase.DurationPolicy
+	Params    string // Params* ("" for ParamsPost)
+	Cases     CaseOverrides
+}
+
+// CaseOverrides are decisions about known-bad source cases that take
+// precedence over the case mapping
+type CaseOverrides struct {
+	Skip  map[int]bool // results never posted nor reported as unmapped
+	Force map[int]int  // source case -> target case, whatever the mapping says
+}
+
+// TargetCase returns the target case of a source case, or false for skipped
+// and unmapped cases
+func (o CaseOverrides) TargetCase(caseID int, caseMapping map[int]int) (int, bool) {
+	if o.Skip[caseID] {
+		return 0, false
+	}
+	if targetID, exists := o.Force[caseID]; exists {
+		return targetID, true
+	}
+	targetID, exists := caseMapping[caseID]
+	return targetID, exists
+}
+
+// LoadOptions reads QASE_STATUS_MAP, QASE_COMMENT_NORMALIZE,
+// QASE_COMMENT_HOOK, QASE_MAX_DURATION, QASE_DURATION_OVER_MAX,
+// QASE_DURATION_ROUNDING, QASE_PARAMS_MODE, QASE_SKIP_CASES and
+// QASE_FORCE_CASES
+func LoadOptions() (Options, error) {
+	var opts Options
+	var err error
+	opts.StatusMap, err = ParseStatusMap(os.Getenv("QASE_STATUS_MAP"))
+	if err != nil {
+		return opts, fmt.Errorf("failed to parse QASE_STATUS_MAP: %w", err)
+	}
+
+	opts.Comments, err = comment.NewPipeline(os.Getenv("QASE_COMMENT_NORMALIZE"), os.Getenv("QASE_COMMENT_HOOK"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid QASE_COMMENT_NORMALIZE: %w", err)
+	}
+
+	opts.Durations, err = qase.NewDurationPolicy(os.Getenv("QASE_MAX_DURATION"), os.Getenv("QASE_DURATION_OVER_MAX"), os.Getenv("QASE_DURATION_ROUNDING"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid QASE_MAX_DURATION, QASE_DURATION_OVER_MAX or QASE_DURATION_ROUNDING: %w", err)
+	}
+
+	opts.Params = os.Getenv("QASE_PARAMS_MODE")
+	if opts.Params == "" {
+		opts.Params = ParamsPost
+	}
+	if err := ValidateParamsMode(opts.Params); err != nil {
+		return opts, err
+	}
+
+	opts.Cases, err = ParseCaseOverrides(os.Getenv("QASE_SKIP_CASES"), os.Getenv("QASE_FORCE_CASES"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid QASE_SKIP_CASES or QASE_FORCE_CASES: %w", err)
+	}
+	return opts, nil
+}
+
+// ParseCaseOverrides parses comma-separated source case IDs to skip and
+// "source:target" case pairs to force
+func ParseCaseOverrides(skip, force string) (CaseOverrides, error) {
+	var overrides CaseOverrides
+	for _, value := range strings.Split(skip, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		caseID, err := strconv.Atoi(value)
+		if err != nil || caseID <= 0 {
+			return overrides, fmt.Errorf("invalid case ID: %s", value)
+		}
+		if overrides.Skip == nil {
+			overrides.Skip = make(map[int]bool)
+		}
+		overrides.Skip[caseID] = true
+	}
+
+	for _, pair := range strings.Split(force, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return overrides, fmt.Errorf("invalid case pair: %s", pair)
+		}
+		sourceID, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || sourceID <= 0 {
+			return overrides, fmt.Errorf("invalid case pair: %s", pair)
+		}
+		targetID, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || targetID <= 0 {
+			return overrides, fmt.Errorf("invalid case pair: %s", pair)
+		}
+		if overrides.Skip[sourceID] {
+			return overrides, fmt.Errorf("case %d is both skipped and forced", sourceID)
+		}
+		if overrides.Force == nil {
+			overrides.Force = make(map[int]int)
+		}
+		overrides.Force[sourceID] = targetID
+	}
+	return overrides, nil
+}
+
+// ValidateParamsMode checks a QASE_PARAMS_MODE value
+func ValidateParamsMode(mode string) error {
+	switch mode {
+	case ParamsPost, ParamsComment, ParamsDrop:
+		return nil
+	default:
+		return fmt.Errorf("unsupported QASE_PARAMS_MODE: %s (use post, comment or drop)", mode)
+	}
+}
+
+// ParseStatusMap parses "from:to" pairs separated by commas, returning nil
+// for an empty string
+func ParseStatusMap(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	statusMap := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid status mapping pair: %s", pair)
+		}
+		from, err := qase.ParseStatus(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status mapping pair %s: %w", pair, err)
+		}
+		to, err := qase.ParseStatus(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status mapping pair %s: %w", pair, err)
+		}
+		statusMap[string(from)] = string(to)
+	}
+	return statusMap, nil
+}
+
+// Results maps source results to target case IDs, returning the bulk items,
+// the number of results without a mapped case and the durations not posted
+// as recorded. Results of skipped cases are left out without being counted.
+func Results(results []qase.Result, caseMapping map[int]int, opts Options) ([]qase.BulkItem, int, qase.DurationStats) {
+	var bulkItems []qase.BulkItem
+	var kept []qase.Result
+	var stats qase.DurationStats
+	skipped := 0
+
+	for _, result := range results {
+		if opts.Cases.Skip[result.CaseID] {
+			continue
+		}
+		targetCaseID, exists := opts.Cases.TargetCase(result.CaseID, caseMapping)
+		if !exists {
+			skipped++
+			continue
+		}
+
+		status := result.Status
+		if mappedStatus, exists := opts.StatusMap[status]; exists {
+			status = mappedStatus
+		}
+
+		bulkItems = append(bulkItems, qase.BulkItem{
+			CaseID: targetCaseID,
+			Status: status,
+			Time:   Duration(result, opts.Durations, &stats),
+		})
+		kept = append(kept, result)
+	}
+
+	// Comments are normalized together, so a hook runs once per run
+	comments := make([]string, len(kept))
+	for i, result := range kept {
+		comments[i] = result.Comment
+	}
+	comments = opts.Comments.Apply(comments)
+	for i, result := range kept {
+		text := comments[i]
+		switch opts.Params {
+		case ParamsPost, "":
+			bulkItems[i].Param = result.Param
+		case ParamsComment:
+			text = WithParams(text, result.Param)
+		}
+		bulkItems[i].Comment = qase.WithSourceMarker(text, result.Hash)
+	}
+
+	return bulkItems, skipped, stats
+}
+
+// Duration returns the time to post for a result: its duration in
+// milliseconds when recorded, else its time in seconds
+func Duration(result qase.Result, policy qase.DurationPolicy, stats *qase.DurationStats) *int {
+	if result.TimeSpentMs != 0 {
+		return policy.Milliseconds(result.CaseID, result.TimeSpentMs, stats)
+	}
+	return policy.Seconds(result.CaseID, result.Time, stats)
+}
+
+// WithParams appends a "Parameters: name=value, ..." line, sorted by name, to
+// a comment
+func WithParams(comment string, params qase.Params) string {
+	if len(params) == 0 {
+		return comment
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + params[name]
+	}
+	line := "Parameters: " + strings.Join(pairs, ", ")
+	if comment == "" {
+		return line
+	}
+	return comment + "\n\n" + line
+}
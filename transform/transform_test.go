@@ -0,0 +1,39 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/comment"
+	"github.com/adrianeortiz/clone-run-multi-ws/mockserver"
+	"github.com/adrianeortiz/clone-run-multi-ws/transform"
+)
+
+func BenchmarkTransformResults(b *testing.B) {
+	// Run sizes are log-normal around the median, averaging ~1.38x of it
+	fixture := mockserver.Generate(mockserver.FixtureOptions{Cases: 10000, Runs: 100, ResultsPerRun: 724, Seed: 1})
+	caseMapping := make(map[int]int, len(fixture.SourceCases))
+	for _, c := range fixture.SourceCases {
+		caseMapping[c.ID] = c.ID
+	}
+	statusMap := map[string]string{"blocked": "skipped", "invalid": "failed"}
+	comments, err := comment.NewPipeline(comment.StripANSI+","+comment.NormalizeNewlines+","+comment.TrimSpace, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bm := range []struct {
+		name string
+		opts transform.Options
+	}{
+		{"plain", transform.Options{StatusMap: statusMap}},
+		{"normalize", transform.Options{StatusMap: statusMap, Comments: comments}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				transform.Results(fixture.Results, caseMapping, bm.opts)
+			}
+			b.ReportMetric(float64(len(fixture.Results)*b.N)/b.Elapsed().Seconds(), "results/s")
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package triage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// Kind is the category of an item needing attention
+type Kind string
+
+const (
+	KindUnmappedCase     Kind = "unmapped_case"
+	KindFailedRun        Kind = "failed_run"
+	KindFailedChunk      Kind = "failed_chunk"
+	KindInvalidStatus    Kind = "invalid_status"
+	KindOversizedComment Kind = "oversized_comment"
+	KindCappedDuration   Kind = "capped_duration"
+	KindDroppedDuration  Kind = "dropped_duration"
+	KindDeletedCase      Kind = "deleted_case"
+	KindDeletedRun       Kind = "deleted_run"
+)
+
+// Item is one thing left behind by a migration, with a suggested remediation
+type Item struct {
+	Kind        Kind   `json:"kind"`
+	SourceRuns  []int  `json:"source_runs,omitempty"`
+	TargetRunID int    `json:"target_run_id,omitempty"`
+	CaseID      int    `json:"case_id,omitempty"` // source case if unmapped, else target case
+	Status      string `json:"status,omitempty"`
+	Results     int    `json:"results,omitempty"` // results affected
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation"`
+}
+
+// Report collects the items needing attention during a migration. A nil
+// *Report discards everything.
+type Report struct {
+	mu       sync.Mutex
+	items    []Item
+	unmapped map[int]*Item
+	long     map[longKey]*longCase
+}
+
+type longKey struct {
+	kind   Kind
+	caseID int
+}
+
+type longCase struct {
+	item    Item
+	longest int // seconds
+}
+
+// New creates an empty report
+func New() *Report {
+	return &Report{unmapped: make(map[int]*Item), long: make(map[longKey]*longCase)}
+}
+
+// Add records an item
+func (r *Report) Add(item Item) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, item)
+}
+
+// AddUnmapped records source case results of a run that had no target case.
+// Cases are reported once, with their result count and runs.
+func (r *Report) AddUnmapped(sourceRunID int, caseIDs []int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, caseID := range caseIDs {
+		item, exists := r.unmapped[caseID]
+		if !exists {
+			item = &Item{
+				Kind:        KindUnmappedCase,
+				CaseID:      caseID,
+				Remediation: "add the source case to the mapping (target custom field value or CSV row) and re-run",
+			}
+			r.unmapped[caseID] = item
+		}
+		item.Results++
+		if n := len(item.SourceRuns); n == 0 || item.SourceRuns[n-1] != sourceRunID {
+			item.SourceRuns = append(item.SourceRuns, sourceRunID)
+		}
+	}
+}
+
+// AddCapped records a source case result of a run whose duration (in
+// seconds) exceeded the maximum and was capped. Cases are reported once, with
+// their result count, runs and longest duration.
+func (r *Report) AddCapped(sourceRunID, caseID, seconds int) {
+	r.addLong(KindCappedDuration, sourceRunID, caseID, seconds,
+		"check the source durations; the target results carry the capped value")
+}
+
+// AddDropped is AddCapped for durations that were dropped instead
+func (r *Report) AddDropped(sourceRunID, caseID, seconds int) {
+	r.addLong(KindDroppedDuration, sourceRunID, caseID, seconds,
+		"check the source durations; the target results were posted without time")
+}
+
+func (r *Report) addLong(kind Kind, sourceRunID, caseID, seconds int, remediation string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := longKey{kind, caseID}
+	c, exists := r.long[key]
+	if !exists {
+		c = &longCase{item: Item{Kind: kind, CaseID: caseID, Remediation: remediation}}
+		r.long[key] = c
+	}
+	c.item.Results++
+	if seconds > c.longest {
+		c.longest = seconds
+	}
+	if n := len(c.item.SourceRuns); n == 0 || c.item.SourceRuns[n-1] != sourceRunID {
+		c.item.SourceRuns = append(c.item.SourceRuns, sourceRunID)
+	}
+}
+
+// DurationSummary summarizes the capped and dropped durations recorded so
+// far, or returns "" when there are none
+func (r *Report) DurationSummary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var summaries []string
+	for _, kind := range []Kind{KindCappedDuration, KindDroppedDuration} {
+		count, longest := 0, 0
+		for key, c := range r.long {
+			if key.kind != kind {
+				continue
+			}
+			count += c.item.Results
+			if c.longest > longest {
+				longest = c.longest
+			}
+		}
+		verb := "capped"
+		if kind == KindDroppedDuration {
+			verb = "dropped"
+		}
+		if summary := DurationSummary(verb, count, longest); summary != "" {
+			summaries = append(summaries, summary)
+		}
+	}
+	return strings.Join(summaries, "; ")
+}
+
+// DurationSummary formats a count of capped or dropped durations (verb) and
+// the longest one seen, e.g. "capped 1,234 durations; max seen 4.1y", or
+// returns "" for none
+func DurationSummary(verb string, count, longestSeconds int) string {
+	if count == 0 {
+		return ""
+	}
+	noun := "durations"
+	if count == 1 {
+		noun = "duration"
+	}
+	return fmt.Sprintf("%s %s %s; max seen %s", verb, groupThousands(count), noun, formatSeconds(longestSeconds))
+}
+
+// formatSeconds renders seconds in the largest whole unit, with one decimal
+// for years, days, hours and minutes
+func formatSeconds(seconds int) string {
+	for _, unit := range []struct {
+		seconds int
+		suffix  string
+	}{{365 * 24 * 3600, "y"}, {24 * 3600, "d"}, {3600, "h"}, {60, "m"}} {
+		if seconds >= unit.seconds {
+			return strconv.FormatFloat(float64(seconds)/float64(unit.seconds), 'f', 1, 64) + unit.suffix
+		}
+	}
+	return strconv.Itoa(seconds) + "s"
+}
+
+// groupThousands renders n with comma thousands separators
+func groupThousands(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// Items returns the items ordered by kind, then case and run
+func (r *Report) Items() []Item {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	items := append([]Item(nil), r.items...)
+	for _, item := range r.unmapped {
+		unmapped := *item
+		unmapped.Detail = fmt.Sprintf("%d result(s) in %d run(s) have no mapped target case", item.Results, len(item.SourceRuns))
+		items = append(items, unmapped)
+	}
+	for _, c := range r.long {
+		long := c.item
+		long.Detail = fmt.Sprintf("%d result(s) in %d run(s) exceeded the maximum duration, longest %s",
+			long.Results, len(long.SourceRuns), formatSeconds(c.longest))
+		items = append(items, long)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		if items[i].CaseID != items[j].CaseID {
+			return items[i].CaseID < items[j].CaseID
+		}
+		return firstRun(items[i]) < firstRun(items[j])
+	})
+	return items
+}
+
+func firstRun(item Item) int {
+	if len(item.SourceRuns) == 0 {
+		return 0
+	}
+	return item.SourceRuns[0]
+}
+
+// Write stores the report at location as CSV when it ends in .csv, else as
+// JSON, without replacing an existing artifact unless force is set. Empty
+// reports are not written.
+func (r *Report) Write(location string, force bool) error {
+	items := r.Items()
+	if len(items) == 0 {
+		return nil
+	}
+
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(location), ".csv") {
+		data, err = encodeCSV(items)
+	} else {
+		data, err = json.MarshalIndent(items, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode needs-attention report: %w", err)
+	}
+
+	location, err = artifact.WriteProtected(location, data, force)
+	if err != nil {
+		return fmt.Errorf("failed to write needs-attention report: %w", err)
+	}
+	fmt.Printf("Needs-attention report (%d items) written to %s\n", len(items), location)
+	return nil
+}
+
+func encodeCSV(items []Item) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	header := []string{"kind", "source_runs", "target_run_id", "case_id", "status", "results", "detail", "remediation"}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		runs := make([]string, len(item.SourceRuns))
+		for i, run := range item.SourceRuns {
+			runs[i] = strconv.Itoa(run)
+		}
+		record := []string{
+			string(item.Kind),
+			strings.Join(runs, ";"),
+			optionalInt(item.TargetRunID),
+			optionalInt(item.CaseID),
+			item.Status,
+			optionalInt(item.Results),
+			item.Detail,
+			item.Remediation,
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package tui
+
+// makeRaw is a no-op where the terminal mode cannot be changed; keys take
+// effect after Enter
+func makeRaw(fd uintptr) (func(), error) {
+	return func() {}, nil
+}
+
+// size is unknown here; the dashboard falls back to 80x24
+func size(fd uintptr) (int, int) {
+	return 0, 0
+}
@@ -0,0 +1,499 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/control"
+)
+
+// Limits of what the dashboard keeps
+const (
+	tailLines     = 500 // log lines kept for the tail, and printed on exit
+	failuresShown = 5
+	refresh       = 500 * time.Millisecond
+)
+
+// Dashboard is a full-screen terminal view of a migration: the project pair
+// and progress, per-worker activity, rate limit status, recent failures and
+// the tail of the log. Everything written to stdout or the log package is
+// captured into the tail. Keys pause and resume the migration and skip runs
+// through the controller. A nil Dashboard is disabled; all methods are safe
+// to call on it.
+type Dashboard struct {
+	term    *os.File // the terminal, stdout before capture
+	ctl     *control.Controller
+	logFile *os.File // full captured log, nil when not kept
+	capture *os.File // write end of the pipe replacing stdout
+	drained chan struct{}
+	stop    chan struct{}
+	once    sync.Once
+	restore func()
+
+	mu         sync.Mutex
+	pair       string
+	phase      string
+	started    time.Time
+	runsTotal  int
+	runsDone   int
+	runsFailed int
+	results    int
+	workers    map[int]*worker
+	selected   int
+	failures   []failure
+	tail       []string
+	clients    []watched
+}
+
+type worker struct {
+	run     int
+	title   string
+	results int
+	since   time.Time
+	busy    bool
+	runs    int
+	posted  int
+}
+
+type failure struct {
+	run   int
+	title string
+	err   string
+}
+
+type watched struct {
+	label  string
+	client *api.Client
+}
+
+// Start takes over the terminal and returns the dashboard. Output is kept in
+// logPath as well when it is not empty. It fails when stdout is not a
+// terminal.
+func Start(ctl *control.Controller, logPath string) (*Dashboard, error) {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, fmt.Errorf("the dashboard needs a terminal on stdout")
+	}
+
+	d := &Dashboard{
+		term:    os.Stdout,
+		ctl:     ctl,
+		drained: make(chan struct{}),
+		stop:    make(chan struct{}),
+		started: time.Now(),
+		phase:   "starting",
+		workers: make(map[int]*worker),
+	}
+	if logPath != "" {
+		if d.logFile, err = os.Create(logPath); err != nil {
+			return nil, fmt.Errorf("failed to create dashboard log: %w", err)
+		}
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+	d.capture = writer
+	os.Stdout = writer
+	log.SetOutput(writer)
+
+	d.restore, err = makeRaw(os.Stdin.Fd())
+	if err != nil {
+		d.restore = func() {}
+		d.appendLine(fmt.Sprintf("Warning: keys take effect after Enter (raw terminal mode unavailable: %v)", err))
+	}
+	fmt.Fprint(d.term, "\x1b[?1049h\x1b[?25l") // alternate screen, hidden cursor
+
+	go d.readLog(reader)
+	go d.readKeys()
+	go d.loop()
+	go d.handleInterrupt()
+	return d, nil
+}
+
+// Stop gives the terminal back and prints the tail of the log, which ends
+// with the migration summary
+func (d *Dashboard) Stop() {
+	if d == nil {
+		return
+	}
+	d.once.Do(func() {
+		close(d.stop)
+		os.Stdout = d.term
+		log.SetOutput(os.Stderr)
+		d.capture.Close()
+		select {
+		case <-d.drained:
+		case <-time.After(time.Second):
+		}
+
+		fmt.Fprint(d.term, "\x1b[?25h\x1b[?1049l") // visible cursor, main screen
+		d.restore()
+
+		d.mu.Lock()
+		tail := append([]string(nil), d.tail...)
+		d.mu.Unlock()
+		for _, line := range tail {
+			fmt.Fprintln(d.term, line)
+		}
+		if d.logFile != nil {
+			d.logFile.Close()
+			fmt.Fprintf(d.term, "Full log written to %s\n", d.logFile.Name())
+		}
+	})
+}
+
+// handleInterrupt restores the terminal on Ctrl-C or SIGTERM, then delivers
+// the signal again so it has its usual effect (watch mode finishes its
+// cycle, other migrations exit)
+func (d *Dashboard) handleInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		signal.Stop(signals)
+		d.Stop()
+		if process, err := os.FindProcess(os.Getpid()); err == nil {
+			process.Signal(sig)
+		}
+	case <-d.stop:
+		signal.Stop(signals)
+	}
+}
+
+// Project starts the view of a project pair
+func (d *Dashboard) Project(source, target string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pair = source + " -> " + target
+	d.runsTotal, d.runsDone, d.runsFailed, d.results = 0, 0, 0, 0
+	d.workers = make(map[int]*worker)
+	d.clients = nil
+}
+
+// Watch shows the rate limit and circuit breaker state of a client
+func (d *Dashboard) Watch(label string, c *api.Client) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clients = append(d.clients, watched{label: label, client: c})
+}
+
+// SetPhase shows the current migration phase
+func (d *Dashboard) SetPhase(phase string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.phase = phase
+}
+
+// SetRunsTotal sets the number of runs to migrate
+func (d *Dashboard) SetRunsTotal(total int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.runsTotal = total
+}
+
+// WorkerStarted shows a post worker taking on a run
+func (d *Dashboard) WorkerStarted(number, runID int, title string, results int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w := d.workers[number]
+	if w == nil {
+		w = &worker{}
+		d.workers[number] = w
+	}
+	w.run, w.title, w.results, w.since, w.busy = runID, title, results, time.Now(), true
+}
+
+// WorkerFinished shows a post worker done with its run
+func (d *Dashboard) WorkerFinished(number, posted int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if w := d.workers[number]; w != nil {
+		w.busy = false
+		w.runs++
+		w.posted += posted
+		w.since = time.Now()
+	}
+}
+
+// RunFinished counts a finished run, keeping the latest failures
+func (d *Dashboard) RunFinished(runID int, title string, results int, err error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.runsDone++
+	d.results += results
+	if err != nil {
+		d.runsFailed++
+		d.failures = append(d.failures, failure{run: runID, title: title, err: err.Error()})
+		if len(d.failures) > failuresShown {
+			d.failures = d.failures[len(d.failures)-failuresShown:]
+		}
+	}
+}
+
+// readLog moves captured output into the tail
+func (d *Dashboard) readLog(reader *os.File) {
+	defer close(d.drained)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if d.logFile != nil {
+			fmt.Fprintln(d.logFile, line)
+		}
+		d.appendLine(line)
+	}
+}
+
+func (d *Dashboard) appendLine(line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tail = append(d.tail, line)
+	if len(d.tail) > tailLines {
+		d.tail = d.tail[len(d.tail)-tailLines:]
+	}
+}
+
+// readKeys handles p (pause/resume), s (skip the runs in progress), x (skip
+// the selected worker's run) and the arrow keys or j/k (select a worker)
+func (d *Dashboard) readKeys() {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		key, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		if key == 0x1b {
+			// Arrow keys arrive as ESC [ A (up) or ESC [ B (down)
+			if next, _ := reader.ReadByte(); next != '[' {
+				continue
+			}
+			switch arrow, _ := reader.ReadByte(); arrow {
+			case 'A':
+				key = 'k'
+			case 'B':
+				key = 'j'
+			default:
+				continue
+			}
+		}
+
+		switch key {
+		case 'p':
+			if d.ctl.State().Paused {
+				d.ctl.Resume()
+			} else {
+				d.ctl.Pause()
+			}
+		case 's':
+			d.ctl.Skip(0)
+		case 'x':
+			if run := d.selectedRun(); run != 0 {
+				d.ctl.Skip(run)
+			}
+		case 'k':
+			d.moveSelection(-1)
+		case 'j':
+			d.moveSelection(1)
+		}
+		d.render()
+	}
+}
+
+func (d *Dashboard) moveSelection(delta int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n := len(d.workers); n > 0 {
+		d.selected = (d.selected + delta + n) % n
+	}
+}
+
+// selectedRun returns the run of the selected worker, or 0 when it is idle
+func (d *Dashboard) selectedRun() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	numbers := d.workerNumbers()
+	if d.selected >= len(numbers) {
+		return 0
+	}
+	if w := d.workers[numbers[d.selected]]; w.busy {
+		return w.run
+	}
+	return 0
+}
+
+// workerNumbers returns the known workers in order; callers hold d.mu
+func (d *Dashboard) workerNumbers() []int {
+	numbers := make([]int, 0, len(d.workers))
+	for number := range d.workers {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func (d *Dashboard) loop() {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		d.render()
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// render redraws the screen
+func (d *Dashboard) render() {
+	width, height := size(d.term.Fd())
+	if width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	paused := d.ctl.State().Paused
+
+	d.mu.Lock()
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	header := fmt.Sprintf("clone-run-multi-ws  %s  phase: %s  elapsed: %v", d.pair, d.phase, time.Since(d.started).Round(time.Second))
+	if paused {
+		header += "  [PAUSED]"
+	}
+	add("%s", header)
+	percent := 0
+	if d.runsTotal > 0 {
+		percent = d.runsDone * 100 / d.runsTotal
+	}
+	add("Runs %d/%d (%d failed)  Results %d  %s %d%%", d.runsDone, d.runsTotal, d.runsFailed, d.results, bar(percent, 30), percent)
+
+	add("")
+	add("Workers")
+	numbers := d.workerNumbers()
+	if len(numbers) == 0 {
+		add("  none active yet")
+	}
+	for i, number := range numbers {
+		w := d.workers[number]
+		mark := " "
+		if i == d.selected {
+			mark = ">"
+		}
+		if w.busy {
+			add("%s worker-%d  run %d '%s', %d results, %v", mark, number, w.run, w.title, w.results, time.Since(w.since).Round(time.Second))
+		} else {
+			add("%s worker-%d  idle for %v (%d runs, %d results posted)", mark, number, time.Since(w.since).Round(time.Second), w.runs, w.posted)
+		}
+	}
+
+	add("")
+	add("Rate limits")
+	for _, c := range d.clients {
+		status := "breaker closed"
+		if open := c.client.Breaker.OpenFor(); open > 0 {
+			status = fmt.Sprintf("BREAKER OPEN for %v", open.Round(time.Second))
+		}
+		limits := c.client.RateLimits()
+		if len(limits) == 0 {
+			add("  %s: no limit reported, %s", c.label, status)
+		}
+		for _, l := range limits {
+			window := "window unknown"
+			if reset := time.Until(l.Reset); reset > 0 {
+				window = fmt.Sprintf("resets in %v", reset.Round(time.Second))
+			}
+			add("  %s %s: %d/%d remaining, %s, %s", c.label, l.Token, l.Remaining, l.Limit, window, status)
+		}
+	}
+
+	add("")
+	add("Failures (%d)", d.runsFailed)
+	for _, f := range d.failures {
+		add("  run %d '%s': %s", f.run, f.title, f.err)
+	}
+
+	keys := "p pause/resume  s skip runs in progress  up/down select worker  x skip its run"
+	room := height - len(lines) - 3
+	add("")
+	add("Log")
+	if room > 0 {
+		start := len(d.tail) - room
+		if start < 0 {
+			start = 0
+		}
+		for _, line := range d.tail[start:] {
+			add("  %s", line)
+		}
+	}
+	d.mu.Unlock()
+
+	if len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for _, line := range lines {
+		b.WriteString(truncate(line, width))
+		b.WriteString("\x1b[K\r\n")
+	}
+	b.WriteString("\x1b[J\x1b[7m")
+	b.WriteString(truncate(keys, width))
+	b.WriteString("\x1b[0m")
+	fmt.Fprint(d.term, b.String())
+}
+
+// bar draws a progress bar of width cells
+func bar(percent, width int) string {
+	filled := percent * width / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// truncate cuts a line to width characters, replacing tabs
+func truncate(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "  ")
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
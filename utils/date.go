@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -63,3 +65,102 @@ func ParseUnixTimestamp(timestampStr string) (time.Time, error) {
 func ToUnixTimestamp(t time.Time) string {
 	return strconv.FormatInt(t.Unix(), 10)
 }
+
+// AfterDateAll is the QASE_AFTER_DATE value that disables the date cutoff
+const AfterDateAll = "all"
+
+// relativeDate matches durations such as "30d", "12h" or "2w"
+var relativeDate = regexp.MustCompile(`^(\d+)\s*(m|h|d|w)$`)
+
+// ParseAfterDate parses a required QASE_AFTER_DATE value. Accepted forms are
+// "all" (no cutoff), a Unix timestamp, a date such as "2025-08-18" or
+// "2025-08-18 09:00", a relative duration such as "30d", "12h" or "2w", and
+// "today", "yesterday" or "last <weekday>". Dates without a zone and calendar
+// expressions are resolved in timezone (an IANA name; empty means UTC). An
+// empty value is an error so a cutoff is never applied implicitly.
+func ParseAfterDate(value, timezone string) (time.Time, error) {
+	return parseAfterDate(value, timezone, time.Now())
+}
+
+func parseAfterDate(value, timezone string, now time.Time) (time.Time, error) {
+	raw := strings.TrimSpace(value)
+	value = strings.ToLower(raw)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("QASE_AFTER_DATE is required (e.g. a Unix timestamp, 2025-08-18, 30d, or %q to include all history)", AfterDateAll)
+	}
+	if value == AfterDateAll {
+		return time.Unix(0, 0).UTC(), nil
+	}
+
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now = now.In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if t, err := ParseUnixTimestamp(value); err == nil {
+		return t, nil
+	}
+
+	if m := relativeDate.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "m":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "h":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+
+	switch value {
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	if day, ok := strings.CutPrefix(value, "last "); ok {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.ToLower(weekday.String()) == strings.TrimSpace(day) {
+				// The most recent such day strictly before today
+				back := (int(now.Weekday()) - int(weekday) + 7) % 7
+				if back == 0 {
+					back = 7
+				}
+				return midnight.AddDate(0, 0, -back), nil
+			}
+		}
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid QASE_AFTER_DATE %q (expected a Unix timestamp, date such as 2025-08-18, duration such as 30d, \"last monday\", or %q)", raw, AfterDateAll)
+}
+
+// LoadTimezone loads an IANA timezone by name. An empty name means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QASE_TIMEZONE %q: %w", name, err)
+	}
+	return loc, nil
+}
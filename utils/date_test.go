@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAfterDate(t *testing.T) {
+	// A Wednesday; 06:30 in New York, 12:30 in Berlin, 19:30 in Tokyo
+	now := time.Date(2025, 8, 20, 10, 30, 0, 0, time.UTC)
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		timezone string
+		want     time.Time
+	}{
+		{"all history", "all", "", time.Unix(0, 0)},
+		{"unix timestamp", "1755500000", "Europe/Berlin", time.Unix(1755500000, 0)},
+		{"minutes", "15m", "", utc(8, 20, 10, 15)},
+		{"hours", "12h", "", utc(8, 19, 22, 30)},
+		{"days", "30d", "", utc(7, 21, 10, 30)},
+		{"weeks", "2w", "", utc(8, 6, 10, 30)},
+		{"case and spaces", " 30D ", "", utc(7, 21, 10, 30)},
+		{"today", "today", "", utc(8, 20, 0, 0)},
+		{"today east of UTC", "today", "Asia/Tokyo", utc(8, 19, 15, 0)},
+		{"yesterday west of UTC", "yesterday", "America/New_York", utc(8, 19, 4, 0)},
+		{"last weekday", "last monday", "", utc(8, 18, 0, 0)},
+		{"last weekday is today", "last wednesday", "", utc(8, 13, 0, 0)},
+		{"date", "2025-08-18", "", utc(8, 18, 0, 0)},
+		{"date in timezone", "2025-08-18", "Europe/Berlin", utc(8, 17, 22, 0)},
+		{"date and time in timezone", "2025-08-18 09:00", "Europe/Berlin", utc(8, 18, 7, 0)},
+		{"explicit offset wins", "2025-08-18T09:00:00+02:00", "America/New_York", utc(8, 18, 7, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAfterDate(tt.value, tt.timezone, now)
+			if err != nil {
+				t.Fatalf("parseAfterDate(%q, %q): %v", tt.value, tt.timezone, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseAfterDate(%q, %q) = %v, want %v", tt.value, tt.timezone, got.UTC(), tt.want.UTC())
+			}
+		})
+	}
+}
+
+func TestParseAfterDateInvalid(t *testing.T) {
+	now := time.Date(2025, 8, 20, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		timezone string
+	}{
+		{"empty", "", ""},
+		{"blank", "  ", ""},
+		{"unknown word", "soon", ""},
+		{"unknown unit", "30x", ""},
+		{"unknown weekday", "last funday", ""},
+		{"invalid date", "2025-13-01", ""},
+		{"unknown timezone", "30d", "Mars/Olympus_Mons"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseAfterDate(tt.value, tt.timezone, now); err == nil {
+				t.Errorf("parseAfterDate(%q, %q) = %v, want an error", tt.value, tt.timezone, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package warehouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+)
+
+// bigQueryRowsPerInsert stays well below the streaming insert request limits
+const bigQueryRowsPerInsert = 500
+
+// bigQuery streams rows into BigQuery tables with the REST API, using the
+// same Google access token as GCS artifacts. Streaming inserts deduplicate on
+// the insert ID on a best-effort basis, so rows carry the source hash (or run
+// ID) as their insert ID.
+type bigQuery struct {
+	project string
+	dataset string
+	runs    string
+	results string
+	client  *http.Client
+
+	once    sync.Once
+	initErr error
+}
+
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+var (
+	bigQueryRunSchema = []bigQueryField{
+		{Name: "target_project", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "run_id", Type: "INTEGER", Mode: "REQUIRED"},
+		{Name: "source_run", Type: "STRING"},
+		{Name: "title", Type: "STRING"},
+		{Name: "description", Type: "STRING"},
+		{Name: "created_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	}
+	bigQueryResultSchema = []bigQueryField{
+		{Name: "target_project", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "run_id", Type: "INTEGER", Mode: "REQUIRED"},
+		{Name: "case_id", Type: "INTEGER", Mode: "REQUIRED"},
+		{Name: "status", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "time_seconds", Type: "INTEGER"},
+		{Name: "comment", Type: "STRING"},
+		{Name: "source_hash", Type: "STRING"},
+		{Name: "loaded_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	}
+)
+
+func newBigQuery(location, prefix string) (*bigQuery, error) {
+	parts := strings.Split(strings.TrimPrefix(location, "bigquery://"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid BigQuery location %q (use bigquery://project/dataset)", location)
+	}
+	return &bigQuery{
+		project: parts[0],
+		dataset: parts[1],
+		runs:    prefix + "runs",
+		results: prefix + "results",
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (b *bigQuery) describe() string {
+	return fmt.Sprintf("bigquery %s.%s (%s, %s)", b.project, b.dataset, b.runs, b.results)
+}
+
+// ensureTables creates the tables once per process, keeping existing ones
+func (b *bigQuery) ensureTables() error {
+	b.once.Do(func() {
+		for _, table := range []struct {
+			name   string
+			schema []bigQueryField
+		}{{b.runs, bigQueryRunSchema}, {b.results, bigQueryResultSchema}} {
+			body := map[string]interface{}{
+				"tableReference": map[string]string{"projectId": b.project, "datasetId": b.dataset, "tableId": table.name},
+				"schema":         map[string]interface{}{"fields": table.schema},
+			}
+			status, respBody, err := b.do("POST", fmt.Sprintf("/projects/%s/datasets/%s/tables",
+				url.PathEscape(b.project), url.PathEscape(b.dataset)), body)
+			if err != nil {
+				b.initErr = err
+				return
+			}
+			if status != http.StatusOK && status != http.StatusConflict {
+				b.initErr = fmt.Errorf("failed to create table %s: status %d: %s", table.name, status, string(respBody))
+				return
+			}
+		}
+	})
+	return b.initErr
+}
+
+func (b *bigQuery) loadRuns(rows []RunRow) error {
+	insert := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		insert[i] = map[string]interface{}{
+			"insertId": row.TargetProject + "/" + strconv.Itoa(row.RunID),
+			"json":     row,
+		}
+	}
+	return b.insert(b.runs, insert)
+}
+
+func (b *bigQuery) loadResults(rows []ResultRow) error {
+	insert := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		insert[i] = map[string]interface{}{"json": row}
+		if row.SourceHash != "" {
+			insert[i]["insertId"] = row.TargetProject + "/" + row.SourceHash
+		}
+	}
+	return b.insert(b.results, insert)
+}
+
+// insert streams rows into a table in batches, failing on any row error
+func (b *bigQuery) insert(table string, rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := b.ensureTables(); err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/projects/%s/datasets/%s/tables/%s/insertAll",
+		url.PathEscape(b.project), url.PathEscape(b.dataset), url.PathEscape(table))
+	for _, r := range chunks(len(rows), bigQueryRowsPerInsert) {
+		status, respBody, err := b.do("POST", path, map[string]interface{}{"rows": rows[r[0]:r[1]]})
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("failed to insert into %s: status %d: %s", table, status, string(respBody))
+		}
+		var response struct {
+			InsertErrors []struct {
+				Index  int `json:"index"`
+				Errors []struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"insertErrors"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return fmt.Errorf("failed to parse insert response: %w", err)
+		}
+		if n := len(response.InsertErrors); n > 0 {
+			first := response.InsertErrors[0]
+			message := ""
+			if len(first.Errors) > 0 {
+				message = first.Errors[0].Reason + ": " + first.Errors[0].Message
+			}
+			return fmt.Errorf("%d rows rejected by %s, first at row %d: %s", n, table, r[0]+first.Index, message)
+		}
+	}
+	return nil
+}
+
+// do sends an authorized JSON request to the BigQuery API
+func (b *bigQuery) do(method, path string, body interface{}) (int, []byte, error) {
+	token, err := artifact.GoogleToken()
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest(method, bigQueryEndpoint()+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// bigQueryEndpoint returns the BigQuery API base URL, honoring
+// BIGQUERY_EMULATOR_HOST
+func bigQueryEndpoint() string {
+	if host := os.Getenv("BIGQUERY_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimRight(host, "/") + "/bigquery/v2"
+	}
+	return "https://bigquery.googleapis.com/bigquery/v2"
+}
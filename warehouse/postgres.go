@@ -0,0 +1,150 @@
+package warehouse
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// postgresRowsPerInsert bounds the size of a single INSERT statement
+const postgresRowsPerInsert = 1000
+
+// postgres loads rows by piping SQL to psql, so no database driver is
+// needed. The command defaults to psql and can be replaced with
+// QASE_WAREHOUSE_PSQL (e.g. "docker exec -i db psql").
+type postgres struct {
+	dsn     string
+	command string
+	runs    string
+	results string
+
+	once    sync.Once
+	initErr error
+}
+
+func newPostgres(dsn, prefix string) *postgres {
+	command := os.Getenv("QASE_WAREHOUSE_PSQL")
+	if command == "" {
+		command = "psql"
+	}
+	return &postgres{dsn: dsn, command: command, runs: prefix + "runs", results: prefix + "results"}
+}
+
+func (p *postgres) describe() string {
+	u, err := url.Parse(p.dsn)
+	if err != nil {
+		return "postgres"
+	}
+	return fmt.Sprintf("postgres %s%s (%s, %s)", u.Host, u.Path, p.runs, p.results)
+}
+
+// ensureTables creates the tables once per process
+func (p *postgres) ensureTables() error {
+	p.once.Do(func() {
+		p.initErr = p.exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  target_project text NOT NULL,
+  run_id bigint NOT NULL,
+  source_run text,
+  title text,
+  description text,
+  created_at timestamptz NOT NULL,
+  PRIMARY KEY (target_project, run_id)
+);
+CREATE TABLE IF NOT EXISTS %s (
+  target_project text NOT NULL,
+  run_id bigint NOT NULL,
+  case_id bigint NOT NULL,
+  status text NOT NULL,
+  time_seconds integer,
+  comment text,
+  source_hash text,
+  loaded_at timestamptz NOT NULL,
+  UNIQUE (target_project, source_hash)
+);
+`, p.runs, p.results))
+	})
+	return p.initErr
+}
+
+func (p *postgres) loadRuns(rows []RunRow) error {
+	if err := p.ensureTables(); err != nil {
+		return err
+	}
+	var sql strings.Builder
+	sql.WriteString("BEGIN;\n")
+	for _, r := range chunks(len(rows), postgresRowsPerInsert) {
+		fmt.Fprintf(&sql, "INSERT INTO %s (target_project, run_id, source_run, title, description, created_at) VALUES\n", p.runs)
+		for i, row := range rows[r[0]:r[1]] {
+			if i > 0 {
+				sql.WriteString(",\n")
+			}
+			fmt.Fprintf(&sql, "(%s, %d, %s, %s, %s, %s)", sqlString(row.TargetProject), row.RunID,
+				sqlString(row.SourceRun), sqlString(row.Title), sqlString(row.Description), sqlTime(row.CreatedAt))
+		}
+		sql.WriteString("\nON CONFLICT (target_project, run_id) DO UPDATE SET source_run = EXCLUDED.source_run, title = EXCLUDED.title, description = EXCLUDED.description;\n")
+	}
+	sql.WriteString("COMMIT;\n")
+	return p.exec(sql.String())
+}
+
+func (p *postgres) loadResults(rows []ResultRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := p.ensureTables(); err != nil {
+		return err
+	}
+	var sql strings.Builder
+	sql.WriteString("BEGIN;\n")
+	for _, r := range chunks(len(rows), postgresRowsPerInsert) {
+		fmt.Fprintf(&sql, "INSERT INTO %s (target_project, run_id, case_id, status, time_seconds, comment, source_hash, loaded_at) VALUES\n", p.results)
+		for i, row := range rows[r[0]:r[1]] {
+			if i > 0 {
+				sql.WriteString(",\n")
+			}
+			timeSeconds := "NULL"
+			if row.TimeSeconds != nil {
+				timeSeconds = strconv.Itoa(*row.TimeSeconds)
+			}
+			sourceHash := "NULL"
+			if row.SourceHash != "" {
+				sourceHash = sqlString(row.SourceHash)
+			}
+			fmt.Fprintf(&sql, "(%s, %d, %d, %s, %s, %s, %s, %s)", sqlString(row.TargetProject), row.RunID, row.CaseID,
+				sqlString(row.Status), timeSeconds, sqlString(row.Comment), sourceHash, sqlTime(row.LoadedAt))
+		}
+		sql.WriteString("\nON CONFLICT (target_project, source_hash) DO NOTHING;\n")
+	}
+	sql.WriteString("COMMIT;\n")
+	return p.exec(sql.String())
+}
+
+// exec runs SQL through psql, stopping at the first error
+func (p *postgres) exec(sql string) error {
+	cmd := exec.Command("sh", "-c", p.command+` --no-psqlrc --quiet -v ON_ERROR_STOP=1 -d "$WAREHOUSE_URL"`)
+	cmd.Env = append(os.Environ(), "WAREHOUSE_URL="+p.dsn)
+	cmd.Stdin = strings.NewReader(sql)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// sqlString quotes a string literal. NUL bytes cannot be stored in text
+// columns and are dropped.
+func sqlString(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func sqlTime(t time.Time) string {
+	return "'" + t.UTC().Format(time.RFC3339Nano) + "'"
+}
@@ -0,0 +1,180 @@
+package warehouse
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+	"github.com/adrianeortiz/clone-run-multi-ws/tracing"
+)
+
+// Modes for QASE_WAREHOUSE_MODE
+const (
+	ModeAlso = "also" // export next to the target Qase project
+	ModeOnly = "only" // export instead of writing to the target project
+)
+
+// RunRow is a migrated run as stored in the runs table
+type RunRow struct {
+	TargetProject string    `json:"target_project"`
+	RunID         int       `json:"run_id"`
+	SourceRun     string    `json:"source_run"` // source run marker, e.g. SRC:123
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ResultRow is a migrated result as stored in the results table
+type ResultRow struct {
+	TargetProject string    `json:"target_project"`
+	RunID         int       `json:"run_id"`
+	CaseID        int       `json:"case_id"`
+	Status        string    `json:"status"`
+	TimeSeconds   *int      `json:"time_seconds"`
+	Comment       string    `json:"comment"`
+	SourceHash    string    `json:"source_hash"`
+	LoadedAt      time.Time `json:"loaded_at"`
+}
+
+// loader writes rows to a warehouse, creating its tables on first use.
+// Rows already loaded (same run, or same result source hash) are not
+// duplicated.
+type loader interface {
+	loadRuns(rows []RunRow) error
+	loadResults(rows []ResultRow) error
+	describe() string
+}
+
+var tablePrefixPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Sink exports migrated runs and results to warehouse tables. It implements
+// target.Target, so it can replace the target Qase project or mirror it.
+// Nothing is sent until the first run is created.
+type Sink struct {
+	project string
+	loader  loader
+
+	mu   sync.Mutex
+	runs map[string]*qase.Run // by source marker
+}
+
+// Open creates a sink for location: a postgres:// (or postgresql://)
+// connection URL, or bigquery://<project>/<dataset>. Tables are named
+// <prefix>runs and <prefix>results.
+func Open(location, targetProject, tablePrefix string) (*Sink, error) {
+	if !tablePrefixPattern.MatchString(tablePrefix) {
+		return nil, fmt.Errorf("invalid warehouse table prefix %q (lowercase letters, digits and underscores)", tablePrefix)
+	}
+
+	var l loader
+	switch {
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		l = newPostgres(location, tablePrefix)
+	case strings.HasPrefix(location, "bigquery://"):
+		var err error
+		l, err = newBigQuery(location, tablePrefix)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported warehouse %q (use postgres://... or bigquery://project/dataset)", location)
+	}
+
+	fmt.Printf("Exporting runs and results to %s\n", l.describe())
+	return &Sink{project: targetProject, loader: l, runs: make(map[string]*qase.Run)}, nil
+}
+
+// FindRun returns a run created by this sink for marker, or nil
+func (s *Sink) FindRun(marker, title string) (*qase.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[runKey(marker, title)], nil
+}
+
+// CreateRun stores a run row. Its ID is derived from the source marker, so a
+// rerun updates the same row instead of adding one.
+func (s *Sink) CreateRun(marker, title, description string, opts qase.RunOptions) (*qase.Run, error) {
+	key := runKey(marker, title)
+	run := &qase.Run{ID: runID(s.project, key), Title: title, Description: &description}
+
+	row := RunRow{
+		TargetProject: s.project,
+		RunID:         run.ID,
+		SourceRun:     marker,
+		Title:         title,
+		Description:   description,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := s.loader.loadRuns([]RunRow{row}); err != nil {
+		return nil, fmt.Errorf("failed to export run: %w", err)
+	}
+
+	s.mu.Lock()
+	s.runs[key] = run
+	s.mu.Unlock()
+	return run, nil
+}
+
+// PostResults stores result rows for a run
+func (s *Sink) PostResults(runID int, items []qase.BulkItem, span *tracing.Span) error {
+	loadedAt := time.Now().UTC()
+	rows := make([]ResultRow, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, ResultRow{
+			TargetProject: s.project,
+			RunID:         runID,
+			CaseID:        item.CaseID,
+			Status:        item.Status,
+			TimeSeconds:   item.Time,
+			Comment:       qase.StripSourceMarker(item.Comment),
+			SourceHash:    qase.SourceHash(item.Comment),
+			LoadedAt:      loadedAt,
+		})
+	}
+
+	exportSpan := tracing.Start("export.results", span)
+	exportSpan.SetAttr("results.count", len(rows))
+	err := s.loader.loadResults(rows)
+	exportSpan.SetError(err)
+	exportSpan.End()
+	if err != nil {
+		return fmt.Errorf("failed to export results of run %d: %w", runID, err)
+	}
+	fmt.Printf("Exported %d results of run %d to %s\n", len(rows), runID, s.loader.describe())
+	return nil
+}
+
+func runKey(marker, title string) string {
+	if marker != "" {
+		return marker
+	}
+	return "title:" + title
+}
+
+// runID derives a stable positive run ID from the target project and run key
+func runID(project, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(project + "\x00" + key))
+	id := int(h.Sum32() & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// chunks splits n rows into [start, end) ranges of at most size
+func chunks(n, size int) [][2]int {
+	var ranges [][2]int
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/control"
+	"github.com/adrianeortiz/clone-run-multi-ws/health"
+	"github.com/adrianeortiz/clone-run-multi-ws/heartbeat"
+)
+
+// watch runs migration cycles every WatchInterval until SIGTERM/SIGINT. After
+// a successful cycle the next one only fetches results that ended since the
+// previous cycle started (minus WatchOverlap). A termination signal lets the
+// current cycle finish before exiting.
+func watch(config *Config, configs []*Config, status *heartbeat.Writer, ctl *control.Controller) {
+	hs := health.Start(config.HealthAddr, config.HealthStallTimeout)
+	defer hs.Stop()
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		fmt.Printf("\nReceived %v: finishing the current cycle before exiting\n", sig)
+		hs.SetStopping()
+		close(stop)
+
+		// A second signal exits immediately
+		<-signals
+		log.Fatalf("Received second termination signal, exiting immediately")
+	}()
+
+	fmt.Printf("Watch mode: replicating every %v\n", config.WatchInterval)
+	for cycle := 1; ; cycle++ {
+		cycleStart := time.Now()
+		fmt.Printf("\n##### Watch cycle %d (results after %s) #####\n", cycle, configs[0].AfterDate.Format("2006-01-02 15:04:05"))
+
+		hs.CycleStarted()
+		err := runCycle(config, configs, status, ctl)
+		hs.CycleFinished(err)
+
+		if err != nil {
+			// Keep the window so the next cycle retries what failed
+			fmt.Printf("Watch cycle %d failed: %v\n", cycle, err)
+		} else {
+			next := cycleStart.Add(-config.WatchOverlap)
+			for _, c := range configs {
+				c.AfterDate = next
+			}
+		}
+
+		status.SetPhase("idle")
+		select {
+		case <-stop:
+			fmt.Println("Watch mode stopped")
+			return
+		case <-time.After(config.WatchInterval):
+		}
+	}
+}
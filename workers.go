@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+)
+
+// workerStats accumulates the posting throughput of each post worker
+type workerStats struct {
+	mu      sync.Mutex
+	workers map[int]*workerThroughput
+}
+
+type workerThroughput struct {
+	runs    int
+	results int
+	busy    time.Duration
+}
+
+func newWorkerStats() *workerStats {
+	return &workerStats{workers: make(map[int]*workerThroughput)}
+}
+
+// workerName is the name a post worker's requests are counted under
+func workerName(worker int) string {
+	return fmt.Sprintf("worker-%d", worker)
+}
+
+// record adds a run a worker spent busy posting results for
+func (s *workerStats) record(worker, results int, busy time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, exists := s.workers[worker]
+	if !exists {
+		w = &workerThroughput{}
+		s.workers[worker] = w
+	}
+	w.runs++
+	w.results += results
+	w.busy += busy
+}
+
+// print writes per-worker throughput, with the requests and rate-share waits
+// recorded by the client's fair share
+func (s *workerStats) print(fair *api.FairShare) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.workers) == 0 {
+		return
+	}
+
+	requests := make(map[string]api.WorkerStats)
+	for _, stats := range fair.Stats() {
+		requests[stats.Worker] = stats
+	}
+
+	ids := make([]int, 0, len(s.workers))
+	for id := range s.workers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fmt.Printf("Per-worker throughput:\n")
+	for _, id := range ids {
+		w := s.workers[id]
+		rate := 0.0
+		if w.busy > 0 {
+			rate = float64(w.results) / w.busy.Seconds()
+		}
+		line := fmt.Sprintf("  %s: %d runs, %d results, %.1f results/s (busy %v)",
+			workerName(id), w.runs, w.results, rate, w.busy.Round(time.Millisecond))
+		if stats, exists := requests[workerName(id)]; exists {
+			line += fmt.Sprintf(", %d requests, waited %v for rate share", stats.Requests, stats.Waited.Round(time.Millisecond))
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adrianeortiz/clone-run-multi-ws/api"
+	"github.com/adrianeortiz/clone-run-multi-ws/artifact"
+	"github.com/adrianeortiz/clone-run-multi-ws/control"
+	"github.com/adrianeortiz/clone-run-multi-ws/heartbeat"
+	"github.com/adrianeortiz/clone-run-multi-ws/mapping"
+	"github.com/adrianeortiz/clone-run-multi-ws/notify"
+	"github.com/adrianeortiz/clone-run-multi-ws/qase"
+)
+
+// workspaceCommand migrates every project of the source workspace to the
+// same-named project of the target workspace
+const workspaceCommand = "migrate-workspace"
+
+// How a source project of a workspace migration found its target project
+const (
+	matchMap   = "project map" // QASE_PROJECT_MAP
+	matchCode  = "code"
+	matchTitle = "title"
+)
+
+// loadWorkspaceConfigs lists the projects visible to the source and target
+// tokens and builds one Config per source project. Projects are paired by
+// QASE_PROJECT_MAP, then by code, then by title. A source project without a
+// target counterpart gets a Config without a match, so the roll-up report
+// lists it.
+func loadWorkspaceConfigs(base *Config) ([]*Config, error) {
+	if base.MatchMode == mapping.ModeCSV {
+		return nil, fmt.Errorf("csv mode maps a single project pair; use QASE_BATCH_FILE with a mapping_csv per pair")
+	}
+
+	srcClient, err := workspaceClient(base, base.SourceBaseURL, base.SourceToken, base.SourceTokenCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain source token: %w", err)
+	}
+	tgtClient, err := workspaceClient(base, base.TargetBaseURL, base.TargetToken, base.TargetTokenCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain target token: %w", err)
+	}
+	srcProjects, err := qase.GetProjects(srcClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source projects: %w", err)
+	}
+	tgtProjects, err := qase.GetProjects(tgtClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target projects: %w", err)
+	}
+	if len(srcProjects) == 0 {
+		return nil, fmt.Errorf("the source token sees no projects")
+	}
+
+	byCode := make(map[string]string, len(tgtProjects))
+	byTitle := make(map[string]string, len(tgtProjects))
+	for _, project := range tgtProjects {
+		byCode[strings.ToUpper(project.Code)] = project.Code
+		title := strings.ToLower(strings.TrimSpace(project.Title))
+		if _, taken := byTitle[title]; taken {
+			byTitle[title] = "" // ambiguous
+		} else {
+			byTitle[title] = project.Code
+		}
+	}
+
+	configs := make([]*Config, 0, len(srcProjects))
+	unmatched := 0
+	mapped := make(map[string]bool)
+	for _, project := range srcProjects {
+		var target, match string
+		if renamed, ok := base.ProjectMap[strings.ToUpper(project.Code)]; ok {
+			mapped[strings.ToUpper(project.Code)] = true
+			// A renamed project is never paired by name instead
+			target = renamed
+			if code, exists := byCode[strings.ToUpper(renamed)]; exists {
+				target, match = code, matchMap
+			} else {
+				fmt.Printf("Warning: QASE_PROJECT_MAP maps %s to %s, which the target token cannot see\n", project.Code, renamed)
+			}
+		} else if code, ok := byCode[strings.ToUpper(project.Code)]; ok {
+			target, match = code, matchCode
+		} else if code := byTitle[strings.ToLower(strings.TrimSpace(project.Title))]; code != "" {
+			target, match = code, matchTitle
+		}
+
+		if match == "" {
+			config := *base
+			config.InBatch = true
+			config.SourceProject = project.Code
+			config.TargetProject = target
+			configs = append(configs, &config)
+			unmatched++
+			continue
+		}
+		config, err := pairConfig(base, BatchPair{SourceProject: project.Code, TargetProject: target})
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", project.Code, err)
+		}
+		config.WorkspaceMatch = match
+		configs = append(configs, config)
+	}
+	for source := range base.ProjectMap {
+		if !mapped[source] {
+			fmt.Printf("Warning: QASE_PROJECT_MAP maps %s, which the source token cannot see\n", source)
+		}
+	}
+
+	fmt.Printf("Workspace: %d source projects, %d with a target project\n", len(srcProjects), len(srcProjects)-unmatched)
+	return configs, nil
+}
+
+// parseProjectMap parses QASE_PROJECT_MAP, a comma-separated list of
+// SOURCE:TARGET project code pairs (e.g. "PROJ:PLATFORM,WEB:SITE"), into a
+// map keyed by upper-case source code
+func parseProjectMap(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	projectMap := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		source, target, ok := strings.Cut(pair, ":")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid QASE_PROJECT_MAP pair: %s (use SOURCE:TARGET)", pair)
+		}
+		if _, exists := projectMap[strings.ToUpper(source)]; exists {
+			return nil, fmt.Errorf("invalid QASE_PROJECT_MAP: %s is mapped twice", source)
+		}
+		projectMap[strings.ToUpper(source)] = target
+	}
+	return projectMap, nil
+}
+
+// migrateWorkspace migrates each project of the workspace in turn and
+// prints and writes the roll-up report
+func migrateWorkspace(config *Config, configs []*Config, status *heartbeat.Writer, ctl *control.Controller) error {
+	report := newWorkspaceReport()
+	failed := 0
+	for i, projectConfig := range configs {
+		if projectConfig.WorkspaceMatch == "" {
+			fmt.Printf("\n##### Project %d/%d: %s has no target project, skipping #####\n", i+1, len(configs), projectConfig.SourceProject)
+			report.add(projectConfig, nil, len(configs))
+			continue
+		}
+		fmt.Printf("\n##### Project %d/%d: %s -> %s #####\n", i+1, len(configs), projectConfig.SourceProject, projectConfig.TargetProject)
+		projectConfig.Outcome = &notify.Summary{}
+		err := migrateProject(projectConfig, status, ctl)
+		if err != nil {
+			log.Printf("Migration of %s -> %s failed: %v", projectConfig.SourceProject, projectConfig.TargetProject, err)
+			failed++
+		}
+		report.add(projectConfig, err, len(configs))
+	}
+
+	report.print()
+	location, err := report.write(config.ArtifactDir, config.Force)
+	if err != nil {
+		log.Printf("Warning: failed to write workspace report: %v", err)
+	} else {
+		fmt.Printf("Workspace report written to %s\n", location)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(configs))
+	}
+	return nil
+}
+
+// sameProject reports whether source and target are one project, whose
+// cases map to themselves. Same-named projects of a workspace migration live
+// in different workspaces.
+func (c *Config) sameProject() bool {
+	return c.SourceProject == c.TargetProject && c.SourceBaseURL == c.TargetBaseURL && !c.Workspace
+}
+
+// workspaceClient returns a client for listing a workspace's projects
+func workspaceClient(config *Config, baseURL, token, command string) (*api.Client, error) {
+	client := api.NewClient(baseURL, token)
+	client.SetReadRetry(config.ReadRetries, config.ReadRetryWait, config.ReadRetryMaxWait, config.ReadRetryBudget)
+	if command != "" {
+		if err := client.SetTokenProvider(api.CommandTokenProvider(command), 0); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Outcomes of a project in the workspace roll-up
+const (
+	projectMigrated = "migrated"
+	projectFailed   = "failed"
+	projectNoTarget = "no target project"
+)
+
+// projectOutcome is one project's row of the workspace roll-up
+type projectOutcome struct {
+	source  string
+	target  string
+	match   string
+	status  string
+	summary notify.Summary
+	err     error
+}
+
+// workspaceReport collects the outcome of each project of a workspace
+// migration
+type workspaceReport struct {
+	outcomes []projectOutcome
+	started  time.Time
+}
+
+func newWorkspaceReport() *workspaceReport {
+	return &workspaceReport{started: time.Now()}
+}
+
+// add records a project's outcome and prints the workspace progress
+func (r *workspaceReport) add(config *Config, err error, total int) {
+	outcome := projectOutcome{source: config.SourceProject, target: config.TargetProject, match: config.WorkspaceMatch, status: projectMigrated, err: err}
+	switch {
+	case config.WorkspaceMatch == "":
+		outcome.status = projectNoTarget
+	case err != nil:
+		outcome.status = projectFailed
+	}
+	if config.Outcome != nil {
+		outcome.summary = *config.Outcome
+	}
+	r.outcomes = append(r.outcomes, outcome)
+
+	pair := outcome.source
+	if outcome.target != "" {
+		pair += " -> " + outcome.target
+	}
+	fmt.Printf("Workspace progress: %d/%d projects (%s: %s, %d results)\n", len(r.outcomes), total,
+		pair, outcome.status, outcome.summary.TotalResults)
+}
+
+// print prints the roll-up table and totals
+func (r *workspaceReport) print() {
+	fmt.Printf("\n=== Workspace Summary ===\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tTARGET\tMATCH\tSTATUS\tRUNS\tFAILED RUNS\tRESULTS\tSKIPPED\tDURATION")
+	var runs, failedRuns, results, skipped int
+	counts := make(map[string]int)
+	for _, o := range r.outcomes {
+		counts[o.status]++
+		runs += o.summary.TotalRuns
+		failedRuns += o.summary.FailedRuns
+		results += o.summary.TotalResults
+		skipped += o.summary.TotalSkipped
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", o.source, o.target, o.match, o.status,
+			o.summary.TotalRuns, o.summary.FailedRuns, o.summary.TotalResults, o.summary.TotalSkipped, o.summary.Duration.Round(time.Second))
+	}
+	w.Flush()
+	fmt.Printf("Projects: %d migrated, %d failed, %d without a target project\n",
+		counts[projectMigrated], counts[projectFailed], counts[projectNoTarget])
+	fmt.Printf("Runs: %d (%d failed)\n", runs, failedRuns)
+	fmt.Printf("Results migrated: %d, skipped: %d\n", results, skipped)
+	fmt.Printf("Total execution time: %v\n", time.Since(r.started).Round(time.Second))
+}
+
+// write writes the roll-up as workspace_report.out.csv in dir and returns
+// its location
+func (r *workspaceReport) write(dir string, force bool) (string, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"source_project", "target_project", "matched_by", "status", "runs", "failed_runs", "results", "skipped_results", "duration_seconds", "error"})
+	for _, o := range r.outcomes {
+		errText := ""
+		if o.err != nil {
+			errText = o.err.Error()
+		}
+		writer.Write([]string{
+			o.source,
+			o.target,
+			o.match,
+			o.status,
+			strconv.Itoa(o.summary.TotalRuns),
+			strconv.Itoa(o.summary.FailedRuns),
+			strconv.Itoa(o.summary.TotalResults),
+			strconv.Itoa(o.summary.TotalSkipped),
+			strconv.Itoa(int(o.summary.Duration.Seconds())),
+			errText,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return artifact.WriteProtected(artifact.Join(dir, "workspace_report.out.csv"), buf.Bytes(), force)
+}